@@ -0,0 +1,87 @@
+package pandoc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Options of converting files with ConvertAll.
+type ConvertOptions struct {
+	Workers   int                            // Number of concurrent conversions, defaults to runtime.NumCPU()
+	Output    func(file string) string       // Returns the output file, defaults to the file with the format as extension
+	Transform func(*Pandoc) (*Pandoc, error) // Transforms each document before it is stored
+}
+
+// A result of converting a file.
+type ConvertResult struct {
+	File   string // Input file
+	Output string // Output file
+	Err    error  // Conversion error
+}
+
+// Converts files in the format described by in to the format described
+// by out, with up to opts.Workers files converted concurrently. Returns
+// results in the order of files and the errors of failed conversions
+// joined. Files not converted before ctx is done fail with ctx.Err().
+//
+// Example:
+//
+//	res, err := pandoc.ConvertAll(ctx, files, pandoc.Format("markdown"), pandoc.Format("html"), pandoc.ConvertOptions{})
+func ConvertAll(ctx context.Context, files []string, in, out Conf, opts ConvertOptions) ([]ConvertResult, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	output := opts.Output
+	if output == nil {
+		output = func(file string) string {
+			return strings.TrimSuffix(file, filepath.Ext(file)) + "." + out.Format
+		}
+	}
+	res := make([]ConvertResult, len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(files); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				res[i].Err = convertFile(ctx, res[i].File, res[i].Output, in, out, opts.Transform)
+			}
+		}()
+	}
+	for i, f := range files {
+		res[i].File, res[i].Output = f, output(f)
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	var errs []error
+	for _, r := range res {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.File, r.Err))
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+func convertFile(ctx context.Context, file, output string, in, out Conf, transform func(*Pandoc) (*Pandoc, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	doc, err := LoadFileContext(ctx, file, in)
+	if err != nil {
+		return err
+	}
+	if transform != nil {
+		if doc, err = transform(doc); err != nil {
+			return err
+		}
+	}
+	return doc.StoreFileContext(ctx, output, out)
+}
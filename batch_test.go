@@ -0,0 +1,58 @@
+package pandoc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConvertAll(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		conf  = Format("json").WithPandoc(fakePandoc(t))
+		files []string
+	)
+	for _, s := range []string{"a", "FAIL", "b", "c"} {
+		f := filepath.Join(dir, s+".src")
+		if err := os.WriteFile(f, []byte(Sprint(&Pandoc{Blocks: []Block{&Para{[]Inline{&Str{s}}}}})), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	upper := func(doc *Pandoc) (*Pandoc, error) {
+		return Filter(doc, func(s *Str) ([]Inline, error) {
+			return []Inline{&Str{strings.ToUpper(s.Text)}}, ReplaceSkip
+		})
+	}
+	res, err := ConvertAll(context.Background(), files, conf, conf, ConvertOptions{Workers: 2, Transform: upper})
+	if err == nil || !strings.Contains(err.Error(), "FAIL.src") || len(res) != len(files) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var perr *PandocError
+	if !errors.As(res[1].Err, &perr) {
+		t.Errorf("unexpected error %v", res[1].Err)
+	}
+	for i, want := range []string{0: "A", 2: "B", 3: "C"} {
+		if i == 1 {
+			continue
+		}
+		if res[i].Err != nil || res[i].Output != strings.TrimSuffix(files[i], ".src")+".json" {
+			t.Fatalf("%s: %+v", files[i], res[i])
+		}
+		if doc, err := LoadFile(res[i].Output, conf); err != nil {
+			t.Error(err)
+		} else if s := Stringify(doc); s != want {
+			t.Errorf("%s: %q, want %q", res[i].Output, s, want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err = ConvertAll(ctx, files, conf, conf, ConvertOptions{})
+	if !errors.Is(err, context.Canceled) || !errors.Is(res[3].Err, context.Canceled) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package pandoc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Works as CheckContext with the background context.
+func (c Conf) Check() error {
+	return c.CheckContext(context.Background())
+}
+
+// Runs pandoc to check that conversions with c can work, e.g. at startup
+// rather than at the first conversion: pandoc runs and, with CheckVersion,
+// is compatible; pandoc reads or writes the format and knows the
+// extensions; the options are valid for the pandoc version, see
+// ValidateFor, e.g. --citeproc of older versions, and the highlight style
+// is known; Lua, filters and the PDF engine used are available. The
+// errors are joined. Files are not checked if pandoc is run by a Runner
+// other than ExecRunner.
+func (c Conf) CheckContext(ctx context.Context) error {
+	caps, err := c.CapabilitiesContext(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if c.CheckVersion {
+		errs = append(errs, caps.compatible())
+	}
+	errs = append(errs, c.checkFormat(ctx)...)
+	errs = append(errs,
+		c.ValidateFor(Reading|Writing, caps.Version),
+		c.ValidateHighlightStyleContext(ctx))
+	errs = append(errs, c.checkTools(caps)...)
+	return errors.Join(errs...)
+}
+
+// checks the format and extensions of c
+func (c *Conf) checkFormat(ctx context.Context) []error {
+	if c.Format == "" || c.Format == "pdf" || strings.HasSuffix(c.Format, ".lua") {
+		// PDF is checked with its engine, custom formats are Lua files
+		return nil
+	}
+	in, out, err := c.FormatsContext(ctx)
+	if err != nil {
+		return []error{err}
+	} else if !slices.Contains(in, c.Format) && !slices.Contains(out, c.Format) {
+		return []error{fmt.Errorf("pandoc neither reads nor writes format %s", c.Format)}
+	}
+	return []error{c.ValidateExtContext(ctx)}
+}
+
+// checks Lua, filters and the PDF engine
+func (c *Conf) checkTools(caps *Capabilities) []error {
+	var errs []error
+	lua := c.optValues("lua-filter", 'L')
+	if len(lua) > 0 && !caps.Features["lua"] {
+		errs = append(errs, fmt.Errorf("pandoc %s has no Lua support for filters", caps.Version))
+	}
+	if _, ok := c.runner().(ExecRunner); !ok {
+		return errs
+	}
+	for _, f := range lua {
+		if !c.findFilter(f, caps, false) {
+			errs = append(errs, fmt.Errorf("Lua filter %s is not found", f))
+		}
+	}
+	for _, f := range c.optValues("filter", 'F') {
+		if !c.findFilter(f, caps, true) {
+			errs = append(errs, fmt.Errorf("filter %s is not found", f))
+		}
+	}
+	if c.Format == "pdf" || c.pdfFile() || c.optValue("pdf-engine") != "" {
+		if engine, err := c.PDFEngine(); err != nil {
+			errs = append(errs, err)
+		} else if _, err := exec.LookPath(engine); err != nil {
+			errs = append(errs, fmt.Errorf("PDF engine %s: %w", engine, err))
+		}
+	}
+	return errs
+}
+
+// reports whether the filter f is found as pandoc looks for it: relative
+// to Dir, in the filters of the user data directory and, for JSON
+// filters, in PATH
+func (c *Conf) findFilter(f string, caps *Capabilities, inPath bool) bool {
+	exists := func(path string) bool {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.Dir, path)
+		}
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	if exists(f) {
+		return true
+	} else if filepath.Base(f) != f {
+		return false
+	}
+	dataDir := c.optValue("data-dir")
+	if dataDir == "" {
+		dataDir = caps.DataDir
+	}
+	if dataDir != "" && exists(filepath.Join(dataDir, "filters", f)) {
+		return true
+	}
+	if inPath {
+		_, err := exec.LookPath(f)
+		return err == nil
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package pandoc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "pandoc")
+	script := `#!/bin/sh
+case "$1" in
+--version) echo "pandoc 2.9.2"; echo "User data directory: ` + dir + `" ;;
+--list-input-formats) printf 'markdown\ndocx\n' ;;
+--list-output-formats) printf 'html\nlatex\n' ;;
+--list-extensions=*) printf '+smart\n-raw_tex\n' ;;
+--list-highlight-styles) printf 'tango\nkate\n' ;;
+*) echo '{"pandoc-api-version":[1,20],"meta":{},"blocks":[]}' ;;
+esac
+`
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	} else if err := os.MkdirAll(filepath.Join(dir, "filters"), 0o755); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile(filepath.Join(dir, "filters", "data.lua"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile(filepath.Join(dir, "local.lua"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	conf := Format("markdown").WithPandoc(exe)
+
+	good := conf.WithDir(dir).WithExt("smart").WithLuaFilter("data.lua").WithLuaFilter("local.lua").WithHighlightStyle("kate")
+	if err := good.Check(); err != nil {
+		t.Errorf("unexpected %v", err)
+	}
+
+	err := Format("asciidoc").WithPandoc(exe).WithExt("smrat").WithVersionCheck().
+		WithCiteproc().WithFilter("no-such-filter").WithLuaFilter("no-such.lua").
+		WithOpt("split-level", "2").WithHighlightStyle("tnago").Check()
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, e := range []string{
+		"incompatible pandoc version: pandoc 2.9.2 uses API 1.20",
+		"pandoc neither reads nor writes format asciidoc",
+		"--split-level=2 requires pandoc 3.0",
+		"unknown highlight style tnago, did you mean tango?",
+		"--citeproc requires pandoc 2.11",
+		"Lua filter no-such.lua is not found",
+		"filter no-such-filter is not found",
+	} {
+		if !strings.Contains(err.Error(), e) {
+			t.Errorf("%q does not contain %q", err, e)
+		}
+	}
+	if !errors.Is(err, ErrIncompatibleVersion) {
+		t.Errorf("%v is not ErrIncompatibleVersion", err)
+	}
+
+	if err := conf.WithExt("smrat").Check(); err == nil || err.Error() != "unknown extension smrat of markdown, did you mean smart?" {
+		t.Errorf("got %v", err)
+	}
+	if err := conf.WithOpt("o", "out.pdf").WithPDFEngine("no-such-engine").Check(); err == nil || !strings.Contains(err.Error(), "PDF engine no-such-engine") {
+		t.Errorf("got %v", err)
+	}
+	if err := conf.WithPandoc(filepath.Join(dir, "missing")).Check(); err == nil {
+		t.Error("no error for missing pandoc")
+	}
+}
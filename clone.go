@@ -0,0 +1,108 @@
+package pandoc
+
+import (
+	"bytes"
+	"slices"
+)
+
+// Returns a deep copy of an element, sharing nothing with it, unlike
+// Clone. Lazy blocks are copied undecoded.
+//
+// Example:
+//
+//	draft := pandoc.DeepClone(doc)
+func DeepClone[P Element](elt P) P {
+	if isNil(elt) {
+		return elt
+	}
+	return deepClone(Element(elt)).(P)
+}
+
+func deepClone(e Element) Element {
+	if isNil(e) {
+		return e
+	}
+	switch e := e.(type) {
+	case MetaMapEntry:
+		return MetaMapEntry{Key: e.Key, Value: DeepClone(e.Value)}
+	case MetaString, MetaBool:
+		return e
+	case *LazyBlock:
+		// the raw encoding is never changed
+		return &LazyBlock{tag: e.tag, raw: e.raw, opts: e.opts}
+	case *UnknownElement:
+		return &UnknownElement{Type: e.Type, Content: bytes.Clone(e.Content)}
+	case *Pandoc:
+		c := &Pandoc{Version: slices.Clone(e.Version)}
+		c.Meta, c.Blocks = cloneList(e.Meta), cloneList(e.Blocks)
+		return c
+	}
+	c := e.clone()
+	cloneFields(c)
+	return c
+}
+
+// replaces the fields of a shallow copy of an element with deep copies
+func cloneFields(e Element) {
+	for i := 0; ; i++ {
+		switch f := e.Field(i).(type) {
+		case nil:
+			return
+		case *Attr:
+			f.Classes, f.KVs = slices.Clone(f.Classes), slices.Clone(f.KVs)
+		case *[]Inline:
+			*f = cloneList(*f)
+		case *[]Block:
+			*f = cloneList(*f)
+		case *[][]Inline:
+			*f = cloneLists(*f)
+		case *[][]Block:
+			*f = cloneLists(*f)
+		case *[]MetaValue:
+			*f = cloneList(*f)
+		case *Meta:
+			*f = cloneList(*f)
+		case *[]*Citation:
+			*f = cloneList(*f)
+		case *[]Definition:
+			*f = slices.Clone(*f)
+			for i := range *f {
+				cloneFields(&(*f)[i])
+			}
+		case *[]ColSpec:
+			*f = slices.Clone(*f)
+		case *Caption:
+			cloneFields(f)
+		case *TableHeadFoot:
+			cloneFields(f)
+		case *[]*TableBody:
+			*f = cloneList(*f)
+		case *[]*TableRow:
+			*f = cloneList(*f)
+		case *[]*TableCell:
+			*f = cloneList(*f)
+		}
+	}
+}
+
+func cloneList[T Element](l []T) []T {
+	if l == nil {
+		return nil
+	}
+	c := make([]T, len(l))
+	for i, e := range l {
+		c[i] = DeepClone(e)
+	}
+	return c
+}
+
+func cloneLists[T Element](l [][]T) [][]T {
+	if l == nil {
+		return nil
+	}
+	c := make([][]T, len(l))
+	for i := range l {
+		c[i] = cloneList(l[i])
+	}
+	return c
+}
@@ -0,0 +1,50 @@
+package pandoc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDeepClone(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []ReadOptions{{}, {Lazy: true}} {
+		doc, err := opts.ReadBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := Sprint(doc)
+		c := DeepClone(doc)
+		if !Equal(doc, c) {
+			t.Fatalf("lazy %v: copy is not equal", opts.Lazy)
+		}
+		c, err = Filter(c, func(b Block) ([]Block, error) {
+			if lb, ok := b.(*LazyBlock); ok {
+				b, _ = lb.Decode()
+			}
+			return []Block{b}, ReplaceContinue
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		Query(c, func(e Element) {
+			switch e := e.(type) {
+			case *Str:
+				e.Text += "!"
+			case *Header:
+				e.Classes = append(e.Classes[:0], "changed")
+			case *TableCell:
+				e.ColSpan++
+			}
+		})
+		if Sprint(doc) != before {
+			t.Errorf("lazy %v: changing the copy changes the document", opts.Lazy)
+		}
+	}
+	var p *Para
+	if DeepClone(p) != nil {
+		t.Error("copy of nil is not nil")
+	}
+}
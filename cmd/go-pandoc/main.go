@@ -0,0 +1,193 @@
+// Command go-pandoc loads a document, applies a chain of Go transformers
+// and stores the result.
+//
+// Usage:
+//
+//	go-pandoc [flags] [input...]
+//
+// Documents are loaded and stored with pandoc unless the format is "json",
+// in which case the pandoc AST is read from the input (or stdin) and written
+// to the output (or stdout) directly. This makes go-pandoc usable both as
+// a standalone converter and as a step of a shell pipeline:
+//
+//	pandoc -t json doc.md | go-pandoc -x auto-ident | pandoc -f json -o doc.html
+//	go-pandoc -f markdown -t html -x strip-notes -o doc.html doc.md
+//
+// Transformers are applied in the order they are given. Use -l to list
+// available transformers. A list of transformers can also be read from a
+// config file given with -c, one or more comma-separated names per line;
+// empty lines and lines starting with "#" are ignored:
+//
+//	# config for the site build
+//	auto-ident
+//	strip-raw, unwrap-spans
+//
+// The transformers of the config file are applied before the ones given
+// with -x. Use -trace to print the time taken by each of them.
+//
+// A name that is not a built-in transformer refers to a plugin, an
+// executable named go-pandoc-<name> in PATH (see pandoc.Plugin).
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/growler/go-pandoc"
+)
+
+// a repeatable flag, also accepting comma-separated values
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+func (l *listFlag) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+type options struct {
+	from         string
+	to           string
+	output       string
+	pandoc       string
+	config       string
+	trace        bool
+	transformers listFlag
+	inputs       []string
+}
+
+func main() {
+	var (
+		opts options
+		list bool
+	)
+	flag.StringVar(&opts.from, "f", "json", "input `format`, with optional extensions (e.g. markdown+smart)")
+	flag.StringVar(&opts.to, "t", "json", "output `format`, with optional extensions")
+	flag.StringVar(&opts.output, "o", "", "output `file` (default stdout)")
+	flag.StringVar(&opts.pandoc, "pandoc", "", "`path` to pandoc executable")
+	flag.Var(&opts.transformers, "x", "apply `transformer` (repeatable, comma-separated)")
+	flag.StringVar(&opts.config, "c", "", "read transformers to apply from `file`")
+	flag.BoolVar(&opts.trace, "trace", false, "print statistics of transformers to stderr")
+	flag.BoolVar(&list, "l", false, "list available transformers and exit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [input...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if list {
+		for _, t := range sortedTransformers() {
+			fmt.Printf("%-16s %s\n", t.name, t.help)
+		}
+		return
+	}
+	opts.inputs = flag.Args()
+	if err := run(&opts); err != nil {
+		fmt.Fprintf(os.Stderr, "go-pandoc: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(opts *options) error {
+	names := opts.transformers
+	if opts.config != "" {
+		conf, err := readConfig(opts.config)
+		if err != nil {
+			return err
+		}
+		names = append(conf, names...)
+	}
+	var p pandoc.Pipeline
+	for _, name := range names {
+		if t, ok := transformers[name]; ok {
+			p = p.Then(name, t.fun)
+		} else if path, err := exec.LookPath("go-pandoc-" + name); err == nil {
+			p = p.Then(name, (&pandoc.Plugin{Path: path}).Transform)
+		} else {
+			return fmt.Errorf("unknown transformer %q", name)
+		}
+	}
+	doc, err := load(opts)
+	if err != nil {
+		return err
+	}
+	if opts.trace {
+		var trace *pandoc.PipelineTrace
+		doc, trace, err = p.Trace(doc)
+		trace.WriteTo(os.Stderr)
+	} else {
+		doc, err = p.Run(doc)
+	}
+	if err != nil {
+		return err
+	}
+	return store(doc, opts)
+}
+
+// reads names of transformers from a config file
+func readConfig(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var names listFlag
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			names.Set(line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return names, nil
+}
+
+func load(opts *options) (*pandoc.Pandoc, error) {
+	if opts.from == "json" && len(opts.inputs) <= 1 {
+		var r io.Reader = os.Stdin
+		if len(opts.inputs) == 1 && opts.inputs[0] != "-" {
+			f, err := os.Open(opts.inputs[0])
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			r = f
+		}
+		return pandoc.ReadFrom(r)
+	}
+	conf := pandoc.Format(opts.from).WithPandoc(opts.pandoc)
+	if len(opts.inputs) == 0 {
+		return pandoc.LoadFrom(os.Stdin, conf)
+	}
+	return pandoc.LoadFiles(opts.inputs, conf)
+}
+
+func store(doc *pandoc.Pandoc, opts *options) error {
+	if opts.to == "json" {
+		var w io.Writer = os.Stdout
+		if opts.output != "" && opts.output != "-" {
+			f, err := os.Create(opts.output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return doc.WriteTo(w)
+	}
+	conf := pandoc.Format(opts.to).WithPandoc(opts.pandoc)
+	if opts.output == "" || opts.output == "-" {
+		return doc.StoreTo(os.Stdout, conf)
+	}
+	return doc.StoreFile(opts.output, conf)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/growler/go-pandoc"
+)
+
+// A named document transformer available from the command line.
+type transformer struct {
+	name string
+	help string
+	fun  func(*pandoc.Pandoc) (*pandoc.Pandoc, error)
+}
+
+var transformers = map[string]transformer{}
+
+// registers a transformer under the given name
+func register(name, help string, fun func(*pandoc.Pandoc) (*pandoc.Pandoc, error)) {
+	if _, ok := transformers[name]; ok {
+		panic(fmt.Sprintf("transformer %q is already registered", name))
+	}
+	transformers[name] = transformer{name, help, fun}
+}
+
+// returns transformers sorted by name
+func sortedTransformers() []transformer {
+	lst := make([]transformer, 0, len(transformers))
+	for _, t := range transformers {
+		lst = append(lst, t)
+	}
+	sort.Slice(lst, func(i, j int) bool { return lst[i].name < lst[j].name })
+	return lst
+}
+
+func init() {
+	register("auto-ident", "assign unique identifiers to headers and figures without one",
+		pandoc.AutoIdents)
+	register("number-headings", "number headers hierarchically",
+		func(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+			return pandoc.NumberHeadings(doc, pandoc.NumberOptions{})
+		})
+	register("strip-notes", "remove footnotes",
+		pandoc.Transformer[*pandoc.Pandoc](func(*pandoc.Note) ([]pandoc.Inline, error) {
+			return nil, pandoc.ReplaceSkip
+		}))
+	register("strip-raw", "remove raw inlines and blocks",
+		func(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+			return doc.Apply(
+				pandoc.Transformer[*pandoc.Pandoc](func(*pandoc.RawInline) ([]pandoc.Inline, error) {
+					return nil, pandoc.ReplaceSkip
+				}),
+				pandoc.Transformer[*pandoc.Pandoc](func(*pandoc.RawBlock) ([]pandoc.Block, error) {
+					return nil, pandoc.ReplaceSkip
+				}),
+			)
+		})
+	register("toc", "insert a table of contents at the top or in a div of class toc",
+		pandoc.TOC(pandoc.TOCOptions{}))
+	register("unwrap-divs", "replace divs with their content",
+		pandoc.Transformer[*pandoc.Pandoc](func(d *pandoc.Div) ([]pandoc.Block, error) {
+			return d.Blocks, pandoc.ReplaceContinue
+		}))
+	register("unwrap-spans", "replace spans with their content",
+		pandoc.Transformer[*pandoc.Pandoc](func(s *pandoc.Span) ([]pandoc.Inline, error) {
+			return s.Inlines, pandoc.ReplaceContinue
+		}))
+}
@@ -0,0 +1,220 @@
+// Command pandoc-diff compares two documents structurally.
+//
+// Usage:
+//
+//	pandoc-diff [flags] old new
+//
+// Both documents are loaded with pandoc (or read directly as AST JSON if
+// the format is "json"), and their top-level blocks are compared. Each
+// changed block is printed on its own line prefixed by "-" or "+", along
+// with unchanged context blocks. With -words, a changed block that was
+// replaced by a block of the same kind is printed once, with
+// word-level changes marked as [-deleted-] and {+inserted+}.
+//
+// The exit status is 0 if the documents are equal, 1 if they differ,
+// and 2 if an error occurred.
+//
+// Example:
+//
+//	pandoc-diff -f markdown -words README.md README.new.md
+//	pandoc-diff -old docx -new markdown report.docx report.md
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/growler/go-pandoc"
+)
+
+func main() {
+	var (
+		format    string
+		oldFormat string
+		newFormat string
+		exe       string
+		context   int
+		wordDiff  bool
+	)
+	flag.StringVar(&format, "f", "json", "`format` of both documents")
+	flag.StringVar(&oldFormat, "old", "", "`format` of the old document (overrides -f)")
+	flag.StringVar(&newFormat, "new", "", "`format` of the new document (overrides -f)")
+	flag.StringVar(&exe, "pandoc", "", "`path` to pandoc executable")
+	flag.IntVar(&context, "U", 1, "number of unchanged context `blocks`")
+	flag.BoolVar(&wordDiff, "words", false, "show word-level changes of modified blocks")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] old new\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if oldFormat == "" {
+		oldFormat = format
+	}
+	if newFormat == "" {
+		newFormat = format
+	}
+	a, err := load(flag.Arg(0), pandoc.Format(oldFormat).WithPandoc(exe))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pandoc-diff: %s\n", err)
+		os.Exit(2)
+	}
+	b, err := load(flag.Arg(1), pandoc.Format(newFormat).WithPandoc(exe))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pandoc-diff: %s\n", err)
+		os.Exit(2)
+	}
+	edits := pandoc.Diff(a, b)
+	if !changed(edits) {
+		return
+	}
+	fmt.Printf("--- %s\n+++ %s\n", flag.Arg(0), flag.Arg(1))
+	printDiff(os.Stdout, edits, context, wordDiff)
+	os.Exit(1)
+}
+
+func load(file string, conf pandoc.Conf) (*pandoc.Pandoc, error) {
+	if conf.Format != "json" {
+		return pandoc.LoadFile(file, conf)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return pandoc.ReadFrom(f)
+}
+
+func changed(edits []pandoc.Edit[pandoc.Block]) bool {
+	for _, e := range edits {
+		if e.Op != pandoc.DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// returns a one-line summary of a block
+func summary(b pandoc.Block) string {
+	const max = 72
+	text := strings.Join(strings.Fields(pandoc.Stringify(b)), " ")
+	if r := []rune(text); len(r) > max {
+		text = string(r[:max]) + "…"
+	}
+	if h, ok := b.(*pandoc.Header); ok {
+		return fmt.Sprintf("%s %d: %s", b.Tag(), h.Level, text)
+	}
+	return fmt.Sprintf("%s: %s", b.Tag(), text)
+}
+
+func inlines(b pandoc.Block) ([]pandoc.Inline, bool) {
+	switch b := b.(type) {
+	case *pandoc.Para:
+		return b.Inlines, true
+	case *pandoc.Plain:
+		return b.Inlines, true
+	case *pandoc.Header:
+		return b.Inlines, true
+	}
+	return nil, false
+}
+
+// prints a word-level diff of two blocks, returns false if the blocks can't
+// be compared word by word
+func printWords(w io.Writer, a, b pandoc.Block) bool {
+	if a.Tag() != b.Tag() {
+		return false
+	}
+	la, ok := inlines(a)
+	if !ok {
+		return false
+	}
+	lb, _ := inlines(b)
+	var (
+		sb strings.Builder
+		op = pandoc.DiffEqual
+	)
+	closeOp := func() {
+		switch op {
+		case pandoc.DiffDelete:
+			sb.WriteString("-]")
+		case pandoc.DiffInsert:
+			sb.WriteString("+}")
+		}
+	}
+	for _, e := range pandoc.DiffList(la, lb) {
+		if e.Op != op {
+			closeOp()
+			switch e.Op {
+			case pandoc.DiffDelete:
+				sb.WriteString("[-")
+			case pandoc.DiffInsert:
+				sb.WriteString("{+")
+			}
+			op = e.Op
+		}
+		sb.WriteString(pandoc.Stringify(e.Elt))
+	}
+	closeOp()
+	fmt.Fprintf(w, "~ %s: %s\n", a.Tag(), sb.String())
+	return true
+}
+
+func printDiff(w io.Writer, edits []pandoc.Edit[pandoc.Block], context int, wordDiff bool) {
+	// mark edits to print: changes and their context
+	show := make([]bool, len(edits))
+	for i, e := range edits {
+		if e.Op == pandoc.DiffEqual {
+			continue
+		}
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(edits) {
+				show[j] = true
+			}
+		}
+	}
+	for i := 0; i < len(edits); i++ {
+		if !show[i] {
+			continue
+		}
+		if i == 0 || !show[i-1] {
+			old, new := position(edits, i)
+			fmt.Fprintf(w, "@@ -%d +%d @@\n", old+1, new+1)
+		}
+		e := edits[i]
+		switch e.Op {
+		case pandoc.DiffEqual:
+			fmt.Fprintf(w, "  %s\n", summary(e.Elt))
+		case pandoc.DiffDelete:
+			// a deletion immediately followed by an insertion is a modification
+			if wordDiff && i+1 < len(edits) && edits[i+1].Op == pandoc.DiffInsert &&
+				printWords(w, e.Elt, edits[i+1].Elt) {
+				i++
+				continue
+			}
+			fmt.Fprintf(w, "- %s\n", summary(e.Elt))
+		case pandoc.DiffInsert:
+			fmt.Fprintf(w, "+ %s\n", summary(e.Elt))
+		}
+	}
+}
+
+// returns positions of an edit in the old and new lists
+func position(edits []pandoc.Edit[pandoc.Block], i int) (int, int) {
+	var old, new int
+	for _, e := range edits[:i] {
+		if e.Op != pandoc.DiffInsert {
+			old++
+		}
+		if e.Op != pandoc.DiffDelete {
+			new++
+		}
+	}
+	return old, new
+}
@@ -0,0 +1,130 @@
+// Command pandoc-inspect validates and inspects pandoc AST JSON. It is
+// a debugging companion for filter developers.
+//
+// Usage:
+//
+//	pandoc-inspect [flags] [file]
+//
+// The document is read from file, or from stdin if no file is given,
+// and validated. Depending on flags, pandoc-inspect then prints the
+// document as indented JSON (-json), as a tree of elements (-tree), its
+// outline of headers as JSON (-outline), or statistics of the document (-stats, the default). The exit code is 1 if
+// the document cannot be read, and 2 if it fails validation.
+//
+// Example:
+//
+//	pandoc -t json doc.md | go-pandoc -x my-filter | pandoc-inspect -tree
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/growler/go-pandoc"
+)
+
+func main() {
+	var (
+		noValidate bool
+		pretty     bool
+		tree       bool
+		stats      bool
+		outline    bool
+	)
+	flag.BoolVar(&noValidate, "novalidate", false, "do not validate the document")
+	flag.BoolVar(&pretty, "json", false, "print the document as indented JSON")
+	flag.BoolVar(&tree, "tree", false, "print the document as a tree of elements")
+	flag.BoolVar(&outline, "outline", false, "print the document outline as JSON")
+	flag.BoolVar(&stats, "stats", false, "print document statistics (default if no other output is selected)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [flags] [file]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	if !pretty && !tree && !outline {
+		stats = true
+	}
+	doc, err := read(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pandoc-inspect: %s\n", err)
+		os.Exit(1)
+	}
+	if pretty {
+		if err := printJSON(os.Stdout, doc); err != nil {
+			fmt.Fprintf(os.Stderr, "pandoc-inspect: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if tree {
+		if err := pandoc.Dump(os.Stdout, doc); err != nil {
+			fmt.Fprintf(os.Stderr, "pandoc-inspect: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if outline {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(pandoc.Outline(doc)); err != nil {
+			fmt.Fprintf(os.Stderr, "pandoc-inspect: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if stats {
+		printStats(os.Stdout, pandoc.Profile(doc))
+	}
+	if !noValidate {
+		if err := pandoc.Validate(doc); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(2)
+		}
+	}
+}
+
+func read(file string) (*pandoc.Pandoc, error) {
+	if file == "" || file == "-" {
+		return pandoc.ReadFrom(os.Stdin)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return pandoc.ReadFrom(f)
+}
+
+func printJSON(w io.Writer, doc *pandoc.Pandoc) error {
+	if err := pandoc.WriteIndent(w, doc, "", "  "); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func printStats(w io.Writer, s pandoc.Stats) {
+	fmt.Fprintf(w, "elements:   %d\n", s.Elements)
+	fmt.Fprintf(w, "max depth:  %d\n", s.MaxDepth)
+	fmt.Fprintf(w, "words:      %d\n", s.Words)
+	fmt.Fprintf(w, "characters: %d\n", s.Chars)
+	fmt.Fprintf(w, "idents:     %d\n", s.Idents)
+	tags := make([]pandoc.Tag, 0, len(s.Tags))
+	for t := range s.Tags {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if s.Tags[tags[i]] != s.Tags[tags[j]] {
+			return s.Tags[tags[i]] > s.Tags[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	for _, t := range tags {
+		fmt.Fprintf(w, "  %-16s %d\n", t, s.Tags[t])
+	}
+}
@@ -0,0 +1,292 @@
+package pandoc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// A pandoc conversion parsed from a command line. Loading Inputs with
+// From and storing the document to Output with To is equivalent to the
+// command line.
+type Invocation struct {
+	From   Conf     // Loads the inputs, with reader options, metadata and filters
+	To     Conf     // Stores the document, with writer options
+	Inputs []string // Input files, the standard input if empty
+	Output string   // Output file, the standard output if empty
+}
+
+// A direction of conversions a Conf is used for.
+type Direction int
+
+const (
+	Reading Direction = 1 << iota // Conf loads documents
+	Writing                       // Conf stores documents
+
+	bothOpt = Reading | Writing
+)
+
+// whether an option has an argument
+type optArg int
+
+const (
+	noArg optArg = iota
+	requiredArg
+	optionalArg // only with "="
+)
+
+type cmdOpt struct {
+	side Direction
+	arg  optArg
+}
+
+// long names of short options
+var shortOpts = map[byte]string{
+	'f': "from", 'r': "from", 't': "to", 'w': "to", 'o': "output",
+	'M': "metadata", 'V': "variable", 'F': "filter", 'L': "lua-filter",
+	'C': "citeproc", 'p': "preserve-tabs", 's': "standalone",
+	'N': "number-sections", 'i': "incremental", 'c': "css", 'T': "title-prefix",
+	'd': "defaults", 'H': "include-in-header", 'B': "include-before-body", 'A': "include-after-body",
+}
+
+// known conversion options
+var cmdOpts = map[string]cmdOpt{
+	"metadata":                {Reading, requiredArg},
+	"metadata-file":           {Reading, requiredArg},
+	"shift-heading-level-by":  {Reading, requiredArg},
+	"base-header-level":       {Reading, requiredArg},
+	"indented-code-classes":   {Reading, requiredArg},
+	"default-image-extension": {Reading, requiredArg},
+	"filter":                  {Reading, requiredArg},
+	"lua-filter":              {Reading, requiredArg},
+	"tab-stop":                {Reading, requiredArg},
+	"track-changes":           {Reading, requiredArg},
+	"extract-media":           {Reading, requiredArg},
+	"abbreviations":           {Reading, requiredArg},
+	"bibliography":            {Reading, requiredArg},
+	"csl":                     {Reading, requiredArg},
+	"citation-abbreviations":  {Reading, requiredArg},
+	"citeproc":                {Reading, noArg},
+	"file-scope":              {Reading, noArg},
+	"preserve-tabs":           {Reading, noArg},
+	"strip-comments":          {Reading, noArg},
+
+	"variable":            {Writing, requiredArg},
+	"variable-json":       {Writing, requiredArg},
+	"template":            {Writing, requiredArg},
+	"toc-depth":           {Writing, requiredArg},
+	"highlight-style":     {Writing, requiredArg},
+	"syntax-definition":   {Writing, requiredArg},
+	"dpi":                 {Writing, requiredArg},
+	"eol":                 {Writing, requiredArg},
+	"columns":             {Writing, requiredArg},
+	"wrap":                {Writing, requiredArg},
+	"reference-location":  {Writing, requiredArg},
+	"markdown-headings":   {Writing, requiredArg},
+	"number-offset":       {Writing, requiredArg},
+	"top-level-division":  {Writing, requiredArg},
+	"include-in-header":   {Writing, requiredArg},
+	"include-before-body": {Writing, requiredArg},
+	"include-after-body":  {Writing, requiredArg},
+	"css":                 {Writing, requiredArg},
+	"reference-doc":       {Writing, requiredArg},
+	"epub-cover-image":    {Writing, requiredArg},
+	"epub-metadata":       {Writing, requiredArg},
+	"epub-embed-font":     {Writing, requiredArg},
+	"epub-subdirectory":   {Writing, requiredArg},
+	"epub-title-page":     {Writing, requiredArg},
+	"split-level":         {Writing, requiredArg},
+	"chunk-template":      {Writing, requiredArg},
+	"pdf-engine":          {Writing, requiredArg},
+	"pdf-engine-opt":      {Writing, requiredArg},
+	"slide-level":         {Writing, requiredArg},
+	"email-obfuscation":   {Writing, requiredArg},
+	"id-prefix":           {Writing, requiredArg},
+	"title-prefix":        {Writing, requiredArg},
+	"ipynb-output":        {Writing, requiredArg},
+	"mathjax":             {Writing, optionalArg},
+	"katex":               {Writing, optionalArg},
+	"webtex":              {Writing, optionalArg},
+	"standalone":          {Writing, noArg},
+	"toc":                 {Writing, noArg},
+	"table-of-contents":   {Writing, noArg},
+	"number-sections":     {Writing, noArg},
+	"incremental":         {Writing, noArg},
+	"section-divs":        {Writing, noArg},
+	"listings":            {Writing, noArg},
+	"no-highlight":        {Writing, noArg},
+	"html-q-tags":         {Writing, noArg},
+	"reference-links":     {Writing, noArg},
+	"embed-resources":     {Writing, noArg},
+	"self-contained":      {Writing, noArg},
+	"list-tables":         {Writing, noArg},
+	"lof":                 {Writing, noArg},
+	"lot":                 {Writing, noArg},
+	"ascii":               {Writing, noArg},
+	"mathml":              {Writing, noArg},
+	"gladtex":             {Writing, noArg},
+
+	"defaults":             {bothOpt, requiredArg},
+	"data-dir":             {bothOpt, requiredArg},
+	"log":                  {bothOpt, requiredArg},
+	"resource-path":        {bothOpt, requiredArg},
+	"request-header":       {bothOpt, requiredArg},
+	"verbose":              {bothOpt, noArg},
+	"quiet":                {bothOpt, noArg},
+	"fail-if-warnings":     {bothOpt, noArg},
+	"no-check-certificate": {bothOpt, noArg},
+	"sandbox":              {bothOpt, noArg},
+	"trace":                {bothOpt, noArg},
+}
+
+func lookupOpt(name string) (cmdOpt, bool) {
+	switch name {
+	case "from", "read", "to", "write", "output":
+		return cmdOpt{arg: requiredArg}, true
+	}
+	opt, ok := cmdOpts[name]
+	return opt, ok
+}
+
+// formats by file extension, as guessed by pandoc; PDF is made with
+// LaTeX
+var extFormats = map[string]string{
+	".md": "markdown", ".markdown": "markdown", ".txt": "markdown",
+	".mkd": "markdown", ".mkdn": "markdown", ".mdwn": "markdown", ".mdown": "markdown", ".rmd": "markdown",
+	".html": "html", ".htm": "html", ".xhtml": "html", ".tex": "latex", ".latex": "latex", ".ltx": "latex",
+	".rst": "rst", ".org": "org", ".docx": "docx", ".odt": "odt",
+	".epub": "epub", ".ipynb": "ipynb", ".json": "json", ".native": "native", ".pdf": "latex",
+	".adoc": "asciidoc", ".asciidoc": "asciidoc", ".textile": "textile", ".typ": "typst",
+	".xml": "docbook", ".db": "docbook", ".pptx": "pptx", ".rtf": "rtf", ".fb2": "fb2",
+	".context": "context", ".ctx": "context", ".dj": "djot", ".muse": "muse", ".opml": "opml",
+	".t2t": "t2t", ".tei": "tei", ".wiki": "mediawiki", ".icml": "icml", ".ms": "ms", ".roff": "ms",
+	".csv": "csv", ".tsv": "tsv", ".bib": "biblatex", ".ris": "ris",
+	".1": "man", ".2": "man", ".3": "man", ".4": "man", ".5": "man", ".6": "man", ".7": "man", ".8": "man", ".9": "man",
+}
+
+// Returns the format pandoc reads a file in, guessed from the path
+// extension as pandoc does, e.g. "rst" for "doc.rst", and whether the
+// extension is known. PDF files are not read by pandoc.
+func DetectFormat(path string) (string, bool) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".pdf" {
+		return "", false
+	}
+	f, ok := extFormats[ext]
+	return f, ok
+}
+
+// Parses pandoc command line arguments, without the executable, into an
+// Invocation. Formats are guessed from file extensions as pandoc does
+// if not given. Reader options, metadata and filters go to From, writer
+// options to To, general options such as --data-dir to both. Options
+// that are not conversion options, such as --version or --list-formats, and
+// unknown options are errors.
+//
+// Example:
+//
+//	inv, err := pandoc.ParseCommandLine(strings.Fields("-f markdown+smart -s --toc -o out.html in.md"))
+//	...
+//	doc, err := pandoc.LoadFiles(inv.Inputs, inv.From)
+//	...
+//	err = doc.StoreFile(inv.Output, inv.To)
+func ParseCommandLine(args []string) (*Invocation, error) {
+	inv := &Invocation{}
+	var from, to string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			inv.Inputs = append(inv.Inputs, args[i+1:]...)
+			break
+		} else if arg == "-" || !strings.HasPrefix(arg, "-") {
+			inv.Inputs = append(inv.Inputs, arg)
+			continue
+		}
+		var (
+			name, val string
+			hasVal    bool
+		)
+		if strings.HasPrefix(arg, "--") {
+			name, val, hasVal = strings.Cut(arg[2:], "=")
+		} else if long, ok := shortOpts[arg[1]]; ok {
+			name, val, hasVal = long, arg[2:], len(arg) > 2
+			if opt, _ := lookupOpt(name); opt.arg == noArg && hasVal {
+				// combined flags, e.g. -sN
+				args = append(args[:i+1:i+1], append([]string{"-" + val}, args[i+1:]...)...)
+				val, hasVal = "", false
+			}
+		} else {
+			return nil, fmt.Errorf("unknown pandoc option %s", arg)
+		}
+		opt, ok := lookupOpt(name)
+		if !ok {
+			return nil, fmt.Errorf("unsupported pandoc option %s", arg)
+		}
+		if opt.arg == noArg && hasVal {
+			return nil, fmt.Errorf("pandoc option %s has no argument", arg)
+		} else if opt.arg == requiredArg && !hasVal {
+			if i++; i == len(args) {
+				return nil, fmt.Errorf("pandoc option %s requires an argument", arg)
+			}
+			val, hasVal = args[i], true
+		}
+		switch name {
+		case "from", "read":
+			from = val
+		case "to", "write":
+			to = val
+		case "output":
+			inv.Output = val
+		default:
+			var vals []string
+			if hasVal {
+				vals = []string{val}
+			}
+			if name == "table-of-contents" {
+				name = "toc"
+			}
+			if opt.side&Reading != 0 {
+				inv.From = inv.From.WithOpt(name, vals...)
+			}
+			if opt.side&Writing != 0 {
+				inv.To = inv.To.WithOpt(name, vals...)
+			}
+		}
+	}
+	if from == "" {
+		from = "markdown"
+		if len(inv.Inputs) > 0 {
+			if f, ok := DetectFormat(inv.Inputs[0]); ok {
+				from = f
+			}
+		}
+	}
+	if to == "" {
+		to = "html"
+		if f, ok := extFormats[strings.ToLower(filepath.Ext(inv.Output))]; ok {
+			to = f
+		}
+	}
+	inv.From.Format, inv.From.Ext = splitFormat(from)
+	inv.To.Format, inv.To.Ext = splitFormat(to)
+	return inv, nil
+}
+
+// splits a format with extensions, e.g. "markdown+smart-raw_html"
+func splitFormat(s string) (string, []string) {
+	i := strings.IndexAny(s, "+-")
+	if i < 0 {
+		return s, nil
+	}
+	format, s := s[:i], s[i:]
+	var ext []string
+	for len(s) > 0 {
+		j := strings.IndexAny(s[1:], "+-") + 1
+		if j == 0 {
+			j = len(s)
+		}
+		ext = append(ext, s[:j])
+		s = s[j:]
+	}
+	return format, ext
+}
@@ -0,0 +1,80 @@
+package pandoc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	for _, tt := range []struct {
+		args     string
+		from, to string
+		inputs   string
+		output   string
+	}{
+		{"-f markdown+smart-raw_html -t html5 -s --toc -o out.html a.md b.md",
+			"markdown +smart -raw_html|", "html5|--standalone --toc", "a.md b.md", "out.html"},
+		{"-sN -Mtitle=Doc --metadata lang=en -V geometry:margin=1in --data-dir=data in.rst -o out.pdf",
+			"rst|--metadata=title=Doc --metadata=lang=en --data-dir=data", "latex|--standalone --number-sections --variable=geometry:margin=1in --data-dir=data", "in.rst", "out.pdf"},
+		{"-fdocx --to gfm --mathjax --lua-filter f.lua -C -- -x.docx",
+			"docx|--lua-filter=f.lua --citeproc", "gfm|--mathjax", "-x.docx", ""},
+		{"", "markdown|", "html|", "", ""},
+	} {
+		inv, err := ParseCommandLine(strings.Fields(tt.args))
+		if err != nil {
+			t.Errorf("%s: %v", tt.args, err)
+			continue
+		}
+		conf := func(c Conf) string {
+			return strings.Join(append([]string{c.Format}, c.Ext...), " ") + "|" + strings.Join(c.Opts, " ")
+		}
+		if got := conf(inv.From); got != tt.from {
+			t.Errorf("%s: from %q, want %q", tt.args, got, tt.from)
+		}
+		if got := conf(inv.To); got != tt.to {
+			t.Errorf("%s: to %q, want %q", tt.args, got, tt.to)
+		}
+		if got := strings.Join(inv.Inputs, " "); got != tt.inputs || inv.Output != tt.output {
+			t.Errorf("%s: inputs %q, output %q", tt.args, got, inv.Output)
+		}
+	}
+	for _, args := range []string{"--version", "-x", "-s=1", "-o", "--toc=2"} {
+		if _, err := ParseCommandLine(strings.Fields(args)); err == nil {
+			t.Errorf("%s: no error", args)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for path, want := range map[string]string{
+		"a.md": "markdown", "dir/B.RST": "rst", "c.docx": "docx", "nb.ipynb": "ipynb",
+		"x.Rmd": "markdown", "page.xhtml": "html", "ls.1": "man", "refs.bib": "biblatex",
+		"doc.pdf": "", "noext": "", "a.xyz": "",
+	} {
+		if got, ok := DetectFormat(path); got != want || ok != (want != "") {
+			t.Errorf("%s: got %q, %v", path, got, ok)
+		}
+	}
+}
+
+func TestLoadFileAuto(t *testing.T) {
+	conf := Conf{}.WithPandoc(fakePandoc(t))
+	f := filepath.Join(t.TempDir(), "doc.json")
+	doc := &Pandoc{Blocks: []Block{&Para{Inlines: []Inline{&Str{"a"}}}}}
+	var sb strings.Builder
+	if err := doc.write(&sb); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile(f, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := LoadFileAuto(f, conf); err != nil {
+		t.Fatal(err)
+	} else if s := Stringify(got); s != "a" {
+		t.Errorf("got %q", s)
+	}
+	if _, err := LoadFileAuto("doc.pdf", conf); err == nil || err.Error() != "unknown format of doc.pdf" {
+		t.Errorf("got %v", err)
+	}
+}
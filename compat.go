@@ -0,0 +1,223 @@
+package pandoc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Options of writing Pandoc AST JSON.
+type WriteOptions struct {
+	// Target pandoc-types API version, e.g. "1.22.2.1", not older than
+	// MinVersion. Constructs the target version does not support are
+	// converted to their older equivalents:
+	//
+	//   - Figure (1.23) becomes a paragraph with a single image with the
+	//     "fig:" title prefix if the figure only contains an image, and a
+	//     Div with the "figure" class and the caption appended otherwise
+	//   - Underline (1.21) becomes a Span with the "underline" class
+	//   - Table (1.21) is written in the simple table representation,
+	//     losing attributes and row spans
+	//
+	// If empty, the document's version is targeted.
+	Version string
+}
+
+// Writes Pandoc AST JSON of the document to w with options o. The
+// document is not modified.
+func (o WriteOptions) Write(w io.Writer, doc *Pandoc) error {
+	if o.Version == "" {
+		return writeBuffered(w, doc)
+	}
+	version := semver(o.Version)
+	if len(version) < 2 || cmpSemver(version[:2], _MinVersion) < 0 || cmpSemver(version[:2], _Version[:2]) > 0 {
+		return fmt.Errorf("unsupported target version %q", o.Version)
+	}
+	doc, err := downgrade(doc, version)
+	if err != nil {
+		return err
+	}
+	return writeBuffered(w, writerFunc(doc.writeDoc))
+}
+
+// converts constructs not supported by version
+func downgrade(doc *Pandoc, version []int) (*Pandoc, error) {
+	doc = &Pandoc{Meta: doc.Meta, Blocks: doc.Blocks, Version: version}
+	var err error
+	if cmpSemver(version, []int{1, 23}) < 0 {
+		if doc, err = Filter(doc, downgradeFigure); err != nil {
+			return nil, err
+		}
+	}
+	if cmpSemver(version, []int{1, 21}) < 0 {
+		if doc, err = Filter(doc, downgradeUnderline); err != nil {
+			return nil, err
+		}
+		if doc, err = Filter(doc, downgradeTable); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func downgradeFigure(f *Figure) ([]Block, error) {
+	if img := figureImage(f); img != nil {
+		c := *img
+		if c.Id == "" {
+			c.Id = f.Id
+		}
+		if caption := captionInlines(f.Caption); len(caption) > 0 {
+			c.Inlines = caption
+		}
+		c.Target.Title = "fig:" + c.Target.Title
+		return []Block{&Para{Inlines: []Inline{&c}}}, ReplaceContinue
+	}
+	div := &Div{Attr: f.Attr, Blocks: append(append([]Block(nil), f.Blocks...), f.Caption.Long...)}
+	div.Classes = append([]string{"figure"}, f.Classes...)
+	return []Block{div}, ReplaceContinue
+}
+
+// returns the image of a figure containing only an image, nil otherwise
+func figureImage(f *Figure) *Image {
+	if len(f.Blocks) != 1 {
+		return nil
+	}
+	var inlines []Inline
+	switch b := f.Blocks[0].(type) {
+	case *Plain:
+		inlines = b.Inlines
+	case *Para:
+		inlines = b.Inlines
+	}
+	if len(inlines) != 1 {
+		return nil
+	}
+	img, _ := inlines[0].(*Image)
+	return img
+}
+
+// returns the caption as a list of inlines
+func captionInlines(c Caption) []Inline {
+	var inlines []Inline
+	for _, b := range c.Long {
+		var lst []Inline
+		switch b := b.(type) {
+		case *Plain:
+			lst = b.Inlines
+		case *Para:
+			lst = b.Inlines
+		default:
+			continue
+		}
+		if len(inlines) > 0 && len(lst) > 0 {
+			inlines = append(inlines, SP)
+		}
+		inlines = append(inlines, lst...)
+	}
+	if len(inlines) == 0 {
+		return c.Short
+	}
+	return inlines
+}
+
+func downgradeUnderline(u *Underline) ([]Inline, error) {
+	return []Inline{&Span{Attr: Attr{Classes: []string{"underline"}}, Inlines: u.Inlines}}, ReplaceContinue
+}
+
+func downgradeTable(t *Table) ([]Block, error) {
+	// nested tables are converted first
+	t, err := Filter(t, downgradeTable)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := writeLegacyTable(&b, t); err != nil {
+		return nil, err
+	}
+	return []Block{&UnknownElement{Type: TableTag, Content: b.Bytes()}}, ReplaceSkip
+}
+
+// writes the contents of the simple table representation: caption,
+// column alignments and widths, header cells and rows of cells
+func writeLegacyTable(w io.Writer, t *Table) error {
+	n := len(t.Aligns)
+	var rows [][]l[Block]
+	for _, r := range t.Head.Rows {
+		rows = append(rows, legacyRow(r, n))
+	}
+	for _, body := range t.Bodies {
+		for _, r := range body.Head {
+			rows = append(rows, legacyRow(r, n))
+		}
+		for _, r := range body.Body {
+			rows = append(rows, legacyRow(r, n))
+		}
+	}
+	for _, r := range t.Foot.Rows {
+		rows = append(rows, legacyRow(r, n))
+	}
+	var head []l[Block]
+	if len(t.Head.Rows) > 0 {
+		head, rows = rows[0], rows[1:]
+	} else {
+		head = make([]l[Block], n)
+	}
+	aligns := make([]tstr, n)
+	widths := []byte{'['}
+	for i, c := range t.Aligns {
+		aligns[i] = taggedStr(c.Align)
+		if i > 0 {
+			widths = append(widths, ',')
+		}
+		if c.Width.Default {
+			widths = append(widths, '0')
+		} else {
+			widths = appendFloat(widths, c.Width.Width)
+		}
+	}
+	widths = append(widths, ']')
+	if err := writeDelim(w, '['); err != nil {
+		return err
+	}
+	if err := list(captionInlines(t.Caption)).write(w); err != nil {
+		return err
+	}
+	if err := writeDelim(w, ','); err != nil {
+		return err
+	}
+	if err := list(aligns).write(w); err != nil {
+		return err
+	}
+	if _, err := w.Write(append([]byte{','}, widths...)); err != nil {
+		return err
+	}
+	if err := writeDelim(w, ','); err != nil {
+		return err
+	}
+	if err := list(head).write(w); err != nil {
+		return err
+	}
+	if err := writeDelim(w, ','); err != nil {
+		return err
+	}
+	if err := dlist(rows).write(w); err != nil {
+		return err
+	}
+	return writeDelim(w, ']')
+}
+
+// returns n cells of a row, each cell spanning several columns followed
+// by empty ones
+func legacyRow(r *TableRow, n int) []l[Block] {
+	cells := make([]l[Block], 0, n)
+	for _, c := range r.Cells {
+		cells = append(cells, list(c.Blocks))
+		for i := 1; i < c.ColSpan; i++ {
+			cells = append(cells, l[Block]{})
+		}
+	}
+	for len(cells) < n {
+		cells = append(cells, l[Block]{})
+	}
+	return cells[:n]
+}
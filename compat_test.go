@@ -0,0 +1,86 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteVersion(t *testing.T) {
+	const src = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[` +
+		`{"t":"Figure","c":[["fig",[],[]],[null,[{"t":"Plain","c":[{"t":"Str","c":"cap"}]}]],[{"t":"Plain","c":[{"t":"Image","c":[["",[],[]],[],["a.png",""]]}]}]]},` +
+		`{"t":"Figure","c":[["",["wide"],[]],[null,[]],[{"t":"Para","c":[{"t":"Underline","c":[{"t":"Str","c":"u"}]}]}]]},` +
+		`{"t":"Table","c":[["",[],[]],[null,[]],[[{"t":"AlignLeft"},{"t":"ColWidthDefault"}],[{"t":"AlignDefault"},{"t":"ColWidth","c":0.5}]],` +
+		`[["",[],[]],[[["",[],[]],[[["",[],[]],{"t":"AlignDefault"},1,1,[{"t":"Plain","c":[{"t":"Str","c":"h"}]}]]]]]],` +
+		`[[["",[],[]],0,[],[[["",[],[]],[[["",[],[]],{"t":"AlignDefault"},1,2,[{"t":"Plain","c":[{"t":"Str","c":"c"}]}]]]]]]],` +
+		`[["",[],[]],[]]]}]}`
+	doc, err := ReadFrom(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		version, want string
+	}{
+		{"", src},
+		{"1.23", strings.Replace(src, "[1,23,1]", "[1,23]", 1)},
+		{"1.22.2.1", `{"pandoc-api-version":[1,22,2,1],"meta":{},"blocks":[` +
+			`{"t":"Para","c":[{"t":"Image","c":[["fig",[],[]],[{"t":"Str","c":"cap"}],["a.png","fig:"]]}]},` +
+			`{"t":"Div","c":[["",["figure","wide"],[]],[{"t":"Para","c":[{"t":"Underline","c":[{"t":"Str","c":"u"}]}]}]]},` +
+			src[strings.Index(src, `{"t":"Table"`):]},
+		{"1.20", `{"pandoc-api-version":[1,20],"meta":{},"blocks":[` +
+			`{"t":"Para","c":[{"t":"Image","c":[["fig",[],[]],[{"t":"Str","c":"cap"}],["a.png","fig:"]]}]},` +
+			`{"t":"Div","c":[["",["figure","wide"],[]],[{"t":"Para","c":[{"t":"Span","c":[["",["underline"],[]],[{"t":"Str","c":"u"}]]}]}]]},` +
+			`{"t":"Table","c":[[],[{"t":"AlignLeft"},{"t":"AlignDefault"}],[0,0.5],[[{"t":"Plain","c":[{"t":"Str","c":"h"}]}],[]],[[[{"t":"Plain","c":[{"t":"Str","c":"c"}]}],[]]]]}]}`},
+	} {
+		var b strings.Builder
+		if err := (WriteOptions{Version: tt.version}).Write(&b, doc); err != nil {
+			t.Fatalf("%s: %s", tt.version, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.version, b.String(), tt.want)
+		}
+	}
+	if Sprint(doc) != src {
+		t.Error("document is modified")
+	}
+	for _, v := range []string{"1", "1.16", "1.24", "2.0"} {
+		if err := (WriteOptions{Version: v}).Write(&strings.Builder{}, doc); err == nil {
+			t.Errorf("%s: no error", v)
+		}
+	}
+}
+
+func TestReadLegacyTable(t *testing.T) {
+	const src = `{"pandoc-api-version":[1,20],"meta":{},"blocks":[{"t":"Table","c":[[{"t":"Str","c":"cap"}],` +
+		`[{"t":"AlignLeft"},{"t":"AlignDefault"}],[0,0.5],[[{"t":"Plain","c":[{"t":"Str","c":"h"}]}],[]],` +
+		`[[[{"t":"Plain","c":[{"t":"Str","c":"a"}]}],[{"t":"Plain","c":[{"t":"Str","c":"b"}]}]],[[],[]]]]}]}`
+	doc, err := ReadFrom(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, ok := doc.Blocks[0].(*Table)
+	if !ok {
+		t.Fatalf("expected table, got %#v", doc.Blocks[0])
+	}
+	if len(table.Aligns) != 2 || !table.Aligns[0].Width.Default || table.Aligns[1].Width.Width != 0.5 {
+		t.Errorf("unexpected columns %v", table.Aligns)
+	}
+	if len(table.Head.Rows) != 1 || len(table.Bodies) != 1 || len(table.Bodies[0].Body) != 2 {
+		t.Errorf("unexpected rows %#v", table)
+	}
+	if got := Stringify(table); got != "cap\nh\na\nb" {
+		t.Errorf("unexpected content %q", got)
+	}
+	if got := Sprint(doc); got != src {
+		t.Errorf("got %s, want %s", got, src)
+	}
+	var b strings.Builder
+	if err := (WriteOptions{Version: Version}).Write(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err = ReadFrom(strings.NewReader(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	if got := Stringify(doc); got != "cap\nh\na\nb" {
+		t.Errorf("unexpected content %q", got)
+	}
+}
@@ -0,0 +1,149 @@
+package pandoc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Returns one document combined from the documents, e.g. a book from its
+// chapters, without changing them. Its blocks are the blocks of the
+// documents in order. Its metadata has the fields of all documents, a
+// field of several documents has the value of the first one. The document
+// has the current API version. Nil documents are skipped.
+//
+// An identifier of an element defined in an earlier document is renamed
+// in the later one to the identifier followed by "-1", "-2" and so on, the
+// first one not used in any document, see IdentAllocator. References to
+// it, see RenameIdents, are renamed in the document defining it, so they
+// keep pointing to the same element; references within other documents
+// point to the element of the first one.
+//
+// Example:
+//
+//	book := pandoc.Concat(chapters...)
+//	book.Meta.Set("title", pandoc.MetaString(title))
+func Concat(docs ...*Pandoc) *Pandoc {
+	var used IdentAllocator
+	for _, doc := range docs {
+		if doc != nil {
+			for _, id := range idents(doc) {
+				used.Reserve(id)
+			}
+		}
+	}
+	defined := make(map[string]bool)
+	res := &Pandoc{}
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		ids := idents(doc)
+		renames := make(map[string]string)
+		for _, id := range ids {
+			if defined[id] && renames[id] == "" {
+				renames[id] = used.Ident(id)
+			}
+		}
+		for _, id := range ids {
+			if r, ok := renames[id]; ok {
+				id = r
+			}
+			defined[id] = true
+		}
+		if len(renames) > 0 {
+			doc = RenameIdents(doc, renames)
+		}
+		for _, e := range doc.Meta {
+			if res.Meta.Get(e.Key) == nil {
+				res.Meta = append(res.Meta, e)
+			}
+		}
+		res.Blocks = append(res.Blocks, doc.Blocks...)
+	}
+	return res
+}
+
+// Splits the document into sections, e.g. chapters for chunked HTML or
+// EPUB. A section starts at a top-level header of the level or a higher
+// one (a lower Level) and has the metadata of the document with the
+// header text as "title". Blocks before the first header are a section
+// of their own, with the metadata as is, if any. Headers nested in other
+// blocks, such as Divs, do not start sections. The level is between 1
+// and 6.
+//
+// Concat of the sections has the blocks of the document. It is the
+// document if the document has blocks before the first header and unique
+// identifiers; otherwise its "title" is the one of the first section.
+//
+// Example:
+//
+//	chapters, err := pandoc.Split(doc, 1)
+func Split(doc *Pandoc, level int) ([]*Pandoc, error) {
+	if level < 1 || level > 6 {
+		return nil, fmt.Errorf("split: header level %d is not between 1 and 6", level)
+	}
+	var (
+		docs  []*Pandoc
+		start int
+	)
+	section := func(end int) {
+		if end == start {
+			return
+		}
+		sec := &Pandoc{Version: doc.Version, Meta: slices.Clone(doc.Meta), Blocks: doc.Blocks[start:end:end]}
+		if h, ok := decodedBlock(sec.Blocks[0]).(*Header); ok && h.Level <= level {
+			sec.Meta.SetInlines("title", h.Inlines...)
+		}
+		docs = append(docs, sec)
+		start = end
+	}
+	for i, b := range doc.Blocks {
+		if h, ok := decodedBlock(b).(*Header); ok && h.Level <= level {
+			section(i)
+		}
+	}
+	section(len(doc.Blocks))
+	return docs, nil
+}
+
+// Returned, wrapped, by ExtractSection if the document has no header
+// with the identifier.
+var ErrNoSection = errors.New("no such section")
+
+// Returns a document of the section of the header with the identifier:
+// the header and the blocks following it up to the next header of the
+// same or a higher level among its siblings, which may be nested, e.g.
+// in a Div. The document has the metadata of doc with the header text as
+// "title", see Split. The document is a deep copy, see DeepClone, so
+// changing it does not change doc.
+//
+// Example:
+//
+//	sec, err := pandoc.ExtractSection(doc, "installation")
+func ExtractSection(doc *Pandoc, ident string) (*Pandoc, error) {
+	var section []Block
+	QueryE(doc, func(blocks []Block) error {
+		for i, b := range blocks {
+			h, ok := b.(*Header)
+			if !ok || h.Ident() != ident {
+				continue
+			}
+			end := i + 1
+			for ; end < len(blocks); end++ {
+				if next, ok := blocks[end].(*Header); ok && next.Level <= h.Level {
+					break
+				}
+			}
+			section = cloneList(blocks[i:end])
+			return Halt
+		}
+		return nil
+	})
+	if section == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoSection, ident)
+	}
+	sec := &Pandoc{Version: doc.Version, Meta: cloneList(doc.Meta), Blocks: section}
+	sec.Meta.SetInlines("title", section[0].(*Header).Inlines...)
+	return sec, nil
+}
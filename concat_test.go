@@ -0,0 +1,166 @@
+package pandoc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConcat(t *testing.T) {
+	chapter := func(title string, ids ...string) *Pandoc {
+		doc := &Pandoc{Meta: Meta{{Key: "title", Value: MetaString(title)}}}
+		for _, id := range ids {
+			doc.Blocks = append(doc.Blocks,
+				&Header{Attr: Attr{Id: id}, Level: 1, Inlines: words(id)},
+				&Para{Inlines: []Inline{&Link{Inlines: words("see"), Target: Target{Url: "#" + id}}}})
+		}
+		return doc
+	}
+	ch1 := chapter("one", "intro", "usage")
+	ch2 := chapter("two", "intro", "intro-1")
+	ch2.Meta.SetString("author", "me")
+	ch3 := chapter("three", "intro")
+	before := Sprint(ch2)
+	book := Concat(ch1, nil, ch2, ch3)
+	if Sprint(ch2) != before {
+		t.Errorf("document is changed: %s", Sprint(ch2))
+	}
+	var ids, links []string
+	Query(book, func(h *Header) { ids = append(ids, h.Ident()) })
+	Query(book, func(l *Link) { links = append(links, l.Target.Url) })
+	want := []string{"intro", "usage", "intro-2", "intro-1", "intro-3"}
+	if len(ids) != len(want) {
+		t.Fatalf("got identifiers %q, want %q", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] || links[i] != "#"+want[i] {
+			t.Errorf("got identifiers %q and links %q, want %q", ids, links, want)
+			break
+		}
+	}
+	if got := Sprint(book.Meta.Get("title")); got != Sprint(MetaString("one")) {
+		t.Errorf("got title %s, want the one of the first document", got)
+	}
+	if book.Meta.Get("author") == nil || len(book.Meta) != 2 {
+		t.Errorf("metadata is not merged: %s", Sprint(book))
+	}
+}
+
+func TestSplitSections(t *testing.T) {
+	header := func(level int, text string) Block {
+		return &Header{Attr: Attr{Id: StringToIdent(text)}, Level: level, Inlines: words(text)}
+	}
+	doc := &Pandoc{Meta: Meta{{Key: "title", Value: &MetaInlines{words("book")}}, {Key: "lang", Value: MetaString("en")}}, Blocks: []Block{
+		&Para{Inlines: words("preface")},
+		header(1, "part one"),
+		header(2, "chapter one"),
+		&Para{Inlines: words("a")},
+		header(2, "chapter two"),
+		&Div{Blocks: []Block{header(1, "nested")}},
+		header(1, "part two"),
+	}}
+	before := Sprint(doc)
+	secs, err := Split(doc, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(doc) != before {
+		t.Errorf("document is changed: %s", Sprint(doc))
+	}
+	titles := []string{"book", "part one", "chapter one", "chapter two", "part two"}
+	if len(secs) != len(titles) {
+		t.Fatalf("got %d sections, want %d", len(secs), len(titles))
+	}
+	for i, sec := range secs {
+		if got := Stringify(sec.Meta.Get("title")); got != titles[i] {
+			t.Errorf("section %d has title %q, want %q", i, got, titles[i])
+		}
+		if sec.Meta.Get("lang") == nil {
+			t.Errorf("section %d has no inherited metadata", i)
+		}
+	}
+	if got := Concat(secs...); !Equal(got, doc) {
+		t.Errorf("sections do not make the document: %s", Sprint(got))
+	}
+	// without blocks before the first header the title is of the first one
+	body := &Pandoc{Meta: doc.Meta, Blocks: doc.Blocks[1:]}
+	secs, _ = Split(body, 2)
+	if got := Concat(secs...); !Equal(&Pandoc{Blocks: got.Blocks}, &Pandoc{Blocks: body.Blocks}) || Stringify(got.Meta.Get("title")) != "part one" {
+		t.Errorf("sections do not make the blocks: %s", Sprint(got))
+	}
+	if secs, _ := Split(&Pandoc{}, 1); len(secs) != 0 {
+		t.Errorf("got %d sections of an empty document", len(secs))
+	}
+	if _, err := Split(doc, 0); err == nil {
+		t.Error("no error splitting at level 0")
+	}
+}
+
+func TestSplitLazy(t *testing.T) {
+	doc, err := ReadOptions{Lazy: true}.ReadBytes([]byte(`{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[` +
+		`{"t":"Para","c":[{"t":"Str","c":"preface"}]},` +
+		`{"t":"Header","c":[1,["a",[],[]],[{"t":"Str","c":"A"}]]},` +
+		`{"t":"Para","c":[{"t":"Str","c":"x"}]},` +
+		`{"t":"Header","c":[1,["b",[],[]],[{"t":"Str","c":"B"}]]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secs, err := Split(doc, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{"A", "B"}
+	if len(secs) != len(titles)+1 {
+		t.Fatalf("got %d sections, want %d", len(secs), len(titles)+1)
+	}
+	if secs[0].Meta.Get("title") != nil {
+		t.Errorf("preface has title %s", Sprint(secs[0]))
+	}
+	for i, sec := range secs[1:] {
+		if got := Stringify(sec.Meta.Get("title")); got != titles[i] {
+			t.Errorf("section %d has title %q, want %q", i+1, got, titles[i])
+		}
+	}
+}
+
+func TestExtractSection(t *testing.T) {
+	header := func(level int, id string) Block {
+		return &Header{Attr: Attr{Id: id}, Level: level, Inlines: words(id)}
+	}
+	doc := &Pandoc{Meta: Meta{{Key: "lang", Value: MetaString("en")}}, Blocks: []Block{
+		header(1, "one"),
+		header(2, "sub"),
+		&Para{Inlines: words("a")},
+		header(3, "subsub"),
+		header(2, "next"),
+		&Div{Blocks: []Block{header(2, "nested"), &Para{Inlines: words("b")}, header(1, "end")}},
+	}}
+	for _, tt := range []struct {
+		ident string
+		want  []Block
+	}{
+		{"one", doc.Blocks},
+		{"sub", doc.Blocks[1:4]},
+		{"next", doc.Blocks[4:6]},
+		{"nested", doc.Blocks[5].(*Div).Blocks[:2]},
+	} {
+		sec, err := ExtractSection(doc, tt.ident)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := Sprint(&Pandoc{Blocks: sec.Blocks}), Sprint(&Pandoc{Blocks: tt.want}); got != want {
+			t.Errorf("section %s is %s, want %s", tt.ident, got, want)
+		}
+		if got := Stringify(sec.Meta.Get("title")); got != tt.ident || sec.Meta.Get("lang") == nil {
+			t.Errorf("section %s has metadata %s", tt.ident, Sprint(sec))
+		}
+	}
+	before := Sprint(doc)
+	sec, _ := ExtractSection(doc, "sub")
+	Query(sec, func(s *Str) { s.Text = "changed" })
+	if Sprint(doc) != before {
+		t.Errorf("changing the section changes the document: %s", Sprint(doc))
+	}
+	if _, err := ExtractSection(doc, "missing"); !errors.Is(err, ErrNoSection) {
+		t.Errorf("got error %v for a missing section, want ErrNoSection", err)
+	}
+}
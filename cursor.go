@@ -0,0 +1,177 @@
+package pandoc
+
+import (
+	"reflect"
+)
+
+// Position of an element visited by QueryCursor: the element, its
+// ancestors and the list containing it. A cursor is only valid during
+// the call it is passed to.
+//
+// Changes depending on context can be made by collecting the paths of
+// elements to change and replacing them with Set.
+type Cursor struct {
+	frames []cursorFrame // the root, ancestors and the element
+}
+
+type cursorFrame struct {
+	elt   Element
+	path  ElementPath
+	list  reflect.Value // list containing the element, if any
+	index int
+}
+
+func (c *Cursor) top() *cursorFrame {
+	return &c.frames[len(c.frames)-1]
+}
+
+// Returns the element.
+func (c *Cursor) Element() Element {
+	return c.top().elt
+}
+
+// Returns the path of the element relative to the queried element.
+func (c *Cursor) Path() ElementPath {
+	return c.top().path
+}
+
+// Returns the nearest ancestor element. The queried element is the
+// parent of its children.
+func (c *Cursor) Parent() Element {
+	return c.frames[len(c.frames)-2].elt
+}
+
+// Returns ancestors of the element, starting from the parent up to the
+// queried element.
+func (c *Cursor) Ancestors() []Element {
+	ancestors := make([]Element, len(c.frames)-1)
+	for i := range ancestors {
+		ancestors[i] = c.frames[len(c.frames)-2-i].elt
+	}
+	return ancestors
+}
+
+// Returns the index of the element in the containing list, -1 if the
+// element is not in a list (e.g. a table head or a metadata value).
+func (c *Cursor) Index() int {
+	return c.top().index
+}
+
+// Returns the list containing the element, nil if the element is not in
+// a list.
+func (c *Cursor) List() []Element {
+	f := c.top()
+	if !f.list.IsValid() {
+		return nil
+	}
+	lst := make([]Element, f.list.Len())
+	for i := range lst {
+		lst[i], _ = asElement(f.list.Index(i))
+	}
+	return lst
+}
+
+// Returns the nearest ancestor of type P of the element under cursor c.
+//
+// Example:
+//
+//	pandoc.QueryCursor(doc, func(s *pandoc.Str, c *pandoc.Cursor) {
+//	    if div, ok := pandoc.Ancestor[*pandoc.Div](c); ok && div.HasClass("note") {
+//	        ...
+//	    }
+//	})
+func Ancestor[P any](c *Cursor) (P, bool) {
+	for i := len(c.frames) - 2; i >= 0; i-- {
+		if p, ok := c.frames[i].elt.(P); ok {
+			return p, true
+		}
+	}
+	var zero P
+	return zero, false
+}
+
+// Works as Query, additionally passing fun the position of the element.
+func QueryCursor[P any, E Element](elt E, fun func(P, *Cursor)) {
+	c := &Cursor{frames: []cursorFrame{{elt: elt, index: -1}}}
+	each(reflect.ValueOf(elt), "", reflect.Value{}, -1, c, func(c *Cursor) {
+		if p, ok := c.Element().(P); ok {
+			fun(p, c)
+		}
+	})
+}
+
+// calls fun for elements of v at path and its descendants. An element
+// v is at index of list.
+func each(v reflect.Value, path ElementPath, list reflect.Value, index int, c *Cursor, fun func(*Cursor)) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		} else if v.Elem().Kind() != reflect.Pointer {
+			// MetaString and MetaBool
+			if elt, ok := asElement(v); ok {
+				c.frames = append(c.frames, cursorFrame{elt, path, list, index})
+				fun(c)
+				c.frames = c.frames[:len(c.frames)-1]
+			}
+			return
+		}
+		each(v.Elem(), path, list, index, c, fun)
+		return
+	case reflect.Slice:
+		if meta, ok := v.Interface().(Meta); ok {
+			for i := range meta {
+				each(v.Index(i).Field(1), path.Append(meta[i].Key), reflect.Value{}, -1, c, fun)
+			}
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			each(v.Index(i), path.Append(i), v, i, c, fun)
+		}
+		return
+	case reflect.Struct:
+		if !v.CanAddr() {
+			return
+		}
+		v = v.Addr()
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+	default:
+		return
+	}
+	x := v.Interface()
+	if lb, ok := x.(*LazyBlock); ok {
+		if decoded, err := lb.Decode(); err == nil {
+			each(reflect.ValueOf(decoded), path, list, index, c, fun)
+		}
+		return
+	}
+	if elt, ok := x.(Element); ok && path != "" {
+		c.frames = append(c.frames, cursorFrame{elt, path, list, index})
+		fun(c)
+		defer func() { c.frames = c.frames[:len(c.frames)-1] }()
+	}
+	if _, ok := x.(Tagged); ok {
+		p, single := parts(x)
+		if single {
+			each(reflect.ValueOf(p[0]).Elem(), path.Append("c"), reflect.Value{}, -1, c, fun)
+			return
+		}
+		for i := range p {
+			each(reflect.ValueOf(p[i]).Elem(), path.Append("c").Append(i), reflect.Value{}, -1, c, fun)
+		}
+	} else if cit, ok := x.(*Citation); ok {
+		each(reflect.ValueOf(&cit.Prefix).Elem(), path.Append("citationPrefix"), reflect.Value{}, -1, c, fun)
+		each(reflect.ValueOf(&cit.Suffix).Elem(), path.Append("citationSuffix"), reflect.Value{}, -1, c, fun)
+	} else if pd, ok := x.(*Pandoc); ok {
+		each(reflect.ValueOf(&pd.Meta).Elem(), path.Append("meta"), reflect.Value{}, -1, c, fun)
+		each(reflect.ValueOf(&pd.Blocks).Elem(), path.Append("blocks"), reflect.Value{}, -1, c, fun)
+	} else {
+		p, _ := parts(x)
+		for i := range p {
+			each(reflect.ValueOf(p[i]).Elem(), path.Append(i), reflect.Value{}, -1, c, fun)
+		}
+	}
+}
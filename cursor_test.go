@@ -0,0 +1,51 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueryCursor(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Inlines: words("a b")},
+		&Div{Attr: Attr{Classes: []string{"note"}}, Blocks: []Block{
+			&Header{Level: 2, Inlines: words("c")},
+			&Para{Inlines: words("d")},
+		}},
+	}}
+	var got []string
+	QueryCursor(doc, func(s *Str, c *Cursor) {
+		if c.Element() != Element(s) {
+			t.Errorf("cursor element %v, want %v", c.Element(), s)
+		}
+		var tags []string
+		for _, a := range c.Ancestors() {
+			if tagged, ok := a.(Tagged); ok {
+				tags = append(tags, string(tagged.Tag()))
+			}
+		}
+		_, inNote := Ancestor[*Div](c)
+		got = append(got, strings.Join([]string{
+			s.Text, strings.Join(tags, "<"), string(c.Path()),
+			string(c.Parent().(Tagged).Tag()), strings.Repeat("+", c.Index()), strings.Repeat("s", len(c.List())),
+			map[bool]string{true: "note"}[inNote],
+		}, " "))
+	})
+	want := []string{
+		"a Header /blocks/0/c/2/0 Header  sss ",
+		"b Header /blocks/0/c/2/2 Header ++ sss ",
+		"c Header<Div /blocks/1/c/1/0/c/2/0 Header  s note",
+		"d Para<Div /blocks/1/c/1/1/c/0 Para  s note",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	var parents []Element
+	QueryCursor(doc, func(b Block, c *Cursor) {
+		parents = append(parents, c.Parent())
+	})
+	if len(parents) != 4 || parents[0] != Element(doc) || parents[2] != Element(doc.Blocks[1]) {
+		t.Errorf("unexpected parents %v", parents)
+	}
+}
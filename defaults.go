@@ -0,0 +1,256 @@
+package pandoc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Returns a Conf reading options from the pandoc defaults file at path.
+// Options of the Conf take precedence over ones of the file.
+func (c Conf) WithDefaults(path string) Conf {
+	return c.WithOpt("defaults", path)
+}
+
+// Writes the Conf used for dir, either Reading or Writing, as a pandoc
+// defaults file in YAML. With the format as "from" or "to", the defaults
+// file replaces the format, its extensions and options on the command
+// line. Options with no counterpart in defaults files are errors.
+func (c Conf) WriteDefaults(w io.Writer, dir Direction) error {
+	var d defaults
+	switch dir {
+	case Reading:
+		d.set("from", c.Format+strings.Join(c.Ext, ""))
+	case Writing:
+		d.set("to", c.Format+strings.Join(c.Ext, ""))
+	default:
+		return errors.New("defaults: direction is either Reading or Writing")
+	}
+	if err := d.conf(&c); err != nil {
+		return err
+	}
+	return d.write(w)
+}
+
+// Writes the invocation as a pandoc defaults file in YAML, see
+// Conf.WriteDefaults.
+func (inv *Invocation) WriteDefaults(w io.Writer) error {
+	var d defaults
+	d.set("from", inv.From.Format+strings.Join(inv.From.Ext, ""))
+	d.set("to", inv.To.Format+strings.Join(inv.To.Ext, ""))
+	for _, f := range inv.Inputs {
+		d.add("input-files", f)
+	}
+	if inv.Output != "" {
+		d.set("output-file", inv.Output)
+	}
+	if err := d.conf(&inv.From); err != nil {
+		return err
+	} else if err := d.conf(&inv.To); err != nil {
+		return err
+	}
+	return d.write(w)
+}
+
+// defaults file keys of options, if named differently
+var defaultsKeys = map[string]string{
+	"toc":            "table-of-contents",
+	"output":         "output-file",
+	"log":            "log-file",
+	"metadata-file":  "metadata-files",
+	"pdf-engine-opt": "pdf-engine-opts",
+}
+
+// options with a list of values in defaults files
+var defaultsLists = map[string]bool{
+	"defaults": true, "metadata-files": true, "bibliography": true, "css": true,
+	"include-in-header": true, "include-before-body": true, "include-after-body": true,
+	"pdf-engine-opts": true, "resource-path": true, "epub-embed-font": true,
+}
+
+// an ordered YAML mapping; values are strings, lists of strings,
+// mappings, filters and raw YAML
+type defaults struct {
+	keys []string
+	vals map[string]any
+}
+
+type defaultsFilter struct{ typ, path string }
+
+// YAML, e.g. JSON
+type yamlRaw string
+
+func (d *defaults) set(key string, val any) {
+	if d.vals == nil {
+		d.vals = make(map[string]any)
+	}
+	if _, ok := d.vals[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.vals[key] = val
+}
+
+// adds val to the list under key unless it is already there
+func (d *defaults) add(key string, val any) {
+	l, _ := d.vals[key].([]any)
+	for _, v := range l {
+		if v == val {
+			return
+		}
+	}
+	d.set(key, append(l, val))
+}
+
+func (d *defaults) sub(key string) *defaults {
+	if m, ok := d.vals[key].(*defaults); ok {
+		return m
+	}
+	m := &defaults{}
+	d.set(key, m)
+	return m
+}
+
+// adds options of c, writing its metadata file if any
+func (d *defaults) conf(c *Conf) error {
+	if err := d.opts(c.Opts); err != nil {
+		return err
+	}
+	if len(c.meta) > 0 {
+		if err := c.writeMetaFile(); err != nil {
+			return err
+		}
+		path, _ := c.metaFile()
+		d.add("metadata-files", path)
+	}
+	return nil
+}
+
+// adds command line options
+func (d *defaults) opts(opts []string) error {
+	for i := 0; i < len(opts); i++ {
+		arg := opts[i]
+		var (
+			name, val string
+			hasVal    bool
+		)
+		if strings.HasPrefix(arg, "--") {
+			name, val, hasVal = strings.Cut(arg[2:], "=")
+		} else if len(arg) > 1 && arg[0] == '-' && shortOpts[arg[1]] != "" {
+			name, val, hasVal = shortOpts[arg[1]], arg[2:], len(arg) > 2
+		} else {
+			return fmt.Errorf("defaults: unknown pandoc option %q", arg)
+		}
+		opt, ok := lookupOpt(name)
+		if !ok {
+			return fmt.Errorf("defaults: unknown pandoc option %s", arg)
+		}
+		if opt.arg == requiredArg && !hasVal {
+			if i++; i == len(opts) {
+				return fmt.Errorf("defaults: pandoc option %s requires an argument", arg)
+			}
+			val = opts[i]
+		}
+		key := name
+		if k, ok := defaultsKeys[name]; ok {
+			key = k
+		}
+		switch {
+		case name == "metadata" || name == "variable":
+			// a key alone is true
+			k, v, ok := splitField(val)
+			if !ok {
+				v = "true"
+			}
+			if name == "variable" {
+				key = "variables"
+			}
+			d.sub(key).set(k, v)
+		case name == "variable-json":
+			k, v, _ := splitField(val)
+			d.sub("variables").set(k, yamlRaw(v))
+		case name == "filter":
+			d.add("filters", defaultsFilter{"json", val})
+		case name == "lua-filter":
+			d.add("filters", defaultsFilter{"lua", val})
+		case name == "mathjax" || name == "katex" || name == "webtex" || name == "mathml" || name == "gladtex":
+			m := &defaults{}
+			m.set("method", name)
+			if val != "" {
+				m.set("url", val)
+			}
+			d.set("html-math-method", m)
+		case name == "no-highlight":
+			d.set("highlight-style", nil)
+		case name == "verbose":
+			d.set("verbosity", "INFO")
+		case name == "quiet":
+			d.set("verbosity", "ERROR")
+		case name == "request-header", name == "from", name == "read", name == "to", name == "write":
+			return fmt.Errorf("defaults: pandoc option %s is not supported", arg)
+		case name == "resource-path":
+			for _, p := range filepath.SplitList(val) {
+				d.add(key, p)
+			}
+		case defaultsLists[key]:
+			d.add(key, val)
+		case opt.arg == noArg:
+			d.set(key, "true")
+		default:
+			d.set(key, val)
+		}
+	}
+	return nil
+}
+
+func (d *defaults) write(w io.Writer) error {
+	var sb strings.Builder
+	d.writeTo(&sb, "")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (d *defaults) writeTo(sb *strings.Builder, indent string) {
+	for _, k := range d.keys {
+		sb.WriteString(indent + yamlScalar(k) + ":")
+		switch v := d.vals[k].(type) {
+		case nil:
+			sb.WriteString(" null\n")
+		case string:
+			sb.WriteString(" " + yamlScalar(v) + "\n")
+		case yamlRaw:
+			sb.WriteString(" " + string(v) + "\n")
+		case *defaults:
+			sb.WriteString("\n")
+			v.writeTo(sb, indent+"  ")
+		case []any:
+			sb.WriteString("\n")
+			for _, e := range v {
+				switch e := e.(type) {
+				case defaultsFilter:
+					sb.WriteString(indent + "  - type: " + e.typ + "\n")
+					sb.WriteString(indent + "    path: " + yamlScalar(e.path) + "\n")
+				case string:
+					sb.WriteString(indent + "  - " + yamlScalar(e) + "\n")
+				}
+			}
+		}
+	}
+}
+
+// returns s as a plain YAML scalar if it is safe, quoted otherwise;
+// integers and booleans are kept plain to be read as such
+func yamlScalar(s string) string {
+	if _, err := strconv.Atoi(s); err == nil || s == "true" || s == "false" {
+		return s
+	}
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_+./-") == "" &&
+		!strings.HasPrefix(s, "-") && s != "null" && s != "yes" && s != "no" && s != "on" && s != "off" {
+		return s
+	}
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,75 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteDefaults(t *testing.T) {
+	conf := Format("markdown").WithExt("smart").
+		WithMetadata("title", "A: B").WithOpt("metadata", "draft").
+		WithLuaFilter("a.lua").WithFilter("b").WithLuaFilter("a.lua").
+		WithBibliography("refs.bib").WithOpt("shift-heading-level-by", "1").WithDefaults("base.yaml")
+	var sb strings.Builder
+	if err := conf.WriteDefaults(&sb, Reading); err != nil {
+		t.Fatal(err)
+	}
+	want := `from: markdown+smart
+metadata:
+  title: "A: B"
+  draft: true
+filters:
+  - type: lua
+    path: a.lua
+  - type: json
+    path: b
+bibliography:
+  - refs.bib
+shift-heading-level-by: 1
+defaults:
+  - base.yaml
+`
+	if got := sb.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	inv, err := ParseCommandLine(strings.Fields("-s --toc -V geometry:margin=1in --no-highlight --mathjax -o out.html -d x.yaml in.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb.Reset()
+	if err := inv.WriteDefaults(&sb); err != nil {
+		t.Fatal(err)
+	}
+	want = `from: markdown
+to: html
+input-files:
+  - in.md
+output-file: out.html
+defaults:
+  - x.yaml
+standalone: true
+table-of-contents: true
+variables:
+  geometry: "margin=1in"
+highlight-style: null
+html-math-method:
+  method: mathjax
+`
+	if got := sb.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	for _, conf := range []Conf{
+		Format("html").WithOpt("request-header", "A:b"),
+		Format("html").WithOpt("no-such-option"),
+		Format("html").WithOpt("to", "docx"),
+	} {
+		if err := conf.WriteDefaults(&sb, Writing); err == nil {
+			t.Errorf("%v: no error", conf.Opts)
+		}
+	}
+	if err := Format("html").WriteDefaults(&sb, Reading|Writing); err == nil {
+		t.Error("no error for both directions")
+	}
+}
@@ -0,0 +1,331 @@
+package pandoc
+
+import "errors"
+
+// Kind of an edit operation produced by Diff.
+type DiffOp int
+
+const (
+	DiffEqual  DiffOp = iota // Element is present in both lists
+	DiffDelete               // Element is present only in the old list
+	DiffInsert               // Element is present only in the new list
+)
+
+func (o DiffOp) String() string {
+	switch o {
+	case DiffEqual:
+		return "="
+	case DiffDelete:
+		return "-"
+	case DiffInsert:
+		return "+"
+	default:
+		return "?"
+	}
+}
+
+// An edit operation of a list diff.
+type Edit[T Element] struct {
+	Op  DiffOp
+	Old int // Index of the element in the old list, -1 for insertions
+	New int // Index of the element in the new list, -1 for deletions
+	Elt T   // The element (from the new list if present in both)
+}
+
+// DiffList computes the shortest edit script transforming list a into
+// list b. Elements are compared structurally, by their JSON encoding.
+// The result enumerates every element of both lists in order: running
+// through it and taking DiffEqual and DiffInsert elements yields b,
+// taking DiffEqual and DiffDelete elements yields a.
+//
+// Example:
+//
+//	for _, e := range pandoc.DiffList(old.Blocks, new.Blocks) {
+//	    if e.Op != pandoc.DiffEqual {
+//	        fmt.Println(e.Op, pandoc.Stringify(e.Elt))
+//	    }
+//	}
+func DiffList[T Element](a, b []T) []Edit[T] {
+	// map each distinct element encoding to a small integer
+	var (
+		keys = make(map[string]int)
+		ka   = make([]int, len(a))
+		kb   = make([]int, len(b))
+	)
+	key := func(e T) int {
+		s := Sprint(e)
+		if k, ok := keys[s]; ok {
+			return k
+		}
+		keys[s] = len(keys)
+		return len(keys) - 1
+	}
+	for i := range a {
+		ka[i] = key(a[i])
+	}
+	for i := range b {
+		kb[i] = key(b[i])
+	}
+	ops := myers(ka, kb)
+	edits := make([]Edit[T], len(ops))
+	var x, y int
+	for i, op := range ops {
+		switch op {
+		case DiffEqual:
+			edits[i] = Edit[T]{op, x, y, b[y]}
+			x++
+			y++
+		case DiffDelete:
+			edits[i] = Edit[T]{op, x, -1, a[x]}
+			x++
+		case DiffInsert:
+			edits[i] = Edit[T]{op, -1, y, b[y]}
+			y++
+		}
+	}
+	return edits
+}
+
+// Diff computes the difference between the top-level blocks of two
+// documents. See DiffList for details.
+func Diff(a, b *Pandoc) []Edit[Block] {
+	return DiffList(a.Blocks, b.Blocks)
+}
+
+// implements Myers' O(ND) difference algorithm, returns a sequence of
+// operations, each consuming one element of a (DiffDelete), b (DiffInsert)
+// or both (DiffEqual).
+func myers(a, b []int) []DiffOp {
+	// strip common prefix and suffix
+	var pre, suf int
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	var (
+		ops    = make([]DiffOp, 0, len(a)+len(b))
+		ma, mb = a[pre : len(a)-suf], b[pre : len(b)-suf]
+		n, m   = len(ma), len(mb)
+		max    = n + m
+		off    = max + 1
+		v      = make([]int, 2*max+3)
+		trace  [][]int
+		d      int
+	)
+	for i := 0; i < pre; i++ {
+		ops = append(ops, DiffEqual)
+	}
+search:
+	for d = 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && ma[x] == mb[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+	// backtrack, collecting operations in reverse order
+	var (
+		rev  = make([]DiffOp, 0, n+m)
+		x, y = n, m
+	)
+	for ; d > 0; d-- {
+		prev := trace[d]
+		at := func(k int) int { return prev[k+d] }
+		k := x - y
+		var pk int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			pk = k + 1
+		} else {
+			pk = k - 1
+		}
+		px := at(pk)
+		py := px - pk
+		for x > px && y > py {
+			rev = append(rev, DiffEqual)
+			x--
+			y--
+		}
+		if x == px {
+			rev = append(rev, DiffInsert)
+		} else {
+			rev = append(rev, DiffDelete)
+		}
+		x, y = px, py
+	}
+	for x > 0 && y > 0 {
+		rev = append(rev, DiffEqual)
+		x--
+		y--
+	}
+	for i := len(rev) - 1; i >= 0; i-- {
+		ops = append(ops, rev[i])
+	}
+	for i := 0; i < suf; i++ {
+		ops = append(ops, DiffEqual)
+	}
+	return ops
+}
+
+var ErrPatchMismatch = errors.New("edit script does not match the list")
+
+// Patch applies an edit script produced by DiffList(a, b) to list a and
+// returns b. Unchanged elements are taken from a.
+func Patch[T Element](a []T, edits []Edit[T]) ([]T, error) {
+	var (
+		b = make([]T, 0, len(edits))
+		x int
+	)
+	for _, e := range edits {
+		switch e.Op {
+		case DiffEqual:
+			if e.Old != x || x >= len(a) {
+				return nil, ErrPatchMismatch
+			}
+			b = append(b, a[x])
+			x++
+		case DiffDelete:
+			if e.Old != x || x >= len(a) {
+				return nil, ErrPatchMismatch
+			}
+			x++
+		case DiffInsert:
+			b = append(b, e.Elt)
+		}
+	}
+	if x != len(a) {
+		return nil, ErrPatchMismatch
+	}
+	return b, nil
+}
+
+// Invert returns the edit script reverting edits, i.e. transforming the
+// new list back into the old one.
+func Invert[T Element](edits []Edit[T]) []Edit[T] {
+	inv := make([]Edit[T], len(edits))
+	for i, e := range edits {
+		inv[i] = Edit[T]{e.Op, e.New, e.Old, e.Elt}
+		switch e.Op {
+		case DiffDelete:
+			inv[i].Op = DiffInsert
+		case DiffInsert:
+			inv[i].Op = DiffDelete
+		}
+	}
+	return inv
+}
+
+// Redline returns a document showing the changes from document a to b,
+// e.g. for review copies. Deleted blocks are wrapped in Divs with the
+// "del" class, inserted blocks in Divs with the "ins" class. Changed
+// paragraphs, plain blocks and headers of the same level are compared
+// by inlines: deleted inlines are wrapped in Strikeout, inserted ones in
+// Spans with the "ins" class. The metadata is taken from b.
+func Redline(a, b *Pandoc) *Pandoc {
+	var (
+		res     = &Pandoc{Version: b.Version, Meta: b.Meta}
+		deleted []Block
+		added   []Block
+	)
+	flush := func() {
+		n := min(len(deleted), len(added))
+		for i := 0; i < n; i++ {
+			if blk := redlineBlock(deleted[i], added[i]); blk != nil {
+				res.Blocks = append(res.Blocks, blk)
+			} else {
+				res.Blocks = append(res.Blocks, redlineDiv("del", deleted[i]), redlineDiv("ins", added[i]))
+			}
+		}
+		for _, blk := range deleted[n:] {
+			res.Blocks = append(res.Blocks, redlineDiv("del", blk))
+		}
+		for _, blk := range added[n:] {
+			res.Blocks = append(res.Blocks, redlineDiv("ins", blk))
+		}
+		deleted, added = deleted[:0], added[:0]
+	}
+	for _, e := range Diff(a, b) {
+		switch e.Op {
+		case DiffEqual:
+			flush()
+			res.Blocks = append(res.Blocks, e.Elt)
+		case DiffDelete:
+			deleted = append(deleted, e.Elt)
+		case DiffInsert:
+			added = append(added, e.Elt)
+		}
+	}
+	flush()
+	return res
+}
+
+func redlineDiv(class string, blk Block) Block {
+	return &Div{Attr: Attr{Classes: []string{class}}, Blocks: []Block{blk}}
+}
+
+// returns block b with inline changes from block a marked, or nil if the
+// blocks are not comparable by inlines
+func redlineBlock(a, b Block) Block {
+	switch b := b.(type) {
+	case *Para:
+		if a, ok := a.(*Para); ok {
+			return &Para{Inlines: redlineInlines(a.Inlines, b.Inlines)}
+		}
+	case *Plain:
+		if a, ok := a.(*Plain); ok {
+			return &Plain{Inlines: redlineInlines(a.Inlines, b.Inlines)}
+		}
+	case *Header:
+		if a, ok := a.(*Header); ok && a.Level == b.Level {
+			c := *b
+			c.Inlines = redlineInlines(a.Inlines, b.Inlines)
+			return &c
+		}
+	}
+	return nil
+}
+
+// returns inlines b with changes from a marked
+func redlineInlines(a, b []Inline) []Inline {
+	var (
+		res   []Inline
+		op    = DiffEqual
+		group []Inline
+	)
+	flush := func() {
+		switch op {
+		case DiffDelete:
+			res = append(res, &Strikeout{Inlines: group})
+		case DiffInsert:
+			res = append(res, &Span{Attr: Attr{Classes: []string{"ins"}}, Inlines: group})
+		}
+		group = nil
+	}
+	for _, e := range DiffList(a, b) {
+		if e.Op != op {
+			flush()
+			op = e.Op
+		}
+		if op == DiffEqual {
+			res = append(res, e.Elt)
+		} else {
+			group = append(group, e.Elt)
+		}
+	}
+	flush()
+	return res
+}
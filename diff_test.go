@@ -0,0 +1,119 @@
+package pandoc
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func words(s string) []Inline {
+	var l []Inline
+	for i, w := range strings.Fields(s) {
+		if i > 0 {
+			l = append(l, SP)
+		}
+		l = append(l, &Str{w})
+	}
+	return l
+}
+
+func applyEdits[T Element](edits []Edit[T], op DiffOp) []T {
+	var l []T
+	for _, e := range edits {
+		if e.Op == DiffEqual || e.Op == op {
+			l = append(l, e.Elt)
+		}
+	}
+	return l
+}
+
+func TestDiffList(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		want string
+	}{
+		{"", "", ""},
+		{"a b c", "a b c", "=a,= ,=b,= ,=c"},
+		{"a b c", "a x c", "=a,= ,-b,+x,= ,=c"},
+		{"a b", "", "-a,- ,-b"},
+		{"", "a", "+a"},
+		{"a b c d", "b c d e", "-a,- ,=b,= ,=c,= ,=d,+ ,+e"},
+	}
+	for _, tt := range tests {
+		var r []string
+		for _, e := range DiffList(words(tt.a), words(tt.b)) {
+			r = append(r, e.Op.String()+Stringify(e.Elt))
+		}
+		if got := strings.Join(r, ","); got != tt.want {
+			t.Errorf("DiffList(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiffListRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	gen := func() []Inline {
+		l := make([]Inline, rnd.Intn(30))
+		for i := range l {
+			l[i] = &Str{string(rune('a' + rnd.Intn(4)))}
+		}
+		return l
+	}
+	for i := 0; i < 200; i++ {
+		a, b := gen(), gen()
+		edits := DiffList(a, b)
+		if Sprint(&Para{applyEdits(edits, DiffDelete)}) != Sprint(&Para{a}) {
+			t.Fatalf("edits do not reproduce the old list")
+		}
+		if Sprint(&Para{applyEdits(edits, DiffInsert)}) != Sprint(&Para{b}) {
+			t.Fatalf("edits do not reproduce the new list")
+		}
+	}
+}
+
+func TestStringify(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Inlines: words("A title")},
+		&Para{Inlines: append(words("Some text"), &Note{[]Block{&Para{words("note")}}}, &Code{Text: "x"})},
+	}}
+	if s := Stringify(doc); s != "A title\nSome textx" {
+		t.Errorf("unexpected %q", s)
+	}
+}
+
+func TestRedline(t *testing.T) {
+	a := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Inlines: words("Title")},
+		&Para{Inlines: words("a b c")},
+		&Para{Inlines: words("gone")},
+		&CodeBlock{Text: "x"},
+	}}
+	b := &Pandoc{Meta: Meta{{"title", MetaString("B")}}, Blocks: []Block{
+		&Header{Level: 1, Inlines: words("Title")},
+		&Para{Inlines: words("a x c d")},
+		&CodeBlock{Text: "y"},
+		&HorizontalRule{},
+	}}
+	got := Redline(a, b)
+	want := []string{
+		`{"t":"Header","c":[1,["",[],[]],[{"t":"Str","c":"Title"}]]}`,
+		`{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Space"},{"t":"Strikeout","c":[{"t":"Str","c":"b"}]},` +
+			`{"t":"Span","c":[["",["ins"],[]],[{"t":"Str","c":"x"}]]},{"t":"Space"},{"t":"Str","c":"c"},` +
+			`{"t":"Span","c":[["",["ins"],[]],[{"t":"Space"},{"t":"Str","c":"d"}]]}]}`,
+		`{"t":"Div","c":[["",["del"],[]],[{"t":"Para","c":[{"t":"Str","c":"gone"}]}]]}`,
+		`{"t":"Div","c":[["",["ins"],[]],[{"t":"CodeBlock","c":[["",[],[]],"y"]}]]}`,
+		`{"t":"Div","c":[["",["del"],[]],[{"t":"CodeBlock","c":[["",[],[]],"x"]}]]}`,
+		`{"t":"Div","c":[["",["ins"],[]],[{"t":"HorizontalRule"}]]}`,
+	}
+	if len(got.Blocks) != len(want) {
+		t.Fatalf("got %d blocks, want %d: %s", len(got.Blocks), len(want), Sprint(got))
+	}
+	for i, blk := range got.Blocks {
+		if Sprint(blk) != want[i] {
+			t.Errorf("block %d:\n got %s\nwant %s", i, Sprint(blk), want[i])
+		}
+	}
+	if got.Meta.Get("title") == nil {
+		t.Error("metadata is not taken from the new document")
+	}
+}
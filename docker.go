@@ -0,0 +1,90 @@
+package pandoc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// DockerRunner is a Runner running pandoc in a container, for hosts
+// with docker but without pandoc. The working directory is mounted in
+// the container at the same path, so files given to LoadFiles or
+// StoreFile must be within it. Conf.Pandoc, if set, is the path to
+// pandoc in the container. The memory limit is set for the container.
+//
+// Example:
+//
+//	conf := pandoc.Format("html").WithRunner(pandoc.DockerRunner{Image: "pandoc/core:3.1"})
+type DockerRunner struct {
+	Image  string   // Image with pandoc
+	Docker string   // Path to docker executable, defaults to "docker" in PATH
+	Opts   []string // Additional options of docker run
+}
+
+func (r DockerRunner) Start(ctx context.Context, c *Command) (Process, error) {
+	docker := r.Docker
+	if docker == "" {
+		docker = "docker"
+	}
+	docker, err := exec.LookPath(docker)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	name, err := containerName()
+	if err != nil {
+		return nil, err
+	}
+	pandoc := c.Pandoc
+	if pandoc == "" {
+		pandoc = "pandoc"
+	}
+	args := []string{"run", "--rm", "--name", name, "-v", dir + ":" + dir, "-w", dir, "--entrypoint", pandoc}
+	if c.Input {
+		args = append(args, "-i")
+	}
+	if runtime.GOOS != "windows" {
+		// files written by pandoc are owned by the user
+		args = append(args, "--user", strconv.Itoa(os.Getuid())+":"+strconv.Itoa(os.Getgid()))
+	}
+	for _, env := range c.Env {
+		args = append(args, "-e", env)
+	}
+	l := c.Limits
+	if l.CPU > 0 {
+		cpu := strconv.FormatInt(int64((l.CPU+time.Second-1)/time.Second), 10)
+		args = append(args, "--ulimit", "cpu="+cpu+":"+cpu)
+	}
+	if l.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(l.Memory, 10))
+	}
+	if l.Files > 0 {
+		files := strconv.Itoa(l.Files)
+		args = append(args, "--ulimit", "nofile="+files+":"+files)
+	}
+	args = append(append(append(args, r.Opts...), r.Image), c.Args...)
+	cmd := exec.CommandContext(ctx, docker, args...)
+	cmd.Cancel = func() error {
+		// killing docker client leaves the container running
+		_ = exec.Command(docker, "kill", name).Run()
+		return cmd.Process.Kill()
+	}
+	return startExec(cmd, c)
+}
+
+func containerName() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return "go-pandoc-" + hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,65 @@
+package pandoc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDockerRunner(t *testing.T) {
+	var (
+		dir    = t.TempDir()
+		log    = filepath.Join(dir, "log")
+		docker = filepath.Join(dir, "docker")
+	)
+	// a fake docker logging its arguments and running the fake pandoc
+	script := `#!/bin/sh
+echo "$@" >>"$FAKE_DOCKER_LOG"
+[ "$1" = kill ] && exit 0
+while [ $# -gt 0 ] && [ "$1" != test/pandoc ]; do shift; done
+shift
+exec "$FAKE_PANDOC" "$@"
+`
+	if err := os.WriteFile(docker, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAKE_PANDOC", fakePandoc(t))
+	t.Setenv("FAKE_DOCKER_LOG", log)
+
+	conf := Format("json").WithDir(dir).WithRunner(DockerRunner{Image: "test/pandoc", Docker: docker})
+	doc := &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"hello"}}}}}
+	var out bytes.Buffer
+	if err := doc.StoreTo(&out, conf); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := LoadFrom(&out, conf); err != nil {
+		t.Fatal(err)
+	} else if s := Stringify(res); s != "hello" {
+		t.Errorf("unexpected document %q", s)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	doc = &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"SLEEP"}}}}}
+	if err := doc.StoreToContext(ctx, io.Discard, conf); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[3], "kill go-pandoc-") {
+		t.Fatalf("unexpected docker runs %q", lines)
+	}
+	for _, want := range []string{"run --rm --name go-pandoc-", " -v " + dir + ":" + dir + " -w " + dir + " --entrypoint pandoc -i ", " test/pandoc -fjson -tjson"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("%q does not contain %q", lines[0], want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package pandoc
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+)
+
+// Options of comparing elements.
+type EqualOptions struct {
+	IgnoreAttr  bool // Identifiers, classes and attributes are not compared
+	IgnoreIdent bool // Identifiers are not compared
+}
+
+// Reports whether elements a and b are structurally equal. Unlike
+// reflect.DeepEqual, nil and empty lists are equal, as are nil elements,
+// typed or not. Lazy blocks are compared as decoded. The API versions of
+// documents are not compared.
+func Equal[E Element](a, b E) bool {
+	return EqualOptions{}.Equal(a, b)
+}
+
+// Reports whether elements a and b are structurally equal with options o.
+func (o EqualOptions) Equal(a, b Element) bool {
+	if isNil(a) || isNil(b) {
+		return isNil(a) && isNil(b)
+	}
+	var err error
+	if lb, ok := a.(*LazyBlock); ok {
+		if a, err = lb.Decode(); err != nil {
+			return false
+		}
+	}
+	if lb, ok := b.(*LazyBlock); ok {
+		if b, err = lb.Decode(); err != nil {
+			return false
+		}
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	switch a := a.(type) {
+	case MetaMapEntry:
+		b := b.(MetaMapEntry)
+		return a.Key == b.Key && o.Equal(a.Value, b.Value)
+	case MetaString:
+		return a == b.(MetaString)
+	case MetaBool:
+		return a == b.(MetaBool)
+	case *UnknownElement:
+		b := b.(*UnknownElement)
+		return a.Type == b.Type && bytes.Equal(a.Content, b.Content)
+	}
+	for i := 0; ; i++ {
+		f := a.Field(i)
+		if f == nil {
+			return true
+		} else if !o.field(f, b.Field(i)) {
+			return false
+		}
+	}
+}
+
+// compares fields of element content of the same type
+func (o EqualOptions) field(a, b any) bool {
+	switch a := a.(type) {
+	case *Attr:
+		return o.attr(a, b.(*Attr))
+	case *string:
+		return *a == *b.(*string)
+	case *int:
+		return *a == *b.(*int)
+	case *QuoteType:
+		return *a == *b.(*QuoteType)
+	case *MathType:
+		return *a == *b.(*MathType)
+	case *CitationMode:
+		return *a == *b.(*CitationMode)
+	case *Alignment:
+		return *a == *b.(*Alignment)
+	case *ListAttrs:
+		return *a == *b.(*ListAttrs)
+	case *Target:
+		return *a == *b.(*Target)
+	case *ColWidth:
+		return *a == *b.(*ColWidth)
+	case *[]Inline:
+		return equalList(o, *a, *b.(*[]Inline))
+	case *[]Block:
+		return equalList(o, *a, *b.(*[]Block))
+	case *[][]Inline:
+		return equalLists(o, *a, *b.(*[][]Inline))
+	case *[][]Block:
+		return equalLists(o, *a, *b.(*[][]Block))
+	case *[]MetaValue:
+		return equalList(o, *a, *b.(*[]MetaValue))
+	case *Meta:
+		return equalList(o, *a, *b.(*Meta))
+	case *[]*Citation:
+		return equalList(o, *a, *b.(*[]*Citation))
+	case *[]Definition:
+		return equalValues[Definition](o, *a, *b.(*[]Definition))
+	case *[]ColSpec:
+		return equalValues[ColSpec](o, *a, *b.(*[]ColSpec))
+	case *Caption:
+		return o.Equal(a, b.(*Caption))
+	case *TableHeadFoot:
+		return o.Equal(a, b.(*TableHeadFoot))
+	case *[]*TableBody:
+		return equalList(o, *a, *b.(*[]*TableBody))
+	case *[]*TableRow:
+		return equalList(o, *a, *b.(*[]*TableRow))
+	case *[]*TableCell:
+		return equalList(o, *a, *b.(*[]*TableCell))
+	default:
+		return false
+	}
+}
+
+func (o EqualOptions) attr(a, b *Attr) bool {
+	switch {
+	case o.IgnoreAttr:
+		return true
+	case !o.IgnoreIdent && a.Id != b.Id:
+		return false
+	default:
+		return slices.Equal(a.Classes, b.Classes) && slices.Equal(a.KVs, b.KVs)
+	}
+}
+
+func equalList[T Element](o EqualOptions, a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !o.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalLists[T Element](o EqualOptions, a, b [][]T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalList(o, a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalValues[T any, P interface {
+	*T
+	Element
+}](o EqualOptions, a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !o.Equal(P(&a[i]), P(&b[i])) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package pandoc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEqual(t *testing.T) {
+	para := func() *Para {
+		return &Para{Inlines: []Inline{&Span{Attr: Attr{Id: "a", Classes: []string{"x"}}, Inlines: []Inline{&Str{"a"}}}, SP, &Str{"b"}}}
+	}
+	a, b := para(), para()
+	if !Equal(a, b) || !Equal[Element](a, b) {
+		t.Error("copies are not equal")
+	}
+	b.Inlines[0].(*Span).Id = "b"
+	if Equal(a, b) {
+		t.Error("different identifiers are equal")
+	}
+	if !(EqualOptions{IgnoreIdent: true}).Equal(a, b) {
+		t.Error("identifiers are not ignored")
+	}
+	b.Inlines[0].(*Span).Classes = nil
+	if (EqualOptions{IgnoreIdent: true}).Equal(a, b) {
+		t.Error("different classes are equal")
+	}
+	if !(EqualOptions{IgnoreAttr: true}).Equal(a, b) {
+		t.Error("attributes are not ignored")
+	}
+	if !Equal(&Para{}, &Para{Inlines: []Inline{}}) {
+		t.Error("nil and empty lists are not equal")
+	}
+	for _, c := range []*Para{
+		{Inlines: []Inline{&Span{Attr: Attr{Id: "a", Classes: []string{"x"}}, Inlines: []Inline{&Str{"a"}}}, SP}},
+		{Inlines: []Inline{&Span{Attr: Attr{Id: "a", Classes: []string{"x"}}, Inlines: []Inline{&Str{"a"}}}, SP, &Str{"b"}, SP}},
+		{Inlines: []Inline{&Span{Attr: Attr{Id: "a", Classes: []string{"x"}}, Inlines: []Inline{&Str{"b"}}}, SP, &Str{"b"}}},
+	} {
+		if Equal(a, c) || Equal(c, a) {
+			t.Errorf("%s and %s are equal", Sprint(a), Sprint(c))
+		}
+	}
+	if Equal[Inline](&Str{"a"}, nil) || !Equal[Inline](nil, nil) {
+		t.Error("unexpected nil comparison")
+	}
+	var p *Para
+	if Equal(p, &Para{}) || Equal(&Para{}, p) || !Equal[Element](p, nil) {
+		t.Error("unexpected typed nil comparison")
+	}
+	if !Equal[Element](&Para{Inlines: []Inline{nil}}, &Para{Inlines: []Inline{(*Str)(nil)}}) {
+		t.Error("nil items are not equal")
+	}
+}
+
+func TestEqualDocument(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := ReadOptions{Lazy: true}.ReadBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(doc, lazy) || !Equal(lazy, doc) {
+		t.Error("lazy document is not equal")
+	}
+	changed, err := Filter(doc, func(c *TableCell) ([]*TableCell, error) {
+		c = Clone(c)
+		c.ColSpan++
+		return []*TableCell{c}, ReplaceHalt
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Equal(doc, changed) || Sprint(doc) == Sprint(changed) {
+		t.Error("changed table cell is equal")
+	}
+}
+
+func BenchmarkEqual(b *testing.B) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	doc, err := ReadBytes(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c, err := ReadBytes(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !Equal(doc, c) {
+			b.Fatal("not equal")
+		}
+	}
+}
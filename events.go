@@ -0,0 +1,311 @@
+package pandoc
+
+import (
+	"io"
+)
+
+// Callbacks of ReadEvents. Any of them may be nil.
+//
+// Returning Skip from BlockStart or InlineStart skips the children of
+// the element (End is still called), returning Halt stops reading. Any
+// other error stops reading and is returned by ReadEvents.
+type EventHandler struct {
+	MetaKey     func(key string) error // Called before each top-level metadata value
+	BlockStart  func(tag Tag) error
+	BlockEnd    func(tag Tag) error
+	InlineStart func(tag Tag) error
+	InlineEnd   func(tag Tag) error
+
+	// Called with the text of Str, Code, Math, RawInline, CodeBlock
+	// and RawBlock elements.
+	Text func(tag Tag, text string) error
+}
+
+// ReadEvents reads a Pandoc AST JSON from the reader, calling handler h
+// for the elements without building the AST. Elements of metadata
+// values produce events as well.
+//
+// Example:
+//
+//	var words int
+//	err := pandoc.ReadEvents(r, pandoc.EventHandler{
+//	    Text: func(tag pandoc.Tag, text string) error {
+//	        if tag == pandoc.StrTag {
+//	            words++
+//	        }
+//	        return nil
+//	    },
+//	})
+func ReadEvents(r io.Reader, h EventHandler) error {
+	e := events{h: h}
+	e.s.init(r)
+	err := e.document()
+	if rslt, ok := isResult(err); ok && rslt.halt() {
+		return nil
+	}
+	return err
+}
+
+type eventKind uint8
+
+const (
+	otherEvent eventKind = iota
+	blockEvent
+	inlineEvent
+)
+
+// kinds of tags; text elements are marked by textTags
+var eventKinds = map[Tag]eventKind{
+	StrTag: inlineEvent, EmphTag: inlineEvent, UnderlineTag: inlineEvent, StrongTag: inlineEvent,
+	StrikeoutTag: inlineEvent, SuperscriptTag: inlineEvent, SubscriptTag: inlineEvent,
+	SmallCapsTag: inlineEvent, QuotedTag: inlineEvent, CiteTag: inlineEvent, CodeTag: inlineEvent,
+	SpaceTag: inlineEvent, SoftBreakTag: inlineEvent, LineBreakTag: inlineEvent, MathTag: inlineEvent,
+	RawInlineTag: inlineEvent, LinkTag: inlineEvent, ImageTag: inlineEvent, NoteTag: inlineEvent,
+	SpanTag: inlineEvent,
+
+	PlainTag: blockEvent, ParaTag: blockEvent, LineBlockTag: blockEvent, CodeBlockTag: blockEvent,
+	RawBlockTag: blockEvent, BlockQuoteTag: blockEvent, OrderedListTag: blockEvent,
+	BulletListTag: blockEvent, DefinitionListTag: blockEvent, HorizontalRuleTag: blockEvent,
+	HeaderTag: blockEvent, TableTag: blockEvent, FigureTag: blockEvent, DivTag: blockEvent,
+}
+
+var textTags = map[Tag]bool{
+	CodeTag: true, MathTag: true, RawInlineTag: true, CodeBlockTag: true, RawBlockTag: true,
+}
+
+type events struct {
+	s scanner
+	h EventHandler
+}
+
+func (e *events) document() error {
+	s := &e.s
+	if err := s.expect(tokLBrace); err != nil {
+		return err
+	}
+	for i := 3; i > 0; i-- {
+		key, err := readString(s)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "pandoc-api-version":
+			if version, err := readField(s, i, listr(readInt)); err != nil {
+				return err
+			} else if cmpSemver(version, _MinVersion) < 0 {
+				return errorf(s, "unsupported pandoc version %v", version)
+			}
+		case "meta":
+			if _, err := readField(s, i, func(*scanner) (any, error) { return nil, e.meta() }); err != nil {
+				return err
+			}
+		case "blocks":
+			if _, err := readField(s, i, func(*scanner) (any, error) { return nil, e.value() }); err != nil {
+				return err
+			}
+		default:
+			return errorf(s, "unknown pandoc field %q", key)
+		}
+	}
+	return nil
+}
+
+func (e *events) meta() error {
+	s := &e.s
+	if err := s.expect(tokLBrace); err != nil {
+		return err
+	}
+	if s.peek() == tokRBrace {
+		s.next()
+		return nil
+	}
+	for {
+		key, err := readString(s)
+		if err != nil {
+			return err
+		}
+		if e.h.MetaKey != nil {
+			if err := result(e.h.MetaKey(key)); err != nil {
+				return err
+			}
+		}
+		if err := s.expect(tokColon); err != nil {
+			return err
+		}
+		if err := e.value(); err != nil {
+			return err
+		}
+		off := s.current()
+		if tok := s.next(); tok == tokRBrace {
+			return nil
+		} else if tok != tokComma {
+			return errorAt(s, off, "expected comma or right brace, got %s", tok)
+		}
+	}
+}
+
+// reads a value producing events for tagged elements
+func (e *events) value() error {
+	s := &e.s
+	switch s.peek() {
+	case tokLBrace:
+		return e.object()
+	case tokLBrack:
+		s.next()
+		if s.peek() == tokRBrack {
+			s.next()
+			return nil
+		}
+		for {
+			if err := e.value(); err != nil {
+				return err
+			}
+			off := s.current()
+			if tok := s.next(); tok == tokRBrack {
+				return nil
+			} else if tok != tokComma {
+				return errorAt(s, off, "expected comma or right bracket, got %s", tok)
+			}
+		}
+	case tokStr, tokNumber, tokTrue, tokFalse, tokNull:
+		if s.next() == tokErr {
+			return s.err
+		}
+		return nil
+	default:
+		return errorf(s, "unexpected %s", s.peek())
+	}
+}
+
+// returns err unless it's a traversal result other than Halt
+func result(err error) error {
+	if rslt, ok := isResult(err); ok && !rslt.halt() {
+		return nil
+	}
+	return err
+}
+
+func (e *events) object() error {
+	s := &e.s
+	if err := s.expect(tokLBrace); err != nil {
+		return err
+	}
+	if s.peek() == tokRBrace {
+		s.next()
+		return nil
+	}
+	key, err := readString(s)
+	if err != nil {
+		return err
+	}
+	if err := s.expect(tokColon); err != nil {
+		return err
+	}
+	if key != "t" {
+		// an untagged object, such as a citation
+		return e.fields()
+	}
+	str, err := readString(s)
+	if err != nil {
+		return err
+	}
+	tag := Tag(str)
+	kind := eventKinds[tag]
+	var start, end func(Tag) error
+	switch kind {
+	case blockEvent:
+		start, end = e.h.BlockStart, e.h.BlockEnd
+	case inlineEvent:
+		start, end = e.h.InlineStart, e.h.InlineEnd
+	}
+	var skip bool
+	if start != nil {
+		if err := start(tag); err != nil {
+			if rslt, ok := isResult(err); !ok || rslt.halt() {
+				return err
+			}
+			skip = true
+		}
+	}
+	if s.peek() == tokComma {
+		s.next()
+		if err := s.expectString("c"); err != nil {
+			return err
+		}
+		if err := s.expect(tokColon); err != nil {
+			return err
+		}
+		switch {
+		case skip:
+			err = s.skipValue()
+		case tag == StrTag:
+			err = e.text(tag)
+		case textTags[tag]:
+			err = e.textTuple(tag)
+		default:
+			err = e.value()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.expect(tokRBrace); err != nil {
+		return err
+	}
+	if end != nil {
+		return result(end(tag))
+	}
+	return nil
+}
+
+// reads the rest of an untagged object, starting from the first value
+func (e *events) fields() error {
+	s := &e.s
+	for {
+		if err := e.value(); err != nil {
+			return err
+		}
+		off := s.current()
+		if tok := s.next(); tok == tokRBrace {
+			return nil
+		} else if tok != tokComma {
+			return errorAt(s, off, "expected comma or right brace, got %s", tok)
+		}
+		if _, err := readString(s); err != nil {
+			return err
+		}
+		if err := s.expect(tokColon); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *events) text(tag Tag) error {
+	text, err := readString(&e.s)
+	if err != nil {
+		return err
+	}
+	if e.h.Text != nil {
+		return result(e.h.Text(tag, text))
+	}
+	return nil
+}
+
+// reads contents of an element with text following attributes, format
+// or math type
+func (e *events) textTuple(tag Tag) error {
+	s := &e.s
+	if err := s.expect(tokLBrack); err != nil {
+		return err
+	}
+	if err := e.value(); err != nil {
+		return err
+	}
+	if err := s.expect(tokComma); err != nil {
+		return err
+	}
+	if err := e.text(tag); err != nil {
+		return err
+	}
+	return s.expect(tokRBrack)
+}
@@ -0,0 +1,87 @@
+package pandoc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadEvents(t *testing.T) {
+	const src = `{"pandoc-api-version":[1,23,1],"meta":{"title":{"t":"MetaInlines","c":[{"t":"Str","c":"T"}]}},"blocks":[` +
+		`{"t":"Header","c":[1,["h",["c"],[]],[{"t":"Str","c":"A"},{"t":"Space"},{"t":"Code","c":[["",[],[]],"x"]}]]},` +
+		`{"t":"Div","c":[["",[],[]],[{"t":"Para","c":[{"t":"Note","c":[{"t":"Plain","c":[{"t":"Str","c":"n"}]}]}]}]]},` +
+		`{"t":"CodeBlock","c":[["",[],[]],"code"]},{"t":"HorizontalRule"}]}`
+	var log []string
+	h := EventHandler{
+		MetaKey:     func(key string) error { log = append(log, "meta "+key); return nil },
+		BlockStart:  func(tag Tag) error { log = append(log, "<"+string(tag)); return nil },
+		BlockEnd:    func(tag Tag) error { log = append(log, string(tag)+">"); return nil },
+		InlineStart: func(tag Tag) error { log = append(log, "("+string(tag)); return nil },
+		InlineEnd:   func(tag Tag) error { log = append(log, string(tag)+")"); return nil },
+		Text:        func(tag Tag, text string) error { log = append(log, string(tag)+":"+text); return nil },
+	}
+	if err := ReadEvents(strings.NewReader(src), h); err != nil {
+		t.Fatal(err)
+	}
+	const want = "meta title (Str Str:T Str) <Header (Str Str:A Str) (Space Space) (Code Code:x Code) Header> " +
+		"<Div <Para (Note <Plain (Str Str:n Str) Plain> Note) Para> Div> <CodeBlock CodeBlock:code CodeBlock> <HorizontalRule HorizontalRule>"
+	if got := strings.Join(log, " "); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	log = nil
+	h.BlockStart = func(tag Tag) error {
+		log = append(log, "<"+string(tag))
+		switch tag {
+		case DivTag:
+			return Skip
+		case CodeBlockTag:
+			return Halt
+		}
+		return nil
+	}
+	if err := ReadEvents(strings.NewReader(src), h); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(log, " "); !strings.HasSuffix(got, "Header> <Div Div> <CodeBlock") {
+		t.Errorf("unexpected events %s", got)
+	}
+
+	errStop := errors.New("stop")
+	if err := ReadEvents(strings.NewReader(src), EventHandler{Text: func(Tag, string) error { return errStop }}); err != errStop {
+		t.Errorf("expected handler error, got %v", err)
+	}
+	if err := ReadEvents(strings.NewReader(src[:100]), EventHandler{}); err == nil {
+		t.Error("no error on truncated input")
+	}
+}
+
+func TestReadEventsText(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got []string
+	Query(doc, func(s *Str) { want = append(want, s.Text) })
+	err = ReadEvents(bytes.NewReader(data), EventHandler{
+		MetaKey: func(string) error { return nil },
+		Text: func(tag Tag, text string) error {
+			if tag == StrTag {
+				got = append(got, text)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %d strings, want %d", len(got), len(want))
+	}
+}
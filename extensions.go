@@ -0,0 +1,143 @@
+package pandoc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+)
+
+// Returns the extensions of the format of c known to pandoc, each with
+// whether it is enabled by default. The result is cached as the one of
+// Capabilities.
+func (c Conf) Extensions() (map[string]bool, error) {
+	return c.ExtensionsContext(context.Background())
+}
+
+// ExtensionsContext is Extensions that kills pandoc if ctx is done
+// before pandoc responds.
+func (c Conf) ExtensionsContext(ctx context.Context) (map[string]bool, error) {
+	query := "--list-extensions=" + c.Format
+	v, err := c.cached(ctx, query, func(ctx context.Context, r Runner, pandoc string) (any, error) {
+		out, err := output(ctx, r, pandoc, query)
+		if err != nil {
+			return nil, fmt.Errorf("running pandoc %s: %w", query, err)
+		}
+		return parseExtensions(string(out)), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// a copy, so that callers cannot modify the cached map
+	return maps.Clone(v.(map[string]bool)), nil
+}
+
+// parses output of pandoc --list-extensions
+func parseExtensions(out string) map[string]bool {
+	exts := make(map[string]bool)
+	for _, line := range strings.Fields(out) {
+		if line[0] == '+' || line[0] == '-' {
+			exts[line[1:]] = line[0] == '+'
+		}
+	}
+	return exts
+}
+
+// Works as ValidateExtContext with the background context.
+func (c Conf) ValidateExt() error {
+	return c.ValidateExtContext(context.Background())
+}
+
+// Reports extensions of c unknown to pandoc for its format, with near
+// misses of their names, and malformed extensions. The errors are joined.
+func (c Conf) ValidateExtContext(ctx context.Context) error {
+	if len(c.Ext) == 0 {
+		return nil
+	}
+	exts, err := c.ExtensionsContext(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, ext := range c.Ext {
+		if len(ext) < 2 || (ext[0] != '+' && ext[0] != '-') {
+			errs = append(errs, fmt.Errorf("extension %q must start with '+' or '-'", ext))
+		} else if _, ok := exts[ext[1:]]; ok {
+			continue
+		} else if near := nearMisses(ext[1:], exts); len(near) > 0 {
+			errs = append(errs, fmt.Errorf("unknown extension %s of %s, did you mean %s?", ext[1:], c.Format, strings.Join(near, " or ")))
+		} else {
+			errs = append(errs, fmt.Errorf("unknown extension %s of %s", ext[1:], c.Format))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// returns up to three names closest to name, the closest first
+func nearMisses(name string, names map[string]bool) []string {
+	type miss struct {
+		name string
+		dist int
+	}
+	var misses []miss
+	for n := range names {
+		if d := editDistance(name, n); d <= 2 && d < len(name) {
+			misses = append(misses, miss{n, d})
+		}
+	}
+	sort.Slice(misses, func(i, j int) bool {
+		if misses[i].dist != misses[j].dist {
+			return misses[i].dist < misses[j].dist
+		}
+		return misses[i].name < misses[j].name
+	})
+	near := make([]string, 0, 3)
+	for i := 0; i < len(misses) && i < 3; i++ {
+		near = append(near, misses[i].name)
+	}
+	return near
+}
+
+// Returns the names of the highlight styles of pandoc, sorted. The result
+// is cached as the one of Capabilities.
+func (c Conf) HighlightStyles() ([]string, error) {
+	return c.HighlightStylesContext(context.Background())
+}
+
+// HighlightStylesContext is HighlightStyles that kills pandoc if ctx is
+// done before pandoc responds.
+func (c Conf) HighlightStylesContext(ctx context.Context) ([]string, error) {
+	return c.list(ctx, "--list-highlight-styles")
+}
+
+// Works as ValidateHighlightStyleContext with the background context.
+func (c Conf) ValidateHighlightStyle() error {
+	return c.ValidateHighlightStyleContext(context.Background())
+}
+
+// Reports a highlight style of c, see WithHighlightStyle, unknown to
+// pandoc, with near misses of its name. A style given as a theme file,
+// with the .theme extension, is not checked.
+func (c Conf) ValidateHighlightStyleContext(ctx context.Context) error {
+	style := c.optValue("highlight-style")
+	if style == "" || strings.HasSuffix(style, ".theme") {
+		return nil
+	}
+	styles, err := c.HighlightStylesContext(ctx)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]bool, len(styles))
+	for _, s := range styles {
+		if strings.EqualFold(s, style) {
+			return nil
+		}
+		names[s] = true
+	}
+	if near := nearMisses(strings.ToLower(style), names); len(near) > 0 {
+		return fmt.Errorf("unknown highlight style %s, did you mean %s?", style, strings.Join(near, " or "))
+	}
+	return fmt.Errorf("unknown highlight style %s, known are %s", style, strings.Join(styles, ", "))
+}
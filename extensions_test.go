@@ -0,0 +1,93 @@
+package pandoc
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestExtensions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "pandoc")
+	script := `#!/bin/sh
+echo "$@" >>` + filepath.Join(dir, "runs") + `
+case "$1" in
+--list-extensions=markdown) printf '+smart\n-raw_tex\n+raw_html\n+footnotes\n-hard_line_breaks\n' ;;
+*) echo "unknown format" >&2; exit 1 ;;
+esac
+`
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	conf := Format("markdown").WithPandoc(exe)
+	exts, err := conf.Extensions()
+	if err != nil {
+		t.Fatal(err)
+	} else if len(exts) != 5 || !exts["smart"] || exts["raw_tex"] {
+		t.Errorf("got %v", exts)
+	}
+	if err := conf.WithExt("smart").WithoutExt("raw_tex").ValidateExt(); err != nil {
+		t.Error(err)
+	}
+	err = conf.WithExt("smrat").WithExt("raw_htm").WithExt("xyzzy").ValidateExt()
+	if err == nil {
+		t.Fatal("no error")
+	}
+	for _, e := range []string{
+		"unknown extension smrat of markdown, did you mean smart?",
+		"unknown extension raw_htm of markdown, did you mean raw_html?",
+		"unknown extension xyzzy of markdown\n",
+	} {
+		if !strings.Contains(err.Error()+"\n", e) {
+			t.Errorf("%q does not contain %q", err, e)
+		}
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "runs")); strings.Count(string(b), "\n") != 1 {
+		t.Errorf("extensions are not cached, pandoc runs:\n%s", b)
+	}
+	if err := Format("nope").WithPandoc(exe).WithExt("smart").ValidateExt(); err == nil || !strings.Contains(err.Error(), "unknown format") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestHighlightStyles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	exe := filepath.Join(t.TempDir(), "pandoc")
+	script := "#!/bin/sh\n[ \"$1\" = --list-highlight-styles ] || exit 1\nprintf 'pygments\\ntango\\nkate\\nzenburn\\n'\n"
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	conf := Format("html").WithPandoc(exe)
+	if styles, err := conf.HighlightStyles(); err != nil {
+		t.Fatal(err)
+	} else if got := strings.Join(styles, " "); got != "kate pygments tango zenburn" {
+		t.Errorf("got %q", got)
+	}
+	for _, tt := range []struct {
+		conf Conf
+		err  string
+	}{
+		{conf, ""},
+		{conf.WithHighlightStyle("Tango"), ""},
+		{conf.WithHighlightStyle("my.theme"), ""},
+		{conf.WithHighlightStyle("tango").WithHighlightStyle(""), ""},
+		{conf.WithHighlightStyle("tnago"), "unknown highlight style tnago, did you mean tango?"},
+		{conf.WithHighlightStyle("solarized"), "unknown highlight style solarized, known are kate, pygments, tango, zenburn"},
+	} {
+		if err := tt.conf.ValidateHighlightStyle(); tt.err == "" && err != nil {
+			t.Errorf("%q: %v", tt.conf.Opts, err)
+		} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("%q: got %v, want %s", tt.conf.Opts, err, tt.err)
+		}
+	}
+	if got := strings.Join(conf.WithSyntaxDefinition("a.xml").WithSyntaxDefinition("b.xml").Opts, " "); got != "--syntax-definition=a.xml --syntax-definition=b.xml" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,52 @@
+package pandoc
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// RunFilter runs fun as a pandoc JSON filter: it reads the document from
+// stdin, applies fun and writes the result to stdout. The format passed
+// to fun is the target format pandoc passes to filters as the first
+// argument (empty if the filter is run without arguments). Elements
+// unknown to the package are passed through as UnknownElement. On error,
+// RunFilter reports it to stderr and exits with status 1.
+//
+// Example:
+//
+//	func main() {
+//	    pandoc.RunFilter(func(doc *pandoc.Pandoc, format string) (*pandoc.Pandoc, error) {
+//	        if format != "html" {
+//	            return doc, nil
+//	        }
+//	        return pandoc.Filter(doc, func(e *pandoc.Emph) ([]pandoc.Inline, error) {
+//	            return []pandoc.Inline{&pandoc.Strong{Inlines: e.Inlines}}, pandoc.ReplaceContinue
+//	        })
+//	    })
+//	}
+//
+// and then
+//
+//	pandoc --filter ./my-filter -o doc.html doc.md
+func RunFilter(fun func(*Pandoc, string) (*Pandoc, error)) {
+	if err := runFilter(os.Stdin, os.Stdout, os.Args[1:], fun); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[0], err)
+		os.Exit(1)
+	}
+}
+
+func runFilter(r io.Reader, w io.Writer, args []string, fun func(*Pandoc, string) (*Pandoc, error)) error {
+	var format string
+	if len(args) > 0 {
+		format = args[0]
+	}
+	doc, err := ReadOptions{KeepUnknown: true}.Read(r)
+	if err != nil {
+		return err
+	}
+	if doc, err = fun(doc, format); err != nil {
+		return err
+	}
+	return writeBuffered(w, doc)
+}
@@ -0,0 +1,35 @@
+package pandoc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRunFilter(t *testing.T) {
+	const (
+		src  = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Emph","c":[{"t":"Str","c":"x"}]}]}]}`
+		want = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Strong","c":[{"t":"Str","c":"x"}]}]}]}`
+	)
+	var format string
+	strong := func(doc *Pandoc, f string) (*Pandoc, error) {
+		format = f
+		return Filter(doc, func(e *Emph) ([]Inline, error) {
+			return []Inline{&Strong{e.Inlines}}, ReplaceContinue
+		})
+	}
+	var b bytes.Buffer
+	if err := runFilter(strings.NewReader(src), &b, []string{"html"}, strong); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want || format != "html" {
+		t.Errorf("unexpected %s (%q)", b.String(), format)
+	}
+	fail := errors.New("fail")
+	if err := runFilter(strings.NewReader(src), &b, nil, func(*Pandoc, string) (*Pandoc, error) {
+		return nil, fail
+	}); err != fail {
+		t.Errorf("unexpected error %v", err)
+	}
+}
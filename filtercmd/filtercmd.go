@@ -0,0 +1,188 @@
+// Package filtercmd turns Go transformers into a complete pandoc filter
+// executable.
+//
+// A filter binary may contain several named filters:
+//
+//	func main() {
+//	    filtercmd.Main(
+//	        filtercmd.Transform("links", "rewrite .md links to .html", rewriteLinks),
+//	        filtercmd.Transform("no-notes", "remove footnotes", stripNotes).For("html", "epub"),
+//	    )
+//	}
+//
+// Pandoc runs filters with the target format as the only argument, so
+// filters are selected by the name the binary is invoked with: if the
+// base name of argv[0] (without extension) is a filter name, only that
+// filter runs, otherwise all of them run in order. Linking the binary
+// under the filter names gives one executable per filter:
+//
+//	ln -s myfilters links
+//	pandoc --filter ./links -o doc.html doc.md
+//
+// On the command line, filters can also be selected with -x, and listed
+// with -l:
+//
+//	myfilters -x links,no-notes html < in.json > out.json
+//
+// The exit status is 0 on success, 1 if a filter fails, 2 on usage
+// errors and 3 if the input document can't be read or the output
+// written.
+package filtercmd
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/growler/go-pandoc"
+)
+
+// Exit codes
+const (
+	ExitOK     = 0
+	ExitFilter = 1
+	ExitUsage  = 2
+	ExitIO     = 3
+)
+
+// A named filter.
+type Filter struct {
+	Name    string
+	Help    string
+	Formats []string // Target formats the filter applies to, all if empty
+	Run     func(doc *pandoc.Pandoc, format string) (*pandoc.Pandoc, error)
+}
+
+// Makes a filter applying transformers in order.
+func Transform(name, help string, transformer ...func(*pandoc.Pandoc) (*pandoc.Pandoc, error)) Filter {
+	return Filter{
+		Name: name,
+		Help: help,
+		Run: func(doc *pandoc.Pandoc, _ string) (*pandoc.Pandoc, error) {
+			return doc.Apply(transformer...)
+		},
+	}
+}
+
+// Returns the filter restricted to target formats. A format also matches
+// its numbered variants, e.g. "html" matches "html5".
+func (f Filter) For(formats ...string) Filter {
+	f.Formats = append(append([]string(nil), f.Formats...), formats...)
+	return f
+}
+
+// Reports whether the filter applies to the target format.
+func (f Filter) Applies(format string) bool {
+	if len(f.Formats) == 0 {
+		return true
+	}
+	format, _, _ = strings.Cut(format, "+")
+	for _, ff := range f.Formats {
+		if rest, ok := strings.CutPrefix(format, ff); ok && strings.Trim(rest, "0123456789") == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Main runs filters as the program and exits.
+func Main(filters ...Filter) {
+	os.Exit(Run(os.Args, os.Stdin, os.Stdout, os.Stderr, filters...))
+}
+
+// Run runs filters with command line args (including the program name)
+// and returns the exit status.
+func Run(args []string, stdin io.Reader, stdout, stderr io.Writer, filters ...Filter) int {
+	prog := "filter"
+	if len(args) > 0 {
+		prog = filepath.Base(args[0])
+		args = args[1:]
+	}
+	fs := flag.NewFlagSet(prog, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		selected string
+		list     bool
+	)
+	fs.StringVar(&selected, "x", "", "comma-separated `filters` to run (default all)")
+	fs.BoolVar(&list, "l", false, "list filters")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: %s [flags] [format]\n", prog)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return ExitUsage
+	}
+	if list {
+		for _, f := range filters {
+			fmt.Fprintf(stdout, "%-20s %s", f.Name, f.Help)
+			if len(f.Formats) > 0 {
+				fmt.Fprintf(stdout, " (%s)", strings.Join(f.Formats, ", "))
+			}
+			fmt.Fprintln(stdout)
+		}
+		return ExitOK
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return ExitUsage
+	}
+	chain, err := choose(prog, selected, filters)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", prog, err)
+		return ExitUsage
+	}
+	format := fs.Arg(0)
+	doc, err := pandoc.ReadOptions{KeepUnknown: true}.Read(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: reading document: %s\n", prog, err)
+		return ExitIO
+	}
+	for _, f := range chain {
+		if !f.Applies(format) {
+			continue
+		}
+		if doc, err = f.Run(doc, format); err != nil {
+			fmt.Fprintf(stderr, "%s: %s: %s\n", prog, f.Name, err)
+			return ExitFilter
+		}
+	}
+	bw := bufio.NewWriter(stdout)
+	if err := doc.WriteTo(bw); err != nil {
+		fmt.Fprintf(stderr, "%s: writing document: %s\n", prog, err)
+		return ExitIO
+	}
+	if err := bw.Flush(); err != nil {
+		fmt.Fprintf(stderr, "%s: writing document: %s\n", prog, err)
+		return ExitIO
+	}
+	return ExitOK
+}
+
+// returns filters to run
+func choose(prog, selected string, filters []Filter) ([]Filter, error) {
+	byName := make(map[string]Filter, len(filters))
+	for _, f := range filters {
+		byName[f.Name] = f
+	}
+	if selected == "" {
+		name := strings.TrimSuffix(prog, filepath.Ext(prog))
+		if f, ok := byName[name]; ok {
+			return []Filter{f}, nil
+		}
+		return filters, nil
+	}
+	var chain []Filter
+	for _, name := range strings.Split(selected, ",") {
+		f, ok := byName[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %q", name)
+		}
+		chain = append(chain, f)
+	}
+	return chain, nil
+}
@@ -0,0 +1,87 @@
+package filtercmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/growler/go-pandoc"
+)
+
+const input = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Str","c":"a"}]}]}`
+
+func appendStr(s string) func(*pandoc.Pandoc) (*pandoc.Pandoc, error) {
+	return func(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+		return pandoc.Filter(doc, func(e *pandoc.Str) ([]pandoc.Inline, error) {
+			return []pandoc.Inline{&pandoc.Str{Text: e.Text + s}}, pandoc.ReplaceSkip
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	filters := []Filter{
+		Transform("b", "appends b", appendStr("b")),
+		Transform("c", "appends c", appendStr("c")).For("html"),
+		{Name: "fail", Run: func(*pandoc.Pandoc, string) (*pandoc.Pandoc, error) { return nil, errors.New("boom") }},
+	}
+	for _, tt := range []struct {
+		name string
+		args []string
+		in   string
+		code int
+		want string
+	}{
+		{"all", []string{"filters", "-x", "b,c", "html5"}, input, ExitOK, "abc"},
+		{"format", []string{"filters", "-x", "b,c", "latex"}, input, ExitOK, "ab"},
+		{"argv0", []string{"/usr/bin/c", "html"}, input, ExitOK, "ac"},
+		{"argv0 ext", []string{"b.exe"}, input, ExitOK, "ab"},
+		{"unknown", []string{"filters", "-x", "d"}, input, ExitUsage, ""},
+		{"flag", []string{"filters", "-y"}, input, ExitUsage, ""},
+		{"args", []string{"filters", "html", "latex"}, input, ExitUsage, ""},
+		{"fail", []string{"fail"}, input, ExitFilter, ""},
+		{"malformed", []string{"b"}, `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[`, ExitIO, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out, errs bytes.Buffer
+			code := Run(tt.args, strings.NewReader(tt.in), &out, &errs, filters...)
+			if code != tt.code {
+				t.Fatalf("exit code %d, want %d: %s", code, tt.code, errs.String())
+			}
+			if code != ExitOK {
+				if errs.Len() == 0 {
+					t.Errorf("no error reported")
+				}
+				return
+			}
+			doc, err := pandoc.ReadFrom(&out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := pandoc.Stringify(doc); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	var out bytes.Buffer
+	code := Run([]string{"filters", "-l"}, nil, &out, &out,
+		Transform("b", "appends b", appendStr("b")).For("html", "epub"))
+	if code != ExitOK || !strings.Contains(out.String(), "appends b (html, epub)") {
+		t.Errorf("got %d %q", code, out.String())
+	}
+}
+
+func TestApplies(t *testing.T) {
+	f := Filter{Formats: []string{"html", "latex"}}
+	for format, want := range map[string]bool{
+		"html": true, "html5": true, "html+smart": true, "latex": true,
+		"htmlx": false, "beamer": false, "": false,
+	} {
+		if got := f.Applies(format); got != want {
+			t.Errorf("%q: got %v", format, got)
+		}
+	}
+}
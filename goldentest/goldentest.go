@@ -0,0 +1,112 @@
+// Package goldentest compares pandoc documents with golden files of
+// canonical Pandoc AST JSON, as written by Pandoc.WriteTo followed by a
+// newline.
+//
+//	func TestFilter(t *testing.T) {
+//	    goldentest.RoundTrip(t, "testdata/input.json")
+//	    goldentest.Transform(t, "testdata/input.json", "testdata/output.json", myFilter)
+//	}
+//
+// Running tests with -update rewrites golden files with the actual
+// output instead of comparing:
+//
+//	go test ./... -update
+package goldentest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/growler/go-pandoc"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// Bytes of context shown around the first mismatch.
+const context = 40
+
+// Reads the document and checks that it's written back byte for byte.
+// With -update, the file is rewritten in the canonical form.
+func RoundTrip(t testing.TB, file string) {
+	t.Helper()
+	doc := Read(t, file)
+	Compare(t, file, doc)
+}
+
+// Reads the input document, applies transformers and compares the
+// result with the golden file.
+func Transform(t testing.TB, input, golden string, transformer ...func(*pandoc.Pandoc) (*pandoc.Pandoc, error)) {
+	t.Helper()
+	doc, err := Read(t, input).Apply(transformer...)
+	if err != nil {
+		t.Fatalf("%s: %s", input, err)
+	}
+	Compare(t, golden, doc)
+}
+
+// Reads a document, failing the test on errors.
+func Read(t testing.TB, file string) *pandoc.Pandoc {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := pandoc.ReadBytes(data)
+	if err != nil {
+		t.Fatalf("%s: %s", file, err)
+	}
+	return doc
+}
+
+// Compares the document with the golden file, reporting the first
+// mismatch. With -update, the golden file is written instead.
+func Compare(t testing.TB, golden string, doc *pandoc.Pandoc) {
+	t.Helper()
+	got, err := pandoc.AppendJSON(nil, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := Mismatch(want, got); msg != "" {
+		t.Errorf("%s: %s", golden, msg)
+	}
+}
+
+// Describes the first difference between want and got, with the line
+// and column and some context around it. Returns an empty string if they
+// are equal.
+func Mismatch(want, got []byte) string {
+	if bytes.Equal(want, got) {
+		return ""
+	}
+	i := 0
+	for i < len(want) && i < len(got) && want[i] == got[i] {
+		i++
+	}
+	line := bytes.Count(want[:i], []byte{'\n'}) + 1
+	col := i - bytes.LastIndexByte(want[:i], '\n')
+	start := max(i-context, 0)
+	return fmt.Sprintf("mismatch at line %d, column %d (offset %d), lengths %d and %d:\n\twant …%s…\n\tgot  …%s…",
+		line, col, i, len(want), len(got), excerpt(want, start, i+context), excerpt(got, start, i+context))
+}
+
+func excerpt(b []byte, start, end int) string {
+	end = min(end, len(b))
+	if start >= end {
+		return ""
+	}
+	return fmt.Sprintf("%q", b[start:end])
+}
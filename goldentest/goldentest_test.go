@@ -0,0 +1,47 @@
+package goldentest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/growler/go-pandoc"
+)
+
+func TestRoundTrip(t *testing.T) {
+	RoundTrip(t, "../testdata/test.json")
+}
+
+func TestTransform(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "out.json")
+	upper := func(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+		return pandoc.Filter(doc, func(s *pandoc.Str) ([]pandoc.Inline, error) {
+			return []pandoc.Inline{&pandoc.Str{Text: strings.ToUpper(s.Text)}}, pandoc.ReplaceContinue
+		})
+	}
+	*update = true
+	Transform(t, "../testdata/test.json", golden, upper)
+	*update = false
+	Transform(t, "../testdata/test.json", golden, upper)
+	data, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"c":"SPANNING"`) || !strings.HasSuffix(string(data), "}\n") {
+		t.Error("unexpected golden file contents")
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	if msg := Mismatch([]byte("abc"), []byte("abc")); msg != "" {
+		t.Errorf("unexpected mismatch %s", msg)
+	}
+	msg := Mismatch([]byte("{\"a\":1,\n\"b\":2}"), []byte("{\"a\":1,\n\"b\":3}"))
+	if !strings.Contains(msg, "line 2, column 5 (offset 12)") || !strings.Contains(msg, `\"b\":2}"`) {
+		t.Errorf("unexpected mismatch %s", msg)
+	}
+	if msg := Mismatch([]byte("abc"), []byte("ab")); !strings.Contains(msg, "offset 2") {
+		t.Errorf("unexpected mismatch %s", msg)
+	}
+}
@@ -0,0 +1,207 @@
+package pandoc
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// IdentAllocator makes identifiers unique in a document: an identifier
+// already used gets the first suffix "-1", "-2" and so on not used, as
+// pandoc and GitHub do for headers of the same text. The zero value has
+// no identifiers used.
+//
+// Example:
+//
+//	ids := pandoc.NewIdentAllocator(doc)
+//	h.Id = ids.Inlines(h.Inlines)
+type IdentAllocator struct {
+	used map[string]bool
+}
+
+// Returns an allocator with the identifiers of the document used.
+func NewIdentAllocator(doc *Pandoc) *IdentAllocator {
+	a := &IdentAllocator{}
+	for _, id := range idents(doc) {
+		a.Reserve(id)
+	}
+	return a
+}
+
+// Marks the identifier used.
+func (a *IdentAllocator) Reserve(id string) {
+	if a.used == nil {
+		a.used = make(map[string]bool)
+	}
+	a.used[id] = true
+}
+
+// Reports whether the identifier is used.
+func (a *IdentAllocator) Used(id string) bool {
+	return a.used[id]
+}
+
+// Returns the identifier, with a suffix if it is used, and marks it
+// used. An empty identifier is "section", as with pandoc.
+func (a *IdentAllocator) Ident(id string) string {
+	if id == "" {
+		id = "section"
+	}
+	r := id
+	for n := 1; a.used[r]; n++ {
+		r = id + "-" + strconv.Itoa(n)
+	}
+	a.Reserve(r)
+	return r
+}
+
+// Returns the unique identifier of the text, see InlinesToIdent.
+func (a *IdentAllocator) Inlines(inlines []Inline) string {
+	return a.Ident(InlinesToIdent(inlines))
+}
+
+// Returns the document with identifiers assigned to headers and figures
+// without ones, unique in the document: the identifiers of headers are
+// made of their text, see InlinesToIdent, and the ones of figures of
+// their captions prefixed by "fig-", or "fig". Identifiers already present are
+// kept, even if duplicate. It is a transformer for Pandoc.Apply.
+//
+// Example:
+//
+//	doc, err = doc.Apply(pandoc.AutoIdents)
+func AutoIdents(doc *Pandoc) (*Pandoc, error) {
+	ids := NewIdentAllocator(doc)
+	return Filter(doc, func(b Block) ([]Block, error) {
+		switch b := b.(type) {
+		case *Header:
+			if b.Id == "" {
+				b = Clone(b)
+				b.Id = ids.Inlines(b.Inlines)
+				return []Block{b}, ReplaceContinue
+			}
+		case *Figure:
+			if b.Id == "" {
+				id := "fig"
+				if text := strings.Trim(StringToIdent(Stringify(&b.Caption)), "-"); text != "" {
+					id += "-" + text
+				}
+				b = Clone(b)
+				b.Id = ids.Ident(id)
+				return []Block{b}, ReplaceContinue
+			}
+		}
+		return nil, Continue
+	})
+}
+
+// Returns the document with the identifier old of elements renamed to
+// new, see RenameIdents.
+//
+// Example:
+//
+//	doc = pandoc.RenameIdent(doc, "fig:1", "fig:overview")
+func RenameIdent(doc *Pandoc, old, new string) *Pandoc {
+	return RenameIdents(doc, map[string]string{old: new})
+}
+
+// Returns the document with identifiers of elements renamed by the map
+// from old identifiers to new ones, along with the references to them:
+// targets of links to "#old" and citations of old, such as
+// pandoc-crossref references "@fig:old", including the text of the
+// citations. The renames are made at once, so identifiers may be
+// swapped. As with Filter, the document is not changed.
+func RenameIdents(doc *Pandoc, renames map[string]string) *Pandoc {
+	doc, _ = Filter(doc, func(e Element) ([]Element, error) {
+		if c, ok := e.(*Cite); ok {
+			return renameCite(c, renames)
+		}
+		l, ok := e.(Linkable)
+		if !ok {
+			return nil, Continue
+		}
+		id, renamed := renames[l.Ident()]
+		link, isLink := e.(*Link)
+		target, toRenamed := "", false
+		if isLink && strings.HasPrefix(link.Target.Url, "#") {
+			target, toRenamed = renames[link.Target.Url[1:]]
+		}
+		if !renamed && !toRenamed {
+			return nil, Continue
+		}
+		c := Clone(e)
+		if renamed {
+			c.(Linkable).SetIdent(id)
+		}
+		if toRenamed {
+			c.(*Link).Target.Url = "#" + target
+		}
+		return []Element{c}, ReplaceContinue
+	})
+	return doc
+}
+
+// renames the citations of c and their keys in its text
+func renameCite(c *Cite, renames map[string]string) ([]Element, error) {
+	var citations []*Citation
+	for i, cit := range c.Citations {
+		id, ok := renames[cit.Id]
+		if !ok {
+			continue
+		} else if citations == nil {
+			citations = slices.Clone(c.Citations)
+		}
+		citations[i] = Clone(cit)
+		citations[i].Id = id
+	}
+	if citations == nil {
+		return nil, Continue
+	}
+	text, err := Filter(&Span{Inlines: c.Inlines}, func(s *Str) ([]Inline, error) {
+		if t := renameCiteKeys(s.Text, renames); t != s.Text {
+			return []Inline{&Str{Text: t}}, ReplaceSkip
+		}
+		return nil, Continue
+	})
+	if err != nil {
+		return nil, err
+	}
+	c = Clone(c)
+	c.Citations, c.Inlines = citations, text.Inlines
+	return []Element{c}, ReplaceContinue
+}
+
+// renames citation keys, such as @key in "[@key, p. 1]", in the text
+func renameCiteKeys(s string, renames map[string]string) string {
+	var sb strings.Builder
+	for {
+		i := strings.IndexByte(s, '@')
+		if i < 0 {
+			break
+		}
+		sb.WriteString(s[:i+1])
+		s = s[i+1:]
+		n := strings.IndexAny(s, " []();,")
+		if n < 0 {
+			n = len(s)
+		}
+		// keys may contain, but not end with, punctuation
+		key := strings.TrimRight(s[:n], ".:")
+		if id, ok := renames[key]; ok {
+			sb.WriteString(id)
+			s = s[len(key):]
+		}
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+// returns the identifiers of elements of the document in document order
+func idents(doc *Pandoc) []string {
+	var ids []string
+	Query(doc, func(l Linkable) {
+		if id := l.Ident(); id != "" {
+			ids = append(ids, id)
+		}
+	})
+	return ids
+}
@@ -0,0 +1,95 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIdentAllocator(t *testing.T) {
+	var ids IdentAllocator
+	ids.Reserve("intro-1")
+	var got []string
+	for _, id := range []string{"intro", "intro", "intro", "", ""} {
+		got = append(got, ids.Ident(id))
+	}
+	if want := "intro,intro-2,intro-3,section,section-1"; strings.Join(got, ",") != want {
+		t.Errorf("got %q, want %s", got, want)
+	}
+	if !ids.Used("intro-1") || ids.Used("intro-4") {
+		t.Error("used identifiers are not tracked")
+	}
+}
+
+func TestAutoIdents(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Inlines: words("Getting started")},
+		&Header{Attr: Attr{Id: "getting-started-1"}, Level: 2, Inlines: words("Kept")},
+		&Div{Blocks: []Block{&Header{Level: 2, Inlines: words("Getting started")}}},
+		&Figure{Caption: Caption{Long: []Block{&Plain{Inlines: words("A chart.")}}}},
+		&Figure{},
+	}}
+	got, err := doc.Apply(AutoIdents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	Query(got, func(l Linkable) {
+		if _, ok := l.(*Div); !ok {
+			ids = append(ids, l.Ident())
+		}
+	})
+	if want := "getting-started,getting-started-1,getting-started-2,fig-a-chart,fig"; strings.Join(ids, ",") != want {
+		t.Errorf("got %q, want %s", ids, want)
+	}
+	if doc.Blocks[0].(*Header).Id != "" {
+		t.Error("document is changed")
+	}
+}
+
+func TestRenameIdent(t *testing.T) {
+	cite := func(text string, ids ...string) Inline {
+		c := &Cite{Inlines: []Inline{&Str{Text: text}}}
+		for _, id := range ids {
+			c.Citations = append(c.Citations, &Citation{Id: id, Mode: NormalCitation})
+		}
+		return c
+	}
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Attr: Attr{Id: "a"}, Level: 1, Inlines: words("A")},
+		&Figure{Attr: Attr{Id: "fig:b"}},
+		&Para{Inlines: []Inline{
+			&Link{Inlines: words("to a"), Target: Target{Url: "#a"}},
+			&Link{Inlines: words("to b"), Target: Target{Url: "#fig:b"}},
+			&Link{Inlines: words("away"), Target: Target{Url: "https://example.com/#a"}},
+			cite("[@fig:b; @smith]", "fig:b", "smith"),
+			cite("@fig:b.", "fig:b"),
+		}},
+	}}
+	before := Sprint(doc)
+	got := RenameIdents(doc, map[string]string{"a": "fig:b", "fig:b": "a"})
+	if Sprint(doc) != before {
+		t.Errorf("document is changed")
+	}
+	var refs []string
+	Query(got, func(e Element) {
+		switch e := e.(type) {
+		case *Header:
+			refs = append(refs, e.Id)
+		case *Figure:
+			refs = append(refs, e.Id)
+		case *Link:
+			refs = append(refs, e.Target.Url)
+		case *Citation:
+			refs = append(refs, e.Id)
+		case *Cite:
+			refs = append(refs, Stringify(e))
+		}
+	})
+	want := "fig:b,a,#fig:b,#a,https://example.com/#a,[@a; @smith],a,smith,@a.,a"
+	if strings.Join(refs, ",") != want {
+		t.Errorf("got %q, want %s", refs, want)
+	}
+	if got := RenameIdent(doc, "missing", "x"); Sprint(got) != before {
+		t.Errorf("document is changed renaming a missing identifier")
+	}
+}
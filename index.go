@@ -0,0 +1,77 @@
+package pandoc
+
+// Index of the elements of a document by tag and identifier, built in a
+// single traversal.
+//
+// The index does not follow changes of the document: after filtering,
+// pass the new document to Rebuild, or call Invalidate to rebuild the
+// index on the next lookup. An index is not safe for concurrent use
+// once invalidated.
+//
+// Example:
+//
+//	idx := pandoc.NewDocIndex(doc)
+//	for _, e := range idx.Elements(pandoc.HeaderTag) {
+//	    ...
+//	}
+//	if target, ok := idx.Lookup("fig:1"); ok {
+//	    ...
+//	}
+type DocIndex struct {
+	doc    *Pandoc
+	valid  bool
+	tags   map[Tag][]Element
+	idents map[string]Linkable
+}
+
+// Returns the index of the document.
+func NewDocIndex(doc *Pandoc) *DocIndex {
+	x := &DocIndex{}
+	x.Rebuild(doc)
+	return x
+}
+
+// Returns the tagged elements with the tag in document order. The
+// returned slice must not be modified.
+func (x *DocIndex) Elements(tag Tag) []Element {
+	x.ensure()
+	return x.tags[tag]
+}
+
+// Returns the element with the identifier. If several elements share
+// it, the first one is returned.
+func (x *DocIndex) Lookup(ident string) (Linkable, bool) {
+	x.ensure()
+	l, ok := x.idents[ident]
+	return l, ok
+}
+
+// Marks the index as stale, to be rebuilt from the document on the next
+// lookup. Intended for in-place changes of the document.
+func (x *DocIndex) Invalidate() {
+	x.valid = false
+}
+
+// Rebuilds the index from the document.
+func (x *DocIndex) Rebuild(doc *Pandoc) {
+	x.doc = doc
+	x.tags = make(map[Tag][]Element)
+	x.idents = make(map[string]Linkable)
+	Query(doc, func(e Element) {
+		if t, ok := e.(Tagged); ok {
+			x.tags[t.Tag()] = append(x.tags[t.Tag()], e)
+		}
+		if l, ok := e.(Linkable); ok && l.Ident() != "" {
+			if _, dup := x.idents[l.Ident()]; !dup {
+				x.idents[l.Ident()] = l
+			}
+		}
+	})
+	x.valid = true
+}
+
+func (x *DocIndex) ensure() {
+	if !x.valid {
+		x.Rebuild(x.doc)
+	}
+}
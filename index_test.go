@@ -0,0 +1,44 @@
+package pandoc
+
+import (
+	"testing"
+)
+
+func TestDocIndex(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Attr: Attr{Id: "intro"}, Inlines: words("Intro")},
+		&Para{Inlines: []Inline{&Span{Attr: Attr{Id: "s"}, Inlines: words("a b")}}},
+		&Header{Level: 2, Attr: Attr{Id: "intro"}, Inlines: words("Again")},
+	}}
+	idx := NewDocIndex(doc)
+	if hs := idx.Elements(HeaderTag); len(hs) != 2 || hs[1] != doc.Blocks[2] {
+		t.Errorf("headers: %v", hs)
+	}
+	if n := len(idx.Elements(StrTag)); n != 4 {
+		t.Errorf("strs: %d", n)
+	}
+	if l, ok := idx.Lookup("intro"); !ok || l != Linkable(doc.Blocks[0].(*Header)) {
+		t.Errorf("intro: %v", l)
+	}
+	if _, ok := idx.Lookup("missing"); ok {
+		t.Errorf("missing found")
+	}
+
+	doc.Blocks = append(doc.Blocks, &Div{Attr: Attr{Id: "d"}})
+	if _, ok := idx.Lookup("d"); ok {
+		t.Errorf("stale index updated")
+	}
+	idx.Invalidate()
+	if _, ok := idx.Lookup("d"); !ok {
+		t.Errorf("invalidated index not rebuilt")
+	}
+
+	res, err := Filter(doc, func(s *Span) ([]Inline, error) { return s.Inlines, ReplaceSkip })
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.Rebuild(res)
+	if _, ok := idx.Lookup("s"); ok || len(idx.Elements(SpanTag)) != 0 {
+		t.Errorf("rebuilt index has the span")
+	}
+}
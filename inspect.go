@@ -0,0 +1,430 @@
+package pandoc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// returns the direct children of an element in document order, lazy
+// blocks decoded. Nil entries of the element's lists are preserved.
+// Captions and definitions are not elements of their own here, their
+// children are the children of the parent.
+func children(e Element) []Element {
+	if m, ok := e.(MetaMapEntry); ok {
+		return []Element{m.Value}
+	}
+	var c []Element
+	for i := 0; ; i++ {
+		field := e.Field(i)
+		if field == nil {
+			return c
+		}
+		c = appendChildren(c, field)
+	}
+}
+
+// appends the children held by a field of element content
+func appendChildren(c []Element, field any) []Element {
+	switch f := field.(type) {
+	case *[]Inline:
+		c = appendElements(c, *f)
+	case *[]Block:
+		for _, b := range *f {
+			c = append(c, decodedBlock(b))
+		}
+	case *[][]Inline:
+		for i := range *f {
+			c = appendChildren(c, &(*f)[i])
+		}
+	case *[][]Block:
+		for i := range *f {
+			c = appendChildren(c, &(*f)[i])
+		}
+	case *[]MetaValue:
+		c = appendElements(c, *f)
+	case *Meta:
+		for _, m := range *f {
+			c = append(c, m)
+		}
+	case *[]*Citation:
+		c = appendElements(c, *f)
+	case *[]Definition:
+		for i := range *f {
+			c = appendChildren(appendChildren(c, &(*f)[i].Term), &(*f)[i].Definition)
+		}
+	case *Caption:
+		c = appendChildren(appendChildren(c, &f.Short), &f.Long)
+	case *TableHeadFoot:
+		c = append(c, f)
+	case *[]*TableBody:
+		c = appendElements(c, *f)
+	case *[]*TableRow:
+		c = appendElements(c, *f)
+	case *[]*TableCell:
+		c = appendElements(c, *f)
+	}
+	return c
+}
+
+func appendElements[T Element](c []Element, l []T) []Element {
+	for _, e := range l {
+		c = append(c, e)
+	}
+	return c
+}
+
+// returns the tag of an element, or its type name for elements
+// that are not tagged (such as TableRow or Citation).
+func tagOf(e Element) Tag {
+	switch e := e.(type) {
+	case Tagged:
+		return e.Tag()
+	case *Pandoc:
+		return "Pandoc"
+	case MetaMapEntry:
+		return "MetaMapEntry"
+	case *Citation:
+		return "Citation"
+	case *TableHeadFoot:
+		return "TableHeadFoot"
+	case *TableBody:
+		return "TableBody"
+	case *TableRow:
+		return "TableRow"
+	case *TableCell:
+		return "TableCell"
+	case *Caption:
+		return "Caption"
+	case *ColSpec:
+		return "ColSpec"
+	case *Definition:
+		return "Definition"
+	default:
+		return Tag(fmt.Sprintf("%T", e))
+	}
+}
+
+// returns attributes of an element, or nil if it has none
+func attrOf(e Element) *Attr {
+	switch e := e.(type) {
+	case *Code:
+		return &e.Attr
+	case *Link:
+		return &e.Attr
+	case *Image:
+		return &e.Attr
+	case *Span:
+		return &e.Attr
+	case *CodeBlock:
+		return &e.Attr
+	case *Header:
+		return &e.Attr
+	case *Table:
+		return &e.Attr
+	case *Figure:
+		return &e.Attr
+	case *Div:
+		return &e.Attr
+	case *TableHeadFoot:
+		return &e.Attr
+	case *TableBody:
+		return &e.Attr
+	case *TableRow:
+		return &e.Attr
+	case *TableCell:
+		return &e.Attr
+	}
+	return nil
+}
+
+// isNil reports whether e is nil or a typed nil pointer.
+func isNil(e Element) bool {
+	if e == nil {
+		return true
+	}
+	v := reflect.ValueOf(e)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// ----------- Validate -------------
+
+type validator struct {
+	ids  map[string]string
+	errs []error
+}
+
+func (v *validator) errorf(loc string, f string, a ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", loc, fmt.Sprintf(f, a...)))
+}
+
+func (v *validator) attr(loc string, a *Attr) {
+	if a.Id != "" {
+		if prev, ok := v.ids[a.Id]; ok {
+			v.errorf(loc, "duplicate identifier %q (first used at %s)", a.Id, prev)
+		} else {
+			v.ids[a.Id] = loc
+		}
+	}
+	for _, c := range a.Classes {
+		if c == "" {
+			v.errorf(loc, "empty class")
+		}
+	}
+}
+
+func (v *validator) visit(loc string, e Element) {
+	switch e := e.(type) {
+	case *Header:
+		if e.Level < 1 {
+			v.errorf(loc, "invalid header level %d", e.Level)
+		}
+	case *Table:
+		for _, hf := range []*TableHeadFoot{&e.Head, &e.Foot} {
+			for _, r := range hf.Rows {
+				v.row(loc, r, len(e.Aligns))
+			}
+		}
+		for _, b := range e.Bodies {
+			if b == nil {
+				continue
+			}
+			if b.RowHeadColumns < 0 {
+				v.errorf(loc, "negative row head columns %d", b.RowHeadColumns)
+			}
+			for _, r := range b.Head {
+				v.row(loc, r, len(e.Aligns))
+			}
+			for _, r := range b.Body {
+				v.row(loc, r, len(e.Aligns))
+			}
+		}
+	case *TableCell:
+		if e.RowSpan < 1 {
+			v.errorf(loc, "invalid row span %d", e.RowSpan)
+		}
+		if e.ColSpan < 1 {
+			v.errorf(loc, "invalid column span %d", e.ColSpan)
+		}
+	case MetaMapEntry:
+		if e.Value == nil {
+			v.errorf(loc, "nil value for key %q", e.Key)
+			return
+		}
+	case *LazyBlock:
+		// children decode lazy blocks unless malformed
+		if _, err := e.Decode(); err != nil {
+			v.errorf(loc, "%v", err)
+		}
+	}
+	if a := attrOf(e); a != nil {
+		v.attr(loc, a)
+	}
+	for i, c := range children(e) {
+		if isNil(c) {
+			v.errorf(loc, "nil element at position %d", i)
+			continue
+		}
+		v.visit(loc+"/"+string(tagOf(c))+"["+strconv.Itoa(i)+"]", c)
+	}
+}
+
+func (v *validator) row(loc string, r *TableRow, cols int) {
+	if r == nil {
+		return
+	}
+	var span int
+	for _, c := range r.Cells {
+		if c != nil {
+			span += c.ColSpan
+		}
+	}
+	if span > cols {
+		v.errorf(loc, "table row spans %d columns, table has %d", span, cols)
+	}
+}
+
+// Validate checks the structural invariants of an element and its
+// children that the type system does not enforce: no nil elements in
+// lists, positive header levels and table cell spans, table rows fitting
+// the column specification, and unique non-empty identifiers. Returns nil
+// if the element is valid, or an error joining all the problems found,
+// each prefixed with the location of the offending element.
+func Validate(e Element) error {
+	if isNil(e) {
+		return errors.New("nil element")
+	}
+	v := validator{ids: map[string]string{}}
+	v.visit(string(tagOf(e)), e)
+	return errors.Join(v.errs...)
+}
+
+// ----------- Dump -------------
+
+func dumpText(sb *strings.Builder, s string) {
+	const max = 60
+	if utf8.RuneCountInString(s) > max {
+		s = string([]rune(s)[:max]) + "…"
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.Quote(s))
+}
+
+func dumpAttr(sb *strings.Builder, a *Attr) {
+	if a.Id != "" {
+		sb.WriteString(" #")
+		sb.WriteString(a.Id)
+	}
+	for _, c := range a.Classes {
+		sb.WriteString(" .")
+		sb.WriteString(c)
+	}
+	for _, kv := range a.KVs {
+		sb.WriteByte(' ')
+		sb.WriteString(kv.Key)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(kv.Value))
+	}
+}
+
+// returns a one-line description of an element
+func describe(e Element) string {
+	var sb strings.Builder
+	sb.WriteString(string(tagOf(e)))
+	switch e := e.(type) {
+	case MetaMapEntry:
+		sb.WriteByte(' ')
+		sb.WriteString(e.Key)
+	case MetaString:
+		dumpText(&sb, string(e))
+	case MetaBool:
+		sb.WriteString(" " + strconv.FormatBool(bool(e)))
+	case *Str:
+		dumpText(&sb, e.Text)
+	case *Code:
+		dumpAttr(&sb, &e.Attr)
+		dumpText(&sb, e.Text)
+	case *Math:
+		sb.WriteString(" " + string(e.MathType))
+		dumpText(&sb, e.Text)
+	case *RawInline:
+		sb.WriteString(" " + e.Format)
+		dumpText(&sb, e.Text)
+	case *Quoted:
+		sb.WriteString(" " + string(e.QuoteType))
+	case *Citation:
+		sb.WriteString(" @" + e.Id + " " + string(e.Mode))
+	case *Link:
+		dumpAttr(&sb, &e.Attr)
+		sb.WriteString(" -> " + e.Target.Url)
+	case *Image:
+		dumpAttr(&sb, &e.Attr)
+		sb.WriteString(" -> " + e.Target.Url)
+	case *Span:
+		dumpAttr(&sb, &e.Attr)
+	case *CodeBlock:
+		dumpAttr(&sb, &e.Attr)
+		dumpText(&sb, e.Text)
+	case *RawBlock:
+		sb.WriteString(" " + e.Format)
+		dumpText(&sb, e.Text)
+	case *OrderedList:
+		sb.WriteString(fmt.Sprintf(" %d %s %s", e.Attr.Start, e.Attr.Style, e.Attr.Delimiter))
+	case *Header:
+		sb.WriteString(" " + strconv.Itoa(e.Level))
+		dumpAttr(&sb, &e.Attr)
+	case *Table:
+		dumpAttr(&sb, &e.Attr)
+		sb.WriteString(fmt.Sprintf(" %d columns", len(e.Aligns)))
+	case *TableCell:
+		dumpAttr(&sb, &e.Attr)
+		if e.RowSpan != 1 || e.ColSpan != 1 {
+			sb.WriteString(fmt.Sprintf(" %dx%d", e.RowSpan, e.ColSpan))
+		}
+	case *Figure:
+		dumpAttr(&sb, &e.Attr)
+	case *Div:
+		dumpAttr(&sb, &e.Attr)
+	}
+	return sb.String()
+}
+
+func dump(w io.Writer, indent string, e Element) error {
+	if isNil(e) {
+		_, err := io.WriteString(w, indent+"<nil>\n")
+		return err
+	}
+	if _, err := io.WriteString(w, indent+describe(e)+"\n"); err != nil {
+		return err
+	}
+	for _, c := range children(e) {
+		if err := dump(w, indent+"  ", c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dump writes a human-readable tree view of the element to w, one element
+// per line, children indented under their parents.
+//
+// Example output:
+//
+//	Pandoc
+//	  Header 1 #intro
+//	    Str "Introduction"
+//	  Para
+//	    Str "Hello,"
+//	    Space
+//	    Str "world"
+func Dump(w io.Writer, e Element) error {
+	return dump(w, "", e)
+}
+
+// ----------- Profile -------------
+
+// Statistics of a document (or any element) collected by Profile.
+type Stats struct {
+	Elements int         // Total number of elements, not counting the root
+	Tags     map[Tag]int // Number of elements by tag
+	MaxDepth int         // Maximum nesting depth, root's children are at depth 1
+	Words    int         // Number of words (Str elements)
+	Chars    int         // Number of characters in words
+	Idents   int         // Number of elements with a non-empty identifier
+}
+
+func (s *Stats) visit(e Element, depth int) {
+	if depth > s.MaxDepth {
+		s.MaxDepth = depth
+	}
+	for _, c := range children(e) {
+		if isNil(c) {
+			continue
+		}
+		s.Elements++
+		s.Tags[tagOf(c)]++
+		switch c := c.(type) {
+		case *Str:
+			s.Words++
+			s.Chars += utf8.RuneCountInString(c.Text)
+		case Linkable:
+			if c.Ident() != "" {
+				s.Idents++
+			}
+		}
+		s.visit(c, depth+1)
+	}
+}
+
+// Profile collects statistics of an element and all its children.
+func Profile(e Element) Stats {
+	s := Stats{Tags: map[Tag]int{}}
+	if !isNil(e) {
+		s.visit(e, 0)
+	}
+	return s
+}
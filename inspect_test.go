@@ -0,0 +1,68 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Attr: Attr{Id: "a"}, Inlines: []Inline{&Str{"A"}}},
+		&Para{Inlines: []Inline{&Str{"foo"}, SP, &Str{"bar"}}},
+	}}
+	if err := Validate(valid); err != nil {
+		t.Errorf("expected valid document, got %s", err)
+	}
+	invalid := &Pandoc{Blocks: []Block{
+		&Header{Level: 0, Attr: Attr{Id: "a"}},
+		&Div{Attr: Attr{Id: "a"}, Blocks: []Block{&Para{Inlines: []Inline{nil}}}},
+		testTable(),
+	}}
+	err := Validate(invalid)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"Pandoc/Header[0]: invalid header level 0",
+		"Pandoc/Div[1]: duplicate identifier \"a\"",
+		"Pandoc/Div[1]/Para[0]: nil element at position 0",
+		"invalid row span 0",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err.Error())
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Attr: Attr{Id: "intro", Classes: []string{"x"}}, Inlines: []Inline{&Str{"Intro"}}},
+		&Para{Inlines: []Inline{&Str{"Hello,"}, SP, &Link{Inlines: []Inline{&Str{"world"}}, Target: Target{Url: "#intro"}}}},
+	}}
+	var sb strings.Builder
+	if err := Dump(&sb, doc); err != nil {
+		t.Fatal(err)
+	}
+	const expected = `Pandoc
+  Header 1 #intro .x
+    Str "Intro"
+  Para
+    Str "Hello,"
+    Space
+    Link -> #intro
+      Str "world"
+`
+	if sb.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, sb.String())
+	}
+}
+
+func TestProfile(t *testing.T) {
+	s := Profile(testTable())
+	if s.Words != 4 || s.Tags[StrTag] != 4 || s.Tags["TableCell"] != 4 || s.Tags[PlainTag] != 4 {
+		t.Errorf("unexpected statistics %+v", s)
+	}
+	if s.MaxDepth != 5 {
+		t.Errorf("expected max depth 5, got %d", s.MaxDepth)
+	}
+}
@@ -0,0 +1,60 @@
+//go:build go1.23
+
+package pandoc
+
+import (
+	"io"
+	"iter"
+)
+
+// Returns an iterator over the descendants of elt of type T, in the
+// order of Query.
+//
+// Example:
+//
+//	for str := range pandoc.Elements[*pandoc.Str](doc) {
+//	    if str.Text == "TODO" {
+//	        break
+//	    }
+//	}
+func Elements[T any, E Element](elt E) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		_ = QueryE(elt, func(t T) error {
+			if !yield(t) {
+				return Halt
+			}
+			return nil
+		})
+	}
+}
+
+// Returns an iterator over Pandoc AST JSON documents written back to back
+// to the reader, as ReadAll reads them, without holding all of them in
+// memory. The iteration stops after an error.
+//
+// Example:
+//
+//	for doc, err := range pandoc.ReadDocs(os.Stdin) {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    ...
+//	}
+func ReadDocs(r io.Reader) iter.Seq2[*Pandoc, error] {
+	return ReadOptions{}.ReadDocs(r)
+}
+
+// ReadDocs is ReadDocs with options o.
+func (o ReadOptions) ReadDocs(r io.Reader) iter.Seq2[*Pandoc, error] {
+	return func(yield func(*Pandoc, error) bool) {
+		s := o.scanner(r)
+		for {
+			doc, err := readNextDoc(s)
+			if doc == nil && err == nil {
+				return
+			} else if !yield(doc, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//go:build go1.23
+
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestElements(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Para{Inlines: words("a b c")},
+		&Div{Blocks: []Block{&Plain{Inlines: words("d")}}},
+	}}
+	var got string
+	for s := range Elements[*Str](doc) {
+		got += s.Text
+	}
+	if got != "abcd" {
+		t.Errorf("got %q, want %q", got, "abcd")
+	}
+	got = ""
+	for s := range Elements[*Str](doc) {
+		if s.Text == "c" {
+			break
+		}
+		got += s.Text
+	}
+	if got != "ab" {
+		t.Errorf("got %q after break, want %q", got, "ab")
+	}
+	var blocks int
+	for range Elements[Block](doc.Blocks[1]) {
+		blocks++
+	}
+	if blocks != 1 {
+		t.Errorf("got %d blocks, want 1", blocks)
+	}
+}
+
+func TestReadDocs(t *testing.T) {
+	const doc = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[]}` + "\n"
+	var n int
+	for _, err := range ReadDocs(strings.NewReader(doc + doc + doc)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Errorf("got %d documents, want 3", n)
+	}
+	n = 0
+	var errs int
+	for _, err := range ReadDocs(strings.NewReader(doc + "{}" + doc)) {
+		if err != nil {
+			errs++
+		}
+		n++
+	}
+	if n != 2 || errs != 1 {
+		t.Errorf("got %d documents and %d errors, want 2 and 1", n, errs)
+	}
+	n = 0
+	for range ReadDocs(strings.NewReader(doc + doc)) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("got %d documents after break, want 1", n)
+	}
+}
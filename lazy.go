@@ -0,0 +1,155 @@
+package pandoc
+
+import (
+	"bytes"
+	"io"
+)
+
+// A top-level block read with ReadOptions.Lazy, kept as raw JSON until
+// it is first visited by Filter or Query, or decoded with Decode. Blocks
+// that can't contain the elements a filter function is looking for are
+// not decoded at all. The block is written back as read.
+//
+// Since the raw JSON is written, decoded blocks must not be modified in
+// place, but replaced with modified copies, as filter functions do (see
+// Filter and Clone).
+type LazyBlock struct {
+	tag     Tag
+	raw     []byte
+	opts    ReadOptions
+	decoded Block
+	err     error
+}
+
+func (b *LazyBlock) Tag() Tag { return b.tag }
+func (b *LazyBlock) clone() Element {
+	c := *b
+	return &c
+}
+func (b *LazyBlock) block()        {}
+func (b *LazyBlock) element()      {}
+func (b *LazyBlock) Field(int) any { return nil }
+
+// Returns the raw JSON of the block.
+func (b *LazyBlock) Raw() []byte {
+	return b.raw
+}
+
+// Decodes the block. The decoded block is cached.
+func (b *LazyBlock) Decode() (Block, error) {
+	if b.decoded == nil && b.err == nil {
+		var s = scanner{opts: b.opts}
+		s.init(bytes.NewReader(b.raw))
+		b.decoded, b.err = readBlock(&s)
+	}
+	return b.decoded, b.err
+}
+
+func (b *LazyBlock) write(w io.Writer) error {
+	_, err := w.Write(b.raw)
+	return err
+}
+
+// returns the decoded block of a lazy block, b otherwise or if the lazy
+// block is malformed
+func decodedBlock(b Block) Block {
+	if lb, ok := b.(*LazyBlock); ok {
+		if decoded, err := lb.Decode(); err == nil {
+			return decoded
+		}
+	}
+	return b
+}
+
+// reads a block as raw JSON
+func readLazyBlock(s *scanner) (Block, error) {
+	if s.peek() != tokLBrace {
+		return nil, s.expect(tokLBrace)
+	}
+	off := s.current()
+	raw, err := s.rawValue(true)
+	if err != nil {
+		return nil, err
+	}
+	// the tag is read from the raw JSON, not to track it in the scanner
+	var t scanner
+	t.init(bytes.NewReader(raw))
+	if err := t.expect(tokLBrace); err != nil {
+		return nil, err
+	}
+	if err := t.expectString("t"); err != nil {
+		return nil, errorAt(s, off, "expected tag")
+	}
+	if err := t.expect(tokColon); err != nil {
+		return nil, err
+	}
+	tag, err := readString(&t)
+	if err != nil {
+		return nil, errorAt(s, off, "expected tag")
+	}
+	return &LazyBlock{tag: Tag(tag), raw: raw, opts: s.opts}, nil
+}
+
+// reports whether a lazy block may contain elements matched by the
+// filter function parameter type P; other blocks are not decoded
+func lazyMatch[P any](b *LazyBlock) bool {
+	var (
+		p   P
+		tag Tag
+	)
+	switch e := any(p).(type) {
+	case *UnknownElement:
+		return true
+	case Tagged:
+		tag = e.Tag()
+	case *Citation:
+		tag = CiteTag
+	case *TableHeadFoot, *TableBody, *TableRow, *TableCell:
+		tag = TableTag
+	default:
+		return true
+	}
+	return bytes.Contains(b.raw, appendQuote(nil, string(tag)))
+}
+
+// decodes lazy blocks of the list that may be visited by fun. Returns
+// the list and the lazy blocks by the decoded blocks, to keep the raw
+// JSON of the blocks fun did not change.
+func resolveLazy[P any, R Element](blocks []Block, fun func(P) ([]R, error)) ([]Block, map[Block]*LazyBlock, error) {
+	var lazy map[Block]*LazyBlock
+	for i, blk := range blocks {
+		lb, ok := blk.(*LazyBlock)
+		if !ok || !lazyMatch[P](lb) {
+			continue
+		}
+		decoded, err := lb.Decode()
+		if err != nil {
+			return nil, nil, err
+		}
+		if lazy == nil {
+			lazy = make(map[Block]*LazyBlock)
+			blocks = append([]Block(nil), blocks...)
+		}
+		lazy[decoded] = lb
+		blocks[i] = decoded
+	}
+	return blocks, lazy, nil
+}
+
+// puts back lazy blocks in place of unchanged decoded ones
+func restoreLazy(blocks []Block, lazy map[Block]*LazyBlock) []Block {
+	if lazy == nil {
+		return blocks
+	}
+	var copied bool
+	for i, blk := range blocks {
+		if lb, ok := lazy[blk]; ok {
+			if !copied {
+				blocks = append([]Block(nil), blocks...)
+				copied = true
+			}
+			blocks[i] = lb
+		}
+	}
+	return blocks
+}
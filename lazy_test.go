@@ -0,0 +1,133 @@
+package pandoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLazy(t *testing.T) {
+	const src = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[` +
+		`{"t":"Header","c":[1,["a",[],[]],[{"t":"Str","c":"A"}]]},` +
+		`{ "t": "Para", "c": [{"t":"Str","c":"x"}] },` +
+		`{"t":"Div","c":[["",[],[]],[{"t":"Header","c":[2,["b",[],[]],[{"t":"Str","c":"B"}]]}]]}]}`
+	doc, err := ReadOptions{Lazy: true}.Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range doc.Blocks {
+		if _, ok := b.(*LazyBlock); !ok {
+			t.Fatalf("expected lazy block, got %#v", b)
+		}
+	}
+	if doc.Blocks[1].Tag() != ParaTag {
+		t.Errorf("unexpected tag %s", doc.Blocks[1].Tag())
+	}
+	var ids []string
+	Query(doc, func(h *Header) { ids = append(ids, h.Id) })
+	if strings.Join(ids, ",") != "a,b" {
+		t.Errorf("unexpected headers %v", ids)
+	}
+	if doc.Blocks[1].(*LazyBlock).decoded != nil {
+		t.Error("block without headers is decoded")
+	}
+	doc, err = Filter(doc, func(h *Header) ([]Block, error) {
+		if h.Level != 2 {
+			return nil, Continue
+		}
+		c := *h
+		c.Level = 3
+		return []Block{&c}, ReplaceSkip
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(src, `"c":[2,`, `"c":[3,`, 1)
+	if got := Sprint(doc); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, ok := doc.Blocks[0].(*LazyBlock); !ok {
+		t.Error("unchanged block is not kept lazy")
+	}
+	if _, err := (ReadOptions{Lazy: true}).Read(strings.NewReader(src[:len(src)-5])); err == nil {
+		t.Error("no error on truncated input")
+	}
+}
+
+func TestLazyInspect(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eager, err := ReadBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := ReadOptions{Lazy: true}.ReadBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outline := func(doc *Pandoc) string {
+		b, err := json.Marshal(Outline(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	if got, want := outline(lazy), outline(eager); got != want || want == "null" {
+		t.Errorf("lazy outline is %s, want %s", got, want)
+	}
+	if got, want := Profile(lazy), Profile(eager); !reflect.DeepEqual(got, want) {
+		t.Errorf("lazy profile is %+v, want %+v", got, want)
+	}
+	var got, want bytes.Buffer
+	if err := Dump(&got, lazy); err != nil {
+		t.Fatal(err)
+	}
+	if err := Dump(&want, eager); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("lazy dump is\n%s\nwant\n%s", got.String(), want.String())
+	}
+	if got, want := fmt.Sprint(Validate(lazy)), fmt.Sprint(Validate(eager)); got != want {
+		t.Errorf("lazy document validates with %s, want %s", got, want)
+	}
+	const src = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[` +
+		`{"t":"Header","c":[0,["",[],[]],[]]},{"t":"Para","c":[1]}]}`
+	doc, err := ReadOptions{Lazy: true}.Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Validate(doc)
+	if err == nil || !strings.Contains(err.Error(), "level") || !strings.Contains(err.Error(), "Para[1]") {
+		t.Errorf("got error %v validating a lazy document", err)
+	}
+}
+
+func BenchmarkLazyQuery(b *testing.B) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, lazy := range []bool{false, true} {
+		name := "eager"
+		if lazy {
+			name = "lazy"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				doc, err := ReadOptions{Lazy: lazy}.Read(bytes.NewReader(data))
+				if err != nil {
+					b.Fatal(err)
+				}
+				Query(doc, func(*CodeBlock) {})
+			}
+		})
+	}
+}
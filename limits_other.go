@@ -0,0 +1,13 @@
+//go:build !unix
+
+package pandoc
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+func limitedCommand(ctx context.Context, pandoc string, c *Command) (*exec.Cmd, error) {
+	return nil, errors.New("pandoc resource limits are not supported on this system")
+}
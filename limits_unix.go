@@ -0,0 +1,15 @@
+//go:build unix
+
+package pandoc
+
+import (
+	"context"
+	"os/exec"
+)
+
+// returns a command running pandoc with the limits of c set by the shell
+// ulimit before pandoc is executed
+func limitedCommand(ctx context.Context, pandoc string, c *Command) (*exec.Cmd, error) {
+	script := c.Limits.ulimit() + `exec "$0" "$@"`
+	return exec.CommandContext(ctx, "/bin/sh", append([]string{"-c", script, pandoc}, c.Args...)...), nil
+}
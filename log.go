@@ -0,0 +1,159 @@
+package pandoc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// A message of the machine-readable pandoc log, see Conf.WithLog.
+type LogMessage struct {
+	Type      string // Message type, e.g. "CouldNotFetchResource"
+	Verbosity string // "ERROR", "WARNING" or "INFO"
+	Message   string // Message text or the content it is about, if any
+	Source    string // Source file of the position, if any
+	Line      int    // Line of the position, 0 if unknown
+	Column    int    // Column of the position, 0 if unknown
+
+	// All fields of the message, such as "path" of a resource
+	Fields map[string]any
+}
+
+// Returns the message in the form pandoc writes it to stderr, e.g.
+// "[WARNING] DuplicateIdentifier: intro at doc.md:3:1".
+func (m LogMessage) String() string {
+	s := "[" + m.Verbosity + "] " + m.Type
+	if m.Message != "" {
+		s += ": " + m.Message
+	}
+	if m.Line > 0 {
+		s += " at " + m.Source + ":" + strconv.Itoa(m.Line) + ":" + strconv.Itoa(m.Column)
+	}
+	return s
+}
+
+// Returns a Conf running pandoc with a machine-readable log, passing its
+// messages to fun before Load and Store functions return, even if pandoc
+// fails. The log is written to a temporary file, so the Runner must share
+// the local file system, as ExecRunner does.
+func (c Conf) WithLog(fun func(LogMessage)) Conf {
+	c.Log = fun
+	return c
+}
+
+// Non-fatal messages of pandoc conversions, such as missing images,
+// duplicate identifiers and citeproc issues, collected by a Conf made with
+// WithReport. A Report may collect messages of concurrent conversions.
+//
+// Example:
+//
+//	var rep pandoc.Report
+//	doc, err := pandoc.LoadFile("doc.md", conf.WithReport(&rep))
+//	...
+//	for _, w := range rep.Warnings() {
+//	    log.Print(w)
+//	}
+type Report struct {
+	mu   sync.Mutex
+	msgs []LogMessage
+}
+
+// Returns a Conf adding messages of pandoc log to r, in addition to
+// passing them to the function of WithLog, if any.
+func (c Conf) WithReport(r *Report) Conf {
+	log := c.Log
+	return c.WithLog(func(m LogMessage) {
+		r.add(m)
+		if log != nil {
+			log(m)
+		}
+	})
+}
+
+func (r *Report) add(m LogMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, m)
+}
+
+// Returns all messages collected, including informational ones.
+func (r *Report) Messages() []LogMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]LogMessage(nil), r.msgs...)
+}
+
+// Returns messages collected with a verbosity other than "INFO".
+func (r *Report) Warnings() []LogMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var warnings []LogMessage
+	for _, m := range r.msgs {
+		if m.Verbosity != "INFO" {
+			warnings = append(warnings, m)
+		}
+	}
+	return warnings
+}
+
+// Removes the messages collected.
+func (r *Report) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = nil
+}
+
+// makes the temporary log file of cmd
+func (c *Conf) logFile(cmd *Command) error {
+	if c.Log == nil {
+		return nil
+	}
+	f, err := os.CreateTemp("", "go-pandoc-log-*.json")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	cmd.Args = append(cmd.Args, "--log="+f.Name())
+	cmd.logFile, cmd.log = f.Name(), c.Log
+	return nil
+}
+
+// reads and removes the log file
+func readLog(path string) ([]LogMessage, error) {
+	b, err := os.ReadFile(path)
+	os.Remove(path)
+	if err != nil {
+		return nil, err
+	} else if len(bytes.TrimSpace(b)) == 0 {
+		// pandoc has failed before writing the log
+		return nil, nil
+	}
+	var raw []map[string]any
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("reading pandoc log: %w", err)
+	}
+	msgs := make([]LogMessage, len(raw))
+	for i, fields := range raw {
+		m := LogMessage{Fields: fields}
+		m.Type, _ = fields["type"].(string)
+		m.Verbosity, _ = fields["verbosity"].(string)
+		m.Source, _ = fields["source"].(string)
+		for _, k := range []string{"message", "contents", "msg"} {
+			if s, ok := fields[k].(string); ok {
+				m.Message = s
+				break
+			}
+		}
+		if n, ok := fields["line"].(float64); ok {
+			m.Line = int(n)
+		}
+		if n, ok := fields["column"].(float64); ok {
+			m.Column = int(n)
+		}
+		msgs[i] = m
+	}
+	return msgs, nil
+}
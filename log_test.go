@@ -0,0 +1,101 @@
+package pandoc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	// a fake pandoc writing a log, copying its input and failing if it
+	// contains FAIL
+	exe := filepath.Join(dir, "pandoc")
+	script := `#!/bin/sh
+for a in "$@"; do
+	case "$a" in --log=*) log="${a#--log=}" ;; esac
+done
+echo "$log" >` + filepath.Join(dir, "path") + `
+printf '%s' '[{"verbosity":"WARNING","type":"DuplicateIdentifier","contents":"intro","source":"doc.md","line":3,"column":1},{"verbosity":"INFO","type":"CouldNotFetchResource","path":"a.png","message":"not found"}]' >"$log"
+input=$(cat; echo .)
+case "$input" in *FAIL*) exit 1 ;; esac
+printf '%s' "${input%.}"
+`
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var msgs []LogMessage
+	conf := Format("json").WithPandoc(exe).WithLog(func(m LogMessage) { msgs = append(msgs, m) })
+	doc := &Pandoc{Blocks: []Block{&Para{Inlines: []Inline{&Str{"a"}}}}}
+	var sb strings.Builder
+	if err := doc.StoreTo(&sb, conf); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %v", msgs)
+	}
+	if s := msgs[0].String(); s != "[WARNING] DuplicateIdentifier: intro at doc.md:3:1" {
+		t.Errorf("got %q", s)
+	}
+	if s := msgs[1].String(); s != "[INFO] CouldNotFetchResource: not found" || msgs[1].Fields["path"] != "a.png" {
+		t.Errorf("got %q, %v", s, msgs[1].Fields)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "path")); err != nil {
+		t.Fatal(err)
+	} else if _, err := os.Stat(strings.TrimSpace(string(b))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("log file is not removed: %v", err)
+	}
+
+	msgs = nil
+	_, err := LoadFrom(strings.NewReader("FAIL"), conf)
+	var perr *PandocError
+	if !errors.As(err, &perr) {
+		t.Fatalf("got %v", err)
+	} else if len(perr.Log) != 2 || len(msgs) != 2 || perr.Log[0].Type != "DuplicateIdentifier" {
+		t.Errorf("got %v, %v", perr.Log, msgs)
+	}
+}
+
+func TestReport(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	exe := filepath.Join(t.TempDir(), "pandoc")
+	script := `#!/bin/sh
+for a in "$@"; do
+	case "$a" in --log=*) log="${a#--log=}" ;; esac
+done
+printf '%s' '[{"verbosity":"INFO","type":"LoadedResource","path":"a.png"},{"verbosity":"WARNING","type":"CiteprocWarning","message":"unknown key"}]' >"$log"
+cat
+`
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		rep  Report
+		logs int
+	)
+	conf := Format("json").WithPandoc(exe).WithLog(func(LogMessage) { logs++ }).WithReport(&rep)
+	doc := &Pandoc{Blocks: []Block{&Para{Inlines: []Inline{&Str{"a"}}}}}
+	for i := 0; i < 2; i++ {
+		if err := doc.StoreTo(&strings.Builder{}, conf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(rep.Messages()); n != 4 || logs != 4 {
+		t.Errorf("got %d messages, %d logged", n, logs)
+	}
+	if w := rep.Warnings(); len(w) != 2 || w[0].Type != "CiteprocWarning" {
+		t.Errorf("got warnings %v", w)
+	}
+	rep.Reset()
+	if n := len(rep.Messages()); n != 0 {
+		t.Errorf("got %d messages after reset", n)
+	}
+}
@@ -0,0 +1,299 @@
+package pandoc
+
+import (
+	"errors"
+	"io"
+	"reflect"
+)
+
+// Matches element e of type E against template m of type T.
+// Returns e as T and true if e matches. Does not modify m.
+//
+// An element matches a template of the same type if its children match
+// the children of the template. A nil list of children in the template
+// matches any children, a non-nil list must match item by item. Other
+// fields of elements are not compared, use Pattern to check them. A
+// Pattern in a list of children matches elements by a predicate and may
+// be repeated or capture the elements it matched.
+//
+// Example:
+//
+//	  var tmpl = &Para{[]Inline{&Code{}, &Link{}}}
+//	  Query(doc, func(e Block) {
+//		     if para, ok := Match(tmpl, e); ok {
+//		         ... // para is Para that consists of a single Code followed by Link
+func Match[T Element, E Element](m T, e E) (T, bool) {
+	var zero T
+	r, ok := any(e).(T)
+	if !ok {
+		return zero, false
+	}
+	var mt matcher
+	if !mt.element(m, e) {
+		return zero, false
+	}
+	mt.apply()
+	return r, true
+}
+
+// A template element of Match matching any element satisfying all of
+// its conditions. Pattern is both Inline and Block, so it can be placed
+// in any list of children of a template. Patterns can't be written.
+//
+// Example:
+//
+//	var link []Element
+//	tmpl := &Para{[]Inline{
+//	    OneOrMore(&Str{}),
+//	    &Space{},
+//	    &Pattern{Template: &Link{}, Capture: &link},
+//	    ZeroOrMore(nil),
+//	}}
+type Pattern struct {
+	Template Element            // The template to match, nil matches any element
+	Where    func(Element) bool // The predicate to satisfy, if not nil
+	Attr     func(*Attr) bool   // The predicate on attributes to satisfy, if not nil; elements without attributes don't match
+
+	// The number of consecutive elements of a list to match, greedily.
+	// Zero Min and Max match a single element, negative Max is unbounded.
+	Min, Max int
+
+	// If not nil, set to the matched elements on a successful match.
+	// Elements matched by several occurrences of the pattern are
+	// concatenated.
+	Capture *[]Element
+}
+
+// Returns a pattern matching any single element.
+func Any() *Pattern { return &Pattern{} }
+
+// Returns a pattern matching one or more elements matching the template.
+func OneOrMore(tmpl Element) *Pattern { return &Pattern{Template: tmpl, Min: 1, Max: -1} }
+
+// Returns a pattern matching any number of elements matching the template.
+func ZeroOrMore(tmpl Element) *Pattern { return &Pattern{Template: tmpl, Max: -1} }
+
+// Returns a pattern matching an optional element matching the template.
+func Optional(tmpl Element) *Pattern { return &Pattern{Template: tmpl, Max: 1} }
+
+// Returns a copy of the pattern capturing the matched elements to dst.
+func (p Pattern) Bind(dst *[]Element) *Pattern {
+	p.Capture = dst
+	return &p
+}
+
+func (p *Pattern) Tag() Tag { return "Pattern" }
+func (p *Pattern) clone() Element {
+	c := *p
+	return &c
+}
+func (*Pattern) inline()               {}
+func (*Pattern) block()                {}
+func (*Pattern) element()              {}
+func (*Pattern) Field(int) any         { return nil }
+func (*Pattern) write(io.Writer) error { return errors.New("can't write a pattern") }
+
+// returns the bounds of repetitions of the pattern in a list of n
+// elements
+func (p *Pattern) bounds(n int) (int, int) {
+	lo, hi := p.Min, p.Max
+	if lo == 0 && hi == 0 {
+		lo, hi = 1, 1
+	}
+	if hi < 0 || hi > n {
+		hi = n
+	}
+	return lo, hi
+}
+
+type capture struct {
+	dst  *[]Element
+	elts []Element
+}
+
+// state of a match: captures made so far, undone on backtracking
+type matcher struct {
+	captures []capture
+}
+
+// sets captured elements
+func (m *matcher) apply() {
+	for _, c := range m.captures {
+		*c.dst = nil
+	}
+	for _, c := range m.captures {
+		*c.dst = append(*c.dst, c.elts...)
+	}
+}
+
+func (m *matcher) element(t, e Element) bool {
+	if lb, ok := e.(*LazyBlock); ok {
+		var err error
+		if e, err = lb.Decode(); err != nil {
+			return false
+		}
+	}
+	if p, ok := t.(*Pattern); ok {
+		if !m.pattern(p, e) {
+			return false
+		} else if p.Capture != nil {
+			m.captures = append(m.captures, capture{p.Capture, []Element{e}})
+		}
+		return true
+	} else if reflect.TypeOf(t) != reflect.TypeOf(e) {
+		return false
+	}
+	mark := len(m.captures)
+	for i := 0; ; i++ {
+		part := t.Field(i)
+		if part == nil {
+			return true
+		} else if !m.part(part, e.Field(i)) {
+			m.captures = m.captures[:mark]
+			return false
+		}
+	}
+}
+
+// matches e against the conditions of p, except for repetitions
+func (m *matcher) pattern(p *Pattern, e Element) bool {
+	if p.Where != nil && !p.Where(e) {
+		return false
+	} else if p.Attr != nil {
+		if attr := attrOf(e); attr == nil || !p.Attr(attr) {
+			return false
+		}
+	}
+	return p.Template == nil || m.element(p.Template, e)
+}
+
+// matches a field of children of the element against the field of the
+// template
+func (m *matcher) part(t, e any) bool {
+	if leaf(t) {
+		return true
+	}
+	switch t := t.(type) {
+	case *[]Inline:
+		return matchList(m, *t, *e.(*[]Inline))
+	case *[]Block:
+		return matchList(m, *t, *e.(*[]Block))
+	case *[]MetaValue:
+		return matchList(m, *t, *e.(*[]MetaValue))
+	case *[]*Citation:
+		return matchList(m, *t, *e.(*[]*Citation))
+	case *[]*TableBody:
+		return matchList(m, *t, *e.(*[]*TableBody))
+	case *[]*TableRow:
+		return matchList(m, *t, *e.(*[]*TableRow))
+	case *[]*TableCell:
+		return matchList(m, *t, *e.(*[]*TableCell))
+	case *[][]Inline:
+		return matchLists(m, *t, *e.(*[][]Inline))
+	case *[][]Block:
+		return matchLists(m, *t, *e.(*[][]Block))
+	case *[]Definition:
+		return matchValues(m, *t, *e.(*[]Definition))
+	case *[]ColSpec:
+		return matchValues(m, *t, *e.(*[]ColSpec))
+	case *Meta:
+		l := *e.(*Meta)
+		if *t == nil {
+			return true
+		} else if len(*t) != len(l) {
+			return false
+		}
+		for i := range l {
+			if (*t)[i].Key != l[i].Key || !m.element((*t)[i].Value, l[i].Value) {
+				return false
+			}
+		}
+		return true
+	case *Caption:
+		return m.element(t, e.(*Caption))
+	case *TableHeadFoot:
+		return m.element(t, e.(*TableHeadFoot))
+	default:
+		// children the matcher does not know of do not match
+		return false
+	}
+}
+
+// matches list l against template t, backtracking on repeated patterns
+func matchList[T Element](m *matcher, t, l []T) bool {
+	if t == nil {
+		return true
+	} else if len(t) == 0 {
+		return len(l) == 0
+	}
+	p, ok := any(t[0]).(*Pattern)
+	if !ok {
+		mark := len(m.captures)
+		if len(l) > 0 && m.element(t[0], l[0]) && matchList(m, t[1:], l[1:]) {
+			return true
+		}
+		m.captures = m.captures[:mark]
+		return false
+	}
+	lo, hi := p.bounds(len(l))
+	mark := len(m.captures)
+	n := 0
+	for n < hi && m.pattern(p, l[n]) {
+		n++
+	}
+	nested := len(m.captures) > mark
+	for longest := n; n >= lo; n-- {
+		if nested && n < longest {
+			// redo the captures of the shorter prefix
+			m.captures = m.captures[:mark]
+			for i := 0; i < n; i++ {
+				m.pattern(p, l[i])
+			}
+		}
+		if matchList(m, t[1:], l[n:]) {
+			if p.Capture != nil {
+				elts := make([]Element, n)
+				for i := range elts {
+					elts[i] = l[i]
+				}
+				m.captures = append(m.captures, capture{p.Capture, elts})
+			}
+			return true
+		}
+	}
+	m.captures = m.captures[:mark]
+	return false
+}
+
+// matches lists of lists item by item
+func matchLists[T Element](m *matcher, t, l [][]T) bool {
+	if t == nil {
+		return true
+	} else if len(t) != len(l) {
+		return false
+	}
+	for i := range t {
+		if !matchList(m, t[i], l[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches lists of struct elements item by item
+func matchValues[T any, PT interface {
+	*T
+	Element
+}](m *matcher, t, l []T) bool {
+	if t == nil {
+		return true
+	} else if len(t) != len(l) {
+		return false
+	}
+	for i := range t {
+		if !m.element(PT(&t[i]), PT(&l[i])) {
+			return false
+		}
+	}
+	return true
+}
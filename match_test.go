@@ -0,0 +1,103 @@
+package pandoc
+
+import (
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	para := &Para{Inlines: []Inline{
+		&Str{"see"}, &Space{}, &Str{"the"}, &Space{},
+		&Link{Attr: Attr{Classes: []string{"ref"}}, Inlines: []Inline{&Str{"docs"}}},
+	}}
+	str := &Pattern{Template: &Str{}}
+	for _, test := range []struct {
+		name string
+		tmpl Block
+		want bool
+	}{
+		{"type", &Para{}, true},
+		{"other type", &Plain{}, false},
+		{"empty", &Para{Inlines: []Inline{}}, false},
+		{"exact", &Para{Inlines: []Inline{&Str{}, &Space{}, &Str{}, &Space{}, &Link{}}}, true},
+		{"exact mismatch", &Para{Inlines: []Inline{&Str{}, &Space{}, &Str{}, &Space{}, &Str{}}}, false},
+		{"any", &Para{Inlines: []Inline{Any(), Any(), Any(), Any(), Any()}}, true},
+		{"too short", &Para{Inlines: []Inline{Any(), Any()}}, false},
+		{"zero or more", &Para{Inlines: []Inline{ZeroOrMore(nil), &Link{}}}, true},
+		{"one or more", &Para{Inlines: []Inline{OneOrMore(&Str{}), &Link{}}}, false},
+		{"backtracking", &Para{Inlines: []Inline{ZeroOrMore(nil), &Space{}, ZeroOrMore(nil)}}, true},
+		{"optional", &Para{Inlines: []Inline{Optional(&Space{}), &Str{}, ZeroOrMore(nil)}}, true},
+		{"nested", &Para{Inlines: []Inline{ZeroOrMore(nil), &Link{Inlines: []Inline{str}}}}, true},
+		{"where", &Para{Inlines: []Inline{&Pattern{Where: func(e Element) bool {
+			s, ok := e.(*Str)
+			return ok && s.Text == "see"
+		}}, ZeroOrMore(nil)}}, true},
+		{"attr", &Para{Inlines: []Inline{ZeroOrMore(nil), &Pattern{Attr: func(a *Attr) bool {
+			return a.HasClass("ref")
+		}}}}, true},
+		{"attr mismatch", &Para{Inlines: []Inline{ZeroOrMore(nil), &Pattern{Attr: func(a *Attr) bool {
+			return a.HasClass("cite")
+		}}}}, false},
+		{"attr without attributes", &Para{Inlines: []Inline{&Pattern{Attr: func(*Attr) bool {
+			return true
+		}}, ZeroOrMore(nil)}}, false},
+		{"pattern", &Pattern{Template: &Para{}}, true},
+	} {
+		if _, ok := Match(test.tmpl, Block(para)); ok != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, ok, test.want)
+		}
+	}
+}
+
+func TestMatchUnknownChildren(t *testing.T) {
+	if (&matcher{}).part(new(float64), new(float64)) {
+		t.Error("unknown children match")
+	}
+}
+
+func TestMatchCapture(t *testing.T) {
+	para := &Para{Inlines: []Inline{
+		&Str{"a"}, &Space{}, &Str{"b"}, &Space{}, &Emph{[]Inline{&Str{"c"}}}, &Str{"d"},
+	}}
+	var (
+		words, emph, inner []Element
+		tail               = []Element{&Str{"stale"}}
+	)
+	tmpl := &Para{Inlines: []Inline{
+		ZeroOrMore(nil).Bind(&words),
+		(&Pattern{Template: &Emph{Inlines: []Inline{Any().Bind(&inner)}}}).Bind(&emph),
+		ZeroOrMore(nil).Bind(&tail),
+	}}
+	if _, ok := Match(tmpl, para); !ok {
+		t.Fatal("no match")
+	}
+	if len(words) != 4 || Stringify(words[2]) != "b" {
+		t.Errorf("words: %v", words)
+	}
+	if len(emph) != 1 || emph[0] != para.Inlines[4] {
+		t.Errorf("emph: %v", emph)
+	}
+	if len(inner) != 1 || Stringify(inner[0]) != "c" {
+		t.Errorf("inner: %v", inner)
+	}
+	if len(tail) != 1 || Stringify(tail[0]) != "d" {
+		t.Errorf("tail: %v", tail)
+	}
+
+	// captures are not set on failure, nor kept from failed attempts
+	var strs, rest []Element
+	tmpl = &Para{Inlines: []Inline{OneOrMore(&Str{}).Bind(&strs), &Link{}}}
+	if _, ok := Match(tmpl, para); ok || strs != nil {
+		t.Errorf("failed match: %v, %v", ok, strs)
+	}
+	tmpl = &Para{Inlines: []Inline{
+		ZeroOrMore(&Pattern{Where: func(e Element) bool {
+			_, ok := e.(*Emph)
+			return !ok
+		}, Template: Any().Bind(&strs)}),
+		&Emph{},
+		ZeroOrMore(nil).Bind(&rest),
+	}}
+	if _, ok := Match(tmpl, para); !ok || len(strs) != 4 || len(rest) != 1 {
+		t.Errorf("backtracking captures: %v, %v, %v", ok, strs, rest)
+	}
+}
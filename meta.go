@@ -0,0 +1,206 @@
+package pandoc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Works as Filter, but walks only the metadata, passing fun the dotted
+// key of the metadata value the element belongs to. Items of lists are
+// keyed by their index, so the name of the second author is
+// "author.1.name".
+//
+// A metadata value returned to replace a value of a map must be single,
+// an empty list removes the key.
+//
+// Example:
+//
+//	doc.Meta, err = pandoc.FilterMeta(doc.Meta, func(m *pandoc.MetaInlines, key string) ([]pandoc.MetaValue, error) {
+//	    if !pandoc.MatchKey("author.*.name", key) {
+//	        return nil, pandoc.Continue
+//	    }
+//	    return []pandoc.MetaValue{pandoc.MetaString(m.Text())}, pandoc.ReplaceSkip
+//	})
+func FilterMeta[P any, R Element](meta Meta, fun func(P, string) ([]R, error)) (Meta, error) {
+	meta, err := walkMeta(meta, "", fun)
+	if _, ok := isResult(err); !ok {
+		return meta, err
+	}
+	return meta, nil
+}
+
+// Works as Query, but walks only the metadata, passing fun the dotted
+// key of the metadata value the element belongs to.
+//
+// Example:
+//
+//	pandoc.QueryMeta(doc.Meta, func(s pandoc.MetaString, key string) {
+//	    fmt.Println(key, s)
+//	})
+func QueryMeta[P any](meta Meta, fun func(P, string)) {
+	walkMeta(meta, "", func(p P, key string) ([]queryResult, error) {
+		fun(p, key)
+		return nil, nil
+	})
+}
+
+// Reports whether a dotted key matches the pattern, where "*" matches
+// any single component of the key.
+func MatchKey(pattern, key string) bool {
+	for {
+		p, prest, pok := strings.Cut(pattern, ".")
+		k, krest, kok := strings.Cut(key, ".")
+		if p != "*" && p != k {
+			return false
+		} else if !pok || !kok {
+			return pok == kok
+		}
+		pattern, key = prest, krest
+	}
+}
+
+func metaKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func walkMeta[P any, R Element](meta Meta, prefix string, fun func(P, string) ([]R, error)) (Meta, error) {
+	var (
+		updated bool
+		src     = meta
+		err     error
+	)
+	for i := 0; i < len(meta); {
+		var lst []MetaValue
+		lst, err = walkMetaValue(meta[i].Value, metaKey(prefix, meta[i].Key), fun)
+		rslt, ok := isResult(err)
+		if !ok {
+			return src, err
+		}
+		if rslt.replace() {
+			if !updated {
+				updated = true
+				meta = append(Meta(nil), meta...)
+			}
+			switch len(lst) {
+			case 0:
+				meta = append(meta[:i], meta[i+1:]...)
+			case 1:
+				meta[i].Value = lst[0]
+				i++
+			default:
+				return src, ErrUnexpectedType
+			}
+		} else {
+			i++
+		}
+		if rslt.halt() {
+			break
+		}
+	}
+	return meta, metaResult(updated, err)
+}
+
+func walkMetaList[P any, R Element](items []MetaValue, prefix string, fun func(P, string) ([]R, error)) ([]MetaValue, error) {
+	var (
+		updated bool
+		src     = items
+		err     error
+	)
+	for i, n := 0, 0; i < len(items); n++ {
+		var lst []MetaValue
+		lst, err = walkMetaValue(items[i], metaKey(prefix, strconv.Itoa(n)), fun)
+		rslt, ok := isResult(err)
+		if !ok {
+			return src, err
+		}
+		if rslt.replace() {
+			if !updated {
+				updated = true
+				items = append([]MetaValue(nil), items...)
+			}
+			items = append(items[:i], append(lst, items[i+1:]...)...)
+			i += len(lst)
+		} else {
+			i++
+		}
+		if rslt.halt() {
+			break
+		}
+	}
+	return items, metaResult(updated, err)
+}
+
+// returns the traversal result of a list walk
+func metaResult(updated bool, err error) error {
+	rslt, _ := isResult(err)
+	if updated {
+		rslt |= replaceElement
+	}
+	return rslt
+}
+
+// walks the metadata value v and returns its replacement, if any
+func walkMetaValue[P any, R Element](v MetaValue, key string, fun func(P, string) ([]R, error)) ([]MetaValue, error) {
+	switch v.(type) {
+	case *MetaMap, *MetaList:
+	default:
+		return walkList([]MetaValue{v}, func(p P) ([]R, error) {
+			return fun(p, key)
+		}, walker{})
+	}
+	var rslt traversalResult
+	lst := []MetaValue{v}
+	if p, ok := any(v).(P); ok {
+		r, err := fun(p, key)
+		if err == Delete {
+			r, err = nil, ReplaceSkip
+		}
+		if rslt, ok = isResult(err); !ok {
+			return nil, err
+		}
+		if rslt.replace() {
+			lst = make([]MetaValue, len(r))
+			for i := range r {
+				if lst[i], ok = any(r[i]).(MetaValue); !ok {
+					return nil, ErrUnexpectedType
+				}
+			}
+		}
+		if rslt.skipChildren() || rslt.halt() {
+			return lst, rslt
+		}
+	}
+	for i, v := range lst {
+		var err error
+		switch v := v.(type) {
+		case *MetaMap:
+			var entries Meta
+			entries, err = walkMeta(v.Entries, key, fun)
+			if r, _ := isResult(err); r.replace() {
+				lst[i] = &MetaMap{Entries: entries}
+			}
+		case *MetaList:
+			var items []MetaValue
+			items, err = walkMetaList(v.Entries, key, fun)
+			if r, _ := isResult(err); r.replace() {
+				lst[i] = &MetaList{Entries: items}
+			}
+		default:
+			lst[i], err = walkChildren(v, func(p P) ([]R, error) {
+				return fun(p, key)
+			}, walker{})
+		}
+		r, ok := isResult(err)
+		if !ok {
+			return nil, err
+		}
+		rslt |= r & (replaceElement | haltTraversal)
+		if r.halt() {
+			break
+		}
+	}
+	return lst, rslt
+}
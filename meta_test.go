@@ -0,0 +1,101 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func testMeta() Meta {
+	author := func(name, email string) MetaValue {
+		return &MetaMap{Entries: Meta{
+			{Key: "name", Value: &MetaInlines{Inlines: words(name)}},
+			{Key: "email", Value: MetaString(email)},
+		}}
+	}
+	return Meta{
+		{Key: "title", Value: &MetaInlines{Inlines: words("A title")}},
+		{Key: "author", Value: &MetaList{Entries: []MetaValue{
+			author("John Doe", "john@example.com"),
+			author("Jane Roe", "jane@example.com"),
+		}}},
+		{Key: "draft", Value: MetaBool(true)},
+	}
+}
+
+func TestQueryMeta(t *testing.T) {
+	var keys []string
+	QueryMeta(testMeta(), func(s *Str, key string) {
+		keys = append(keys, key+"="+s.Text)
+	})
+	want := "title=A title=title author.0.name=John author.0.name=Doe author.1.name=Jane author.1.name=Roe"
+	if got := strings.Join(keys, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestFilterMeta(t *testing.T) {
+	meta := testMeta()
+	res, err := FilterMeta(meta, func(m *MetaInlines, key string) ([]MetaValue, error) {
+		if !MatchKey("author.*.name", key) {
+			return nil, Continue
+		}
+		return []MetaValue{MetaString(m.Text())}, ReplaceSkip
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authors := res.Get("author").(*MetaList).Entries
+	if name := authors[1].(*MetaMap).Get("name"); name != MetaString("Jane Roe") {
+		t.Errorf("name: %#v", name)
+	}
+	if _, ok := meta.Get("author").(*MetaList).Entries[1].(*MetaMap).Get("name").(*MetaInlines); !ok {
+		t.Errorf("source modified")
+	}
+	if res.Get("title") != meta.Get("title") {
+		t.Errorf("title not shared")
+	}
+
+	// removing a key and items of a list
+	res, err = FilterMeta(meta, func(v MetaValue, key string) ([]MetaValue, error) {
+		if key == "draft" || key == "author.0" {
+			return nil, ReplaceSkip
+		}
+		return nil, Continue
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || len(res.Get("author").(*MetaList).Entries) != 1 {
+		t.Errorf("not removed: %v", res)
+	}
+
+	var visited []string
+	FilterMeta(meta, func(v MetaValue, key string) ([]MetaValue, error) {
+		visited = append(visited, key)
+		if key == "author.0.name" {
+			return nil, Halt
+		}
+		return nil, Continue
+	})
+	if got := strings.Join(visited, " "); got != "title author author.0 author.0.name" {
+		t.Errorf("halt: %s", got)
+	}
+}
+
+func TestMatchKey(t *testing.T) {
+	for _, test := range []struct {
+		pattern, key string
+		match        bool
+	}{
+		{"author.*.name", "author.1.name", true},
+		{"author.*.name", "author.name", false},
+		{"author.*", "author.1.name", false},
+		{"*", "title", true},
+		{"title", "title", true},
+		{"title", "subtitle", false},
+	} {
+		if got := MatchKey(test.pattern, test.key); got != test.match {
+			t.Errorf("MatchKey(%q, %q) = %v", test.pattern, test.key, got)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package pandoc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Typed setters of common pandoc options. Options taking a single value
+// replace a previous value of the option set with a setter or WithOpt,
+// the others are added.
+
+// Text wrapping mode of --wrap.
+type WrapMode string
+
+const (
+	WrapAuto     WrapMode = "auto"
+	WrapNone     WrapMode = "none"
+	WrapPreserve WrapMode = "preserve"
+)
+
+// Top-level division of --top-level-division.
+type Division string
+
+const (
+	DivisionDefault Division = "default"
+	DivisionSection Division = "section"
+	DivisionChapter Division = "chapter"
+	DivisionPart    Division = "part"
+)
+
+// returns a Conf without the long option
+func (c Conf) withoutOpt(opt string) Conf {
+	opts := make([]string, 0, len(c.Opts)+1)
+	for _, o := range c.Opts {
+		if o != "--"+opt && !strings.HasPrefix(o, "--"+opt+"=") {
+			opts = append(opts, o)
+		}
+	}
+	c.Opts = opts
+	return c
+}
+
+// returns the value of the last long option opt, empty if there is none
+func (c *Conf) optValue(opt string) string {
+	vals := c.optValues(opt, 0)
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[len(vals)-1]
+}
+
+// returns the values of the long option opt and the short option short,
+// if not 0
+func (c *Conf) optValues(opt string, short byte) []string {
+	var vals []string
+	for i := 0; i < len(c.Opts); i++ {
+		o := c.Opts[i]
+		if v, ok := strings.CutPrefix(o, "--"+opt+"="); ok {
+			vals = append(vals, v)
+		} else if short != 0 && o == "-"+string(short) && i+1 < len(c.Opts) {
+			i++
+			vals = append(vals, c.Opts[i])
+		} else if short != 0 && len(o) > 2 && o[:2] == "-"+string(short) {
+			vals = append(vals, o[2:])
+		}
+	}
+	return vals
+}
+
+// returns a Conf with the long option set to val, replacing a previous
+// value
+func (c Conf) setOpt(opt string, val ...string) Conf {
+	return c.withoutOpt(opt).WithOpt(opt, val...)
+}
+
+// Returns a Conf producing a standalone document, with header and footer.
+func (c Conf) WithStandalone() Conf {
+	return c.setOpt("standalone")
+}
+
+// Returns a Conf including a table of contents of headings up to level
+// depth, or up to the default level if depth is 0.
+func (c Conf) WithTOC(depth int) Conf {
+	c = c.setOpt("toc")
+	if depth > 0 {
+		c = c.setOpt("toc-depth", strconv.Itoa(depth))
+	}
+	return c
+}
+
+// Returns a Conf numbering section headings.
+func (c Conf) WithNumberSections() Conf {
+	return c.setOpt("number-sections")
+}
+
+// Returns a Conf wrapping text in the output with the mode.
+func (c Conf) WithWrap(mode WrapMode) Conf {
+	return c.setOpt("wrap", string(mode))
+}
+
+// Returns a Conf wrapping text in the output at n columns.
+func (c Conf) WithColumns(n int) Conf {
+	return c.setOpt("columns", strconv.Itoa(n))
+}
+
+// Returns a Conf shifting heading levels by n, which may be negative.
+func (c Conf) WithShiftHeadingLevel(n int) Conf {
+	return c.setOpt("shift-heading-level-by", strconv.Itoa(n))
+}
+
+// Returns a Conf treating top-level headings as the division.
+func (c Conf) WithTopLevelDivision(div Division) Conf {
+	return c.setOpt("top-level-division", string(div))
+}
+
+// Returns a Conf using the document at path as the style reference of
+// docx, odt and pptx output.
+func (c Conf) WithReferenceDoc(path string) Conf {
+	return c.setOpt("reference-doc", path)
+}
+
+// Returns a Conf using the template at path for standalone output.
+func (c Conf) WithTemplate(path string) Conf {
+	return c.setOpt("template", path)
+}
+
+// Returns a Conf setting the template variable key to val, replacing a
+// previous value of key. Strings and numbers are set as they are, true
+// sets the variable without a value and false unsets it, a slice of them
+// sets a list. Other values, such as maps, are set as JSON with
+// --variable-json, which requires pandoc 3.7.
+func (c Conf) WithVariable(key string, val any) Conf {
+	c = c.withoutField("variable", 'V', key).withoutField("variable-json", 0, key)
+	if b, ok := val.(bool); ok {
+		if b {
+			c = c.WithOpt("variable", key)
+		}
+		return c
+	} else if s, ok := scalar(val); ok {
+		return c.WithOpt("variable", key, s)
+	} else if l, ok := scalars(val); ok {
+		for _, s := range l {
+			c = c.WithOpt("variable", key, s)
+		}
+		return c
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return c.WithOpt("variable", key, fmt.Sprint(val))
+	}
+	return c.WithOpt("variable-json", key, string(b))
+}
+
+// Returns a Conf setting the metadata field key to val, replacing a
+// previous value of key. Strings, booleans and numbers are set with
+// --metadata, lists of them with one --metadata per item. Other values,
+// such as maps or lists of maps, are encoded as JSON to a metadata file
+// in the temporary directory, written when pandoc is run by Load and
+// Store functions and kept for runs with the same values. A Runner must
+// share the local file system for them, as ExecRunner does.
+func (c Conf) WithMetadata(key string, val any) Conf {
+	c = c.withoutField("metadata", 'M', key)
+	c.meta = slices.DeleteFunc(slices.Clone(c.meta), func(f metaField) bool { return f.key == key })
+	if b, ok := val.(bool); ok {
+		return c.WithOpt("metadata", key, strconv.FormatBool(b))
+	} else if s, ok := scalar(val); ok && !isBoolText(s) {
+		return c.WithOpt("metadata", key, s)
+	} else if l, ok := scalars(val); ok && len(l) > 1 && !slices.ContainsFunc(l, isBoolText) {
+		// pandoc makes a list of a repeated field; a single item would
+		// be a scalar
+		for _, s := range l {
+			c = c.WithOpt("metadata", key, s)
+		}
+		return c
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return c.WithOpt("metadata", key, fmt.Sprint(val))
+	}
+	c.meta = append(c.meta, metaField{key, b})
+	return c
+}
+
+// a metadata field set in the metadata file of a Conf
+type metaField struct {
+	key string
+	val json.RawMessage
+}
+
+// returns the path and the content of the metadata file of c, named by
+// the content
+func (c *Conf) metaFile() (string, []byte) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range c.meta {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(f.key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(f.val)
+	}
+	buf.WriteByte('}')
+	sum := sha256.Sum256(buf.Bytes())
+	return filepath.Join(os.TempDir(), "go-pandoc-meta-"+hex.EncodeToString(sum[:8])+".json"), buf.Bytes()
+}
+
+// writes the metadata file of c unless there is one
+func (c *Conf) writeMetaFile() error {
+	if len(c.meta) == 0 {
+		return nil
+	}
+	path, data := c.metaFile()
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	// renamed once written, so that a concurrent run never reads a part
+	f, err := os.CreateTemp(filepath.Dir(path), "go-pandoc-meta-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// returns a Conf without the long option opt, or the short option short,
+// setting the field key, e.g. --metadata=key:val
+func (c Conf) withoutField(opt string, short byte, key string) Conf {
+	opts := make([]string, 0, len(c.Opts)+1)
+	for i := 0; i < len(c.Opts); i++ {
+		o := c.Opts[i]
+		if v, ok := strings.CutPrefix(o, "--"+opt+"="); ok && fieldKey(v) == key {
+			continue
+		} else if short != 0 && o == "-"+string(short) && i+1 < len(c.Opts) {
+			if fieldKey(c.Opts[i+1]) == key {
+				i++
+				continue
+			}
+		} else if short != 0 && len(o) > 2 && o[:2] == "-"+string(short) && fieldKey(o[2:]) == key {
+			continue
+		}
+		opts = append(opts, o)
+	}
+	c.Opts = opts
+	return c
+}
+
+// splits a field of --metadata or --variable, KEY[=VAL] or KEY[:VAL];
+// ok is false for a key alone
+func splitField(s string) (key, val string, ok bool) {
+	if i := strings.IndexAny(s, ":="); i >= 0 {
+		return s[:i], s[i+1:], true
+	}
+	return s, "", false
+}
+
+func fieldKey(s string) string {
+	key, _, _ := splitField(s)
+	return key
+}
+
+// returns v as a command line value, if it is a string, a boolean or
+// a number
+func scalar(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// returns the command line values of a slice or an array of scalars
+func scalars(v any) ([]string, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	l := make([]string, rv.Len())
+	for i := range l {
+		s, ok := scalar(rv.Index(i).Interface())
+		if !ok {
+			return nil, false
+		}
+		l[i] = s
+	}
+	return l, true
+}
+
+// reports whether pandoc reads a --metadata value as a boolean
+func isBoolText(s string) bool {
+	return strings.EqualFold(s, "true") || strings.EqualFold(s, "false")
+}
+
+// Returns a Conf reading metadata from the YAML or JSON file at path.
+func (c Conf) WithMetadataFile(path string) Conf {
+	return c.WithOpt("metadata-file", path)
+}
+
+// Returns a Conf running the JSON filter at path.
+func (c Conf) WithFilter(path string) Conf {
+	return c.WithOpt("filter", path)
+}
+
+// Returns a Conf running the Lua filter at path.
+func (c Conf) WithLuaFilter(path string) Conf {
+	return c.WithOpt("lua-filter", path)
+}
+
+// Returns a Conf processing citations.
+func (c Conf) WithCiteproc() Conf {
+	return c.setOpt("citeproc")
+}
+
+// Returns a Conf with the bibliography at path for citations.
+func (c Conf) WithBibliography(path string) Conf {
+	return c.WithOpt("bibliography", path)
+}
+
+// Returns a Conf formatting citations with the CSL style at path.
+func (c Conf) WithCSL(path string) Conf {
+	return c.setOpt("csl", path)
+}
+
+// Returns a Conf linking the CSS stylesheet url in HTML output.
+func (c Conf) WithCSS(url string) Conf {
+	return c.WithOpt("css", url)
+}
+
+// Returns a Conf highlighting code with the style, e.g. "tango", or the
+// theme file with the .theme extension, or disabling highlighting if
+// style is empty. See HighlightStyles and ValidateHighlightStyle.
+func (c Conf) WithHighlightStyle(style string) Conf {
+	if style == "" {
+		return c.withoutOpt("highlight-style").setOpt("no-highlight")
+	}
+	return c.withoutOpt("no-highlight").setOpt("highlight-style", style)
+}
+
+// Returns a Conf highlighting code with the KDE syntax definition at
+// path, in addition to the built-in ones.
+func (c Conf) WithSyntaxDefinition(path string) Conf {
+	return c.WithOpt("syntax-definition", path)
+}
+
+// Returns a Conf producing PDF with the engine, e.g. "xelatex", instead
+// of the detected one, see PDFEngine.
+func (c Conf) WithPDFEngine(engine string) Conf {
+	return c.setOpt("pdf-engine", engine)
+}
+
+// Returns a Conf embedding images, stylesheets and scripts in HTML
+// output.
+func (c Conf) WithEmbedResources() Conf {
+	return c.setOpt("embed-resources")
+}
+
+// Returns a Conf looking up images and other resources in dirs.
+func (c Conf) WithResourcePath(dirs ...string) Conf {
+	return c.setOpt("resource-path", strings.Join(dirs, string(os.PathListSeparator)))
+}
+
+// Returns a Conf extracting images and other media of the input, e.g.
+// of docx or epub, to dir, which is made if missing, and linking them
+// from the document.
+func (c Conf) WithExtractMedia(dir string) Conf {
+	return c.setOpt("extract-media", dir)
+}
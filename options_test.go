@@ -0,0 +1,86 @@
+package pandoc
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	base := Format("docx").WithOpt("toc-depth", "2").WithStandalone()
+	for _, tt := range []struct {
+		conf Conf
+		opts string
+	}{
+		{base.WithTOC(0), "--toc-depth=2 --standalone --toc"},
+		{base.WithTOC(3).WithStandalone(), "--toc --toc-depth=3 --standalone"},
+		{base.WithWrap(WrapNone).WithColumns(72).WithWrap(WrapPreserve), "--toc-depth=2 --standalone --columns=72 --wrap=preserve"},
+		{base.WithVariable("geometry", "margin=1in").WithMetadata("title", "Doc").WithVariable("fontsize", "12pt"),
+			"--toc-depth=2 --standalone --variable=geometry:margin=1in --metadata=title:Doc --variable=fontsize:12pt"},
+		{base.WithShiftHeadingLevel(-1).WithReferenceDoc("ref.docx").WithTopLevelDivision(DivisionChapter),
+			"--toc-depth=2 --standalone --shift-heading-level-by=-1 --reference-doc=ref.docx --top-level-division=chapter"},
+		{base.WithHighlightStyle("tango").WithHighlightStyle(""), "--toc-depth=2 --standalone --no-highlight"},
+		{base.WithHighlightStyle("").WithHighlightStyle("kate"), "--toc-depth=2 --standalone --highlight-style=kate"},
+	} {
+		if got := strings.Join(tt.conf.Opts, " "); got != tt.opts {
+			t.Errorf("got %q, want %q", got, tt.opts)
+		}
+	}
+	if got := strings.Join(base.Opts, " "); got != "--toc-depth=2 --standalone" {
+		t.Errorf("base is modified: %q", got)
+	}
+}
+
+func TestFields(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	base := Format("html").WithOpt("V", "draft=yes").WithVariable("lang", "en")
+	for _, tt := range []struct {
+		conf Conf
+		opts string
+	}{
+		{base.WithVariable("draft", true), "--variable=lang:en --variable=draft"},
+		{base.WithVariable("draft", false).WithVariable("lang", 2), "--variable=lang:2"},
+		{base.WithVariable("toc-title", "A: B").WithVariable("lang", "de"), "-V draft=yes --variable=toc-title:A: B --variable=lang:de"},
+		{base.WithVariable("author", []string{"A", "B"}), "-V draft=yes --variable=lang:en --variable=author:A --variable=author:B"},
+		{base.WithVariable("names", map[string]int{"b": 2, "a": 1}).WithVariable("x", []any{1, []int{2}}),
+			`-V draft=yes --variable=lang:en --variable-json=names:{"a":1,"b":2} --variable-json=x:[1,[2]]`},
+		{base.WithVariable("names", map[string]int{}).WithVariable("names", "n"), "-V draft=yes --variable=lang:en --variable=names:n"},
+		{Format("html").WithMetadata("draft", true).WithMetadata("version", 1.5).WithMetadata("title", "true"),
+			"--metadata=draft:true --metadata=version:1.5"},
+		{Format("html").WithMetadata("keywords", []string{"a", "b"}).WithMetadata("draft", false).WithMetadata("keywords", "c"),
+			"--metadata=draft:false --metadata=keywords:c"},
+	} {
+		if got := strings.Join(tt.conf.Opts, " "); got != tt.opts {
+			t.Errorf("got %q, want %q", got, tt.opts)
+		}
+	}
+
+	conf := Format("html").WithMetadata("title", "Doc").
+		WithMetadata("author", []map[string]string{{"name": "A"}}).
+		WithMetadata("keywords", []string{"a"}).
+		WithMetadata("title", map[string]string{"main": "Doc"})
+	if got := strings.Join(conf.Opts, " "); got != "" {
+		t.Errorf("got options %q", got)
+	}
+	cmd, err := conf.storeCmd(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, ok := strings.CutPrefix(cmd.Args[len(cmd.Args)-1], "--metadata-file=")
+	if !ok {
+		t.Fatalf("no metadata file: %q", cmd.Args)
+	}
+	want := `{"author":[{"name":"A"}],"keywords":["a"],"title":{"main":"Doc"}}`
+	if b, err := os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	} else if string(b) != want {
+		t.Errorf("got metadata file %s, want %s", b, want)
+	}
+	if cmd := conf.StoreCommand(""); cmd.Args[len(cmd.Args)-1] != "--metadata-file="+path {
+		t.Errorf("got %q, want the same metadata file", cmd.Args)
+	}
+	if cmd := conf.WithMetadata("title", "Doc").StoreCommand(""); cmd.Args[len(cmd.Args)-1] == "--metadata-file="+path {
+		t.Errorf("got %q, want another metadata file", cmd.Args)
+	}
+}
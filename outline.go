@@ -0,0 +1,76 @@
+package pandoc
+
+// An entry of a document outline. It is suitable for encoding with
+// encoding/json.
+type OutlineEntry struct {
+	Level    int             `json:"level"`
+	Ident    string          `json:"id,omitempty"`
+	Title    string          `json:"title"`
+	Position int             `json:"position"` // Index of the top-level block containing the header
+	Words    int             `json:"words"`    // Number of words in the section, including subsections
+	Children []*OutlineEntry `json:"children,omitempty"`
+}
+
+// Outline returns the tree of document headers, including headers nested
+// in other blocks such as Divs. Words before the first header are not
+// counted in any entry.
+//
+// Example:
+//
+//	json.NewEncoder(w).Encode(pandoc.Outline(doc))
+func Outline(doc *Pandoc) []*OutlineEntry {
+	var (
+		roots []*OutlineEntry
+		stack []*OutlineEntry
+		pos   int
+	)
+	var visit func(e Element)
+	visit = func(e Element) {
+		switch e := e.(type) {
+		case *Header:
+			entry := &OutlineEntry{
+				Level:    e.Level,
+				Ident:    e.Ident(),
+				Title:    Stringify(e),
+				Position: pos,
+			}
+			for len(stack) > 0 && stack[len(stack)-1].Level >= e.Level {
+				stack = stack[:len(stack)-1]
+			}
+			if len(stack) == 0 {
+				roots = append(roots, entry)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, entry)
+			}
+			stack = append(stack, entry)
+			return
+		case *Str:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Words++
+			}
+		}
+		for _, c := range children(e) {
+			if !isNil(c) {
+				visit(c)
+			}
+		}
+	}
+	for i, b := range doc.Blocks {
+		pos = i
+		if !isNil(b) {
+			visit(decodedBlock(b))
+		}
+	}
+	var total func(l []*OutlineEntry)
+	total = func(l []*OutlineEntry) {
+		for _, e := range l {
+			total(e.Children)
+			for _, c := range e.Children {
+				e.Words += c.Words
+			}
+		}
+	}
+	total(roots)
+	return roots
+}
@@ -0,0 +1,32 @@
+package pandoc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestOutline(t *testing.T) {
+	h := func(level int, id, text string) *Header {
+		return &Header{Attr: Attr{Id: id}, Level: level, Inlines: words(text)}
+	}
+	doc := &Pandoc{Blocks: []Block{
+		&Para{words("preface")},
+		h(1, "intro", "Introduction"),
+		&Para{words("a b c")},
+		h(2, "", "Details"),
+		&Para{words("d e")},
+		&Div{Blocks: []Block{h(2, "more", "More details"), &Para{words("f")}}},
+		h(1, "end", "The end"),
+	}}
+	b, err := json.Marshal(Outline(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `[{"level":1,"id":"intro","title":"Introduction","position":1,"words":6,"children":[` +
+		`{"level":2,"title":"Details","position":3,"words":2},` +
+		`{"level":2,"id":"more","title":"More details","position":5,"words":1}]},` +
+		`{"level":1,"id":"end","title":"The end","position":6,"words":0}]`
+	if string(b) != want {
+		t.Errorf("unexpected outline\n%s\nwant\n%s", b, want)
+	}
+}
@@ -155,3 +155,24 @@ func FuzzScanner(f *testing.F) {
 		}
 	})
 }
+
+func TestSyntaxError(t *testing.T) {
+	pad := strings.Repeat("    \n", 100)
+	for _, tt := range []struct {
+		in           string
+		line, column int
+		context      string
+	}{
+		{"{\n\n   x", 3, 4, "x"},
+		{pad + `{"pandoc-api-version":[1,23,1],"meta":{},` + "\n" + `"blocks":[{"t":"Para","c":[{"t":"Str","c":"a\q"}]}]}`, 102, 46, `"a\q"`},
+	} {
+		_, err := ReadFrom(strings.NewReader(tt.in))
+		var se *SyntaxError
+		if !errors.As(err, &se) {
+			t.Fatalf("%q: expected SyntaxError, got %v", tt.in, err)
+		}
+		if se.Line != tt.line || se.Column != tt.column || !strings.Contains(se.Context, tt.context) {
+			t.Errorf("got %s, want line %d, column %d near %q", se, tt.line, tt.column, tt.context)
+		}
+	}
+}
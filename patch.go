@@ -0,0 +1,204 @@
+package pandoc
+
+import (
+	"io"
+)
+
+// A serializable set of changes turning one document into another, see
+// NewPatch. Patches are stored as JSON:
+//
+//	{"pandoc-patch":1,
+//	 "meta":{...},                      // metadata entries set
+//	 "unset":["key",...],               // metadata keys removed
+//	 "hunks":[{"old":3,                 // index of the first old block
+//	           "delete":[...],          // old blocks replaced...
+//	           "insert":[...]}, ...]}   // ...with new ones
+type DocPatch struct {
+	Meta  Meta     // Metadata entries set or changed
+	Unset []string // Metadata keys removed
+	Hunks []Hunk   // Changes of blocks, in order
+}
+
+// A change of consecutive top-level blocks.
+type Hunk struct {
+	Old    int     // Index of the first changed block in the old document
+	Delete []Block // Blocks removed, checked against the document by Apply
+	Insert []Block // Blocks inserted in place of the removed ones
+}
+
+const patchFormat = 1
+
+// Returns the patch transforming document a into b. Blocks are compared
+// as with DiffList.
+func NewPatch(a, b *Pandoc) *DocPatch {
+	p := &DocPatch{}
+	for _, e := range b.Meta {
+		if v := a.Meta.Get(e.Key); v == nil || !Equal(v, e.Value) {
+			p.Meta = append(p.Meta, e)
+		}
+	}
+	for _, e := range a.Meta {
+		if b.Meta.Get(e.Key) == nil {
+			p.Unset = append(p.Unset, e.Key)
+		}
+	}
+	var (
+		h *Hunk
+		x int // index of the current block of a
+	)
+	for _, e := range Diff(a, b) {
+		if e.Op == DiffEqual {
+			h = nil
+			x++
+			continue
+		}
+		if h == nil {
+			p.Hunks = append(p.Hunks, Hunk{Old: x})
+			h = &p.Hunks[len(p.Hunks)-1]
+		}
+		if e.Op == DiffDelete {
+			h.Delete = append(h.Delete, e.Elt)
+			x++
+		} else {
+			h.Insert = append(h.Insert, e.Elt)
+		}
+	}
+	return p
+}
+
+// Applies patch p to the document and returns the patched copy. Returns
+// ErrPatchMismatch if the blocks the patch removes are not in the
+// document.
+func Apply(doc *Pandoc, p *DocPatch) (*Pandoc, error) {
+	res := &Pandoc{Version: doc.Version, Meta: append(Meta(nil), doc.Meta...)}
+	for _, e := range p.Meta {
+		res.Meta.Set(e.Key, e.Value)
+	}
+	for _, key := range p.Unset {
+		res.Meta.Set(key, nil)
+	}
+	var x int
+	for _, h := range p.Hunks {
+		if h.Old < x || h.Old+len(h.Delete) > len(doc.Blocks) {
+			return nil, ErrPatchMismatch
+		}
+		res.Blocks = append(res.Blocks, doc.Blocks[x:h.Old]...)
+		for i, blk := range h.Delete {
+			if !Equal(blk, doc.Blocks[h.Old+i]) {
+				return nil, ErrPatchMismatch
+			}
+		}
+		res.Blocks = append(res.Blocks, h.Insert...)
+		x = h.Old + len(h.Delete)
+	}
+	res.Blocks = append(res.Blocks, doc.Blocks[x:]...)
+	return res, nil
+}
+
+func (h *Hunk) write(w io.Writer) error {
+	if err := writeDelim(w, '{'); err != nil {
+		return err
+	}
+	if err := writeField(w, "old", ',', num(h.Old)); err != nil {
+		return err
+	}
+	if err := writeField(w, "delete", ',', list(h.Delete)); err != nil {
+		return err
+	}
+	return writeField(w, "insert", '}', list(h.Insert))
+}
+
+func (p *DocPatch) write(w io.Writer) error {
+	if err := writeDelim(w, '{'); err != nil {
+		return err
+	}
+	if err := writeField(w, "pandoc-patch", ',', num(patchFormat)); err != nil {
+		return err
+	}
+	if err := writeKey(w, "meta"); err != nil {
+		return err
+	}
+	if err := writeMetaMap(w, p.Meta); err != nil {
+		return err
+	}
+	if err := writeDelim(w, ','); err != nil {
+		return err
+	}
+	if err := writeField(w, "unset", ',', strList(p.Unset)); err != nil {
+		return err
+	}
+	hunks := make([]*Hunk, len(p.Hunks))
+	for i := range p.Hunks {
+		hunks[i] = &p.Hunks[i]
+	}
+	return writeField(w, "hunks", '}', list(hunks))
+}
+
+// WriteTo writes the JSON encoding of the patch to w.
+func (p *DocPatch) WriteTo(w io.Writer) (int64, error) {
+	cw := countingWriter{w: w}
+	err := writeBuffered(&cw, p)
+	return cw.n, err
+}
+
+// ReadPatch reads a patch written by DocPatch.WriteTo.
+func ReadPatch(r io.Reader) (*DocPatch, error) {
+	var s scanner
+	s.init(r)
+	if err := s.expect(tokLBrace); err != nil {
+		return nil, err
+	}
+	p := &DocPatch{}
+	for i := 4; i > 0; i-- {
+		key, err := readString(&s)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "pandoc-patch":
+			var format int
+			if format, err = readField(&s, i, readInt); err == nil && format != patchFormat {
+				err = errorf(&s, "unsupported patch format %d", format)
+			}
+		case "meta":
+			p.Meta, err = readField(&s, i, readMeta)
+		case "unset":
+			p.Unset, err = readField(&s, i, listr(readString))
+		case "hunks":
+			p.Hunks, err = readField(&s, i, listr(readHunk))
+		default:
+			err = errorf(&s, "unknown patch field %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func readHunk(s *scanner) (Hunk, error) {
+	var h Hunk
+	if err := s.expect(tokLBrace); err != nil {
+		return h, err
+	}
+	for i := 3; i > 0; i-- {
+		key, err := readString(s)
+		if err != nil {
+			return h, err
+		}
+		switch key {
+		case "old":
+			h.Old, err = readField(s, i, readInt)
+		case "delete":
+			h.Delete, err = readField(s, i, listr(readBlock))
+		case "insert":
+			h.Insert, err = readField(s, i, listr(readBlock))
+		default:
+			err = errorf(s, "unknown hunk field %q", key)
+		}
+		if err != nil {
+			return h, err
+		}
+	}
+	return h, nil
+}
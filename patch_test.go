@@ -0,0 +1,66 @@
+package pandoc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDocPatch(t *testing.T) {
+	para := func(s ...string) Block {
+		p := &Para{}
+		for _, s := range s {
+			p.Inlines = append(p.Inlines, &Str{s})
+		}
+		return p
+	}
+	a := &Pandoc{
+		Meta:   Meta{{"title", MetaString("A")}, {"draft", MetaBool(true)}, {"lang", MetaString("en")}},
+		Blocks: []Block{para("a"), para("b"), para("c"), para("d")},
+	}
+	b := &Pandoc{
+		Meta:   Meta{{"title", MetaString("B")}, {"lang", MetaString("en")}, {"author", MetaString("X")}},
+		Blocks: []Block{para("x"), para("a"), para("c"), para("e"), para("f")},
+	}
+	p := NewPatch(a, b)
+	if len(p.Meta) != 2 || len(p.Unset) != 1 || p.Unset[0] != "draft" || len(p.Hunks) != 3 {
+		t.Fatalf("unexpected patch %s", patchString(t, p))
+	}
+	var buf bytes.Buffer
+	if _, err := p.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPatch(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patchString(t, read) != patchString(t, p) {
+		t.Errorf("read patch %s, want %s", patchString(t, read), patchString(t, p))
+	}
+	got, err := Apply(a, read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(&MetaBlocks{got.Blocks}, &MetaBlocks{b.Blocks}) {
+		t.Errorf("patched blocks %s, want %s", Sprint(&MetaBlocks{got.Blocks}), Sprint(&MetaBlocks{b.Blocks}))
+	}
+	if got.Meta.Get("draft") != nil || !Equal(got.Meta.Get("title"), MetaValue(MetaString("B"))) || got.Meta.Get("author") == nil {
+		t.Errorf("unexpected patched meta %v", got.Meta)
+	}
+	if len(a.Blocks) != 4 || len(a.Meta) != 3 {
+		t.Error("the patched document is modified")
+	}
+	if _, err := Apply(b, p); err != ErrPatchMismatch {
+		t.Errorf("expected mismatch, got %v", err)
+	}
+	if _, err := ReadPatch(bytes.NewReader([]byte(`{"pandoc-patch":2,"meta":{},"unset":[],"hunks":[]}`))); err == nil {
+		t.Error("unsupported format is read")
+	}
+}
+
+func patchString(t *testing.T, p *DocPatch) string {
+	var b bytes.Buffer
+	if _, err := p.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
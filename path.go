@@ -0,0 +1,279 @@
+package pandoc
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A path of an element in a document: a JSON pointer (RFC 6901) into the
+// JSON encoding of the document. For example, "/blocks/3/c/1" is the
+// second inline of the fourth top-level block if it is a paragraph, and
+// "/meta/title" is the title metadata value. The empty path refers to
+// the document itself.
+type ElementPath string
+
+// Returns the path extended with a step, an index or a key.
+func (p ElementPath) Append(step any) ElementPath {
+	switch step := step.(type) {
+	case int:
+		return p + "/" + ElementPath(strconv.Itoa(step))
+	default:
+		s := strings.NewReplacer("~", "~0", "/", "~1").Replace(fmt.Sprint(step))
+		return p + "/" + ElementPath(s)
+	}
+}
+
+// returns unescaped steps of the path
+func (p ElementPath) steps() ([]string, error) {
+	if p == "" {
+		return nil, nil
+	} else if p[0] != '/' {
+		return nil, fmt.Errorf("invalid element path %q", p)
+	}
+	steps := strings.Split(string(p[1:]), "/")
+	for i := range steps {
+		steps[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(steps[i])
+	}
+	return steps, nil
+}
+
+// Returns the element at path in the document.
+func Resolve(doc *Pandoc, path ElementPath) (Element, error) {
+	steps, err := path.steps()
+	if err != nil {
+		return nil, err
+	}
+	v := reflect.ValueOf(doc)
+	for len(steps) > 0 {
+		if _, v, steps, err = child(v, steps, false); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if elt, ok := asElement(v); ok {
+		return elt, nil
+	}
+	return nil, fmt.Errorf("%s: not an element", path)
+}
+
+// Returns a copy of the document with the element at path replaced by
+// elt. Like Filter, only the elements on the path are copied, the rest
+// is shared with the document.
+func Set(doc *Pandoc, path ElementPath, elt Element) (*Pandoc, error) {
+	steps, err := path.steps()
+	if err != nil {
+		return nil, err
+	} else if len(steps) == 0 {
+		return nil, fmt.Errorf("can't replace the document")
+	}
+	v, err := set(reflect.ValueOf(doc), steps, elt)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return v.Interface().(*Pandoc), nil
+}
+
+func set(v reflect.Value, steps []string, elt Element) (reflect.Value, error) {
+	if len(steps) == 0 {
+		e := reflect.ValueOf(elt)
+		switch t := v.Type(); {
+		case elt != nil && e.Type().AssignableTo(t):
+			return e, nil
+		case elt != nil && e.Type() == reflect.PointerTo(t) && !e.IsNil():
+			return e.Elem(), nil
+		default:
+			return reflect.Value{}, fmt.Errorf("can't set %s to %T", t, elt)
+		}
+	}
+	parent, slot, rest, err := child(v, steps, true)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	nv, err := set(slot, rest, elt)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	slot.Set(nv)
+	return parent, nil
+}
+
+// Works as Query, additionally passing fun the path of the element.
+func QueryPath[P any](doc *Pandoc, fun func(P, ElementPath)) {
+	QueryCursor(doc, func(p P, c *Cursor) {
+		fun(p, c.Path())
+	})
+}
+
+// returns the element held by v
+func asElement(v reflect.Value) (Element, bool) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil, false
+		}
+	case reflect.Struct:
+		if !v.CanAddr() {
+			return nil, false
+		}
+		v = v.Addr()
+	}
+	if !v.CanInterface() {
+		return nil, false
+	}
+	elt, ok := v.Interface().(Element)
+	return elt, ok
+}
+
+// returns the child of value v at the first steps and the rest of steps.
+// If cp, the child is addressed in a shallow copy of v, returned as
+// parent.
+func child(v reflect.Value, steps []string, cp bool) (parent, slot reflect.Value, rest []string, err error) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return parent, slot, nil, fmt.Errorf("no element at %q", steps[0])
+		}
+		if parent, slot, rest, err = child(v.Elem(), steps, cp); err == nil && cp {
+			p := reflect.New(v.Type()).Elem()
+			p.Set(parent)
+			parent = p
+		}
+		return parent, slot, rest, err
+	case reflect.Slice:
+		if cp {
+			parent = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			reflect.Copy(parent, v)
+		} else {
+			parent = v
+		}
+		if meta, ok := v.Interface().(Meta); ok {
+			for i := range meta {
+				if meta[i].Key == steps[0] {
+					return parent, parent.Index(i).Field(1), steps[1:], nil
+				}
+			}
+			return parent, slot, nil, fmt.Errorf("no metadata key %q", steps[0])
+		}
+		i, err := strconv.Atoi(steps[0])
+		if err != nil || i < 0 || i >= v.Len() {
+			return parent, slot, nil, fmt.Errorf("invalid index %q", steps[0])
+		}
+		return parent, parent.Index(i), steps[1:], nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return parent, slot, nil, fmt.Errorf("no element at %q", steps[0])
+		}
+		if lb, ok := v.Interface().(*LazyBlock); ok {
+			decoded, err := lb.Decode()
+			if err != nil {
+				return parent, slot, nil, err
+			}
+			return child(reflect.ValueOf(decoded), steps, cp)
+		}
+		if cp {
+			parent = reflect.New(v.Elem().Type())
+			parent.Elem().Set(v.Elem())
+		} else {
+			parent = v
+		}
+	case reflect.Struct:
+		parent = reflect.New(v.Type())
+		parent.Elem().Set(v)
+		if !cp && v.CanAddr() {
+			parent = v.Addr()
+		}
+	default:
+		return parent, slot, nil, fmt.Errorf("no element at %q", steps[0])
+	}
+	// parent is a pointer to a struct
+	step, rest := steps[0], steps[1:]
+	x := parent.Interface()
+	if _, ok := x.(Tagged); ok {
+		if step != "c" {
+			return parent, slot, nil, fmt.Errorf("no element at %q", step)
+		}
+		p, single := parts(x)
+		switch {
+		case p == nil:
+			return parent, slot, nil, fmt.Errorf("%s has no content", x.(Tagged).Tag())
+		case single:
+			slot = reflect.ValueOf(p[0]).Elem()
+		case len(rest) == 0:
+			return parent, slot, nil, fmt.Errorf("no element at %q", step)
+		default:
+			if slot, err = tupleItem(p, rest[0]); err != nil {
+				return parent, slot, nil, err
+			}
+			rest = rest[1:]
+		}
+	} else if c, ok := x.(*Citation); ok {
+		f := citationField(c, step)
+		if f == nil {
+			return parent, slot, nil, fmt.Errorf("no citation field %q", step)
+		}
+		slot = reflect.ValueOf(f).Elem()
+	} else if pd, ok := x.(*Pandoc); ok {
+		switch step {
+		case "blocks":
+			slot = reflect.ValueOf(&pd.Blocks).Elem()
+		case "meta":
+			slot = reflect.ValueOf(&pd.Meta).Elem()
+		default:
+			return parent, slot, nil, fmt.Errorf("no document field %q", step)
+		}
+	} else {
+		p, _ := parts(x)
+		if slot, err = tupleItem(p, step); err != nil {
+			return parent, slot, nil, err
+		}
+	}
+	if v.Kind() == reflect.Struct {
+		parent = parent.Elem()
+	}
+	return parent, slot, rest, nil
+}
+
+func tupleItem(p []any, step string) (reflect.Value, error) {
+	i, err := strconv.Atoi(step)
+	if err != nil || i < 0 || i >= len(p) {
+		return reflect.Value{}, fmt.Errorf("invalid index %q", step)
+	}
+	return reflect.ValueOf(p[i]).Elem(), nil
+}
+
+// returns pointers to the parts of the JSON encoding of x, see
+// Element.Field: the content of a tagged element, if single, or the
+// items of the tuple it is encoded as
+func parts(x any) (p []any, single bool) {
+	e, ok := x.(Element)
+	if !ok {
+		return nil, false
+	}
+	for i := 0; ; i++ {
+		field := e.Field(i)
+		if field == nil {
+			return p, len(p) == 1
+		}
+		p = append(p, field)
+	}
+}
+
+func citationField(c *Citation, name string) any {
+	switch name {
+	case "citationId":
+		return &c.Id
+	case "citationPrefix":
+		return &c.Prefix
+	case "citationSuffix":
+		return &c.Suffix
+	case "citationMode":
+		return &c.Mode
+	case "citationNoteNum":
+		return &c.NoteNum
+	case "citationHash":
+		return &c.Hash
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,79 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestElementPath(t *testing.T) {
+	const src = `{"pandoc-api-version":[1,23,1],"meta":{"title":{"t":"MetaInlines","c":[{"t":"Str","c":"T"}]},"draft":{"t":"MetaBool","c":true}},"blocks":[` +
+		`{"t":"Header","c":[1,["h",[],[]],[{"t":"Str","c":"A"},{"t":"Space"},{"t":"Emph","c":[{"t":"Str","c":"B"}]}]]},` +
+		`{"t":"BulletList","c":[[{"t":"Plain","c":[{"t":"Str","c":"i"}]}],[{"t":"Para","c":[{"t":"Cite","c":[[{"citationId":"k","citationPrefix":[{"t":"Str","c":"see"}],"citationSuffix":[],"citationMode":{"t":"NormalCitation"},"citationNoteNum":1,"citationHash":0}],[{"t":"Str","c":"[@k]"}]]}]}]]},` +
+		`{"t":"Table","c":[["",[],[]],[null,[]],[[{"t":"AlignDefault"},{"t":"ColWidthDefault"}]],[["",[],[]],[]],[[["",[],[]],0,[],[[["",[],[]],[[["",[],[]],{"t":"AlignDefault"},1,1,[{"t":"Plain","c":[{"t":"Str","c":"cell"}]}]]]]]]],[["",[],[]],[]]]}]}`
+	doc, err := ReadFrom(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var strs []string
+	QueryPath(doc, func(s *Str, path ElementPath) {
+		strs = append(strs, string(path)+"="+s.Text)
+		if e, err := Resolve(doc, path); err != nil || e != Element(s) {
+			t.Errorf("%s resolved to %v, %v", path, e, err)
+		}
+	})
+	want := []string{
+		"/meta/title/c/0=T",
+		"/blocks/0/c/2/0=A",
+		"/blocks/0/c/2/2/c/0=B",
+		"/blocks/1/c/0/0/c/0=i",
+		"/blocks/1/c/1/0/c/0/c/0/0/citationPrefix/0=see",
+		"/blocks/1/c/1/0/c/0/c/1/0=[@k]",
+		"/blocks/2/c/4/0/3/0/1/0/4/0/c/0=cell",
+	}
+	if got := strings.Join(strs, "\n"); got != strings.Join(want, "\n") {
+		t.Errorf("got paths\n%s\nwant\n%s", got, strings.Join(want, "\n"))
+	}
+	var bools int
+	QueryPath(doc, func(MetaBool, ElementPath) { bools++ })
+	if bools != 1 {
+		t.Errorf("found %d booleans, want 1", bools)
+	}
+	if e, err := Resolve(doc, "/blocks/2/c/3"); err != nil || e != Element(&doc.Blocks[2].(*Table).Head) {
+		t.Errorf("resolved table head to %v, %v", e, err)
+	}
+	for _, p := range []ElementPath{"blocks", "/blocks/9", "/blocks/0/1", "/blocks/0/c", "/blocks/0/c/2/1/c", "/meta/nope", "/blocks/0/c/1"} {
+		if _, err := Resolve(doc, p); err == nil {
+			t.Errorf("%s resolved", p)
+		}
+	}
+
+	before := Sprint(doc)
+	set, err := Set(doc, "/blocks/0/c/2/2/c/0", &Str{"C"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(doc) != before {
+		t.Error("the document is modified")
+	}
+	if got := Stringify(set.Blocks[0]); got != "A C" {
+		t.Errorf("got %q after Set", got)
+	}
+	if set.Blocks[1] != doc.Blocks[1] {
+		t.Error("blocks off the path are copied")
+	}
+	if set, err = Set(set, "/blocks/2/c/4/0/3/0/1/0", &TableCell{Blocks: []Block{&Plain{[]Inline{&Str{"new"}}}}}); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := Resolve(set, "/blocks/2/c/4/0/3/0/1/0/4/0/c/0"); Stringify(e) != "new" {
+		t.Errorf("got %s after Set", Sprint(e))
+	}
+	if set, err = Set(set, "/meta/title", MetaString("X")); err != nil || set.Meta.Get("title") != MetaValue(MetaString("X")) {
+		t.Errorf("title is not set: %v", err)
+	}
+	if _, err := Set(doc, "/blocks/0/c/2/0", &Para{}); err == nil {
+		t.Error("a block is set in place of an inline")
+	}
+	if ElementPath("/meta").Append("a/b~c") != "/meta/a~1b~0c" {
+		t.Error("path step is not escaped")
+	}
+}
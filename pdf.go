@@ -0,0 +1,125 @@
+package pandoc
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Returned, wrapped, by PDFEngine and StoreFile if no PDF engine is found.
+var ErrNoPDFEngine = errors.New("no PDF engine found")
+
+// PDF engines by the format pandoc converts documents to for them, in the
+// order of detection
+var pdfEngines = []struct {
+	writer  string
+	engines []string
+}{
+	{"latex", []string{"pdflatex", "xelatex", "lualatex", "latexmk", "tectonic"}},
+	{"typst", []string{"typst"}},
+	{"html", []string{"weasyprint", "wkhtmltopdf", "prince", "pagedjs-cli"}},
+	{"context", []string{"context"}},
+	{"ms", []string{"pdfroff"}},
+}
+
+// formats producing PDF by the engines of a writer of pdfEngines
+var pdfWriters = map[string]string{
+	"latex": "latex", "beamer": "latex", "typst": "typst", "context": "context", "ms": "ms",
+	"html": "html", "html4": "html", "html5": "html",
+}
+
+// Returns the PDF engine used to store documents with c to PDF files:
+// the one of WithPDFEngine, or the first engine able to make PDF from the
+// format of c found in PATH. For the "pdf" format LaTeX engines are
+// searched first. An error lists the engines searched. The engine is not
+// detected, and is empty, if pandoc is run by a Runner other than
+// ExecRunner, leaving the choice to pandoc.
+func (c Conf) PDFEngine() (string, error) {
+	if engine := c.optValue("pdf-engine"); engine != "" {
+		return engine, nil
+	}
+	if _, ok := c.runner().(ExecRunner); !ok {
+		return "", nil
+	}
+	var searched []string
+	for _, l := range pdfEngines {
+		if c.Format != "pdf" && l.writer != pdfWriters[c.Format] {
+			continue
+		}
+		for _, engine := range l.engines {
+			if _, err := exec.LookPath(engine); err == nil {
+				return engine, nil
+			}
+			searched = append(searched, engine)
+		}
+	}
+	if len(searched) == 0 {
+		return "", fmt.Errorf("%w: format %s does not make PDF", ErrNoPDFEngine, c.Format)
+	}
+	return "", fmt.Errorf("%w: none of %s is in PATH", ErrNoPDFEngine, strings.Join(searched, ", "))
+}
+
+// returns the format pandoc converts documents to for the PDF engine
+func engineWriter(engine string) string {
+	name := strings.TrimSuffix(filepath.Base(engine), ".exe")
+	for _, l := range pdfEngines {
+		for _, e := range l.engines {
+			if e == name {
+				return l.writer
+			}
+		}
+	}
+	return "latex"
+}
+
+// reports whether c stores documents to a PDF file
+func (c *Conf) pdfFile() bool {
+	out := c.optValue("output")
+	for i, o := range c.Opts {
+		if o == "-o" && i+1 < len(c.Opts) {
+			out = c.Opts[i+1]
+		} else if strings.HasPrefix(o, "-o") && len(o) > 2 {
+			out = o[2:]
+		}
+	}
+	return strings.EqualFold(filepath.Ext(out), ".pdf")
+}
+
+// PDFError is returned when the PDF engine fails to make a PDF file. Log
+// is the part of the engine log pandoc reports, e.g. a LaTeX error and
+// its line; with --verbose pandoc writes the whole log to stderr, which
+// is in the PandocError.
+//
+// Example:
+//
+//	var perr *pandoc.PDFError
+//	if errors.As(err, &perr) {
+//	    log.Printf("%s failed:\n%s", perr.Engine, perr.Log)
+//	}
+type PDFError struct {
+	Engine string // PDF engine, empty if chosen by pandoc
+	Log    string // Engine log reported by pandoc
+	Err    *PandocError
+}
+
+func (e *PDFError) Error() string {
+	engine := e.Engine
+	if engine == "" {
+		engine = "the PDF engine"
+	}
+	return "pandoc: making PDF with " + engine + ": " + e.Log
+}
+
+func (e *PDFError) Unwrap() error { return e.Err }
+
+// returns the PDFError of the pandoc error err, if pandoc has failed to
+// make PDF
+func pdfError(engine string, err *PandocError) error {
+	_, log, ok := strings.Cut(err.Stderr, "Error producing PDF.")
+	if !ok {
+		return err
+	}
+	return &PDFError{Engine: engine, Log: strings.TrimSpace(log), Err: err}
+}
@@ -0,0 +1,79 @@
+package pandoc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPDF(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	// a fake pandoc recording its arguments and failing as pandoc does
+	// on LaTeX errors; PATH has the engines only, so builtins are used
+	exe := filepath.Join(dir, "pandoc")
+	script := `#!/bin/sh
+echo "$@" >` + filepath.Join(dir, "args") + `
+while read -r line; do :; done
+echo "Error producing PDF." >&2
+echo "! Undefined control sequence." >&2
+printf '%s\n' 'l.3 \foo' >&2
+exit 43
+`
+	bin := filepath.Join(dir, "bin")
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	} else if err := os.Mkdir(bin, 0o755); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile(filepath.Join(bin, "xelatex"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	doc := &Pandoc{Blocks: []Block{&Para{Inlines: []Inline{&Str{"a"}}}}}
+	out := filepath.Join(dir, "out.pdf")
+
+	err := doc.StoreFile(out, Format("pdf").WithPandoc(exe))
+	var (
+		pdfErr *PDFError
+		perr   *PandocError
+	)
+	if !errors.As(err, &pdfErr) || !errors.As(err, &perr) {
+		t.Fatalf("got %v", err)
+	} else if pdfErr.Engine != "xelatex" || pdfErr.Log != "! Undefined control sequence.\nl.3 \\foo" || perr.ExitCode() != 43 {
+		t.Errorf("got %+v", pdfErr)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "args")); err != nil {
+		t.Fatal(err)
+	} else if got := strings.TrimSpace(string(b)); got != "-fjson -tlatex -o "+out+" --pdf-engine=xelatex" {
+		t.Errorf("pandoc run with %q", got)
+	}
+
+	for _, tt := range []struct {
+		conf   Conf
+		engine string
+		err    string
+	}{
+		{Format("latex"), "xelatex", ""},
+		{Format("html5").WithPDFEngine("weasyprint"), "weasyprint", ""},
+		{Format("html5"), "", "no PDF engine found: none of weasyprint, wkhtmltopdf, prince, pagedjs-cli is in PATH"},
+		{Format("docx"), "", "no PDF engine found: format docx does not make PDF"},
+		{Format("html").WithRunner(SSHRunner{Host: "h"}), "", ""},
+	} {
+		engine, err := tt.conf.PDFEngine()
+		if engine != tt.engine || (err == nil) != (tt.err == "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("%s: got %q, %v", tt.conf.Format, engine, err)
+		}
+	}
+	if err := doc.StoreFile(out, Format("html").WithPandoc(exe)); !errors.Is(err, ErrNoPDFEngine) {
+		t.Errorf("got %v", err)
+	}
+	// output other than PDF files is left to pandoc
+	if err := doc.StoreFile(filepath.Join(dir, "out.html"), Format("html").WithPandoc(exe)); !errors.As(err, &perr) || errors.As(err, &pdfErr) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package pandoc
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"text/tabwriter"
+	"time"
+)
+
+// A chain of named document transformers, applied in order. Stages
+// running pandoc filters, see ThenFilter, may be interleaved with Go
+// transformers, e.g. to migrate filters one at a time.
+//
+// Example:
+//
+//	p := pandoc.Pipeline{}.
+//	    Then("links", pandoc.Transformer[*pandoc.Pandoc](fixLinks)).
+//	    ThenFilter("crossref", pandoc.Conf{}.WithLuaFilter("crossref.lua")).
+//	    Then("notes", pandoc.RemoveAll[*pandoc.Note, *pandoc.Pandoc])
+//	doc, trace, err := p.Trace(doc)
+//	trace.WriteTo(os.Stderr)
+type Pipeline struct {
+	stages []pipelineStage
+}
+
+type pipelineStage struct {
+	name string
+	fun  func(*Pandoc) (*Pandoc, error)
+}
+
+// Returns the pipeline with a stage added.
+func (p Pipeline) Then(name string, transformer func(*Pandoc) (*Pandoc, error)) Pipeline {
+	p.stages = append(p.stages[:len(p.stages):len(p.stages)], pipelineStage{name, transformer})
+	return p
+}
+
+// Returns the pipeline with a stage running the document through pandoc
+// with the filters of conf, see Pandoc.RunFilters. Put filters running
+// one after another in a single stage to have one pandoc run for them.
+func (p Pipeline) ThenFilter(name string, conf Conf) Pipeline {
+	return p.Then(name, func(doc *Pandoc) (*Pandoc, error) {
+		return doc.RunFilters(conf)
+	})
+}
+
+// Applies the stages to the document. An error of a stage is prefixed
+// with its name.
+func (p Pipeline) Run(doc *Pandoc) (*Pandoc, error) {
+	for _, s := range p.stages {
+		next, err := s.fun(doc)
+		if err != nil {
+			return doc, fmt.Errorf("%s: %w", s.name, err)
+		}
+		doc = next
+	}
+	return doc, nil
+}
+
+// Works as Run, additionally recording statistics of the stages run,
+// including the failed one. Tracing walks the whole document, so lazy
+// blocks are decoded.
+func (p Pipeline) Trace(doc *Pandoc) (*Pandoc, *PipelineTrace, error) {
+	var (
+		trace  = &PipelineTrace{}
+		seen   = make(map[Element]struct{})
+		before runtime.MemStats
+		after  runtime.MemStats
+	)
+	newElements(doc, seen)
+	for _, s := range p.stages {
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		next, err := s.fun(doc)
+		st := StageTrace{Name: s.name, Duration: time.Since(start)}
+		runtime.ReadMemStats(&after)
+		st.Allocs = after.Mallocs - before.Mallocs
+		st.Bytes = after.TotalAlloc - before.TotalAlloc
+		if err == nil {
+			st.Replaced = newElements(next, seen)
+		}
+		trace.Stages = append(trace.Stages, st)
+		if err != nil {
+			return doc, trace, fmt.Errorf("%s: %w", s.name, err)
+		}
+		doc = next
+	}
+	return doc, trace, nil
+}
+
+// adds elements of doc missing in seen to it and returns their number.
+// Shared subtrees are not walked.
+func newElements(doc *Pandoc, seen map[Element]struct{}) int {
+	n := 0
+	if _, ok := seen[doc]; ok {
+		return 0
+	}
+	seen[doc] = struct{}{}
+	QueryE(doc, func(e Element) error {
+		if _, ok := seen[e]; ok {
+			return Skip
+		}
+		seen[e] = struct{}{}
+		n++
+		return Continue
+	})
+	return n
+}
+
+// Statistics of the stages of a pipeline run.
+type PipelineTrace struct {
+	Stages []StageTrace
+}
+
+// Statistics of a pipeline stage.
+type StageTrace struct {
+	Name     string
+	Duration time.Duration // Wall time of the stage
+	Allocs   uint64        // Heap allocations made during the stage, by any goroutine
+	Bytes    uint64        // Bytes allocated during the stage, by any goroutine
+
+	// Elements of the resulting document not shared with the input one:
+	// replacements and copies of the elements that contain them.
+	Replaced int
+}
+
+// Returns the total wall time of the stages.
+func (t *PipelineTrace) Duration() time.Duration {
+	var d time.Duration
+	for _, s := range t.Stages {
+		d += s.Duration
+	}
+	return d
+}
+
+// Writes a table of the stages statistics.
+func (t *PipelineTrace) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	tw := tabwriter.NewWriter(cw, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "stage\ttime\t%\tallocs\tbytes\treplaced\t")
+	total := t.Duration()
+	for _, s := range t.Stages {
+		share := 0.0
+		if total > 0 {
+			share = float64(s.Duration) * 100 / float64(total)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f\t%d\t%d\t%d\t\n", s.Name, s.Duration, share, s.Allocs, s.Bytes, s.Replaced)
+	}
+	fmt.Fprintf(tw, "total\t%s\t\t\t\t\t\n", total)
+	err := tw.Flush()
+	return cw.n, err
+}
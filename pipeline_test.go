@@ -0,0 +1,101 @@
+package pandoc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Para{Inlines: []Inline{&Str{"a"}, &Space{}, &Str{"b"}}},
+		&Para{Inlines: []Inline{&Str{"c"}}},
+	}}
+	upper := Transformer[*Pandoc](func(s *Str) ([]Inline, error) {
+		if s.Text != "b" {
+			return nil, Continue
+		}
+		return []Inline{&Str{"B"}}, ReplaceSkip
+	})
+	p := Pipeline{}.
+		Then("upper", upper).
+		Then("none", func(doc *Pandoc) (*Pandoc, error) { return doc, nil })
+	res, trace, err := p.Trace(doc)
+	if err != nil {
+		t.Fatal(err)
+	} else if s := Stringify(res); s != "a B\nc" {
+		t.Errorf("got %q", s)
+	}
+	if len(trace.Stages) != 2 || trace.Stages[0].Name != "upper" {
+		t.Fatalf("stages: %+v", trace.Stages)
+	}
+	// the new Str and the copy of its paragraph
+	if r := trace.Stages[0].Replaced; r != 2 {
+		t.Errorf("upper replaced %d", r)
+	}
+	if r := trace.Stages[1].Replaced; r != 0 {
+		t.Errorf("none replaced %d", r)
+	}
+	var sb strings.Builder
+	if _, err := trace.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	} else if out := sb.String(); !strings.Contains(out, "upper") || !strings.Contains(out, "total") {
+		t.Errorf("report:\n%s", out)
+	}
+
+	fail := errors.New("fail")
+	p = p.Then("fail", func(*Pandoc) (*Pandoc, error) { return nil, fail })
+	if _, err := p.Run(doc); !errors.Is(err, fail) || !strings.HasPrefix(err.Error(), "fail: ") {
+		t.Errorf("run: %v", err)
+	}
+	if _, trace, err := p.Trace(doc); !errors.Is(err, fail) || len(trace.Stages) != 3 {
+		t.Errorf("trace: %v, %+v", err, trace)
+	}
+}
+
+func TestPipelineFilter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	// a fake pandoc running a filter making "a" uppercase and recording
+	// its arguments
+	exe := filepath.Join(dir, "pandoc")
+	script := "#!/bin/sh\necho \"$@\" >>" + filepath.Join(dir, "args") + "\nexec sed 's/\"c\":\"a\"/\"c\":\"A\"/g'\n"
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	doc := &Pandoc{Blocks: []Block{&Para{Inlines: []Inline{&Str{"a"}, &Space{}, &Str{"b"}}}}}
+	var seen string
+	p := Pipeline{}.
+		Then("b", Transformer[*Pandoc](func(s *Str) ([]Inline, error) {
+			if s.Text != "b" {
+				return nil, Continue
+			}
+			return []Inline{&Str{"a"}}, ReplaceSkip
+		})).
+		ThenFilter("filters", Conf{}.WithPandoc(exe).WithLuaFilter("upper.lua").WithFilter("f")).
+		Then("check", func(doc *Pandoc) (*Pandoc, error) {
+			seen = Stringify(doc)
+			return doc, nil
+		})
+	res, err := p.Run(doc)
+	if err != nil {
+		t.Fatal(err)
+	} else if seen != "A A" || Stringify(res) != "A A" {
+		t.Errorf("got %q, seen %q", Stringify(res), seen)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "args")); err != nil {
+		t.Fatal(err)
+	} else if got := string(b); got != "-fjson -tjson --lua-filter=upper.lua --filter=f\n" {
+		t.Errorf("pandoc run with %q", got)
+	}
+
+	p = p.ThenFilter("failing", Conf{}.WithPandoc(filepath.Join(dir, "missing")))
+	if _, err := p.Run(doc); err == nil || !strings.HasPrefix(err.Error(), "failing: ") {
+		t.Errorf("run: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package pandoc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// An external transformer: an executable reading a document as AST JSON
+// from stdin and writing the transformed document to stdout, like a
+// pandoc JSON filter. Unlike a pandoc filter, a plugin may be given a
+// fragment of a document instead of the whole one: the environment
+// variable GO_PANDOC_SCOPE tells what the input is.
+//
+//   - "document": the whole document.
+//   - "blocks": the blocks of the input document are a fragment, to be
+//     replaced with the blocks of the output. The metadata is given as
+//     the context of the fragment and is ignored on output.
+//   - "inlines": the input document holds a single Plain with the
+//     fragment, to be replaced with the inlines of the single Plain or
+//     Para of the output, or with nothing if there are no blocks.
+//
+// The plugin fails if it exits with a non-zero status, its standard
+// error is reported in PluginError.
+//
+// Any interpreted script with a "#!" line is a plugin, so filters can be
+// written without recompiling, e.g. in Python:
+//
+//	#!/usr/bin/env python3
+//	import json, sys
+//	doc = json.load(sys.stdin)
+//	...
+//	json.dump(doc, sys.stdout)
+//
+// Filters written in Starlark can also run in process, see package
+// github.com/growler/go-pandoc/script.
+//
+// Example:
+//
+//	plantuml, err := pandoc.LookPlugin("plantuml", "plugins")
+//	...
+//	reg := pandoc.ClassRegistry{}.Div("plantuml", plantuml.Div)
+type Plugin struct {
+	Path string   // Path to the executable
+	Args []string // Arguments of the executable
+	Dir  string   // Working directory
+}
+
+// Finds a plugin executable by name in the directories, then in PATH.
+// In PATH, the name prefixed with "go-pandoc-" is looked up first.
+func LookPlugin(name string, dirs ...string) (*Plugin, error) {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		if p, err := exec.LookPath(path); err == nil {
+			return &Plugin{Path: p}, nil
+		}
+	}
+	if !strings.ContainsRune(name, filepath.Separator) {
+		if p, err := exec.LookPath("go-pandoc-" + name); err == nil {
+			return &Plugin{Path: p}, nil
+		}
+	}
+	p, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s not found", name)
+	}
+	return &Plugin{Path: p}, nil
+}
+
+// PluginError is returned when a plugin fails.
+type PluginError struct {
+	Path   string // Path to the executable
+	Stderr string // Standard error output of the plugin
+	Err    error  // Underlying error, usually *exec.ExitError
+}
+
+func (e *PluginError) Error() string {
+	msg := strings.TrimSpace(e.Stderr)
+	name := filepath.Base(e.Path)
+	if msg == "" {
+		return name + ": " + e.Err.Error()
+	}
+	return name + ": " + e.Err.Error() + ": " + msg
+}
+
+func (e *PluginError) Unwrap() error { return e.Err }
+
+// Returns the document transformed by the plugin. Can be used as a
+// transformer, e.g. with Pipeline.Then or Pandoc.Apply.
+func (p *Plugin) Transform(doc *Pandoc) (*Pandoc, error) {
+	return p.TransformContext(context.Background(), doc)
+}
+
+// Works as Transform, killing the plugin if ctx is done.
+func (p *Plugin) TransformContext(ctx context.Context, doc *Pandoc) (*Pandoc, error) {
+	return p.run(ctx, "document", doc)
+}
+
+// Returns the blocks transformed by the plugin, passing it the metadata
+// as the context.
+func (p *Plugin) TransformBlocks(ctx context.Context, meta Meta, blocks []Block) ([]Block, error) {
+	doc, err := p.run(ctx, "blocks", &Pandoc{Meta: meta, Blocks: blocks})
+	if err != nil {
+		return nil, err
+	}
+	return doc.Blocks, nil
+}
+
+// Returns the inlines transformed by the plugin, passing it the metadata
+// as the context.
+func (p *Plugin) TransformInlines(ctx context.Context, meta Meta, inlines []Inline) ([]Inline, error) {
+	doc, err := p.run(ctx, "inlines", &Pandoc{Meta: meta, Blocks: []Block{&Plain{Inlines: inlines}}})
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Blocks) == 0 {
+		return nil, nil
+	} else if len(doc.Blocks) == 1 {
+		switch b := doc.Blocks[0].(type) {
+		case *Plain:
+			return b.Inlines, nil
+		case *Para:
+			return b.Inlines, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: expected a single Plain or Para", filepath.Base(p.Path))
+}
+
+// A filter function replacing a Div with the result of the plugin for
+// the Div, e.g. for ClassRegistry.Div.
+func (p *Plugin) Div(d *Div) ([]Block, error) {
+	blocks, err := p.TransformBlocks(context.Background(), nil, []Block{d})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, ReplaceSkip
+}
+
+// A filter function replacing a Span with the result of the plugin for
+// the Span, e.g. for ClassRegistry.Span.
+func (p *Plugin) Span(s *Span) ([]Inline, error) {
+	inlines, err := p.TransformInlines(context.Background(), nil, []Inline{s})
+	if err != nil {
+		return nil, err
+	}
+	return inlines, ReplaceSkip
+}
+
+func (p *Plugin) run(ctx context.Context, scope string, doc *Pandoc) (*Pandoc, error) {
+	cmd := &Command{Pandoc: p.Path, Dir: p.Dir, Args: p.Args, Env: []string{"GO_PANDOC_SCOPE=" + scope}}
+	res, err := load(ctx, ExecRunner{}, cmd, func(w io.Writer) error {
+		return doc.WriteTo(w)
+	})
+	var perr *PandocError
+	if errors.As(err, &perr) {
+		return nil, &PluginError{Path: p.Path, Stderr: perr.Stderr, Err: perr.Err}
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(p.Path), err)
+	}
+	return res, nil
+}
@@ -0,0 +1,72 @@
+package pandoc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writes a plugin replacing strings "x" with "y", failing unless run in
+// the scope given as the argument
+func testPlugin(t *testing.T) string {
+	dir := t.TempDir()
+	script := `#!/bin/sh
+if [ "$GO_PANDOC_SCOPE" != "$1" ]; then
+	echo "unexpected scope $GO_PANDOC_SCOPE" >&2
+	exit 3
+fi
+sed 's/"c":"x"/"c":"y"/g'
+`
+	if err := os.WriteFile(filepath.Join(dir, "xy"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPlugin(t *testing.T) {
+	dir := testPlugin(t)
+	p, err := LookPlugin("xy", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LookPlugin("no-such-plugin", dir); err == nil {
+		t.Error("found a missing plugin")
+	}
+	doc := &Pandoc{Blocks: []Block{
+		&Div{Attr: Attr{Classes: []string{"xy"}}, Blocks: []Block{&Para{Inlines: []Inline{&Str{"x"}}}}},
+		&Para{Inlines: []Inline{&Str{"x"}, &Space{}, &Span{Attr: Attr{Classes: []string{"xy"}}, Inlines: []Inline{&Str{"x"}}}}},
+	}}
+
+	p.Args = []string{"document"}
+	res, err := p.Transform(doc)
+	if err != nil {
+		t.Fatal(err)
+	} else if s := Stringify(res); s != "y\ny y" {
+		t.Errorf("document: %q", s)
+	}
+
+	p.Args = []string{"blocks"}
+	res, err = ClassRegistry{}.Div("xy", p.Div).Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	} else if s := Stringify(res); s != "y\nx x" {
+		t.Errorf("blocks: %q", s)
+	}
+
+	p.Args = []string{"inlines"}
+	res, err = ClassRegistry{}.Span("xy", p.Span).Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	} else if s := Stringify(res); s != "x\nx y" {
+		t.Errorf("inlines: %q", s)
+	}
+
+	_, err = p.TransformBlocks(context.Background(), nil, doc.Blocks)
+	var perr *PluginError
+	if !errors.As(err, &perr) || !strings.Contains(err.Error(), "unexpected scope blocks") {
+		t.Errorf("error: %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package pandoc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Returned by Pool.Start after the pool is closed.
+var ErrPoolClosed = errors.New("pandoc pool is closed")
+
+// Pool is a Runner keeping pandoc processes started ahead of time, so a
+// conversion does not wait for pandoc to start. A pandoc process
+// converts a single document, so after a warm process is taken another
+// one is started in the background for the same command. A warm process
+// is discarded if it exits before it is taken or if it stays idle longer
+// than MaxIdle.
+//
+// Only commands reading the standard input are warmed up: pandoc reads
+// input files as soon as it starts, so other commands are passed to the
+// Runner as is.
+//
+// Example:
+//
+//	pool := pandoc.NewPool(pandoc.ExecRunner{}, 4)
+//	defer pool.Close()
+//	conf := pandoc.Format("markdown").WithRunner(pool)
+type Pool struct {
+	Runner  Runner        // Runner starting processes, defaults to ExecRunner
+	Size    int           // Number of warm processes for each command, defaults to 1
+	MaxIdle time.Duration // Maximum idle time of a warm process, defaults to a minute
+
+	mu     sync.Mutex
+	idle   map[string][]*warmProcess
+	closed bool
+}
+
+// Returns a pool keeping size warm processes for each command.
+func NewPool(r Runner, size int) *Pool {
+	return &Pool{Runner: r, Size: size}
+}
+
+func (p *Pool) runner() Runner {
+	if p.Runner == nil {
+		return ExecRunner{}
+	}
+	return p.Runner
+}
+
+func (p *Pool) size() int {
+	if p.Size <= 0 {
+		return 1
+	}
+	return p.Size
+}
+
+func (p *Pool) maxIdle() time.Duration {
+	if p.MaxIdle <= 0 {
+		return time.Minute
+	}
+	return p.MaxIdle
+}
+
+// returns the key of commands interchangeable with c
+func poolKey(c *Command) string {
+	return fmt.Sprintf("%q %q %q %v %q %v", c.Pandoc, c.Dir, c.Env, c.ClearEnv, c.Args, c.Limits)
+}
+
+func (p *Pool) Start(ctx context.Context, c *Command) (Process, error) {
+	if !c.Input {
+		return p.runner().Start(ctx, c)
+	}
+	key := poolKey(c)
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	var w *warmProcess
+	for list := p.idle[key]; len(list) > 0 && w == nil; list = p.idle[key] {
+		w, p.idle[key] = list[0], list[1:]
+		if !w.timer.Stop() || !w.alive() {
+			go w.retire()
+			w = nil
+		}
+	}
+	p.mu.Unlock()
+	go p.fill(key, c)
+	if w == nil {
+		return p.runner().Start(ctx, c)
+	}
+	w.take(ctx, c.Stderr)
+	return w, nil
+}
+
+// starts warm processes for the command until there are Size of them
+func (p *Pool) fill(key string, c *Command) {
+	cmd := *c
+	for {
+		p.mu.Lock()
+		if p.closed || len(p.idle[key]) >= p.size() {
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+		w, err := p.warm(&cmd)
+		if err != nil {
+			return
+		}
+		p.mu.Lock()
+		if p.closed || len(p.idle[key]) >= p.size() {
+			p.mu.Unlock()
+			w.retire()
+			return
+		}
+		if p.idle == nil {
+			p.idle = make(map[string][]*warmProcess)
+		}
+		p.idle[key] = append(p.idle[key], w)
+		w.timer = time.AfterFunc(p.maxIdle(), func() { p.expire(key, w) })
+		p.mu.Unlock()
+	}
+}
+
+func (p *Pool) warm(c *Command) (*warmProcess, error) {
+	ctx, kill := context.WithCancel(context.Background())
+	w := &warmProcess{kill: kill, stop: func() bool { return true }, peeked: make(chan struct{})}
+	c.Stderr = &w.stderr
+	proc, err := p.runner().Start(ctx, c)
+	if err != nil {
+		kill()
+		return nil, err
+	}
+	w.proc = proc
+	go w.peek()
+	return w, nil
+}
+
+func (p *Pool) expire(key string, w *warmProcess) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := p.idle[key]
+	for i := range list {
+		if list[i] == w {
+			p.idle[key] = append(list[:i:i], list[i+1:]...)
+			go w.retire()
+			break
+		}
+	}
+	if len(p.idle[key]) == 0 {
+		delete(p.idle, key)
+	}
+}
+
+// Stops warm processes. Conversions in progress are not affected.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, list := range p.idle {
+		for _, w := range list {
+			w.timer.Stop()
+			go w.retire()
+		}
+	}
+	p.idle = nil
+	return nil
+}
+
+// a started process waiting for its input
+type warmProcess struct {
+	proc   Process
+	kill   context.CancelFunc
+	stop   func() bool // stops killing on the context of the taker
+	stderr stderrSwitch
+	timer  *time.Timer
+
+	// the first read of the output, returning before the input is
+	// given only if pandoc has exited
+	peeked chan struct{}
+	buf    [1]byte
+	n      int
+	err    error
+}
+
+func (w *warmProcess) peek() {
+	w.n, w.err = w.proc.Stdout().Read(w.buf[:])
+	close(w.peeked)
+}
+
+func (w *warmProcess) alive() bool {
+	select {
+	case <-w.peeked:
+		return false
+	default:
+		return true
+	}
+}
+
+func (w *warmProcess) take(ctx context.Context, stderr io.Writer) {
+	w.stop = context.AfterFunc(ctx, w.kill)
+	w.stderr.attach(stderr)
+}
+
+func (w *warmProcess) retire() {
+	w.kill()
+	if in := w.proc.Stdin(); in != nil {
+		_ = in.Close()
+	}
+	_, _ = io.Copy(io.Discard, w.Stdout())
+	_ = w.Wait()
+}
+
+func (w *warmProcess) Stdin() io.WriteCloser { return w.proc.Stdin() }
+func (w *warmProcess) Stdout() io.Reader     { return w }
+
+func (w *warmProcess) Read(b []byte) (int, error) {
+	<-w.peeked
+	if len(b) == 0 {
+		return 0, nil
+	} else if w.n > 0 {
+		b[0], w.n = w.buf[0], 0
+		return 1, nil
+	} else if w.err != nil {
+		return 0, w.err
+	}
+	return w.proc.Stdout().Read(b)
+}
+
+func (w *warmProcess) Wait() error {
+	err := w.proc.Wait()
+	w.stop()
+	w.kill()
+	return err
+}
+
+// a writer buffering writes until attached to the destination
+type stderrSwitch struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	w   io.Writer
+}
+
+func (s *stderrSwitch) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.w == nil {
+		return s.buf.Write(p)
+	}
+	return s.w.Write(p)
+}
+
+func (s *stderrSwitch) attach(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w == nil {
+		w = io.Discard
+	}
+	_, _ = s.buf.WriteTo(w)
+	s.w = w
+}
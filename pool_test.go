@@ -0,0 +1,114 @@
+package pandoc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counts started processes
+type countingRunner struct {
+	exe     string
+	started atomic.Int32
+}
+
+func (r *countingRunner) Start(ctx context.Context, cmd *Command) (Process, error) {
+	r.started.Add(1)
+	c := *cmd
+	c.Pandoc = r.exe
+	return ExecRunner{}.Start(ctx, &c)
+}
+
+// waits for the pool to have n warm processes storing documents
+func waitWarm(t *testing.T, p *Pool, n int) []*warmProcess {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		p.mu.Lock()
+		var all []*warmProcess
+		for key, list := range p.idle {
+			if strings.Contains(key, `["-fjson"`) {
+				all = append(all, list...)
+			}
+		}
+		p.mu.Unlock()
+		if len(all) == n {
+			return all
+		}
+	}
+	t.Fatalf("no %d warm processes", n)
+	return nil
+}
+
+func TestPool(t *testing.T) {
+	r := &countingRunner{exe: fakePandoc(t)}
+	pool := NewPool(r, 1)
+	defer pool.Close()
+	conf := Format("json").WithRunner(pool)
+	doc := &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"hello"}}}}}
+	store := func(doc *Pandoc) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := doc.StoreTo(&out, conf); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+	want := store(doc)
+	if n := len(waitWarm(t, pool, 1)); n != 1 || r.started.Load() != 2 {
+		t.Fatalf("started %d", r.started.Load())
+	}
+	// the warm process is taken and replaced
+	if got := store(doc); got != want {
+		t.Errorf("unexpected output %q", got)
+	}
+	waitWarm(t, pool, 1)
+	if n := r.started.Load(); n != 3 {
+		t.Errorf("started %d, want 3", n)
+	}
+	if res, err := LoadFrom(strings.NewReader(want), conf); err != nil || Stringify(res) != "hello" {
+		t.Errorf("load: %v", err)
+	}
+
+	// a dead warm process is discarded
+	w := waitWarm(t, pool, 1)[0]
+	w.kill()
+	<-w.peeked
+	if got := store(doc); got != want {
+		t.Errorf("unexpected output %q", got)
+	}
+
+	// the stderr of a warm process is reported and the process is killed
+	// on the context of the conversion
+	waitWarm(t, pool, 1)
+	var perr *PandocError
+	if err := (&Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"FAIL"}}}}}).StoreTo(io.Discard, conf); !errors.As(err, &perr) || !strings.Contains(perr.Stderr, "fake pandoc failed") {
+		t.Errorf("unexpected error %v", err)
+	}
+	waitWarm(t, pool, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := (&Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"SLEEP"}}}}}).StoreToContext(ctx, io.Discard, conf); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	pool.Close()
+	if err := doc.StoreTo(io.Discard, conf); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// idle processes are recycled
+	pool = &Pool{Runner: r, MaxIdle: time.Millisecond}
+	defer pool.Close()
+	conf = conf.WithRunner(pool)
+	started := r.started.Load()
+	store(doc)
+	for r.started.Load() != started+2 {
+		time.Sleep(time.Millisecond)
+	}
+	waitWarm(t, pool, 0)
+}
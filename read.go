@@ -1,7 +1,6 @@
 package pandoc
 
 import (
-	"fmt"
 	"io"
 )
 
@@ -62,7 +61,10 @@ func readInline(s *scanner) (ret Inline, err error) {
 	case NoteTag:
 		return readObj(readNote)(s)
 	default:
-		return nil, errorf("unknown inline type %q", s.string())
+		if s.opts.KeepUnknown {
+			return readUnknown(s, Tag(s.string()))
+		}
+		return nil, errorf(s, "unknown inline type %q", s.string())
 	}
 }
 
@@ -242,7 +244,7 @@ func readCitation(s *scanner) (*Citation, error) {
 			return nil, err
 		}
 		if !s.stringInBuffer() {
-			return nil, errorf("expected string, got %s", s.string())
+			return nil, errorf(s, "expected string, got %s", s.string())
 		}
 		switch string(s.buf[s.str : s.pos-1]) {
 		case "citationId":
@@ -258,7 +260,7 @@ func readCitation(s *scanner) (*Citation, error) {
 		case "citationHash":
 			citation.Hash, err = readField(s, i, readInt)
 		default:
-			return nil, errorf("unknown citation field %q", s.string())
+			return nil, errorf(s, "unknown citation field %q", s.string())
 		}
 		if err != nil {
 			return nil, err
@@ -333,8 +335,42 @@ func readLink(s *scanner) (Inline, error) {
 	return &Link{attr, inlines, target}, nil
 }
 
+// ----------- unknown elements -------------
+
+func readUnknown(s *scanner, tag Tag) (*UnknownElement, error) {
+	if s.peek() == tokRBrace {
+		s.next()
+		return &UnknownElement{Type: tag}, nil
+	}
+	if err := s.expect(tokComma); err != nil {
+		return nil, err
+	}
+	if err := s.expectString("c"); err != nil {
+		return nil, err
+	}
+	if err := s.expect(tokColon); err != nil {
+		return nil, err
+	}
+	content, err := s.raw(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.expect(tokRBrace); err != nil {
+		return nil, err
+	}
+	return &UnknownElement{Type: tag, Content: content}, nil
+}
+
 // ----------- blocks -------------
 
+// returns the top-level block reader
+func (s *scanner) topBlock() func(*scanner) (Block, error) {
+	if s.opts.Lazy {
+		return readLazyBlock
+	}
+	return readBlock
+}
+
 func readBlock(s *scanner) (ret Block, err error) {
 	if err := s.expect(tokLBrace); err != nil {
 		return nil, err
@@ -349,7 +385,7 @@ func readBlock(s *scanner) (ret Block, err error) {
 		return nil, err
 	}
 	if !s.stringInBuffer() {
-		return nil, errorf("expected tag, got %s", s.string())
+		return nil, errorf(s, "expected tag, got %s", s.string())
 	}
 	switch Tag(s.buf[s.str : s.pos-1]) {
 	case HorizontalRuleTag:
@@ -381,7 +417,10 @@ func readBlock(s *scanner) (ret Block, err error) {
 	case BlockQuoteTag:
 		return readObj(readBlockQuote)(s)
 	default:
-		return nil, errorf("unknown block type %q", s.string())
+		if s.opts.KeepUnknown {
+			return readUnknown(s, Tag(s.string()))
+		}
+		return nil, errorf(s, "unknown block type %q", s.string())
 	}
 }
 
@@ -538,6 +577,10 @@ func readTable(s *scanner) (*Table, error) {
 	if err != nil {
 		return nil, err
 	}
+	if s.peek() == tokLBrack && s.peek2() != tokStr {
+		// starts with a caption instead of Attr
+		return readLegacyTable(s, tuple(5))
+	}
 	attr, tup, err := readItem(readAttr)(s, tup)
 	if err != nil {
 		return nil, err
@@ -565,6 +608,59 @@ func readTable(s *scanner) (*Table, error) {
 	return &Table{attr, caption, colSpec, head, bodies, foot}, nil
 }
 
+// Table of pandoc-types before 1.21: caption, column alignments, column
+// widths, header cells and rows of cells, lifted to the current model
+func readLegacyTable(s *scanner, tup tuple) (*Table, error) {
+	caption, tup, err := readItem(listr(readInline))(s, tup)
+	if err != nil {
+		return nil, err
+	}
+	aligns, tup, err := readItem(listr(readAlignment))(s, tup)
+	if err != nil {
+		return nil, err
+	}
+	widths, tup, err := readItem(listr(readFloat))(s, tup)
+	if err != nil {
+		return nil, err
+	}
+	head, tup, err := readItem(listr(listr(readBlock)))(s, tup)
+	if err != nil {
+		return nil, err
+	}
+	rows, _, err := readItem(listr(listr(listr(readBlock))))(s, tup)
+	if err != nil {
+		return nil, err
+	}
+	t := &Table{Aligns: make([]ColSpec, len(aligns)), Bodies: []*TableBody{{}}}
+	if len(caption) > 0 {
+		t.Caption.Long = []Block{&Plain{Inlines: caption}}
+	}
+	for i, a := range aligns {
+		t.Aligns[i] = ColSpec{Align: a, Width: DefaultColWidth()}
+		if i < len(widths) && widths[i] != 0 {
+			t.Aligns[i].Width = ColWidth{Width: widths[i]}
+		}
+	}
+	for _, cell := range head {
+		if len(cell) > 0 {
+			t.Head.Rows = []*TableRow{legacyCells(head)}
+			break
+		}
+	}
+	for _, row := range rows {
+		t.Bodies[0].Body = append(t.Bodies[0].Body, legacyCells(row))
+	}
+	return t, nil
+}
+
+func legacyCells(cells [][]Block) *TableRow {
+	row := &TableRow{Cells: make([]*TableCell, len(cells))}
+	for i, blocks := range cells {
+		row.Cells[i] = &TableCell{Align: AlignDefault, RowSpan: 1, ColSpan: 1, Blocks: blocks}
+	}
+	return row
+}
+
 // DefinitionList
 func readDefinitionList(s *scanner) (*DefinitionList, error) {
 	list, err := listr(readDefinition)(s)
@@ -724,7 +820,7 @@ func readColWidth(s *scanner) (ColWidth, error) {
 		return ColWidth{}, err
 	}
 	if !s.stringInBuffer() {
-		return ColWidth{}, errorf("expected tag, got %s", s.string())
+		return ColWidth{}, errorf(s, "expected tag, got %s", s.string())
 	}
 	switch Tag(s.buf[s.str : s.pos-1]) {
 	case _ColWidthDefault:
@@ -739,7 +835,7 @@ func readColWidth(s *scanner) (ColWidth, error) {
 			return ColWidth{flt, false}, nil
 		}
 	default:
-		return ColWidth{}, errorf("unknown col width type %q", s.string())
+		return ColWidth{}, errorf(s, "unknown col width type %q", s.string())
 	}
 }
 
@@ -851,7 +947,7 @@ func readMetaValue(s *scanner) (MetaValue, error) {
 		return nil, err
 	}
 	if !s.stringInBuffer() {
-		return nil, errorf("expected tag, got %s", s.string())
+		return nil, errorf(s, "expected tag, got %s", s.string())
 	}
 	switch Tag(s.buf[s.str : s.pos-1]) {
 	case MetaMapTag:
@@ -867,7 +963,10 @@ func readMetaValue(s *scanner) (MetaValue, error) {
 	case MetaBlocksTag:
 		return readObj(readMetaBlocks)(s)
 	default:
-		return nil, errorf("unknown meta value type %q", s.string())
+		if s.opts.KeepUnknown {
+			return readUnknown(s, Tag(s.string()))
+		}
+		return nil, errorf(s, "unknown meta value type %q", s.string())
 	}
 }
 
@@ -896,15 +995,16 @@ func readMeta(s *scanner) ([]MetaMapEntry, error) {
 		if err := s.expect(tokColon); err != nil {
 			return nil, err
 		}
-		if val, err := readMetaValue(s); err != nil {
-			return nil, err
-		} else {
+		depth := s.depth
+		if val, err := readMetaValue(s); err == nil {
 			m = append(m, MetaMapEntry{key, val})
+		} else if !s.recover(depth, err) {
+			return nil, err
 		}
 		if tok := s.next(); tok == tokRBrace {
 			break
 		} else if tok != tokComma {
-			return nil, errorf("expected comma or right bracket, got %s", tok)
+			return nil, errorf(s, "expected comma or right bracket, got %s", tok)
 		}
 	}
 	return m, nil
@@ -1004,11 +1104,11 @@ func readTags[T ~string](tags ...T) func(*scanner) (T, error) {
 			return
 		}
 		if !s.stringInBuffer() {
-			err = errorf("expected one of %v, got %s", tags, s.string())
+			err = errorf(s, "expected one of %v, got %s", tags, s.string())
 			return
 		}
 		if elt, ok := m[string(s.buf[s.str:s.pos-1])]; !ok {
-			err = errorf("expected one of %v, got %s", tags, s.string())
+			err = errorf(s, "expected one of %v, got %s", tags, s.string())
 			return
 		} else if err = s.expect(tokRBrace); err != nil {
 			return
@@ -1083,7 +1183,7 @@ func testlistr[T any, R func(*scanner) (T, error)](r R) func(*scanner) ([]T, err
 			if tok := s.next(); tok == tokRBrack {
 				break
 			} else if tok != tokComma {
-				return nil, errorf("expected comma or right bracket, got %s at %d", tok, off)
+				return nil, errorAt(s, off, "expected comma or right bracket, got %s", tok)
 			}
 		}
 		return nil, nil
@@ -1107,16 +1207,17 @@ func listr[T any, R func(*scanner) (T, error)](r R) func(*scanner) ([]T, error)
 				s.next()
 				break
 			}
-			item, err := r(s)
-			if err != nil {
+			depth := s.depth
+			if item, err := r(s); err == nil {
+				ret = append(ret, item)
+			} else if !s.recover(depth, err) {
 				return nil, err
 			}
-			ret = append(ret, item)
 			off := s.current()
 			if tok := s.next(); tok == tokRBrack {
 				break
 			} else if tok != tokComma {
-				return nil, errorf("expected comma or right bracket, got %s at %d", tok, off)
+				return nil, errorAt(s, off, "expected comma or right bracket, got %s", tok)
 			}
 		}
 		return ret, nil
@@ -1145,7 +1246,7 @@ func readNull(s *scanner) (any, error) {
 	if tok := s.next(); tok == tokNull {
 		return nil, nil
 	} else {
-		return nil, errorf("expected null, got %s at %d", tok, off)
+		return nil, errorAt(s, off, "expected null, got %s", tok)
 	}
 }
 
@@ -1157,7 +1258,7 @@ func readBool(s *scanner) (bool, error) {
 	} else if tok == tokFalse {
 		return false, nil
 	} else {
-		return false, errorf("expected boolean, got %s at %d", tok, off)
+		return false, errorAt(s, off, "expected boolean, got %s", tok)
 	}
 }
 
@@ -1169,8 +1270,14 @@ func readString(s *scanner) (string, error) {
 	return s.string(), nil
 }
 
-func errorf(f string, a ...any) error {
-	panic(fmt.Errorf(f, a...).Error())
+func errorf(s *scanner, f string, a ...any) error {
+	return errorAt(s, s.current(), f, a...)
+}
+
+func errorAt(s *scanner, off int, f string, a ...any) error {
+	err := s.errorAt(off, f, a...)
+	s.err = err
+	return err
 }
 
 // compares two semver versions
@@ -1194,10 +1301,95 @@ func cmpSemver(mine, their []int) int {
 	}
 }
 
-// ReadFrom parses a Pandoc AST JSON from the reader.
+// Options of reading Pandoc AST JSON.
+type ReadOptions struct {
+	// In the lenient mode, list items and metadata values that can't be
+	// parsed are skipped instead of failing the whole document. The
+	// input must still be well-formed JSON.
+	Lenient bool
+	// If set, called with the errors of values skipped in the lenient
+	// mode.
+	Warn func(error)
+	// If set, inlines, blocks and metadata values of unknown types (e.g.
+	// added by a newer pandoc-types version) are read as UnknownElement
+	// instead of failing.
+	KeepUnknown bool
+	// If set, top-level blocks are read as LazyBlock, only checking that
+	// they are well-formed JSON, and decoded when needed.
+	Lazy bool
+	// If set, strings read with ReadBytes share memory with the input
+	// instead of being copied. The input must not be modified afterwards.
+	Borrow bool
+}
+
+// ReadFrom parses a Pandoc AST JSON from the reader. Malformed input is
+// reported as *SyntaxError.
 func ReadFrom(r io.Reader) (*Pandoc, error) {
-	var s = scanner{}
+	return ReadOptions{}.Read(r)
+}
+
+// Read parses a Pandoc AST JSON from the reader with options o.
+func (o ReadOptions) Read(r io.Reader) (*Pandoc, error) {
+	return readDoc(o.scanner(r))
+}
+
+// ReadBytes parses a Pandoc AST JSON from b. The slice is scanned in
+// place, without buffering.
+func ReadBytes(b []byte) (*Pandoc, error) {
+	return ReadOptions{}.ReadBytes(b)
+}
+
+// ReadBytes is ReadBytes with options o.
+func (o ReadOptions) ReadBytes(b []byte) (*Pandoc, error) {
+	var s = scanner{opts: o}
+	s.initBytes(b)
+	return readDoc(&s)
+}
+
+// ReadAll parses Pandoc AST JSON documents written back to back to the
+// reader, e.g. by a filter process handling many documents, until EOF.
+// The documents read before an error are returned with it. See also
+// ReadDocs.
+func ReadAll(r io.Reader) ([]*Pandoc, error) {
+	return ReadOptions{}.ReadAll(r)
+}
+
+// ReadAll is ReadAll with options o.
+func (o ReadOptions) ReadAll(r io.Reader) ([]*Pandoc, error) {
+	s := o.scanner(r)
+	var docs []*Pandoc
+	for {
+		doc, err := readNextDoc(s)
+		if err != nil || doc == nil {
+			return docs, err
+		}
+		docs = append(docs, doc)
+	}
+}
+
+// returns a scanner of r
+func (o ReadOptions) scanner(r io.Reader) *scanner {
+	var s = &scanner{opts: o}
+	if o.Lazy {
+		// raw blocks are skipped by chunks
+		s.buf = make([]byte, 0, 32<<10)
+	}
 	s.init(r)
+	return s
+}
+
+// reads the next of the documents written back to back, nil at EOF
+func readNextDoc(s *scanner) (*Pandoc, error) {
+	if s.peek() == tokEOF {
+		if s.err != nil && s.err != io.EOF {
+			return nil, s.err
+		}
+		return nil, nil
+	}
+	return readDoc(s)
+}
+
+func readDoc(s *scanner) (*Pandoc, error) {
 	if err := s.expect(tokLBrace); err != nil {
 		return nil, err
 	}
@@ -1210,26 +1402,246 @@ func ReadFrom(r io.Reader) (*Pandoc, error) {
 			return nil, err
 		}
 		if !s.stringInBuffer() {
-			return nil, errorf("expected string, got %s", s.string())
+			return nil, errorf(s, "expected string, got %s", s.string())
 		}
 		switch string(s.buf[s.str : s.pos-1]) {
 		case "pandoc-api-version":
-			if version, err := readField(&s, i, listr(readInt)); err != nil {
+			if doc.Version, err = readField(s, i, listr(readInt)); err != nil {
 				return nil, err
-			} else if cmpSemver(version, _Version) < 0 {
-				return nil, errorf("unsupported pandoc version %v", version)
+			} else if cmpSemver(doc.Version, _MinVersion) < 0 {
+				return nil, errorf(s, "unsupported pandoc version %v", doc.Version)
 			}
 		case "meta":
-			if doc.Meta, err = readField(&s, i, readMeta); err != nil {
+			if doc.Meta, err = readField(s, i, readMeta); err != nil {
 				return nil, err
 			}
 		case "blocks":
-			if doc.Blocks, err = readField(&s, i, listr(readBlock)); err != nil {
+			if doc.Blocks, err = readField(s, i, listr(s.topBlock())); err != nil {
 				return nil, err
 			}
 		default:
-			return nil, errorf("unknown pandoc field %q", s.string())
+			return nil, errorf(s, "unknown pandoc field %q", s.string())
 		}
 	}
 	return doc, nil
 }
+
+// ReadMeta parses only the metadata of a Pandoc AST JSON from the
+// reader. The blocks are skipped without parsing if they precede the
+// metadata, and not read at all otherwise.
+func ReadMeta(r io.Reader) (Meta, error) {
+	return ReadOptions{}.ReadMeta(r)
+}
+
+// ReadMeta is ReadMeta with options o.
+func (o ReadOptions) ReadMeta(r io.Reader) (Meta, error) {
+	var s = scanner{opts: o, buf: make([]byte, 0, 32<<10)}
+	s.init(r)
+	if err := s.expect(tokLBrace); err != nil {
+		return nil, err
+	}
+	for i := 3; i > 0; i-- {
+		key, err := readString(&s)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "pandoc-api-version":
+			if version, err := readField(&s, i, listr(readInt)); err != nil {
+				return nil, err
+			} else if cmpSemver(version, _MinVersion) < 0 {
+				return nil, errorf(&s, "unsupported pandoc version %v", version)
+			}
+		case "meta":
+			if err := s.expect(tokColon); err != nil {
+				return nil, err
+			}
+			return readMeta(&s)
+		case "blocks":
+			if _, err := readField(&s, i, func(s *scanner) (any, error) { return nil, s.skipValue() }); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errorf(&s, "unknown pandoc field %q", key)
+		}
+	}
+	return nil, errorf(&s, "no meta")
+}
+
+// BlockReader reads a Pandoc AST JSON document incrementally, one
+// top-level block at a time, so the document does not have to be held
+// in memory entirely. The metadata must precede the blocks, as in
+// documents produced by pandoc.
+//
+// Example:
+//
+//	br, err := pandoc.ReadBlocks(os.Stdin)
+//	if err != nil {
+//	    return err
+//	}
+//	bw := br.Writer(os.Stdout)
+//	for br.Next() {
+//	    if err := bw.WriteBlocks(process(br.Block())); err != nil {
+//	        return err
+//	    }
+//	}
+//	if err := br.Err(); err != nil {
+//	    return err
+//	}
+//	return bw.Close()
+type BlockReader struct {
+	s       scanner
+	meta    Meta
+	version []int
+	block   Block
+	fields  int // fields left after blocks
+	first   bool
+	done    bool
+	err     error
+}
+
+// Returns a BlockReader reading a document from r. The document is read
+// up to the first block.
+func ReadBlocks(r io.Reader) (*BlockReader, error) {
+	return ReadOptions{}.ReadBlocks(r)
+}
+
+// ReadBlocks is ReadBlocks with options o.
+func (o ReadOptions) ReadBlocks(r io.Reader) (*BlockReader, error) {
+	b := &BlockReader{s: scanner{opts: o}, first: true}
+	s := &b.s
+	s.init(r)
+	if err := s.expect(tokLBrace); err != nil {
+		return nil, err
+	}
+	for i := 3; i > 0; i-- {
+		key, err := readString(s)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "pandoc-api-version":
+			if err := b.readVersion(i); err != nil {
+				return nil, err
+			}
+		case "meta":
+			if b.meta, err = readField(s, i, readMeta); err != nil {
+				return nil, err
+			}
+		case "blocks":
+			if err := s.expect(tokColon); err != nil {
+				return nil, err
+			}
+			if err := s.expect(tokLBrack); err != nil {
+				return nil, err
+			}
+			b.fields = i - 1
+			return b, nil
+		default:
+			return nil, errorf(s, "unknown pandoc field %q", key)
+		}
+	}
+	return nil, errorf(s, "no blocks")
+}
+
+func (b *BlockReader) readVersion(n int) (err error) {
+	if b.version, err = readField(&b.s, n, listr(readInt)); err != nil {
+		return err
+	} else if cmpSemver(b.version, _MinVersion) < 0 {
+		return errorf(&b.s, "unsupported pandoc version %v", b.version)
+	}
+	return nil
+}
+
+// Returns the document metadata.
+func (b *BlockReader) Meta() Meta {
+	return b.meta
+}
+
+// Returns the pandoc-types API version of the document, or nil if it is
+// not read yet, since it follows the blocks.
+func (b *BlockReader) Version() []int {
+	return b.version
+}
+
+// Reads the next block, which is then available through Block. Returns
+// false at the end of the document or on error.
+func (b *BlockReader) Next() bool {
+	if b.done || b.err != nil {
+		return false
+	}
+	s := &b.s
+	b.block = nil
+	for {
+		if b.first {
+			b.first = false
+			if s.peek() == tokRBrack {
+				s.next()
+				b.finish()
+				return false
+			}
+		} else {
+			off := s.current()
+			if tok := s.next(); tok == tokRBrack {
+				b.finish()
+				return false
+			} else if tok != tokComma {
+				b.err = errorAt(s, off, "expected comma or right bracket, got %s", tok)
+				return false
+			}
+		}
+		depth := s.depth
+		if blk, err := s.topBlock()(s); err == nil {
+			b.block = blk
+			return true
+		} else if !s.recover(depth, err) {
+			b.err = err
+			return false
+		}
+	}
+}
+
+// reads the fields following the blocks
+func (b *BlockReader) finish() {
+	s := &b.s
+	b.done = true
+	if b.fields == 0 {
+		b.err = s.expect(tokRBrace)
+		return
+	}
+	if b.err = s.expect(tokComma); b.err != nil {
+		return
+	}
+	for i := b.fields; i > 0; i-- {
+		key, err := readString(s)
+		if err != nil {
+			b.err = err
+			return
+		}
+		if key != "pandoc-api-version" {
+			b.err = errorf(s, "can't read field %q following blocks", key)
+			return
+		}
+		if b.err = b.readVersion(i); b.err != nil {
+			return
+		}
+	}
+}
+
+// Returns the block read by the last call to Next.
+func (b *BlockReader) Block() Block {
+	return b.block
+}
+
+// Returns the first error encountered while reading.
+func (b *BlockReader) Err() error {
+	return b.err
+}
+
+// Returns a BlockWriter writing a document with the metadata and version
+// of the document being read to w.
+func (b *BlockReader) Writer(w io.Writer) *BlockWriter {
+	bw := NewBlockWriter(w, b.meta)
+	bw.version = b.version
+	return bw
+}
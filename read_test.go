@@ -107,6 +107,186 @@ func BenchmarkParse(b *testing.B) {
 	}
 }
 
+func TestReadBytes(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []ReadOptions{{}, {Borrow: true}, {Lazy: true}} {
+		doc, err := opts.ReadBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := doc.WriteTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		b.WriteByte('\n')
+		if !bytes.Equal(data, b.Bytes()) {
+			t.Errorf("%+v: data mismatch %d %d", opts, len(data), b.Len())
+		}
+	}
+	for _, src := range []string{``, `{`, `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Str","c":"a`} {
+		if _, err := ReadBytes([]byte(src)); err == nil {
+			t.Errorf("no error reading %q", src)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		b, err := Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(append(b, '\n'), data) {
+			t.Errorf("data mismatch %d %d", len(data), len(b)+1)
+		}
+	}
+	b, err := AppendJSON([]byte("x="), &Str{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `x={"t":"Str","c":"a"}` {
+		t.Errorf("unexpected %s", b)
+	}
+}
+
+// counts Write calls
+type writeCounter struct {
+	bytes.Buffer
+	calls int
+}
+
+func (w *writeCounter) Write(b []byte) (int, error) {
+	w.calls++
+	return w.Buffer.Write(b)
+}
+
+func TestWriteBuffered(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var w writeCounter
+	if err := doc.WriteTo(&w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Len() != len(data)-1 {
+		t.Errorf("written %d bytes, want %d", w.Len(), len(data)-1)
+	}
+	if max := len(data)/writeBufferSize + 1; w.calls > max {
+		t.Errorf("%d writes, want at most %d", w.calls, max)
+	}
+
+	w = writeCounter{}
+	bw := NewBlockWriter(&w, doc.Meta)
+	if err := bw.WriteBlocks(doc.Blocks...); err != nil {
+		t.Fatal(err)
+	}
+	if err := bw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != Sprint(doc) {
+		t.Error("block writer output mismatch")
+	}
+	if max := len(data)/writeBufferSize + 2; w.calls > max {
+		t.Errorf("%d block writer writes, want at most %d", w.calls, max)
+	}
+}
+
+func TestWriteMany(t *testing.T) {
+	ch1 := &Pandoc{Meta: Meta{{Key: "title", Value: MetaString("one")}}, Blocks: []Block{&Para{Inlines: words("a b")}}}
+	ch2 := &Pandoc{Blocks: []Block{&HorizontalRule{}, &Para{Inlines: words("c")}}}
+	var b bytes.Buffer
+	meta := Meta{{Key: "title", Value: MetaString("book")}}
+	if err := WriteMany(&b, meta, ch1, nil, ch2); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadFrom(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Pandoc{Meta: meta, Blocks: append(append([]Block{}, ch1.Blocks...), ch2.Blocks...)}
+	if got := Sprint(doc); got != Sprint(want) {
+		t.Errorf("got %s, want %s", got, Sprint(want))
+	}
+	b.Reset()
+	if err := WriteMany(&b, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := b.String(); got != Sprint(&Pandoc{}) {
+		t.Errorf("got %s for no documents, want %s", got, Sprint(&Pandoc{}))
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	doc, err := ReadBytes(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	f, err := os.Create(os.DevNull)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := doc.WriteTo(f); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWriteIndent(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{&Para{Inlines: []Inline{&Str{"a"}}}}}
+	var b bytes.Buffer
+	if err := WriteIndent(&b, doc, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\n  \"blocks\": [\n    {\n      \"t\": \"Para\",") {
+		t.Errorf("unexpected output:\n%s", b.String())
+	}
+	read, err := ReadFrom(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(read) != Sprint(doc) {
+		t.Error("indented JSON reads back differently")
+	}
+}
+
+func BenchmarkParseBytes(b *testing.B) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := (ReadOptions{Borrow: true}).ReadBytes(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkQuery(b *testing.B) {
 	b.StopTimer()
 	f, err := os.Open("testdata/test.json")
@@ -145,6 +325,211 @@ func TestRead(t *testing.T) {
 	t.Logf("%#v", doc)
 }
 
+func TestReadVersion(t *testing.T) {
+	for _, src := range []string{
+		`{"pandoc-api-version":[1,22,2,1],"meta":{},"blocks":[]}`,
+		`{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[]}`,
+	} {
+		doc, err := ReadFrom(strings.NewReader(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := Sprint(doc); got != src {
+			t.Errorf("version is not preserved: %s, want %s", got, src)
+		}
+	}
+	if got := Sprint(&Pandoc{}); !strings.HasPrefix(got, `{"pandoc-api-version":[`+strings.ReplaceAll(Version, ".", ",")+`]`) {
+		t.Errorf("unexpected default version: %s", got)
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	const (
+		d1 = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Str","c":"a"}]}]}`
+		d2 = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[]}`
+	)
+	for _, src := range []string{"", " \n", d1, d1 + d2, d1 + "\n" + d2 + "\n", " " + d2 + "\n\n" + d1} {
+		docs, err := ReadAll(strings.NewReader(src))
+		if err != nil {
+			t.Fatalf("%q: %v", src, err)
+		}
+		var got string
+		for _, doc := range docs {
+			got += Sprint(doc)
+		}
+		if want := strings.Join(strings.Fields(src), ""); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+	for _, o := range []ReadOptions{{}, {Lazy: true}} {
+		docs, err := o.ReadAll(strings.NewReader(d1 + d2 + d2[:20]))
+		if err == nil {
+			t.Error("no error reading a truncated document")
+		}
+		if len(docs) != 2 {
+			t.Errorf("got %d documents before the error, want 2", len(docs))
+		}
+	}
+	if _, err := ReadAll(strings.NewReader(d1 + "x")); err == nil {
+		t.Error("no error reading trailing garbage")
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data)-1; i += len(data)/500 + 1 {
+		if _, err := ReadFrom(bytes.NewReader(data[:i])); err == nil {
+			t.Fatalf("no error reading %d bytes", i)
+		}
+	}
+}
+
+func TestReadLenient(t *testing.T) {
+	const src = `{"pandoc-api-version":[1,23,1],"meta":{"a":{"t":"MetaFoo","c":1},"b":{"t":"MetaBool","c":true}},` +
+		`"blocks":[{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Str","c":{"x":[1,2]}},{"t":"Space"}]},{"t":"Foo","c":[]},{"t":"HorizontalRule"}]}`
+	if _, err := ReadFrom(strings.NewReader(src)); err == nil {
+		t.Fatal("malformed document is read in the strict mode")
+	}
+	var warns []error
+	doc, err := ReadOptions{Lenient: true, Warn: func(err error) { warns = append(warns, err) }}.Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"pandoc-api-version":[1,23,1],"meta":{"b":{"t":"MetaBool","c":true}},` +
+		`"blocks":[{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Space"}]},{"t":"HorizontalRule"}]}`
+	if got := Sprint(doc); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if len(warns) != 3 {
+		t.Errorf("expected 3 warnings, got %v", warns)
+	}
+	if _, err := (ReadOptions{Lenient: true}).Read(strings.NewReader(src[:len(src)-10])); err == nil {
+		t.Error("truncated document is read in the lenient mode")
+	}
+}
+
+func TestReadUnknown(t *testing.T) {
+	const src = `{"pandoc-api-version":[1,23,1],"meta":{"a":{"t":"MetaFoo","c":{"x":[1,-2.5,true,null]}}},` +
+		`"blocks":[{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Bar","c":["q\"s",{"t":"Space"}]},{"t":"Baz"}]},{"t":"Qux","c":[[],{}]}]}`
+	if _, err := ReadFrom(strings.NewReader(src)); err == nil {
+		t.Fatal("unknown elements are read without KeepUnknown")
+	}
+	doc, err := ReadOptions{KeepUnknown: true}.Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Sprint(doc); got != src {
+		t.Errorf("got %s, want %s", got, src)
+	}
+	if u, ok := doc.Blocks[1].(*UnknownElement); !ok || u.Tag() != "Qux" || string(u.Content) != `[[],{}]` {
+		t.Errorf("unexpected block %#v", doc.Blocks[1])
+	}
+}
+
+func TestReadBlocks(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	br, err := ReadBlocks(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	bw := br.Writer(&b)
+	for br.Next() {
+		if err := bw.WriteBlocks(br.Block()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := br.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := bw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if bw.Count() != len(doc.Blocks) {
+		t.Errorf("read %d blocks, want %d", bw.Count(), len(doc.Blocks))
+	}
+	if b.String() != Sprint(doc) {
+		t.Error("streamed document differs")
+	}
+	for _, tt := range []struct {
+		src     string
+		blocks  int
+		version []int
+		fail    bool
+	}{
+		{`{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[]}`, 0, []int{1, 23, 1}, false},
+		{`{"meta":{},"blocks":[{"t":"HorizontalRule"},{"t":"HorizontalRule"}],"pandoc-api-version":[1,22]}`, 2, []int{1, 22}, false},
+		{`{"meta":{},"blocks":[{"t":"HorizontalRule"},{"t":"Foo"}],"pandoc-api-version":[1,22]}`, 1, nil, true},
+		{`{"pandoc-api-version":[1,23,1],"blocks":[{"t":"HorizontalRule"}],"meta":{}}`, 1, nil, true},
+		{`{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"HorizontalRule"}`, 1, nil, true},
+	} {
+		br, err := ReadBlocks(strings.NewReader(tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for br.Next() {
+			n++
+		}
+		if n != tt.blocks || (br.Err() != nil) != tt.fail || !tt.fail && cmpSemver(br.Version(), tt.version) != 0 {
+			t.Errorf("%s: got %d blocks, version %v, error %v", tt.src, n, br.Version(), br.Err())
+		}
+	}
+}
+
+func TestReadMeta(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := ReadFrom(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := ReadMeta(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(&Pandoc{Meta: meta}) != Sprint(&Pandoc{Meta: doc.Meta}) {
+		t.Error("meta differs")
+	}
+	const src = `{"blocks":[{"t":"Para","c":[{"t":"Str","c":"]}\\\"[{"}]},` +
+		`{"t":"CodeBlock","c":[["",[],[]],"` + "\\\\" + `"]}],"pandoc-api-version":[1,23,1],` +
+		`"meta":{"title":{"t":"MetaString","c":"t"}}}`
+	if _, err := ReadFrom(strings.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	for _, pad := range []int{0, 1, 50, 120, 127, 128, 200} {
+		var s scanner
+		s.init(strings.NewReader(strings.Repeat(" ", pad) + src))
+		if err := s.skipValue(); err != nil {
+			t.Fatalf("%d: %s", pad, err)
+		}
+		if s.next() != tokEOF {
+			t.Errorf("%d: value is not skipped", pad)
+		}
+	}
+	if meta, err = ReadMeta(strings.NewReader(src)); err != nil {
+		t.Fatal(err)
+	}
+	if len(meta) != 1 || meta[0].Key != "title" {
+		t.Errorf("unexpected meta %v", meta)
+	}
+	if _, err := ReadMeta(strings.NewReader(src[:40])); err == nil {
+		t.Error("no error on truncated input")
+	}
+}
+
 // func (intReader) read(d *json.Decoder) (int, error) {
 // 	tok, err := d.Token()
 // 	if err != nil {
@@ -0,0 +1,79 @@
+package pandoc
+
+// A set of handlers of Divs and Spans keyed by class, applied in a
+// single traversal. A Div or Span is passed to the handler of its first
+// class having one, and the handler works as a filter function: its
+// result replaces the element or controls the traversal of its
+// children.
+//
+// Example:
+//
+//	reg := pandoc.ClassRegistry{}.
+//	    Div("include", include).
+//	    Div("todo", func(*pandoc.Div) ([]pandoc.Block, error) { return nil, pandoc.Delete }).
+//	    Span("kbd", kbd)
+//	doc, err = reg.Apply(doc)
+type ClassRegistry struct {
+	divs  []classHandler[*Div, Block]
+	spans []classHandler[*Span, Inline]
+}
+
+type classHandler[E Element, R Element] struct {
+	class string
+	fun   func(E) ([]R, error)
+}
+
+// Returns the registry with the handler of Divs of the class set.
+func (r ClassRegistry) Div(class string, fun func(*Div) ([]Block, error)) ClassRegistry {
+	r.divs = register(r.divs, class, fun)
+	return r
+}
+
+// Returns the registry with the handler of Spans of the class set.
+func (r ClassRegistry) Span(class string, fun func(*Span) ([]Inline, error)) ClassRegistry {
+	r.spans = register(r.spans, class, fun)
+	return r
+}
+
+// Returns the document with the handlers applied.
+func (r ClassRegistry) Apply(doc *Pandoc) (*Pandoc, error) {
+	var fs FilterSet
+	if len(r.divs) > 0 {
+		fs = AddFilter(fs, func(d *Div) ([]Block, error) {
+			return dispatch(r.divs, d, d.Classes)
+		})
+	}
+	if len(r.spans) > 0 {
+		fs = AddFilter(fs, func(s *Span) ([]Inline, error) {
+			return dispatch(r.spans, s, s.Classes)
+		})
+	}
+	if len(fs.funs) == 0 {
+		return doc, nil
+	}
+	return Filter(doc, fs.Func())
+}
+
+// returns handlers with the handler of the class replaced or added
+func register[E Element, R Element](handlers []classHandler[E, R], class string, fun func(E) ([]R, error)) []classHandler[E, R] {
+	handlers = append([]classHandler[E, R](nil), handlers...)
+	for i := range handlers {
+		if handlers[i].class == class {
+			handlers[i].fun = fun
+			return handlers
+		}
+	}
+	return append(handlers, classHandler[E, R]{class, fun})
+}
+
+// passes e to the handler of its first class having one
+func dispatch[E Element, R Element](handlers []classHandler[E, R], e E, classes []string) ([]R, error) {
+	for _, class := range classes {
+		for _, h := range handlers {
+			if h.class == class {
+				return h.fun(e)
+			}
+		}
+	}
+	return nil, Continue
+}
@@ -0,0 +1,48 @@
+package pandoc
+
+import (
+	"testing"
+)
+
+func TestClassRegistry(t *testing.T) {
+	div := func(class string, blocks ...Block) *Div {
+		return &Div{Attr: Attr{Classes: []string{class}}, Blocks: blocks}
+	}
+	doc := &Pandoc{Blocks: []Block{
+		div("todo", &Para{Inlines: []Inline{&Str{"later"}}}),
+		&Para{Inlines: []Inline{
+			&Span{Attr: Attr{Classes: []string{"x", "kbd"}}, Inlines: []Inline{&Str{"ctrl"}}},
+			&Space{},
+			&Span{Attr: Attr{Classes: []string{"x"}}, Inlines: []Inline{&Str{"y"}}},
+		}},
+		div("note", div("todo"), &Para{Inlines: []Inline{&Str{"n"}}}),
+	}}
+	var notes int
+	reg := ClassRegistry{}.
+		Div("todo", func(*Div) ([]Block, error) { return nil, Continue }).
+		Div("note", func(*Div) ([]Block, error) { notes++; return nil, Continue }).
+		Span("kbd", func(s *Span) ([]Inline, error) {
+			return []Inline{&Code{Text: Stringify(s)}}, ReplaceSkip
+		})
+	// the handler is replaced, the original registry is kept
+	del := reg.Div("todo", func(*Div) ([]Block, error) { return nil, Delete })
+	res, err := del.Apply(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Blocks) != 2 || notes != 1 {
+		t.Fatalf("blocks: %d, notes: %d", len(res.Blocks), notes)
+	}
+	if c, ok := res.Blocks[0].(*Para).Inlines[0].(*Code); !ok || c.Text != "ctrl" {
+		t.Errorf("span: %#v", res.Blocks[0].(*Para).Inlines[0])
+	}
+	if _, ok := res.Blocks[0].(*Para).Inlines[2].(*Span); !ok {
+		t.Errorf("unregistered span replaced")
+	}
+	if n := len(res.Blocks[1].(*Div).Blocks); n != 1 {
+		t.Errorf("nested todo kept: %d", n)
+	}
+	if res, err := reg.Apply(doc); err != nil || len(res.Blocks) != 3 {
+		t.Errorf("original registry: %v, %d", err, len(res.Blocks))
+	}
+}
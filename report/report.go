@@ -0,0 +1,564 @@
+// Package report generates documents from templates.
+//
+// A template is a regular pandoc document with placeholders: Divs and
+// Spans marked with classes and a "key" attribute naming a value of the
+// report data. For example, in markdown:
+//
+//	# Sales report for [region]{.data key="region.name"}
+//
+//	::: {.data key="sales" columns="month,total" headers="Month,Total"}
+//	:::
+//
+//	::: {.repeat key="managers"}
+//	## [name]{.data key="name"}
+//
+//	[phone]{.data key="phone"}
+//	:::
+//
+//	::: {.chart key="sales" type="bar"}
+//	:::
+//
+// The placeholder classes are:
+//
+//   - .data: the placeholder is replaced with the value. A Span is replaced
+//     with inlines, a Div with blocks. A list of records (maps or structs)
+//     is rendered as a table, with columns listed in the "columns"
+//     attribute (or all record keys, sorted) and header labels in the
+//     "headers" attribute. Columns of numbers are aligned to the right. A
+//     list of other values is rendered as a bullet list. Numbers and times
+//     are formatted with the "format" attribute, if set (see fmt and
+//     time.Time.Format respectively).
+//   - .repeat: the content of the placeholder is repeated for each element
+//     of a list value. Keys inside the content are looked up in the element
+//     first, the key "." refers to the element itself.
+//   - .if: the content is kept only if the value is set and is not false,
+//     zero or empty. With .not, the condition is reversed.
+//   - any class registered in Template.Renderers: the placeholder is
+//     replaced with blocks produced by the renderer, e.g. a chart image.
+//
+// Keys are dot-separated paths through maps, structs and lists, e.g.
+// "sales.0.total". Values of type pandoc.Inline, pandoc.Block, their
+// slices and *pandoc.Pandoc are inserted as is, and Image values are
+// rendered as images.
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/growler/go-pandoc"
+)
+
+// An image value.
+type Image struct {
+	Src   string // Image URL or path
+	Alt   string // Alternative text
+	Title string
+}
+
+// A Renderer produces blocks for a placeholder Div with attributes attr
+// and value v of the key attribute (nil if the key is not set).
+type Renderer func(attr pandoc.Attr, v any) ([]pandoc.Block, error)
+
+// A report template.
+type Template struct {
+	Doc *pandoc.Pandoc
+
+	// Renderers of custom placeholder Divs by class, such as charts or
+	// diagrams.
+	Renderers map[string]Renderer
+}
+
+// Makes a new template from document doc.
+func New(doc *pandoc.Pandoc) *Template {
+	return &Template{Doc: doc, Renderers: make(map[string]Renderer)}
+}
+
+// Loads a template from file f in the format described by conf.
+func Load(f string, conf pandoc.Conf) (*Template, error) {
+	doc, err := pandoc.LoadFile(f, conf)
+	if err != nil {
+		return nil, err
+	}
+	return New(doc), nil
+}
+
+// Registers a renderer of placeholders with class.
+func (t *Template) Register(class string, r Renderer) *Template {
+	if t.Renderers == nil {
+		t.Renderers = make(map[string]Renderer)
+	}
+	t.Renderers[class] = r
+	return t
+}
+
+// Returns a new document with data merged into the template.
+func (t *Template) Execute(data any) (*pandoc.Pandoc, error) {
+	return t.exec(t.Doc, &scope{value: data})
+}
+
+// Merges data into the template and stores the document to w in the
+// format described by conf.
+func (t *Template) Render(w io.Writer, data any, conf pandoc.Conf) error {
+	doc, err := t.Execute(data)
+	if err != nil {
+		return err
+	}
+	if conf.Format == "json" {
+		return doc.WriteTo(w)
+	}
+	return doc.StoreTo(w, conf)
+}
+
+// Merges data into the template and stores the document to file f in
+// the format described by conf.
+func (t *Template) RenderFile(f string, data any, conf pandoc.Conf) error {
+	doc, err := t.Execute(data)
+	if err != nil {
+		return err
+	}
+	return doc.StoreFile(f, conf)
+}
+
+// An error of a placeholder.
+type Error struct {
+	Key string
+	Err error
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("report: %s: %s", e.Key, e.Err) }
+func (e *Error) Unwrap() error { return e.Err }
+
+var ErrNoValue = errors.New("no value")
+
+// the data lookup chain
+type scope struct {
+	value  any
+	parent *scope
+}
+
+func (s *scope) lookup(key string) (any, bool) {
+	if key == "." {
+		return s.value, true
+	}
+	path := strings.Split(key, ".")
+	for ; s != nil; s = s.parent {
+		if v, ok := field(reflect.ValueOf(s.value), path[0]); ok {
+			for _, p := range path[1:] {
+				if v, ok = field(v, p); !ok {
+					return nil, false
+				}
+			}
+			if !v.IsValid() {
+				return nil, true
+			}
+			return v.Interface(), true
+		}
+	}
+	return nil, false
+}
+
+// returns a map entry, a struct field or a list element
+func field(v reflect.Value, name string) (reflect.Value, bool) {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, false
+		}
+		e := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		return e, e.IsValid()
+	case reflect.Struct:
+		f := v.FieldByName(name)
+		if !f.IsValid() || !f.CanInterface() {
+			return reflect.Value{}, false
+		}
+		return f, true
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(name)
+		if err != nil || i < 0 || i >= v.Len() {
+			return reflect.Value{}, false
+		}
+		return v.Index(i), true
+	}
+	return reflect.Value{}, false
+}
+
+func (t *Template) exec(doc *pandoc.Pandoc, s *scope) (*pandoc.Pandoc, error) {
+	doc, err := pandoc.Filter(doc, func(d *pandoc.Div) ([]pandoc.Block, error) {
+		return t.div(d, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pandoc.Filter(doc, func(sp *pandoc.Span) ([]pandoc.Inline, error) {
+		return t.span(sp, s)
+	})
+}
+
+// executes blocks in scope s
+func (t *Template) blocks(blocks []pandoc.Block, s *scope) ([]pandoc.Block, error) {
+	doc, err := t.exec(&pandoc.Pandoc{Blocks: blocks}, s)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Blocks, nil
+}
+
+func (t *Template) value(attr *pandoc.Attr, s *scope) (string, any, error) {
+	key, ok := attr.Get("key")
+	if !ok {
+		return key, nil, &Error{key, errors.New("placeholder without key")}
+	}
+	v, ok := s.lookup(key)
+	if !ok {
+		return key, nil, &Error{key, ErrNoValue}
+	}
+	return key, v, nil
+}
+
+func (t *Template) div(d *pandoc.Div, s *scope) ([]pandoc.Block, error) {
+	switch {
+	case d.HasClass("data"):
+		key, v, err := t.value(&d.Attr, s)
+		if err != nil {
+			return nil, err
+		}
+		b, err := toBlocks(v, &d.Attr)
+		if err != nil {
+			return nil, &Error{key, err}
+		}
+		return b, pandoc.ReplaceSkip
+	case d.HasClass("repeat"):
+		key, v, err := t.value(&d.Attr, s)
+		if err != nil {
+			return nil, err
+		}
+		items := reflect.ValueOf(v)
+		if v != nil && items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			return nil, &Error{key, fmt.Errorf("cannot repeat %T", v)}
+		}
+		var result []pandoc.Block
+		for i := 0; v != nil && i < items.Len(); i++ {
+			b, err := t.blocks(d.Blocks, &scope{items.Index(i).Interface(), s})
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, b...)
+		}
+		return result, pandoc.ReplaceSkip
+	case d.HasClass("if"):
+		_, v, err := t.value(&d.Attr, s)
+		if err != nil && !errors.Is(err, ErrNoValue) {
+			return nil, err
+		}
+		if truthy(v) == d.HasClass("not") {
+			return nil, pandoc.ReplaceSkip
+		}
+		b, err := t.blocks(d.Blocks, s)
+		if err != nil {
+			return nil, err
+		}
+		return b, pandoc.ReplaceSkip
+	}
+	for _, c := range d.Classes {
+		if r, ok := t.Renderers[c]; ok {
+			var v any
+			if key, ok := d.Get("key"); ok {
+				if v, ok = s.lookup(key); !ok {
+					return nil, &Error{key, ErrNoValue}
+				}
+			}
+			b, err := r(d.Attr, v)
+			if err != nil {
+				return nil, &Error{c, err}
+			}
+			return b, pandoc.ReplaceSkip
+		}
+	}
+	return nil, pandoc.Continue
+}
+
+func (t *Template) span(sp *pandoc.Span, s *scope) ([]pandoc.Inline, error) {
+	switch {
+	case sp.HasClass("data"):
+		key, v, err := t.value(&sp.Attr, s)
+		if err != nil {
+			return nil, err
+		}
+		l, err := toInlines(v, &sp.Attr)
+		if err != nil {
+			return nil, &Error{key, err}
+		}
+		return l, pandoc.ReplaceSkip
+	case sp.HasClass("if"):
+		_, v, err := t.value(&sp.Attr, s)
+		if err != nil && !errors.Is(err, ErrNoValue) {
+			return nil, err
+		}
+		if truthy(v) == sp.HasClass("not") {
+			return nil, pandoc.ReplaceSkip
+		}
+		c, err := pandoc.Filter(&pandoc.Span{Inlines: sp.Inlines}, func(sp *pandoc.Span) ([]pandoc.Inline, error) {
+			return t.span(sp, s)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return c.Inlines, pandoc.ReplaceSkip
+	}
+	return nil, pandoc.Continue
+}
+
+// reports whether the value is set and is not false, zero or empty
+func truthy(v any) bool {
+	if v == nil {
+		return false
+	}
+	r := reflect.ValueOf(v)
+	switch r.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String, reflect.Array:
+		return r.Len() > 0
+	case reflect.Pointer, reflect.Interface:
+		return !r.IsNil()
+	}
+	return !r.IsZero()
+}
+
+// returns text inlines, splitting words by spaces
+func text(s string) []pandoc.Inline {
+	var l []pandoc.Inline
+	for i, line := range strings.Split(s, "\n") {
+		if i > 0 {
+			l = append(l, pandoc.LB)
+		}
+		for j, w := range strings.Fields(line) {
+			if j > 0 {
+				l = append(l, pandoc.SP)
+			}
+			l = append(l, &pandoc.Str{Text: w})
+		}
+	}
+	return l
+}
+
+// formats a scalar value; returns false if the value is not a scalar
+func format(v any, attr *pandoc.Attr) (string, bool) {
+	f, hasFormat := attr.Get("format")
+	switch v := v.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case time.Time:
+		if !hasFormat {
+			f = time.DateOnly
+		}
+		return v.Format(f), true
+	case fmt.Stringer:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	}
+	if isNumber(v) {
+		if hasFormat {
+			return fmt.Sprintf(f, v), true
+		}
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+func isNumber(v any) bool {
+	if v == nil {
+		return false
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func toInlines(v any, attr *pandoc.Attr) ([]pandoc.Inline, error) {
+	switch v := v.(type) {
+	case pandoc.Inline:
+		return []pandoc.Inline{v}, nil
+	case []pandoc.Inline:
+		return v, nil
+	case Image:
+		return []pandoc.Inline{&pandoc.Image{
+			Inlines: text(v.Alt),
+			Target:  pandoc.Target{Url: v.Src, Title: v.Title},
+		}}, nil
+	case *Image:
+		return toInlines(*v, attr)
+	}
+	if s, ok := format(v, attr); ok {
+		return text(s), nil
+	}
+	return nil, fmt.Errorf("cannot render %T as inlines", v)
+}
+
+func toBlocks(v any, attr *pandoc.Attr) ([]pandoc.Block, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case pandoc.Block:
+		return []pandoc.Block{v}, nil
+	case []pandoc.Block:
+		return v, nil
+	case *pandoc.Pandoc:
+		return v.Blocks, nil
+	case string:
+		var blocks []pandoc.Block
+		for _, p := range strings.Split(v, "\n\n") {
+			if l := text(p); len(l) > 0 {
+				blocks = append(blocks, &pandoc.Para{Inlines: l})
+			}
+		}
+		return blocks, nil
+	}
+	if r := reflect.ValueOf(v); (r.Kind() == reflect.Slice || r.Kind() == reflect.Array) && r.Type().Elem() != reflect.TypeOf(pandoc.Inline(nil)) {
+		if isRecords(r) {
+			return table(r, attr)
+		}
+		items := make([][]pandoc.Block, r.Len())
+		for i := range items {
+			l, err := toInlines(r.Index(i).Interface(), attr)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = []pandoc.Block{&pandoc.Plain{Inlines: l}}
+		}
+		return []pandoc.Block{&pandoc.BulletList{Items: items}}, nil
+	}
+	l, err := toInlines(v, attr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot render %T as blocks", v)
+	}
+	return []pandoc.Block{&pandoc.Para{Inlines: l}}, nil
+}
+
+// reports whether all list elements are maps or structs
+func isRecords(r reflect.Value) bool {
+	if r.Len() == 0 {
+		return false
+	}
+	for i := 0; i < r.Len(); i++ {
+		e := r.Index(i)
+		for e.Kind() == reflect.Pointer || e.Kind() == reflect.Interface {
+			e = e.Elem()
+		}
+		if e.Kind() != reflect.Map && e.Kind() != reflect.Struct {
+			return false
+		}
+	}
+	return true
+}
+
+// returns names of all record fields
+func columns(r reflect.Value) []string {
+	seen := make(map[string]bool)
+	var cols []string
+	for i := 0; i < r.Len(); i++ {
+		e := r.Index(i)
+		for e.Kind() == reflect.Pointer || e.Kind() == reflect.Interface {
+			e = e.Elem()
+		}
+		var names []string
+		if e.Kind() == reflect.Map {
+			for _, k := range e.MapKeys() {
+				if k.Kind() == reflect.String {
+					names = append(names, k.String())
+				}
+			}
+			sort.Strings(names)
+		} else {
+			for j := 0; j < e.NumField(); j++ {
+				if f := e.Type().Field(j); f.IsExported() {
+					names = append(names, f.Name)
+				}
+			}
+		}
+		for _, n := range names {
+			if !seen[n] {
+				seen[n] = true
+				cols = append(cols, n)
+			}
+		}
+	}
+	return cols
+}
+
+func splitList(s string) []string {
+	l := strings.Split(s, ",")
+	for i := range l {
+		l[i] = strings.TrimSpace(l[i])
+	}
+	return l
+}
+
+func cell(blocks ...pandoc.Block) *pandoc.TableCell {
+	return &pandoc.TableCell{Align: pandoc.AlignDefault, RowSpan: 1, ColSpan: 1, Blocks: blocks}
+}
+
+// renders a list of records as a table
+func table(r reflect.Value, attr *pandoc.Attr) ([]pandoc.Block, error) {
+	var cols, headers []string
+	if c, ok := attr.Get("columns"); ok {
+		cols = splitList(c)
+	} else {
+		cols = columns(r)
+	}
+	if h, ok := attr.Get("headers"); ok {
+		headers = splitList(h)
+	}
+	t := &pandoc.Table{
+		Aligns: make([]pandoc.ColSpec, len(cols)),
+		Bodies: []*pandoc.TableBody{{}},
+	}
+	head := &pandoc.TableRow{}
+	for i, c := range cols {
+		label := c
+		if i < len(headers) {
+			label = headers[i]
+		}
+		head.Cells = append(head.Cells, cell(&pandoc.Plain{Inlines: text(label)}))
+		t.Aligns[i] = pandoc.ColSpec{Align: pandoc.AlignRight, Width: pandoc.DefaultColWidth()}
+	}
+	t.Head.Rows = []*pandoc.TableRow{head}
+	for i := 0; i < r.Len(); i++ {
+		row := &pandoc.TableRow{}
+		for j, c := range cols {
+			var v any
+			if f, ok := field(r.Index(i), c); ok && f.IsValid() {
+				v = f.Interface()
+			}
+			if v != nil && !isNumber(v) {
+				t.Aligns[j].Align = pandoc.AlignDefault
+			}
+			l, err := toInlines(v, attr)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", c, err)
+			}
+			row.Cells = append(row.Cells, cell(&pandoc.Plain{Inlines: l}))
+		}
+		t.Bodies[0].Body = append(t.Bodies[0].Body, row)
+	}
+	if c, ok := attr.Get("caption"); ok {
+		t.Caption.Long = []pandoc.Block{&pandoc.Plain{Inlines: text(c)}}
+	}
+	t.Id = attr.Id
+	return []pandoc.Block{t}, nil
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/growler/go-pandoc"
+)
+
+func span(key string, classes ...string) *pandoc.Span {
+	return &pandoc.Span{Attr: pandoc.Attr{Classes: append(classes, "data"), KVs: []pandoc.KV{{Key: "key", Value: key}}}}
+}
+
+func div(class string, kvs []pandoc.KV, blocks ...pandoc.Block) *pandoc.Div {
+	return &pandoc.Div{Attr: pandoc.Attr{Classes: []string{class}, KVs: kvs}, Blocks: blocks}
+}
+
+func kv(kv ...string) []pandoc.KV {
+	var l []pandoc.KV
+	for i := 0; i+1 < len(kv); i += 2 {
+		l = append(l, pandoc.KV{Key: kv[i], Value: kv[i+1]})
+	}
+	return l
+}
+
+type manager struct {
+	Name  string
+	Phone string
+}
+
+func TestExecute(t *testing.T) {
+	tmpl := New(&pandoc.Pandoc{Blocks: []pandoc.Block{
+		&pandoc.Header{Level: 1, Inlines: []pandoc.Inline{&pandoc.Str{Text: "Report:"}, pandoc.SP, span("region.name")}},
+		div("data", kv("key", "sales", "columns", "month,total", "headers", "Month,Total")),
+		div("repeat", kv("key", "managers"),
+			&pandoc.Para{Inlines: []pandoc.Inline{span("Name"), pandoc.SP, span("phone")}}),
+		div("if", kv("key", "draft"), &pandoc.Para{Inlines: []pandoc.Inline{&pandoc.Str{Text: "DRAFT"}}}),
+		div("chart", kv("key", "sales", "type", "bar")),
+		div("data", kv("key", "tags")),
+	}})
+	tmpl.Register("chart", func(attr pandoc.Attr, v any) ([]pandoc.Block, error) {
+		kind, _ := attr.Get("type")
+		return []pandoc.Block{&pandoc.Para{Inlines: []pandoc.Inline{&pandoc.Image{
+			Target: pandoc.Target{Url: kind + ".png"},
+		}}}}, nil
+	})
+	data := map[string]any{
+		"region": map[string]string{"name": "North"},
+		"sales": []map[string]any{
+			{"month": "Jan", "total": 10},
+			{"month": "Feb", "total": 12.5},
+		},
+		"managers": []manager{{"Ann", "1"}, {"Bob", "2"}},
+		"phone":    "n/a",
+		"draft":    false,
+		"tags":     []string{"a", "b"},
+	}
+	doc, err := tmpl.Execute(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := pandoc.Dump(&sb, doc); err != nil {
+		t.Fatal(err)
+	}
+	got := sb.String()
+	for _, want := range []string{
+		`Str "North"`,
+		`Str "Feb"`, `Str "12.5"`,
+		`Str "Ann"`, `Str "Bob"`, `Str "n/a"`,
+		`Image -> bar.png`,
+		`Str "b"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%s not found in\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "DRAFT") || strings.Contains(got, "Span") || strings.Contains(got, "Div") {
+		t.Errorf("placeholders are not replaced:\n%s", got)
+	}
+	tbl := doc.Blocks[1].(*pandoc.Table)
+	if tbl.Aligns[0].Align != pandoc.AlignDefault || tbl.Aligns[1].Align != pandoc.AlignRight {
+		t.Errorf("unexpected alignments %v", tbl.Aligns)
+	}
+	if _, err := tmpl.Execute(map[string]any{}); !errors.Is(err, ErrNoValue) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
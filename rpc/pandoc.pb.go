@@ -0,0 +1,437 @@
+// A document conversion and filtering service on top of pandoc.
+//
+// Documents are streamed in chunks: the first request of a call has the
+// options, the following ones chunks of the document, and the responses
+// are chunks of the result. A call failing after sending chunks ends
+// with an error status, the chunks received must then be discarded.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: pandoc.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ConvertRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Request:
+	//
+	//	*ConvertRequest_Options
+	//	*ConvertRequest_Data
+	Request       isConvertRequest_Request `protobuf_oneof:"request"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConvertRequest) Reset() {
+	*x = ConvertRequest{}
+	mi := &file_pandoc_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertRequest) ProtoMessage() {}
+
+func (x *ConvertRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pandoc_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertRequest.ProtoReflect.Descriptor instead.
+func (*ConvertRequest) Descriptor() ([]byte, []int) {
+	return file_pandoc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ConvertRequest) GetRequest() isConvertRequest_Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *ConvertRequest) GetOptions() *ConvertOptions {
+	if x != nil {
+		if x, ok := x.Request.(*ConvertRequest_Options); ok {
+			return x.Options
+		}
+	}
+	return nil
+}
+
+func (x *ConvertRequest) GetData() []byte {
+	if x != nil {
+		if x, ok := x.Request.(*ConvertRequest_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+type isConvertRequest_Request interface {
+	isConvertRequest_Request()
+}
+
+type ConvertRequest_Options struct {
+	Options *ConvertOptions `protobuf:"bytes,1,opt,name=options,proto3,oneof"`
+}
+
+type ConvertRequest_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*ConvertRequest_Options) isConvertRequest_Request() {}
+
+func (*ConvertRequest_Data) isConvertRequest_Request() {}
+
+type ConvertOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Input format, a pandoc format optionally followed by extensions,
+	// e.g. "markdown+smart", or a media type.
+	From string `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
+	// Output format, a pandoc format or a media type.
+	To string `protobuf:"bytes,2,opt,name=to,proto3" json:"to,omitempty"`
+	// Names of the transformers applied in order.
+	Transformers  []string `protobuf:"bytes,3,rep,name=transformers,proto3" json:"transformers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConvertOptions) Reset() {
+	*x = ConvertOptions{}
+	mi := &file_pandoc_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConvertOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertOptions) ProtoMessage() {}
+
+func (x *ConvertOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_pandoc_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertOptions.ProtoReflect.Descriptor instead.
+func (*ConvertOptions) Descriptor() ([]byte, []int) {
+	return file_pandoc_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ConvertOptions) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *ConvertOptions) GetTransformers() []string {
+	if x != nil {
+		return x.Transformers
+	}
+	return nil
+}
+
+type FilterRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Request:
+	//
+	//	*FilterRequest_Options
+	//	*FilterRequest_Data
+	Request       isFilterRequest_Request `protobuf_oneof:"request"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilterRequest) Reset() {
+	*x = FilterRequest{}
+	mi := &file_pandoc_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterRequest) ProtoMessage() {}
+
+func (x *FilterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pandoc_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterRequest.ProtoReflect.Descriptor instead.
+func (*FilterRequest) Descriptor() ([]byte, []int) {
+	return file_pandoc_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *FilterRequest) GetRequest() isFilterRequest_Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *FilterRequest) GetOptions() *FilterOptions {
+	if x != nil {
+		if x, ok := x.Request.(*FilterRequest_Options); ok {
+			return x.Options
+		}
+	}
+	return nil
+}
+
+func (x *FilterRequest) GetData() []byte {
+	if x != nil {
+		if x, ok := x.Request.(*FilterRequest_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+type isFilterRequest_Request interface {
+	isFilterRequest_Request()
+}
+
+type FilterRequest_Options struct {
+	Options *FilterOptions `protobuf:"bytes,1,opt,name=options,proto3,oneof"`
+}
+
+type FilterRequest_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*FilterRequest_Options) isFilterRequest_Request() {}
+
+func (*FilterRequest_Data) isFilterRequest_Request() {}
+
+type FilterOptions struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Names of the transformers applied in order.
+	Transformers  []string `protobuf:"bytes,1,rep,name=transformers,proto3" json:"transformers,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FilterOptions) Reset() {
+	*x = FilterOptions{}
+	mi := &file_pandoc_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FilterOptions) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FilterOptions) ProtoMessage() {}
+
+func (x *FilterOptions) ProtoReflect() protoreflect.Message {
+	mi := &file_pandoc_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FilterOptions.ProtoReflect.Descriptor instead.
+func (*FilterOptions) Descriptor() ([]byte, []int) {
+	return file_pandoc_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *FilterOptions) GetTransformers() []string {
+	if x != nil {
+		return x.Transformers
+	}
+	return nil
+}
+
+type Chunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chunk) Reset() {
+	*x = Chunk{}
+	mi := &file_pandoc_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chunk) ProtoMessage() {}
+
+func (x *Chunk) ProtoReflect() protoreflect.Message {
+	mi := &file_pandoc_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chunk.ProtoReflect.Descriptor instead.
+func (*Chunk) Descriptor() ([]byte, []int) {
+	return file_pandoc_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Chunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_pandoc_proto protoreflect.FileDescriptor
+
+const file_pandoc_proto_rawDesc = "" +
+	"\n" +
+	"\fpandoc.proto\x12\vgopandoc.v1\"j\n" +
+	"\x0eConvertRequest\x127\n" +
+	"\aoptions\x18\x01 \x01(\v2\x1b.gopandoc.v1.ConvertOptionsH\x00R\aoptions\x12\x14\n" +
+	"\x04data\x18\x02 \x01(\fH\x00R\x04dataB\t\n" +
+	"\arequest\"X\n" +
+	"\x0eConvertOptions\x12\x12\n" +
+	"\x04from\x18\x01 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\x02 \x01(\tR\x02to\x12\"\n" +
+	"\ftransformers\x18\x03 \x03(\tR\ftransformers\"h\n" +
+	"\rFilterRequest\x126\n" +
+	"\aoptions\x18\x01 \x01(\v2\x1a.gopandoc.v1.FilterOptionsH\x00R\aoptions\x12\x14\n" +
+	"\x04data\x18\x02 \x01(\fH\x00R\x04dataB\t\n" +
+	"\arequest\"3\n" +
+	"\rFilterOptions\x12\"\n" +
+	"\ftransformers\x18\x01 \x03(\tR\ftransformers\"\x1b\n" +
+	"\x05Chunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data2\x86\x01\n" +
+	"\x06Pandoc\x12>\n" +
+	"\aConvert\x12\x1b.gopandoc.v1.ConvertRequest\x1a\x12.gopandoc.v1.Chunk(\x010\x01\x12<\n" +
+	"\x06Filter\x12\x1a.gopandoc.v1.FilterRequest\x1a\x12.gopandoc.v1.Chunk(\x010\x01B\"Z github.com/growler/go-pandoc/rpcb\x06proto3"
+
+var (
+	file_pandoc_proto_rawDescOnce sync.Once
+	file_pandoc_proto_rawDescData []byte
+)
+
+func file_pandoc_proto_rawDescGZIP() []byte {
+	file_pandoc_proto_rawDescOnce.Do(func() {
+		file_pandoc_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pandoc_proto_rawDesc), len(file_pandoc_proto_rawDesc)))
+	})
+	return file_pandoc_proto_rawDescData
+}
+
+var file_pandoc_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_pandoc_proto_goTypes = []any{
+	(*ConvertRequest)(nil), // 0: gopandoc.v1.ConvertRequest
+	(*ConvertOptions)(nil), // 1: gopandoc.v1.ConvertOptions
+	(*FilterRequest)(nil),  // 2: gopandoc.v1.FilterRequest
+	(*FilterOptions)(nil),  // 3: gopandoc.v1.FilterOptions
+	(*Chunk)(nil),          // 4: gopandoc.v1.Chunk
+}
+var file_pandoc_proto_depIdxs = []int32{
+	1, // 0: gopandoc.v1.ConvertRequest.options:type_name -> gopandoc.v1.ConvertOptions
+	3, // 1: gopandoc.v1.FilterRequest.options:type_name -> gopandoc.v1.FilterOptions
+	0, // 2: gopandoc.v1.Pandoc.Convert:input_type -> gopandoc.v1.ConvertRequest
+	2, // 3: gopandoc.v1.Pandoc.Filter:input_type -> gopandoc.v1.FilterRequest
+	4, // 4: gopandoc.v1.Pandoc.Convert:output_type -> gopandoc.v1.Chunk
+	4, // 5: gopandoc.v1.Pandoc.Filter:output_type -> gopandoc.v1.Chunk
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_pandoc_proto_init() }
+func file_pandoc_proto_init() {
+	if File_pandoc_proto != nil {
+		return
+	}
+	file_pandoc_proto_msgTypes[0].OneofWrappers = []any{
+		(*ConvertRequest_Options)(nil),
+		(*ConvertRequest_Data)(nil),
+	}
+	file_pandoc_proto_msgTypes[2].OneofWrappers = []any{
+		(*FilterRequest_Options)(nil),
+		(*FilterRequest_Data)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pandoc_proto_rawDesc), len(file_pandoc_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pandoc_proto_goTypes,
+		DependencyIndexes: file_pandoc_proto_depIdxs,
+		MessageInfos:      file_pandoc_proto_msgTypes,
+	}.Build()
+	File_pandoc_proto = out.File
+	file_pandoc_proto_goTypes = nil
+	file_pandoc_proto_depIdxs = nil
+}
@@ -0,0 +1,160 @@
+// A document conversion and filtering service on top of pandoc.
+//
+// Documents are streamed in chunks: the first request of a call has the
+// options, the following ones chunks of the document, and the responses
+// are chunks of the result. A call failing after sending chunks ends
+// with an error status, the chunks received must then be discarded.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             (unknown)
+// source: pandoc.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Pandoc_Convert_FullMethodName = "/gopandoc.v1.Pandoc/Convert"
+	Pandoc_Filter_FullMethodName  = "/gopandoc.v1.Pandoc/Filter"
+)
+
+// PandocClient is the client API for Pandoc service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PandocClient interface {
+	// Converts a document with pandoc, applying transformers to it.
+	Convert(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConvertRequest, Chunk], error)
+	// Applies transformers to a document of pandoc JSON AST, as a pandoc
+	// filter.
+	Filter(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[FilterRequest, Chunk], error)
+}
+
+type pandocClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPandocClient(cc grpc.ClientConnInterface) PandocClient {
+	return &pandocClient{cc}
+}
+
+func (c *pandocClient) Convert(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ConvertRequest, Chunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pandoc_ServiceDesc.Streams[0], Pandoc_Convert_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ConvertRequest, Chunk]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pandoc_ConvertClient = grpc.BidiStreamingClient[ConvertRequest, Chunk]
+
+func (c *pandocClient) Filter(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[FilterRequest, Chunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Pandoc_ServiceDesc.Streams[1], Pandoc_Filter_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[FilterRequest, Chunk]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pandoc_FilterClient = grpc.BidiStreamingClient[FilterRequest, Chunk]
+
+// PandocServer is the server API for Pandoc service.
+// All implementations must embed UnimplementedPandocServer
+// for forward compatibility.
+type PandocServer interface {
+	// Converts a document with pandoc, applying transformers to it.
+	Convert(grpc.BidiStreamingServer[ConvertRequest, Chunk]) error
+	// Applies transformers to a document of pandoc JSON AST, as a pandoc
+	// filter.
+	Filter(grpc.BidiStreamingServer[FilterRequest, Chunk]) error
+	mustEmbedUnimplementedPandocServer()
+}
+
+// UnimplementedPandocServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedPandocServer struct{}
+
+func (UnimplementedPandocServer) Convert(grpc.BidiStreamingServer[ConvertRequest, Chunk]) error {
+	return status.Error(codes.Unimplemented, "method Convert not implemented")
+}
+func (UnimplementedPandocServer) Filter(grpc.BidiStreamingServer[FilterRequest, Chunk]) error {
+	return status.Error(codes.Unimplemented, "method Filter not implemented")
+}
+func (UnimplementedPandocServer) mustEmbedUnimplementedPandocServer() {}
+func (UnimplementedPandocServer) testEmbeddedByValue()                {}
+
+// UnsafePandocServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PandocServer will
+// result in compilation errors.
+type UnsafePandocServer interface {
+	mustEmbedUnimplementedPandocServer()
+}
+
+func RegisterPandocServer(s grpc.ServiceRegistrar, srv PandocServer) {
+	// If the following call panics, it indicates UnimplementedPandocServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Pandoc_ServiceDesc, srv)
+}
+
+func _Pandoc_Convert_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PandocServer).Convert(&grpc.GenericServerStream[ConvertRequest, Chunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pandoc_ConvertServer = grpc.BidiStreamingServer[ConvertRequest, Chunk]
+
+func _Pandoc_Filter_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(PandocServer).Filter(&grpc.GenericServerStream[FilterRequest, Chunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Pandoc_FilterServer = grpc.BidiStreamingServer[FilterRequest, Chunk]
+
+// Pandoc_ServiceDesc is the grpc.ServiceDesc for Pandoc service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pandoc_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gopandoc.v1.Pandoc",
+	HandlerType: (*PandocServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Convert",
+			Handler:       _Pandoc_Convert_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Filter",
+			Handler:       _Pandoc_Filter_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "pandoc.proto",
+}
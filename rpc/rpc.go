@@ -0,0 +1,412 @@
+// Package rpc implements a gRPC document conversion and filtering
+// service on top of pandoc, see pandoc.proto, so that several services
+// can share one host having pandoc. It is a module of its own, so that
+// only programs serving or calling it depend on gRPC.
+//
+// Calls stream documents in chunks and apply transformers by name:
+//
+//	s := grpc.NewServer()
+//	rpc.RegisterPandocServer(s, &rpc.Server{
+//	    Transformers: map[string]func(*pandoc.Pandoc) (*pandoc.Pandoc, error){
+//	        "no-notes": pandoc.RemoveAll[*pandoc.Note, *pandoc.Pandoc],
+//	    },
+//	})
+//	log.Fatal(s.Serve(lis))
+//
+// and on the client:
+//
+//	c := rpc.NewPandocClient(conn)
+//	err := rpc.Convert(ctx, c, &rpc.ConvertOptions{From: "markdown", To: "html", Transformers: []string{"no-notes"}}, in, out)
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pandoc.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/growler/go-pandoc"
+	"github.com/growler/go-pandoc/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Size of the chunks of documents sent if none is configured.
+const DefaultChunkSize = 64 << 10
+
+// Server is a PandocServer converting documents with a backend of
+// package service. The zero value is a usable server running pandoc for
+// every conversion.
+type Server struct {
+	UnimplementedPandocServer
+
+	Backend       service.Backend  // Conversion backend, defaults to service.Exec{}
+	Inputs        []service.Format // Accepted input formats, defaults to service.DefaultInputs
+	Outputs       []service.Format // Produced output formats, defaults to service.DefaultOutputs
+	MaxInputSize  int64            // Maximum document size, defaults to service.DefaultMaxBodySize
+	MaxOutputSize int64            // Maximum result size, defaults to service.DefaultMaxOutputSize
+	Timeout       time.Duration    // Maximum time of a call, defaults to service.DefaultTimeout
+	ChunkSize     int              // Size of the chunks of results, defaults to DefaultChunkSize
+
+	// Transformers are the named transformers calls may apply.
+	Transformers map[string]func(*pandoc.Pandoc) (*pandoc.Pandoc, error)
+}
+
+func (s *Server) backend() service.Backend {
+	if s.Backend == nil {
+		return service.Exec{}
+	}
+	return s.Backend
+}
+
+func (s *Server) inputs() []service.Format {
+	if s.Inputs == nil {
+		return service.DefaultInputs
+	}
+	return s.Inputs
+}
+
+func (s *Server) outputs() []service.Format {
+	if s.Outputs == nil {
+		return service.DefaultOutputs
+	}
+	return s.Outputs
+}
+
+func limit[T int | int64 | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+func (s *Server) Convert(stream grpc.BidiStreamingServer[ConvertRequest, Chunk]) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return errNoOptions
+	} else if err != nil {
+		return err
+	}
+	opts := req.GetOptions()
+	if opts == nil {
+		return errNoOptions
+	}
+	from, ok := service.LookupInput(opts.From, s.inputs())
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "unsupported input format %q", opts.From)
+	}
+	to, ok := s.output(opts.To)
+	if !ok {
+		return status.Errorf(codes.InvalidArgument, "unsupported output format %q", opts.To)
+	}
+	transform, err := s.transform(opts.Transformers)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(stream.Context(), limit(s.Timeout, service.DefaultTimeout))
+	defer cancel()
+	r := s.reader(ctx, func() ([]byte, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		} else if req.GetOptions() != nil {
+			return nil, errOptions
+		}
+		return req.GetData(), nil
+	})
+	w := s.writer(stream)
+	err = service.Convert(ctx, s.backend(), r, from, to, w, transform)
+	if r.err != nil && r.err != io.EOF {
+		// the backend may not report why the input ended prematurely
+		return callError(r.err)
+	} else if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = w.flush()
+	}
+	return callError(err)
+}
+
+func (s *Server) Filter(stream grpc.BidiStreamingServer[FilterRequest, Chunk]) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return errNoOptions
+	} else if err != nil {
+		return err
+	}
+	opts := req.GetOptions()
+	if opts == nil {
+		return errNoOptions
+	}
+	transform, err := s.transform(opts.Transformers)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(stream.Context(), limit(s.Timeout, service.DefaultTimeout))
+	defer cancel()
+	r := s.reader(ctx, func() ([]byte, error) {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		} else if req.GetOptions() != nil {
+			return nil, errOptions
+		}
+		return req.GetData(), nil
+	})
+	w := s.writer(stream)
+	doc, err := pandoc.ReadOptions{KeepUnknown: true}.Read(r)
+	if r.err != nil && r.err != io.EOF {
+		return callError(r.err)
+	} else if err != nil {
+		return status.Errorf(codes.InvalidArgument, "reading document: %s", err)
+	}
+	if transform != nil {
+		if doc, err = transform(doc); err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return callError(err)
+		}
+	}
+	if err := doc.WriteTo(w); err != nil {
+		return callError(err)
+	}
+	return callError(w.flush())
+}
+
+// returns the output format named by its pandoc format or media type
+func (s *Server) output(name string) (service.Format, bool) {
+	for _, f := range s.outputs() {
+		if f.Conf.Format == name || f.MediaType == name {
+			return f, true
+		}
+	}
+	return service.Format{}, false
+}
+
+// returns the transformer applying the named transformers in order, nil
+// if there are none
+func (s *Server) transform(names []string) (func(*pandoc.Pandoc) (*pandoc.Pandoc, error), error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var p pandoc.Pipeline
+	for _, name := range names {
+		t, ok := s.Transformers[name]
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "unknown transformer %q", name)
+		}
+		p = p.Then(name, t)
+	}
+	return p.Run, nil
+}
+
+var (
+	errNoOptions      = status.Error(codes.InvalidArgument, "the first request has no options")
+	errOptions        = status.Error(codes.InvalidArgument, "options are sent after the first request")
+	errInputTooLarge  = status.Error(codes.ResourceExhausted, "document is too large")
+	errOutputTooLarge = status.Error(codes.ResourceExhausted, "output is too large")
+)
+
+// returns the status error of a failed call: errors of malformed
+// documents are invalid arguments, pandoc failures failed preconditions,
+// and other errors of the backend or transformers internal ones
+func callError(err error) error {
+	var (
+		st   interface{ GRPCStatus() *status.Status }
+		serr *pandoc.SyntaxError
+		perr *pandoc.PandocError
+	)
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &st):
+		return st.GRPCStatus().Err()
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.As(err, &serr):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &perr):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func (s *Server) reader(ctx context.Context, recv func() ([]byte, error)) *chunkReader {
+	return &chunkReader{ctx: ctx, recv: recv, limit: limit(s.MaxInputSize, service.DefaultMaxBodySize)}
+}
+
+// a reader of the chunks of a document until ctx is done, remembering the
+// read error
+type chunkReader struct {
+	ctx   context.Context
+	recv  func() ([]byte, error)
+	buf   []byte
+	n     int64
+	limit int64
+	err   error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 && r.err == nil {
+		if r.err = r.ctx.Err(); r.err != nil {
+			break
+		}
+		r.buf, r.err = r.recv()
+		if r.n += int64(len(r.buf)); r.n > r.limit {
+			r.buf, r.err = nil, errInputTooLarge
+		}
+	}
+	if len(r.buf) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (s *Server) writer(stream interface{ Send(*Chunk) error }) *chunkWriter {
+	return &chunkWriter{
+		send:  stream.Send,
+		size:  limit(s.ChunkSize, DefaultChunkSize),
+		limit: limit(s.MaxOutputSize, service.DefaultMaxOutputSize),
+	}
+}
+
+// a writer sending chunks of a document, refusing to write beyond a
+// limit
+type chunkWriter struct {
+	send  func(*Chunk) error
+	buf   []byte
+	size  int
+	n     int64
+	limit int64
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	if w.n+int64(len(p)) > w.limit {
+		return 0, errOutputTooLarge
+	}
+	w.n += int64(len(p))
+	n := len(p)
+	for len(p) > 0 {
+		if w.buf == nil {
+			w.buf = make([]byte, 0, w.size)
+		}
+		k := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf, p = w.buf[:len(w.buf)+k], p[k:]
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// sends the chunk buffered, if any
+func (w *chunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	// the chunk sent may still be in use, it is not reused
+	chunk := &Chunk{Data: w.buf}
+	w.buf = nil
+	return w.send(chunk)
+}
+
+// ----------- client -------------
+
+// Converts a document read from r with the client and writes the result
+// to w. Chunks of the result are written as they are received, so w has
+// a partial result if the call fails.
+func Convert(ctx context.Context, c PandocClient, opts *ConvertOptions, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.Convert(ctx)
+	if err != nil {
+		return err
+	}
+	return call(cancel, stream, &ConvertRequest{Request: &ConvertRequest_Options{Options: opts}}, func(data []byte) *ConvertRequest {
+		return &ConvertRequest{Request: &ConvertRequest_Data{Data: data}}
+	}, r, w)
+}
+
+// Applies transformers to a document of pandoc JSON AST read from r with
+// the client and writes the result to w, as Convert.
+func Filter(ctx context.Context, c PandocClient, opts *FilterOptions, r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.Filter(ctx)
+	if err != nil {
+		return err
+	}
+	return call(cancel, stream, &FilterRequest{Request: &FilterRequest_Options{Options: opts}}, func(data []byte) *FilterRequest {
+		return &FilterRequest{Request: &FilterRequest_Data{Data: data}}
+	}, r, w)
+}
+
+// sends the options and chunks of r to the stream while writing the
+// chunks received to w; cancel aborts the stream. The call does not wait
+// for the sender once the result is received, since it may be blocked
+// reading r.
+func call[Req any](cancel func(), stream grpc.BidiStreamingClient[Req, Chunk], opts *Req, data func([]byte) *Req, r io.Reader, w io.Writer) error {
+	errc := make(chan error, 1)
+	go func() {
+		// errors of the stream are received
+		if stream.Send(opts) != nil {
+			errc <- nil
+			return
+		}
+		for {
+			buf := make([]byte, DefaultChunkSize)
+			n, err := r.Read(buf)
+			if n > 0 && stream.Send(data(buf[:n])) != nil {
+				errc <- nil
+				return
+			}
+			if err == io.EOF {
+				errc <- stream.CloseSend()
+				return
+			} else if err != nil {
+				// reported before the stream is aborted, so that the call
+				// fails with the read error
+				errc <- fmt.Errorf("reading document: %w", err)
+				cancel()
+				return
+			}
+		}
+	}()
+	err := receive(stream, w)
+	cancel()
+	select {
+	case serr := <-errc:
+		if serr != nil {
+			return serr
+		}
+	default:
+	}
+	return err
+}
+
+func receive(stream grpc.ServerStreamingClient[Chunk], w io.Writer) error {
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk.GetData()); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/growler/go-pandoc"
+	"github.com/growler/go-pandoc/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// a backend reading plain text as a paragraph, writing its text, and
+// waiting for timeout on "slow" format
+type fakeBackend struct{}
+
+func (fakeBackend) Load(ctx context.Context, r io.Reader, conf pandoc.Conf) (*pandoc.Pandoc, error) {
+	if conf.Format == "json" {
+		return pandoc.ReadFrom(r)
+	}
+	text, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &pandoc.Pandoc{Blocks: []pandoc.Block{&pandoc.Para{Inlines: []pandoc.Inline{&pandoc.Str{Text: string(text)}}}}}, nil
+}
+
+func (fakeBackend) Store(ctx context.Context, doc *pandoc.Pandoc, w io.Writer, conf pandoc.Conf) error {
+	switch conf.Format {
+	case "slow":
+		<-ctx.Done()
+		return ctx.Err()
+	case "json":
+		return doc.WriteTo(w)
+	default:
+		_, err := io.WriteString(w, pandoc.Stringify(doc))
+		return err
+	}
+}
+
+var upper = pandoc.Transformer[*pandoc.Pandoc](func(s *pandoc.Str) ([]pandoc.Inline, error) {
+	return []pandoc.Inline{&pandoc.Str{Text: strings.ToUpper(s.Text)}}, pandoc.ReplaceSkip
+})
+
+// serves srv and returns a client of it
+func serve(t *testing.T, srv *Server) PandocClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterPandocServer(s, srv)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewPandocClient(conn)
+}
+
+func TestConvert(t *testing.T) {
+	c := serve(t, &Server{
+		Backend: fakeBackend{},
+		Inputs: []service.Format{
+			{MediaType: "text/plain", Conf: pandoc.Format("plain")},
+			{MediaType: "application/json", Conf: pandoc.Format("json")},
+		},
+		Outputs: []service.Format{
+			{MediaType: "text/plain", Conf: pandoc.Format("plain")},
+			{MediaType: "text/slow", Conf: pandoc.Format("slow")},
+		},
+		MaxInputSize:  1 << 20,
+		MaxOutputSize: 512 << 10,
+		Timeout:       50 * time.Millisecond,
+		ChunkSize:     1000,
+		Transformers:  map[string]func(*pandoc.Pandoc) (*pandoc.Pandoc, error){"upper": upper},
+	})
+	ctx := context.Background()
+	large := strings.Repeat("a", 300<<10)
+	for _, tt := range []struct {
+		name  string
+		opts  *ConvertOptions
+		input string
+		want  string
+		code  codes.Code
+	}{
+		{"plain", &ConvertOptions{From: "plain", To: "plain"}, "hello", "hello", codes.OK},
+		{"media types", &ConvertOptions{From: "text/plain", To: "text/plain", Transformers: []string{"upper"}}, "hello", "HELLO", codes.OK},
+		{"large", &ConvertOptions{From: "plain", To: "plain", Transformers: []string{"upper"}}, large, strings.ToUpper(large), codes.OK},
+		{"input format", &ConvertOptions{From: "markdown", To: "plain"}, "hello", "", codes.InvalidArgument},
+		{"input extensions", &ConvertOptions{From: "plain+smart", To: "plain"}, "hello", "hello", codes.OK},
+		{"output format", &ConvertOptions{From: "plain", To: "html"}, "hello", "", codes.InvalidArgument},
+		{"transformer", &ConvertOptions{From: "plain", To: "plain", Transformers: []string{"upper", "lower"}}, "hello", "", codes.InvalidArgument},
+		{"malformed", &ConvertOptions{From: "json", To: "plain"}, "{", "", codes.InvalidArgument},
+		{"input size", &ConvertOptions{From: "plain", To: "plain"}, large + large + large + large, "", codes.ResourceExhausted},
+		{"output size", &ConvertOptions{From: "plain", To: "plain"}, large + large, "", codes.ResourceExhausted},
+		{"timeout", &ConvertOptions{From: "plain", To: "slow"}, "hello", "", codes.DeadlineExceeded},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Convert(ctx, c, tt.opts, strings.NewReader(tt.input), &out)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("code %s, want %s: %v", code, tt.code, err)
+			}
+			if err == nil && out.String() != tt.want {
+				t.Errorf("unexpected output %.20q, want %.20q", out.String(), tt.want)
+			}
+		})
+	}
+
+	// options must come first, once
+	for _, reqs := range [][]*ConvertRequest{
+		{},
+		{{Request: &ConvertRequest_Data{Data: []byte("hello")}}},
+		{{Request: &ConvertRequest_Options{Options: &ConvertOptions{From: "plain", To: "plain"}}}, {Request: &ConvertRequest_Options{Options: &ConvertOptions{}}}},
+	} {
+		stream, err := c.Convert(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, req := range reqs {
+			if err := stream.Send(req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stream.CloseSend()
+		if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%d requests: %v", len(reqs), err)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	c := serve(t, &Server{
+		Timeout:   50 * time.Millisecond,
+		ChunkSize: 10,
+		Transformers: map[string]func(*pandoc.Pandoc) (*pandoc.Pandoc, error){
+			"upper": upper,
+			"slow": func(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+				time.Sleep(100 * time.Millisecond)
+				return doc, nil
+			},
+			"pandoc": func(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+				return nil, &pandoc.PandocError{Err: errors.New("exit status 1")}
+			},
+			"broken": func(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+				return nil, errors.New("broken")
+			},
+		},
+	})
+	ctx := context.Background()
+	input := `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Str","c":"hello"}]}]}`
+	var out bytes.Buffer
+	if err := Filter(ctx, c, &FilterOptions{Transformers: []string{"upper"}}, strings.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+	if doc, err := pandoc.ReadFrom(&out); err != nil {
+		t.Fatal(err)
+	} else if s := pandoc.Stringify(doc); s != "HELLO" {
+		t.Errorf("unexpected document %q", s)
+	}
+	if err := Filter(ctx, c, &FilterOptions{}, strings.NewReader(input[:20]), io.Discard); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("malformed document: %v", err)
+	}
+	for name, code := range map[string]codes.Code{
+		"lower":  codes.InvalidArgument,
+		"slow":   codes.DeadlineExceeded,
+		"pandoc": codes.FailedPrecondition,
+		"broken": codes.Internal,
+	} {
+		if err := Filter(ctx, c, &FilterOptions{Transformers: []string{name}}, strings.NewReader(input), io.Discard); status.Code(err) != code {
+			t.Errorf("transformer %s: %v, want %s", name, err, code)
+		}
+	}
+	// the call fails without waiting for a document never sent
+	r, w := io.Pipe()
+	defer w.Close()
+	if err := Filter(ctx, c, &FilterOptions{Transformers: []string{"lower"}}, r, io.Discard); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown transformer of a pending document: %v", err)
+	}
+}
@@ -1,22 +1,51 @@
 package pandoc
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// A configuration for running pandoc executable.
+// A configuration for running pandoc executable. Conf is the only
+// configuration type of the package, used by all Load and Store
+// functions. It is built with its With* methods, each returning
+// a modified copy and never changing the original Conf, so a base
+// configuration can be shared safely.
+//
+// Example:
+//
+//	base := pandoc.Format("markdown").WithExt("smart")
+//	html := base.WithOpt("standalone")
+//	gfm := base.WithoutExt("smart").WithDir("docs")
 type Conf struct {
 	Pandoc string   // Path to pandoc executable
 	Dir    string   // Working directory
 	Format string   // Format to load or store.
 	Ext    []string // List of format extensions, each must start with '+' or '-'
 	Opts   []string // Additional options
+	Runner Runner   // Runs pandoc, defaults to ExecRunner
+	Env    []string // Environment of pandoc in the form "key=value", if not nil
+
+	Timeout   time.Duration    // Maximum time of a conversion, no limit if zero
+	MaxOutput int64            // Maximum size of pandoc standard output, no limit if zero
+	Limits    Limits           // Resource limits of pandoc process
+	Log       func(LogMessage) // Receives messages of pandoc log, if not nil
+
+	// If true, Load and Store functions refuse to run a pandoc with
+	// pandoc-types API incompatible with Version.
+	CheckVersion bool
+
+	meta []metaField // Metadata fields of WithMetadata set in a metadata file
 }
 
 var DefaultFormat = Conf{
@@ -34,37 +63,109 @@ func (c Conf) WithPandoc(path string) Conf {
 	return c
 }
 
+// Returns a Conf running pandoc with the runner.
+func (c Conf) WithRunner(r Runner) Conf {
+	c.Runner = r
+	return c
+}
+
+// Returns a Conf refusing to run a pandoc with incompatible AST API
+// version. See Capabilities.
+func (c Conf) WithVersionCheck() Conf {
+	c.CheckVersion = true
+	return c
+}
+
+// Returns a Conf killing pandoc if a conversion takes longer than d.
+// The conversion fails with context.DeadlineExceeded.
+func (c Conf) WithTimeout(d time.Duration) Conf {
+	c.Timeout = d
+	return c
+}
+
+// Returns a Conf killing pandoc if it writes more than n bytes to the
+// standard output, that is a loaded document or a document stored not
+// to a file. The conversion fails with ErrOutputLimit.
+func (c Conf) WithMaxOutput(n int64) Conf {
+	c.MaxOutput = n
+	return c
+}
+
+// Returns a Conf running pandoc with resource limits.
+func (c Conf) WithLimits(l Limits) Conf {
+	c.Limits = l
+	return c
+}
+
+// Returns a Conf with a specified working directory.
 func (c Conf) WithDir(dir string) Conf {
 	c.Dir = dir
 	return c
 }
 
-func (c Conf) WithExt(ext string) Conf {
-	for i := range c.Ext {
-		if c.Ext[i] == "-"+ext {
-			c.Ext = append(append(c.Ext[:i], "+"+ext), c.Ext[i+1:]...)
-			return c
-		} else if c.Ext[i] == "+"+ext {
-			return c
-		}
+// Returns a Conf running pandoc with environment variables kv, each in
+// the form "key=value", replacing previous values of the keys. Unless
+// Env is already set, the environment of pandoc is no longer inherited,
+// so the result is reproducible; variables pandoc may need, such as
+// PATH for PDF engines, must be set explicitly.
+//
+// Example:
+//
+//	conf := pandoc.Format("pdf").WithEnv("HOME=/tmp", "PATH="+os.Getenv("PATH"))
+func (c Conf) WithEnv(kv ...string) Conf {
+	env := append(make([]string, 0, len(c.Env)+len(kv)), c.Env...)
+	for _, v := range kv {
+		key, _, _ := strings.Cut(v, "=")
+		env = slices.DeleteFunc(env, func(e string) bool {
+			return strings.HasPrefix(e, key+"=")
+		})
+		env = append(env, v)
 	}
-	c.Ext = append(c.Ext, "+"+ext)
+	c.Env = env
 	return c
 }
 
-func (c Conf) WithoutExt(ext string) Conf {
+// Returns a Conf running pandoc with the locale lang, e.g. "en_US.UTF-8".
+// See WithEnv.
+func (c Conf) WithLang(lang string) Conf {
+	return c.WithEnv("LANG=" + lang)
+}
+
+// Returns a Conf with a specified pandoc user data directory, holding
+// templates, filters and reference documents.
+func (c Conf) WithDataDir(dir string) Conf {
+	return c.setOpt("data-dir", dir)
+}
+
+// returns a copy of the list with room for one more element, so that
+// modifying it does not affect other Confs sharing the original list
+func copyList(l []string) []string {
+	return append(make([]string, 0, len(l)+1), l...)
+}
+
+// sets extension ext to state on ('+') or off ('-')
+func (c Conf) setExt(ext string, state byte) Conf {
+	c.Ext = copyList(c.Ext)
 	for i := range c.Ext {
-		if c.Ext[i] == "-"+ext {
-			return c
-		} else if c.Ext[i] == "+"+ext {
-			c.Ext = append(append(c.Ext[:i], "-"+ext), c.Ext[i+1:]...)
+		if len(c.Ext[i]) > 0 && c.Ext[i][1:] == ext {
+			c.Ext[i] = string(state) + ext
 			return c
 		}
 	}
-	c.Ext = append(c.Ext, "-"+ext)
+	c.Ext = append(c.Ext, string(state)+ext)
 	return c
 }
 
+// Returns a Conf with format extension ext enabled.
+func (c Conf) WithExt(ext string) Conf {
+	return c.setExt(ext, '+')
+}
+
+// Returns a Conf with format extension ext disabled.
+func (c Conf) WithoutExt(ext string) Conf {
+	return c.setExt(ext, '-')
+}
+
 // Add an option to the configuration. Accepts:
 //   - single-letter option, e.g. "s"
 //   - single-letter option with value, e.g. "s", "foo"
@@ -74,6 +175,7 @@ func (c Conf) WithOpt(opt string, val ...string) Conf {
 	if opt == "" {
 		return c
 	}
+	c.Opts = copyList(c.Opts)
 	if len(opt) == 1 {
 		c.Opts = append(c.Opts, "-"+opt)
 		if len(val) == 1 {
@@ -108,239 +210,929 @@ func (c *Conf) pandocExecutable() (string, error) {
 	}
 }
 
-func (c *Conf) loadCmd() (*exec.Cmd, error) {
-	pandoc, err := c.pandocExecutable()
-	if err != nil {
-		return nil, err
+// Time to wait for pandoc output to be closed after pandoc is killed on
+// context cancellation.
+const waitDelay = time.Second
+
+func (c *Conf) runner() Runner {
+	if c.Runner == nil {
+		return ExecRunner{}
 	}
-	return &exec.Cmd{
-		Path: pandoc,
-		Dir:  c.Dir,
-		Args: append([]string{
-			"pandoc",
-			"-tjson",
-			strings.Join(append([]string{"-f", c.Format}, c.Ext...), ""),
-		}, c.Opts...),
-	}, nil
+	return c.Runner
 }
 
-func (c *Conf) storeCmd() (*exec.Cmd, error) {
-	pandoc, err := c.pandocExecutable()
-	if err != nil {
+func (c *Conf) command(ctx context.Context, args ...string) (*Command, error) {
+	if c.CheckVersion {
+		caps, err := c.CapabilitiesContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := caps.compatible(); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.writeMetaFile(); err != nil {
 		return nil, err
 	}
-	return &exec.Cmd{
-		Path: pandoc,
-		Dir:  c.Dir,
-		Args: append([]string{
-			"pandoc",
-			"-fjson",
-			strings.Join(append([]string{"-t", c.Format}, c.Ext...), ""),
-		}, c.Opts...),
-	}, nil
+	cmd := c.newCommand(args...)
+	if err := c.logFile(cmd); err != nil {
+		return nil, err
+	}
+	return cmd, nil
 }
 
-func LoadFrom(r io.Reader, conf Conf) (*Pandoc, error) {
-	cmd, err := conf.loadCmd()
-	if err != nil {
-		return nil, err
+func (c *Conf) newCommand(args ...string) *Command {
+	args = append(append([]string(nil), args...), c.Opts...)
+	if len(c.meta) > 0 {
+		path, _ := c.metaFile()
+		args = append(args, "--metadata-file="+path)
 	}
-	ip, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, err
+	return &Command{
+		Pandoc:    c.Pandoc,
+		Dir:       c.Dir,
+		Args:      args,
+		Env:       c.Env,
+		ClearEnv:  c.Env != nil,
+		Limits:    c.Limits,
+		timeout:   c.Timeout,
+		maxOutput: c.MaxOutput,
+	}
+}
+
+func (c *Conf) loadArgs() []string {
+	return []string{"-tjson", strings.Join(append([]string{"-f", c.Format}, c.Ext...), "")}
+}
+
+func (c *Conf) storeArgs() []string {
+	return []string{"-fjson", strings.Join(append([]string{"-t", c.Format}, c.Ext...), "")}
+}
+
+func (c *Conf) loadCmd(ctx context.Context) (*Command, error) {
+	return c.command(ctx, c.loadArgs()...)
+}
+
+func (c *Conf) storeCmd(ctx context.Context) (*Command, error) {
+	if !c.pdfFile() {
+		return c.command(ctx, c.storeArgs()...)
 	}
-	op, err := cmd.StdoutPipe()
+	engine, err := c.PDFEngine()
 	if err != nil {
 		return nil, err
 	}
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
-		return nil, err
+	conf := *c
+	if engine != "" && conf.optValue("pdf-engine") == "" {
+		conf = conf.WithPDFEngine(engine)
 	}
-	var readErr = make(chan error, 1)
-	go func() {
-		_, err := io.Copy(ip, r)
-		_ = ip.Close()
-		readErr <- err
-	}()
-	p, err := ReadFrom(op)
+	if conf.Format == "pdf" {
+		conf.Format = engineWriter(engine)
+	}
+	cmd, err := conf.command(ctx, conf.storeArgs()...)
 	if err != nil {
-		_, _ = io.Copy(io.Discard, op)
-		_ = cmd.Wait()
 		return nil, err
 	}
-	if err = cmd.Wait(); err != nil {
-		return nil, err
+	cmd.pdf, cmd.pdfEngine = true, engine
+	return cmd, nil
+}
+
+// Returns a shell command converting a document from the format
+// described by c to the one described by to with pandoc alone, e.g.
+// to reproduce a conversion from the command line. The executable,
+// directory and environment are taken from c.
+//
+// Example:
+//
+//	fmt.Println(pandoc.Format("markdown").WithExt("smart").CommandLine(pandoc.Format("html")))
+//	// Output: pandoc -f markdown+smart -t html
+func (c Conf) CommandLine(to Conf) string {
+	cmd := c.newCommand("-f", c.Format+strings.Join(c.Ext, ""), "-t", to.Format+strings.Join(to.Ext, ""))
+	cmd.Args = append(cmd.Args, to.Opts...)
+	return cmd.String()
+}
+
+// Returns the command Load functions run to load a document from files,
+// or from the standard input if there are none, without running it. The
+// version check is not done.
+func (c Conf) LoadCommand(files ...string) *Command {
+	cmd := c.newCommand(c.loadArgs()...)
+	cmd.Args = append(cmd.Args, files...)
+	cmd.Input = len(files) == 0
+	return cmd
+}
+
+// Returns the command Store functions run to store a document to file
+// f, or to the standard output if f is empty, without running it. The
+// version check is not done.
+func (c Conf) StoreCommand(f string) *Command {
+	if f != "" {
+		c = c.WithOpt("o", f)
 	}
-	return p, nil
+	cmd := c.newCommand(c.storeArgs()...)
+	cmd.Input = true
+	return cmd
 }
 
-func LoadFile(f string, conf Conf) (*Pandoc, error) {
-	cmd, err := conf.loadCmd()
-	if err != nil {
-		return nil, err
+// A Runner starts pandoc for Load and Store functions. ExecRunner, the
+// default, runs the pandoc executable; other runners may run pandoc in
+// a container or on a remote host.
+type Runner interface {
+	// Starts pandoc as described by cmd. The process must be stopped
+	// once ctx is done.
+	Start(ctx context.Context, cmd *Command) (Process, error)
+}
+
+// A pandoc invocation.
+type Command struct {
+	Pandoc string   // Path to pandoc executable from Conf, empty for the default
+	Dir    string   // Working directory
+	Args   []string // Command line arguments, without the executable
+	Env    []string // Additional environment variables, in the form "key=value"
+	Limits Limits   // Resource limits, a runner unable to apply them must fail
+
+	ClearEnv bool // Whether Env is the whole environment instead of added to the inherited one
+
+	Input  bool      // Whether pandoc reads the standard input, set when started
+	Stderr io.Writer // Destination of the standard error, set when started
+
+	timeout   time.Duration
+	maxOutput int64
+	pdf       bool   // whether pandoc makes a PDF file
+	pdfEngine string // the PDF engine, empty if chosen by pandoc
+	logFile   string // the temporary file of pandoc log
+	log       func(LogMessage)
+}
+
+// Resource limits of a pandoc process. Zero values are no limits.
+// ExecRunner, on Unix, and SSHRunner set them as rlimits of the process.
+type Limits struct {
+	CPU    time.Duration // CPU time, rounded up to whole seconds for ulimit -t
+	Memory int64         // Virtual memory size, in bytes
+	Files  int           // Number of open files
+}
+
+func (l Limits) isZero() bool { return l == Limits{} }
+
+// returns shell commands setting the limits
+func (l Limits) ulimit() string {
+	var sb strings.Builder
+	if l.CPU > 0 {
+		// rounded up, as zero is no limit
+		sb.WriteString("ulimit -t " + strconv.FormatInt(int64((l.CPU+time.Second-1)/time.Second), 10) + " && ")
 	}
-	cmd.Args = append(cmd.Args, f)
-	op, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
+	if l.Memory > 0 {
+		sb.WriteString("ulimit -v " + strconv.FormatInt((l.Memory+1023)/1024, 10) + " && ")
 	}
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
-		return nil, err
+	if l.Files > 0 {
+		sb.WriteString("ulimit -n " + strconv.Itoa(l.Files) + " && ")
 	}
-	p, err := ReadFrom(op)
-	if err != nil {
-		_, _ = io.Copy(io.Discard, op)
-		_ = cmd.Wait()
-		return nil, err
+	return sb.String()
+}
+
+// Returned by Load and Store functions if pandoc output exceeds
+// Conf.MaxOutput.
+var ErrOutputLimit = errors.New("pandoc output limit exceeded")
+
+// Returns the command line, starting with the pandoc executable.
+func (c *Command) Argv() []string {
+	pandoc := c.Pandoc
+	if pandoc == "" {
+		pandoc = "pandoc"
 	}
-	if err = cmd.Wait(); err != nil {
-		return nil, err
+	return append([]string{pandoc}, c.Args...)
+}
+
+// Returns the command line as a shell command, e.g. for logs and bug
+// reports.
+func (c *Command) String() string {
+	var sb strings.Builder
+	if c.Dir != "" {
+		sb.WriteString("cd " + shellArg(c.Dir) + " && ")
 	}
-	return p, nil
+	if c.ClearEnv || len(c.Env) > 0 {
+		sb.WriteString("env ")
+		if c.ClearEnv {
+			sb.WriteString("-i ")
+		}
+		for _, env := range c.Env {
+			sb.WriteString(shellArg(env) + " ")
+		}
+	}
+	for i, arg := range c.Argv() {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(shellArg(arg))
+	}
+	return sb.String()
 }
 
-func LoadFiles(f []string, conf Conf) (*Pandoc, error) {
-	cmd, err := conf.loadCmd()
-	if err != nil {
-		return nil, err
+// quotes s for the shell unless it is safe as is
+func shellArg(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+		return s
 	}
-	cmd.Args = append(cmd.Args, f...)
-	op, err := cmd.StdoutPipe()
+	return shellQuote(s)
+}
+
+// A started pandoc process.
+type Process interface {
+	Stdin() io.WriteCloser // The standard input, nil unless Command.Input is set
+	Stdout() io.Reader     // The standard output, read to EOF before Wait
+	Wait() error           // Waits for pandoc to exit
+}
+
+// ExecRunner is the default Runner, running the pandoc executable.
+type ExecRunner struct{}
+
+func (ExecRunner) Start(ctx context.Context, c *Command) (Process, error) {
+	pandoc, err := (&Conf{Pandoc: c.Pandoc}).pandocExecutable()
 	if err != nil {
 		return nil, err
 	}
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
+	var cmd *exec.Cmd
+	if c.Limits.isZero() {
+		cmd = exec.CommandContext(ctx, pandoc)
+		cmd.Args = append([]string{filepath.Base(pandoc)}, c.Args...)
+	} else if cmd, err = limitedCommand(ctx, pandoc, c); err != nil {
 		return nil, err
 	}
-	p, err := ReadFrom(op)
-	if err != nil {
-		_, _ = io.Copy(io.Discard, op)
-		_ = cmd.Wait()
+	cmd.Dir = c.Dir
+	if c.ClearEnv {
+		cmd.Env = append([]string{}, c.Env...)
+	} else if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	return startExec(cmd, c)
+}
+
+// starts cmd with the standard streams of c
+func startExec(cmd *exec.Cmd, c *Command) (Process, error) {
+	var err error
+	cmd.Stderr = c.Stderr
+	cmd.WaitDelay = waitDelay
+	p := &execProcess{cmd: cmd}
+	if c.Input {
+		if p.stdin, err = cmd.StdinPipe(); err != nil {
+			return nil, err
+		}
+	}
+	if p.stdout, err = cmd.StdoutPipe(); err != nil {
 		return nil, err
 	}
-	if err = cmd.Wait(); err != nil {
+	if err := cmd.Start(); err != nil {
 		return nil, err
 	}
 	return p, nil
 }
 
-func (p *Pandoc) StoreTo(w io.Writer, conf Conf) error {
-	cmd, err := conf.storeCmd()
-	if err != nil {
-		return err
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (p *execProcess) Stdin() io.WriteCloser { return p.stdin }
+func (p *execProcess) Stdout() io.Reader     { return p.stdout }
+func (p *execProcess) Wait() error           { return p.cmd.Wait() }
+
+// Size of buffers between the AST reader or writer and pandoc.
+const pipeBufferSize = 64 << 10
+
+// PandocError is returned when pandoc fails. It carries pandoc's
+// diagnostics written to stderr.
+//
+// Example:
+//
+//	var perr *pandoc.PandocError
+//	if errors.As(err, &perr) {
+//	    log.Printf("%s failed with %d:\n%s", perr.Args, perr.ExitCode(), perr.Stderr)
+//	}
+type PandocError struct {
+	Args   []string     // Command line arguments, including pandoc itself
+	Stderr string       // Pandoc's standard error output
+	Err    error        // Underlying error, usually *exec.ExitError
+	Log    []LogMessage // Messages of pandoc log, see Conf.WithLog
+}
+
+func (e *PandocError) Error() string {
+	msg := strings.TrimSpace(e.Stderr)
+	if msg == "" {
+		return "pandoc: " + e.Err.Error()
+	}
+	return "pandoc: " + e.Err.Error() + ": " + msg
+}
+
+func (e *PandocError) Unwrap() error { return e.Err }
+
+// Returns pandoc's exit code, or -1 if pandoc has not exited normally.
+// The code is of an error with the method ExitCode() int, such as
+// *exec.ExitError.
+func (e *PandocError) ExitCode() int {
+	var exit interface{ ExitCode() int }
+	if errors.As(e.Err, &exit) {
+		return exit.ExitCode()
 	}
-	ip, err := cmd.StdinPipe()
+	return -1
+}
+
+// Maximum amount of pandoc's stderr kept for PandocError.
+const maxStderr = 64 << 10
+
+// keeps the beginning of the written data, discarding the rest
+type stderrBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	truncated bool
+}
+
+func (b *stderrBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n := maxStderr - len(b.buf); n < len(p) {
+		b.buf = append(b.buf, p[:n]...)
+		b.truncated = true
+	} else {
+		b.buf = append(b.buf, p...)
+	}
+	return len(p), nil
+}
+
+func (b *stderrBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return string(b.buf) + "\n[truncated]"
+	}
+	return string(b.buf)
+}
+
+// a running pandoc process; its input is fed concurrently while the
+// output is consumed
+type process struct {
+	ctx    context.Context // the context of the caller, with the timeout
+	kill   context.CancelFunc
+	stop   context.CancelFunc // stops the timeout
+	args   []string
+	proc   Process
+	stderr stderrBuffer
+	stdout io.Reader
+	stdin  *stdinWriter
+	fed    chan error
+	over   bool // the output has exceeded the limit
+
+	pdf       bool
+	pdfEngine string
+	logFile   string
+	log       func(LogMessage)
+}
+
+// the output of a process, killing it after the limit is exceeded
+type outputLimit struct {
+	r io.Reader
+	n int64
+	p *process
+}
+
+func (l *outputLimit) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	if l.n -= int64(n); l.n < 0 {
+		l.p.over = true
+		l.p.kill()
+		return 0, ErrOutputLimit
+	}
+	return n, err
+}
+
+// a pandoc input pipe remembering write errors, to tell failures of
+// pandoc from failures of the feeding function
+type stdinWriter struct {
+	w   io.WriteCloser
+	err error
+}
+
+func (s *stdinWriter) Write(b []byte) (int, error) {
+	n, err := s.w.Write(b)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// starts pandoc; if feed is not nil, it's called in a separate goroutine
+// to write pandoc input
+func start(ctx context.Context, r Runner, cmd *Command, feed func(io.Writer) error) (*process, error) {
+	stop := context.CancelFunc(func() {})
+	if cmd.timeout > 0 {
+		ctx, stop = context.WithTimeout(ctx, cmd.timeout)
+	}
+	pctx, kill := context.WithCancel(ctx)
+	p := &process{ctx: ctx, kill: kill, stop: stop, args: append([]string{"pandoc"}, cmd.Args...),
+		pdf: cmd.pdf, pdfEngine: cmd.pdfEngine, logFile: cmd.logFile, log: cmd.log}
+	cmd.Input = feed != nil
+	cmd.Stderr = &p.stderr
+	proc, err := r.Start(pctx, cmd)
 	if err != nil {
-		return nil
+		kill()
+		stop()
+		if cmd.logFile != "" {
+			os.Remove(cmd.logFile)
+		}
+		return nil, err
 	}
-	cmd.Stdout = w
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
-		return err
+	p.proc, p.stdout = proc, proc.Stdout()
+	if cmd.maxOutput > 0 {
+		p.stdout = &outputLimit{r: p.stdout, n: cmd.maxOutput, p: p}
 	}
-	if err := p.write(ip); err != nil {
-		_ = ip.Close()
-		_ = cmd.Wait()
-		return err
+	if feed != nil {
+		ip := proc.Stdin()
+		p.stdin = &stdinWriter{w: ip}
+		p.fed = make(chan error, 1)
+		go func() {
+			bw := bufio.NewWriterSize(p.stdin, pipeBufferSize)
+			err := feed(bw)
+			if err == nil {
+				err = bw.Flush()
+			}
+			if cerr := ip.Close(); err == nil && p.stdin.err == nil {
+				err = cerr
+			}
+			if err != nil && p.stdin.err == nil {
+				// the input has failed, pandoc must not produce
+				// an output from a truncated document
+				p.kill()
+			}
+			p.fed <- err
+		}()
 	}
-	if err = ip.Close(); err != nil {
-		_ = cmd.Wait()
+	return p, nil
+}
+
+// waits for pandoc to exit and passes its log messages; consumed is the
+// error of consuming its output.
+func (p *process) wait(consumed error) error {
+	err := p.exit(consumed)
+	if p.logFile == "" {
 		return err
 	}
-	if err = cmd.Wait(); err != nil {
-		return err
+	msgs, lerr := readLog(p.logFile)
+	for _, m := range msgs {
+		p.log(m)
+	}
+	var perr *PandocError
+	if errors.As(err, &perr) {
+		perr.Log = msgs
+	} else if err == nil {
+		err = lerr
 	}
-	return nil
+	return err
 }
 
-func (p *Pandoc) StoreFile(f string, conf Conf) error {
-	conf = conf.WithOpt("o", f)
-	cmd, err := conf.storeCmd()
-	if err != nil {
+// waits for pandoc to exit
+func (p *process) exit(consumed error) error {
+	_, _ = io.Copy(io.Discard, p.stdout)
+	var fed error
+	if p.fed != nil {
+		fed = <-p.fed
+	}
+	exited := p.proc.Wait()
+	p.kill()
+	killed := p.ctx.Err()
+	p.stop()
+	switch {
+	case killed != nil:
+		// pandoc was killed, other errors are consequences
+		return killed
+	case p.over:
+		return ErrOutputLimit
+	case fed != nil && p.stdin.err == nil:
+		// the input has failed and pandoc was killed
+		return fed
+	case exited != nil:
+		err := &PandocError{
+			Args:   p.args,
+			Stderr: p.stderr.String(),
+			Err:    exited,
+		}
+		if p.pdf {
+			return pdfError(p.pdfEngine, err)
+		}
 		return err
+	case fed != nil:
+		return fed
+	default:
+		return consumed
 	}
-	ip, err := cmd.StdinPipe()
+}
+
+// runs pandoc feeding its input with feed and consuming its output with
+// consume concurrently
+func run(ctx context.Context, r Runner, cmd *Command, feed func(io.Writer) error, consume func(io.Reader) error) error {
+	p, err := start(ctx, r, cmd, feed)
 	if err != nil {
-		return nil
-	}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
 		return err
 	}
-	if err := p.write(ip); err != nil {
-		_ = ip.Close()
-		_ = cmd.Wait()
+	return p.wait(consume(bufio.NewReaderSize(p.stdout, pipeBufferSize)))
+}
+
+func copyFrom(r io.Reader) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.Copy(w, r)
 		return err
 	}
-	if err = ip.Close(); err != nil {
-		_ = cmd.Wait()
+}
+
+func copyTo(w io.Writer) func(io.Reader) error {
+	return func(r io.Reader) error {
+		_, err := io.Copy(w, r)
 		return err
 	}
-	if err = cmd.Wait(); err != nil {
+}
+
+// runs pandoc consuming its output as AST
+func load(ctx context.Context, r Runner, cmd *Command, feed func(io.Writer) error) (*Pandoc, error) {
+	var doc *Pandoc
+	err := run(ctx, r, cmd, feed, func(r io.Reader) (err error) {
+		doc, err = ReadFrom(r)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return doc, nil
 }
 
-func StoreTo(w io.Writer, conf Conf, meta Meta, docs ...*Pandoc) error {
-	cmd, err := conf.storeCmd()
+// Loads a document from r in the format described by conf.
+func LoadFrom(r io.Reader, conf Conf) (*Pandoc, error) {
+	return LoadFromContext(context.Background(), r, conf)
+}
+
+// LoadFromContext is LoadFrom that kills pandoc if ctx is done before
+// the document is loaded.
+func LoadFromContext(ctx context.Context, r io.Reader, conf Conf) (*Pandoc, error) {
+	cmd, err := conf.loadCmd(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	ip, err := cmd.StdinPipe()
+	return load(ctx, conf.runner(), cmd, copyFrom(r))
+}
+
+// Loads a document from s in the format described by conf.
+//
+// Example:
+//
+//	doc, err := pandoc.LoadString("*Hello*, world", pandoc.Format("markdown"))
+func LoadString(s string, conf Conf) (*Pandoc, error) {
+	return LoadStringContext(context.Background(), s, conf)
+}
+
+// LoadStringContext is LoadString that kills pandoc if ctx is done
+// before the document is loaded.
+func LoadStringContext(ctx context.Context, s string, conf Conf) (*Pandoc, error) {
+	return LoadFromContext(ctx, strings.NewReader(s), conf)
+}
+
+// Loads a document from file f in the format described by conf.
+func LoadFile(f string, conf Conf) (*Pandoc, error) {
+	return LoadFilesContext(context.Background(), []string{f}, conf)
+}
+
+// LoadFileContext is LoadFile that kills pandoc if ctx is done before
+// the document is loaded.
+func LoadFileContext(ctx context.Context, f string, conf Conf) (*Pandoc, error) {
+	return LoadFilesContext(ctx, []string{f}, conf)
+}
+
+// Loads a document from file f in the format detected from its extension,
+// see DetectFormat, with conf otherwise.
+func LoadFileAuto(f string, conf Conf) (*Pandoc, error) {
+	return LoadFileAutoContext(context.Background(), f, conf)
+}
+
+// LoadFileAutoContext is LoadFileAuto that kills pandoc if ctx is done
+// before the document is loaded.
+func LoadFileAutoContext(ctx context.Context, f string, conf Conf) (*Pandoc, error) {
+	format, ok := DetectFormat(f)
+	if !ok {
+		return nil, fmt.Errorf("unknown format of %s", f)
+	}
+	conf.Format = format
+	return LoadFilesContext(ctx, []string{f}, conf)
+}
+
+// Loads a document concatenated from files f in the format described by conf.
+func LoadFiles(f []string, conf Conf) (*Pandoc, error) {
+	return LoadFilesContext(context.Background(), f, conf)
+}
+
+// LoadFilesContext is LoadFiles that kills pandoc if ctx is done before
+// the document is loaded.
+func LoadFilesContext(ctx context.Context, f []string, conf Conf) (*Pandoc, error) {
+	cmd, err := conf.loadCmd(ctx)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	cmd.Stdout = w
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
+	cmd.Args = append(cmd.Args, f...)
+	return load(ctx, conf.runner(), cmd, nil)
+}
+
+// Stores the document to w in the format described by conf.
+func (p *Pandoc) StoreTo(w io.Writer, conf Conf) error {
+	return p.StoreToContext(context.Background(), w, conf)
+}
+
+// StoreToContext is StoreTo that kills pandoc if ctx is done before
+// the document is stored.
+func (p *Pandoc) StoreToContext(ctx context.Context, w io.Writer, conf Conf) error {
+	cmd, err := conf.storeCmd(ctx)
+	if err != nil {
 		return err
 	}
-	if err := writeMany(ip, meta, docs...); err != nil {
-		_ = ip.Close()
-		_ = cmd.Wait()
-		return err
+	return run(ctx, conf.runner(), cmd, p.write, copyTo(w))
+}
+
+// Returns the document stored in the format described by conf.
+//
+// Example:
+//
+//	html, err := doc.StoreString(pandoc.Format("html"))
+func (p *Pandoc) StoreString(conf Conf) (string, error) {
+	return p.StoreStringContext(context.Background(), conf)
+}
+
+// StoreStringContext is StoreString that kills pandoc if ctx is done
+// before the document is stored.
+func (p *Pandoc) StoreStringContext(ctx context.Context, conf Conf) (string, error) {
+	var sb strings.Builder
+	if err := p.StoreToContext(ctx, &sb, conf); err != nil {
+		return "", err
 	}
-	if err = ip.Close(); err != nil {
-		_ = cmd.Wait()
+	return sb.String(), nil
+}
+
+// Stores the document to file f in the format described by conf.
+func (p *Pandoc) StoreFile(f string, conf Conf) error {
+	return p.StoreFileContext(context.Background(), f, conf)
+}
+
+// StoreFileContext is StoreFile that kills pandoc if ctx is done before
+// the document is stored.
+func (p *Pandoc) StoreFileContext(ctx context.Context, f string, conf Conf) error {
+	conf = conf.WithOpt("o", f)
+	cmd, err := conf.storeCmd(ctx)
+	if err != nil {
 		return err
 	}
-	if err = cmd.Wait(); err != nil {
+	return run(ctx, conf.runner(), cmd, p.write, copyTo(os.Stdout))
+}
+
+// Stores documents concatenated, with metadata meta, as WriteMany
+// combines them, to w in the format described by conf.
+func StoreTo(w io.Writer, conf Conf, meta Meta, docs ...*Pandoc) error {
+	return StoreToContext(context.Background(), w, conf, meta, docs...)
+}
+
+// StoreToContext is StoreTo that kills pandoc if ctx is done before
+// the documents are stored.
+func StoreToContext(ctx context.Context, w io.Writer, conf Conf, meta Meta, docs ...*Pandoc) error {
+	cmd, err := conf.storeCmd(ctx)
+	if err != nil {
 		return err
 	}
-	return nil
+	return run(ctx, conf.runner(), cmd, func(w io.Writer) error { return WriteMany(w, meta, docs...) }, copyTo(w))
 }
 
+// Stores documents concatenated, with metadata meta, as WriteMany
+// combines them, to file f in the format described by conf.
 func StoreFile(f string, conf Conf, meta Meta, docs ...*Pandoc) error {
+	return StoreFileContext(context.Background(), f, conf, meta, docs...)
+}
+
+// StoreFileContext is StoreFile that kills pandoc if ctx is done before
+// the documents are stored.
+func StoreFileContext(ctx context.Context, f string, conf Conf, meta Meta, docs ...*Pandoc) error {
 	conf = conf.WithOpt("o", f)
-	cmd, err := conf.storeCmd()
+	cmd, err := conf.storeCmd(ctx)
 	if err != nil {
 		return err
 	}
-	ip, err := cmd.StdinPipe()
+	return run(ctx, conf.runner(), cmd, func(w io.Writer) error { return WriteMany(w, meta, docs...) }, copyTo(os.Stdout))
+}
+
+// Runs the document through pandoc with the filters and other options
+// of conf, such as --lua-filter, --filter and --citeproc, and returns
+// the resulting document. Filters run in the order of the options, in
+// one pandoc run; the format of conf is ignored and filters see json as
+// the output format.
+func (p *Pandoc) RunFilters(conf Conf) (*Pandoc, error) {
+	return p.RunFiltersContext(context.Background(), conf)
+}
+
+// RunFiltersContext is RunFilters that kills pandoc if ctx is done before
+// the document is filtered.
+func (p *Pandoc) RunFiltersContext(ctx context.Context, conf Conf) (*Pandoc, error) {
+	cmd, err := conf.command(ctx, "-fjson", "-tjson")
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Start(); err != nil {
-		return err
+	return load(ctx, conf.runner(), cmd, p.write)
+}
+
+// A pandoc output stream returned by StoreReader.
+type storeReader struct {
+	p    *process
+	r    io.Reader
+	once sync.Once
+	err  error
+	eof  bool
+}
+
+func (s *storeReader) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	if err == io.EOF {
+		s.eof = true
 	}
-	if err := writeMany(ip, meta, docs...); err != nil {
-		_ = ip.Close()
-		_ = cmd.Wait()
-		return err
+	return n, err
+}
+
+func (s *storeReader) Close() error {
+	s.once.Do(func() {
+		if !s.eof {
+			// the output is abandoned, so is pandoc
+			s.p.kill()
+			s.err = errors.New("pandoc output is closed before EOF")
+			_ = s.p.wait(nil)
+		} else {
+			s.err = s.p.wait(nil)
+		}
+	})
+	return s.err
+}
+
+// StoreReader starts pandoc storing the document in the format described
+// by conf and returns a reader of pandoc output. The document is written
+// to pandoc as pandoc consumes it, so neither the AST nor the output is
+// held in memory entirely. The reader must be closed; Close waits for
+// pandoc to exit and returns its error, if any. Closing the reader before
+// EOF aborts pandoc.
+func (p *Pandoc) StoreReader(conf Conf) (io.ReadCloser, error) {
+	return p.StoreReaderContext(context.Background(), conf)
+}
+
+// StoreReaderContext is StoreReader that kills pandoc if ctx is done
+// before the reader is closed.
+func (p *Pandoc) StoreReaderContext(ctx context.Context, conf Conf) (io.ReadCloser, error) {
+	cmd, err := conf.storeCmd(ctx)
+	if err != nil {
+		return nil, err
+	}
+	proc, err := start(ctx, conf.runner(), cmd, p.write)
+	if err != nil {
+		return nil, err
+	}
+	return &storeReader{p: proc, r: proc.stdout}, nil
+}
+
+// Loader is a pandoc input stream returned by LoadWriter.
+type Loader struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	doc  *Pandoc
+	err  error
+}
+
+// LoadWriter starts pandoc loading a document in the format described
+// by conf and returns a writer of pandoc input. The AST is parsed as
+// pandoc produces it. Writes block while pandoc is busy. Close signals
+// the end of input and waits for the document.
+func LoadWriter(conf Conf) (*Loader, error) {
+	return LoadWriterContext(context.Background(), conf)
+}
+
+// LoadWriterContext is LoadWriter that kills pandoc if ctx is done
+// before the document is loaded.
+func LoadWriterContext(ctx context.Context, conf Conf) (*Loader, error) {
+	cmd, err := conf.loadCmd(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if err = ip.Close(); err != nil {
-		_ = cmd.Wait()
+	pr, pw := io.Pipe()
+	proc, err := start(ctx, conf.runner(), cmd, func(w io.Writer) error {
+		_, err := io.Copy(w, pr)
+		// unblocks writers if pandoc stops reading
+		_ = pr.CloseWithError(err)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	l := &Loader{pw: pw, done: make(chan struct{})}
+	go func() {
+		var err error
+		l.doc, err = ReadFrom(bufio.NewReaderSize(proc.stdout, pipeBufferSize))
+		if l.err = proc.wait(err); l.err != nil {
+			l.doc = nil
+			_ = pr.CloseWithError(l.err)
+		}
+		close(l.done)
+	}()
+	return l, nil
+}
+
+func (l *Loader) Write(b []byte) (int, error) {
+	return l.pw.Write(b)
+}
+
+// Close ends the input and waits for pandoc to exit.
+func (l *Loader) Close() error {
+	_ = l.pw.Close()
+	<-l.done
+	return l.err
+}
+
+// Document returns the loaded document. It must be called after Close.
+func (l *Loader) Document() (*Pandoc, error) {
+	<-l.done
+	return l.doc, l.err
+}
+
+// Appender streams a document to a running pandoc block by block, so
+// generators can emit blocks as they are produced instead of assembling
+// the whole document first. Note that most pandoc writers produce the
+// output only after the whole input is read.
+//
+// Example:
+//
+//	a, err := pandoc.NewAppender(os.Stdout, pandoc.Format("html"), meta)
+//	if err != nil {
+//	    return err
+//	}
+//	for _, section := range report {
+//	    if err := a.Append(section.Blocks()...); err != nil {
+//	        a.Close()
+//	        return err
+//	    }
+//	}
+//	return a.Close()
+type Appender struct {
+	bw   *BlockWriter
+	buf  *bufio.Writer
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error
+}
+
+// Starts pandoc storing a document with metadata meta in the format
+// described by conf to w. To store the document to a file, pass
+// the file with the "o" option of conf and io.Discard as w.
+func NewAppender(w io.Writer, conf Conf, meta Meta) (*Appender, error) {
+	return NewAppenderContext(context.Background(), w, conf, meta)
+}
+
+// NewAppenderContext is NewAppender that kills pandoc if ctx is done
+// before the appender is closed.
+func NewAppenderContext(ctx context.Context, w io.Writer, conf Conf, meta Meta) (*Appender, error) {
+	cmd, err := conf.storeCmd(ctx)
+	if err != nil {
+		return nil, err
 	}
-	if err = cmd.Wait(); err != nil {
+	pr, pw := io.Pipe()
+	proc, err := start(ctx, conf.runner(), cmd, func(w io.Writer) error {
+		_, err := io.Copy(w, pr)
+		_ = pr.CloseWithError(err)
 		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	a := &Appender{pw: pw, done: make(chan struct{})}
+	a.buf = bufio.NewWriterSize(pw, pipeBufferSize)
+	a.bw = NewBlockWriter(a.buf, meta)
+	go func() {
+		a.err = proc.wait(copyTo(w)(proc.stdout))
+		if a.err != nil {
+			_ = pr.CloseWithError(a.err)
+		} else {
+			_ = pr.CloseWithError(errors.New("pandoc has exited"))
+		}
+		close(a.done)
+	}()
+	return a, nil
+}
+
+// Appends top-level blocks to the document.
+func (a *Appender) Append(blocks ...Block) error {
+	return a.bw.WriteBlocks(blocks...)
+}
+
+// Close finishes the document and waits for pandoc to exit.
+func (a *Appender) Close() error {
+	err := a.bw.Close()
+	if err == nil {
+		err = a.buf.Flush()
+	}
+	_ = a.pw.Close()
+	<-a.done
+	if a.err != nil {
+		return a.err
 	}
-	return nil
+	return err
 }
@@ -0,0 +1,349 @@
+package pandoc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// makes a fake pandoc executable copying its input files or stdin to
+// stdout, failing if an input contains "FAIL" and stalling if it contains
+// "SLEEP"
+func fakePandoc(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	exe := filepath.Join(t.TempDir(), "pandoc")
+	script := `#!/bin/sh
+files=
+for a in "$@"; do
+	case "$a" in
+	-o) out=1 ;;
+	-*) ;;
+	*) if [ -n "$out" ]; then out=; exec >"$a"; else files="$files $a"; fi ;;
+	esac
+done
+input=$(cat $files; echo .)
+case "$input" in
+*FAIL*) echo "fake pandoc failed" >&2; exit 1 ;;
+*SLEEP*) exec sleep 10 ;;
+esac
+printf '%s' "${input%.}"
+`
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func bigDoc(n int) *Pandoc {
+	doc := &Pandoc{}
+	for i := 0; i < n; i++ {
+		doc.Blocks = append(doc.Blocks, &Para{[]Inline{&Str{strings.Repeat("x", 100)}}})
+	}
+	return doc
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("read failed") }
+
+func TestRunPipes(t *testing.T) {
+	var (
+		conf = Format("json").WithPandoc(fakePandoc(t))
+		// larger than any OS pipe buffer
+		doc  = bigDoc(5000)
+		want = Sprint(doc)
+	)
+	var b bytes.Buffer
+	if err := doc.StoreTo(&b, conf); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want {
+		t.Fatalf("StoreTo produced unexpected output")
+	}
+	got, err := LoadFrom(&b, conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(got) != want {
+		t.Fatalf("LoadFrom produced unexpected document")
+	}
+	r, err := doc.StoreReader(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := LoadWriter(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(l, r); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := l.Document(); err != nil || Sprint(got) != want {
+		t.Fatalf("LoadWriter produced unexpected document (%v)", err)
+	}
+	file := filepath.Join(t.TempDir(), "out.json")
+	if err := doc.StoreFile(file, conf); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := LoadFile(file, conf); err != nil || Sprint(got) != want {
+		t.Fatalf("LoadFile produced unexpected document (%v)", err)
+	}
+	str, err := doc.StoreString(conf)
+	if err != nil || str != want {
+		t.Fatalf("StoreString produced unexpected output (%v)", err)
+	}
+	if got, err := LoadString(str, conf); err != nil || Sprint(got) != want {
+		t.Fatalf("LoadString produced unexpected document (%v)", err)
+	}
+	if _, err := LoadString("FAIL", conf); err == nil {
+		t.Fatal("LoadString: no error")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	conf := Format("json").WithPandoc(fakePandoc(t))
+	if _, err := LoadFrom(failingReader{}, conf); err == nil || err.Error() != "read failed" {
+		t.Errorf("unexpected error %v", err)
+	}
+	doc := bigDoc(5000)
+	doc.Blocks = append(doc.Blocks, &Para{[]Inline{&Str{"FAIL"}}})
+	var perr *PandocError
+	if err := doc.StoreTo(io.Discard, conf); !errors.As(err, &perr) || perr.ExitCode() != 1 ||
+		perr.Stderr != "fake pandoc failed\n" || perr.Args[0] != "pandoc" {
+		t.Errorf("unexpected error %v", err)
+	}
+	r, err := bigDoc(5000).StoreReader(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(make([]byte, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err == nil {
+		t.Errorf("closing before EOF must fail")
+	}
+}
+
+func TestAppender(t *testing.T) {
+	var (
+		conf = Format("json").WithPandoc(fakePandoc(t))
+		doc  = bigDoc(5000)
+		b    bytes.Buffer
+	)
+	a, err := NewAppender(&b, conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, blk := range doc.Blocks {
+		if err := a.Append(blk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != Sprint(doc) {
+		t.Errorf("unexpected output")
+	}
+	if a, err = NewAppender(io.Discard, conf, nil); err != nil {
+		t.Fatal(err)
+	}
+	_ = a.Append(&Para{[]Inline{&Str{"FAIL"}}})
+	if err := a.Close(); err == nil {
+		t.Errorf("pandoc failure is not reported")
+	}
+}
+
+func TestRunContext(t *testing.T) {
+	conf := Format("json").WithPandoc(fakePandoc(t))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	doc := &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"SLEEP"}}}}}
+	start := time.Now()
+	if err := doc.StoreToContext(ctx, io.Discard, conf); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := LoadFromContext(ctx, strings.NewReader(Sprint(doc)), conf); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("pandoc is not killed in time (%s)", d)
+	}
+}
+
+func TestConf(t *testing.T) {
+	base := Format("markdown").WithExt("smart").WithoutExt("raw_html").WithOpt("standalone")
+	a := base.WithoutExt("smart").WithOpt("toc")
+	b := base.WithExt("raw_html").WithOpt("number-sections")
+	for _, tt := range []struct {
+		conf      Conf
+		ext, opts string
+	}{
+		{base, "+smart -raw_html", "--standalone"},
+		{a, "-smart -raw_html", "--standalone --toc"},
+		{b, "+smart +raw_html", "--standalone --number-sections"},
+	} {
+		if got := strings.Join(tt.conf.Ext, " "); got != tt.ext {
+			t.Errorf("unexpected extensions %q, want %q", got, tt.ext)
+		}
+		if got := strings.Join(tt.conf.Opts, " "); got != tt.opts {
+			t.Errorf("unexpected options %q, want %q", got, tt.opts)
+		}
+	}
+}
+
+// runs pandoc in a container, recording the commands
+type dockerRunner struct {
+	exe  string
+	args [][]string
+}
+
+func (r *dockerRunner) Start(ctx context.Context, cmd *Command) (Process, error) {
+	r.args = append(r.args, cmd.Args)
+	c := *cmd
+	c.Pandoc = r.exe
+	return ExecRunner{}.Start(ctx, &c)
+}
+
+func TestRunner(t *testing.T) {
+	r := &dockerRunner{exe: fakePandoc(t)}
+	conf := Format("json").WithRunner(r)
+	doc := &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"hello"}}}}}
+	var out bytes.Buffer
+	if err := doc.StoreTo(&out, conf); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := LoadFrom(&out, conf); err != nil {
+		t.Fatal(err)
+	} else if s := Stringify(res); s != "hello" {
+		t.Errorf("unexpected document %q", s)
+	}
+	if len(r.args) != 2 || r.args[0][0] != "-fjson" || r.args[1][0] != "-tjson" {
+		t.Errorf("unexpected commands %q", r.args)
+	}
+	doc = &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"FAIL"}}}}}
+	var perr *PandocError
+	if err := doc.StoreTo(io.Discard, conf); !errors.As(err, &perr) || !strings.Contains(perr.Stderr, "fake pandoc failed") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	conf := Format("json").WithPandoc(fakePandoc(t))
+	doc := &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{strings.Repeat("x", 1000)}}}}}
+	if err := doc.StoreTo(io.Discard, conf.WithMaxOutput(100)); err != ErrOutputLimit {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := LoadFrom(strings.NewReader(Sprint(doc)), conf.WithMaxOutput(100)); err != ErrOutputLimit {
+		t.Errorf("unexpected error %v", err)
+	}
+	if err := doc.StoreTo(io.Discard, conf.WithMaxOutput(10000)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	start := time.Now()
+	sleep := &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"SLEEP"}}}}}
+	if err := sleep.StoreTo(io.Discard, conf.WithTimeout(100*time.Millisecond)); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("pandoc is not killed in time (%s)", d)
+	}
+	if err := doc.StoreTo(io.Discard, conf.WithTimeout(time.Minute)); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// a pandoc reporting its limits
+	exe := filepath.Join(t.TempDir(), "pandoc")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\necho $(ulimit -t) $(ulimit -v) $(ulimit -n) \"$@\" >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var perr *PandocError
+	err := doc.StoreTo(io.Discard, conf.WithPandoc(exe).WithLimits(Limits{CPU: 1500 * time.Millisecond, Memory: 1 << 30, Files: 64}))
+	if !errors.As(err, &perr) || strings.TrimSpace(perr.Stderr) != "2 1048576 64 -fjson -tjson" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRunEnv(t *testing.T) {
+	// a pandoc reporting its environment
+	exe := filepath.Join(t.TempDir(), "pandoc")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\necho \"$LANG|$HOME|$GOPANDOC_TEST\" \"$@\" >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOPANDOC_TEST", "inherited")
+	t.Setenv("HOME", "/home/test")
+	for _, tt := range []struct {
+		conf Conf
+		want string
+	}{
+		{Format("html"), "|/home/test|inherited -fjson -thtml"},
+		{Format("html").WithEnv(), "|| -fjson -thtml"},
+		{Format("html").WithEnv("HOME=/tmp").WithLang("C").WithEnv("HOME=/srv"), "C|/srv| -fjson -thtml"},
+		{Format("html").WithDataDir("data"), "|/home/test|inherited -fjson -thtml --data-dir=data"},
+	} {
+		var perr *PandocError
+		err := (&Pandoc{}).StoreTo(io.Discard, tt.conf.WithPandoc(exe))
+		if !errors.As(err, &perr) {
+			t.Errorf("unexpected error %v", err)
+		} else if got := strings.TrimSpace(perr.Stderr); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+	base := Format("html").WithEnv("A=1")
+	if a, b := base.WithEnv("B=2"), base.WithEnv("C=3"); len(a.Env) != 2 || a.Env[1] != "B=2" || b.Env[1] != "C=3" {
+		t.Errorf("environment is shared: %q, %q", a.Env, b.Env)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	conf := Format("markdown").WithExt("smart").WithOpt("standalone").WithDir("docs").WithEnv("LANG=C").WithVersionCheck()
+	for _, tt := range []struct {
+		cmd   *Command
+		argv  string
+		input bool
+	}{
+		{conf.LoadCommand(), "pandoc -tjson -fmarkdown+smart --standalone", true},
+		{conf.LoadCommand("a.md", "b.md"), "pandoc -tjson -fmarkdown+smart --standalone a.md b.md", false},
+		{conf.StoreCommand(""), "pandoc -fjson -tmarkdown+smart --standalone", true},
+		{conf.WithPandoc("/opt/pandoc").StoreCommand("out.md"), "/opt/pandoc -fjson -tmarkdown+smart --standalone -o out.md", true},
+	} {
+		if got := strings.Join(tt.cmd.Argv(), " "); got != tt.argv {
+			t.Errorf("got %q, want %q", got, tt.argv)
+		}
+		if tt.cmd.Input != tt.input || tt.cmd.Dir != "docs" || !tt.cmd.ClearEnv || len(tt.cmd.Env) != 1 {
+			t.Errorf("%s: unexpected command %+v", tt.argv, tt.cmd)
+		}
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	from := Format("markdown").WithExt("smart").WithoutExt("raw_html")
+	to := Format("html").WithOpt("standalone").WithOpt("metadata", "title", "My doc")
+	if got, want := from.CommandLine(to), `pandoc -f markdown+smart-raw_html -t html --standalone '--metadata=title:My doc'`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	from = from.WithDir("my docs").WithEnv("LANG=C").WithPandoc("/opt/pandoc")
+	if got, want := from.CommandLine(Format("gfm")), `cd 'my docs' && env -i LANG=C /opt/pandoc -f markdown+smart-raw_html -t gfm`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if got, want := Format("rst").LoadCommand("it's.rst").String(), `pandoc -tjson -frst 'it'\''s.rst'`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
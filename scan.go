@@ -1,6 +1,8 @@
 package pandoc
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -8,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"unicode/utf8"
+	"unsafe"
 )
 
 // Simple streaming JSON parser suitable for parsing pandoc JSON AST.
@@ -76,6 +79,79 @@ type scanner struct {
 	str    int             // start of the current string/atom/number. -1 if there is no any.
 	num    int64           // parsed number
 	intnum bool            // true if the number is an integer
+	depth  int             // nesting depth of arrays and objects
+	line   int             // number of lines before the current buffer
+	lstart int             // offset of the line start before the current buffer
+	opts   ReadOptions     // reading options
+	borrow bool            // strings share memory with buf
+}
+
+// A SyntaxError describes malformed pandoc JSON input.
+type SyntaxError struct {
+	Msg     string // description of the error
+	Offset  int    // byte offset of the error in the input
+	Line    int    // line of the error, starting at 1
+	Column  int    // column of the error in bytes, starting at 1
+	Context string // input around the error
+	Err     error  // underlying reader error, if any
+}
+
+func (e *SyntaxError) Error() string {
+	msg := fmt.Sprintf("%s at line %d, column %d (offset %d)", e.Msg, e.Line, e.Column, e.Offset)
+	if e.Context != "" {
+		msg += fmt.Sprintf(" near %q", e.Context)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// number of bytes of input around an error included in SyntaxError
+const errorContext = 16
+
+// returns a syntax error at offset off. If off is not in the buffer
+// anymore, the current offset is used.
+func (p *scanner) errorAt(off int, f string, a ...any) *SyntaxError {
+	if off < p.off || off > p.off+len(p.buf) {
+		off = p.current()
+	}
+	i := off - p.off
+	b := p.buf[:i]
+	e := &SyntaxError{
+		Msg:     fmt.Sprintf(f, a...),
+		Offset:  off,
+		Line:    p.line + 1 + bytes.Count(b, []byte{'\n'}),
+		Context: string(p.buf[max(0, i-errorContext):min(len(p.buf), i+errorContext)]),
+	}
+	if n := bytes.LastIndexByte(b, '\n'); n >= 0 {
+		e.Column = i - n
+	} else {
+		e.Column = off - p.lstart + 1
+	}
+	if p.err != nil && p.err != io.EOF {
+		if _, ok := p.err.(*SyntaxError); !ok {
+			e.Err = p.err
+		}
+	}
+	return e
+}
+
+// returns a syntax error at the current offset
+func (p *scanner) errorf(f string, a ...any) *SyntaxError {
+	return p.errorAt(p.current(), f, a...)
+}
+
+// accounts lines in the first n bytes of the buffer before they are discarded
+func (p *scanner) discard(n int) {
+	if i := bytes.LastIndexByte(p.buf[:n], '\n'); i >= 0 {
+		p.line += bytes.Count(p.buf[:i+1], []byte{'\n'})
+		p.lstart = p.off + i + 1
+	}
 }
 
 func (p *scanner) stringInBuffer() bool {
@@ -84,6 +160,9 @@ func (p *scanner) stringInBuffer() bool {
 
 func (p *scanner) string() string {
 	if p.str >= 0 {
+		if p.borrow && p.pos-1 > p.str {
+			return unsafe.String(&p.buf[p.str], p.pos-1-p.str)
+		}
 		return string(p.buf[p.str : p.pos-1])
 	} else {
 		return p.sb.String()
@@ -92,18 +171,16 @@ func (p *scanner) string() string {
 
 func (p *scanner) expectString(s string) error {
 	if t := p.peek(); t != tokStr {
-		if p.err != nil {
-			return fmt.Errorf("expected %s, got %s at %d (%w)", s, t.String(), p.off+p.pos, p.err)
-		} else {
-			return fmt.Errorf("expected %s, got %s at %d", s, t.String(), p.off+p.pos)
-		}
+		return p.errorf("expected %s, got %s", s, t.String())
 	} else {
-		o := p.off + p.pos
-		p.next()
+		o := p.current()
+		if p.next() == tokErr {
+			return p.err
+		}
 		if p.sb.Len() != 0 && p.sb.String() != s {
-			return fmt.Errorf("expected string %s, got %s at %d", s, p.sb.String(), o)
+			return p.errorAt(o, "expected string %s, got %s", s, p.sb.String())
 		} else if string(p.buf[p.str:p.pos-1]) != s {
-			return fmt.Errorf("expected string %s, got %s at %d", s, string(p.buf[p.str:p.pos-1]), o)
+			return p.errorAt(o, "expected string %s, got %s", s, string(p.buf[p.str:p.pos-1]))
 		}
 	}
 	return nil
@@ -111,18 +188,130 @@ func (p *scanner) expectString(s string) error {
 
 func (p *scanner) expect(tok token) error {
 	if t := p.peek(); t != tok {
-		if t == tokErr {
-			p.err = fmt.Errorf("expected %s, got %s at %d (%w) \"%s\"", tok.String(), t.String(), p.off+p.pos, p.err, p.buf[p.pos:])
-		} else {
-			p.err = fmt.Errorf("expected %s, got %s at %d \"%s\"", tok.String(), t.String(), p.off+p.pos, p.buf[p.pos:])
-			panic(p.err.Error())
-		}
+		err := p.errorf("expected %s, got %s", tok.String(), t.String())
+		p.err = err
+		return err
+	}
+	if p.next() == tokErr {
 		return p.err
 	}
-	p.next()
 	return nil
 }
 
+// skips the rest of a malformed value at nesting depth, up to the next
+// comma or closing bracket at that depth. Returns false if the input
+// can't be skipped.
+func (p *scanner) skip(depth int) bool {
+	for p.depth >= depth {
+		if p.depth == depth {
+			switch p.peek() {
+			case tokComma, tokRBrack, tokRBrace:
+				if _, ok := p.err.(*SyntaxError); ok {
+					p.err = nil
+				}
+				return true
+			}
+		}
+		if t := p.next(); t == tokErr || t == tokEOF {
+			return false
+		}
+	}
+	return false
+}
+
+// reads a value and appends it to b as compact JSON
+func (p *scanner) raw(b []byte) ([]byte, error) {
+	depth := p.depth
+	for {
+		switch t := p.next(); t {
+		case tokErr:
+			return nil, p.err
+		case tokEOF:
+			return nil, p.errorf("unexpected EOF")
+		case tokStr:
+			b = appendQuote(b, p.string())
+		case tokNumber:
+			if p.intnum {
+				b = strconv.AppendInt(b, p.num, 10)
+			} else {
+				b = appendFloat(b, p.float())
+			}
+		default:
+			if p.depth < depth || p.depth == depth && (t == tokComma || t == tokColon) {
+				return nil, p.errorf("unexpected %s", t.String())
+			}
+			b = append(b, t.String()...)
+		}
+		if p.depth == depth {
+			return b, nil
+		}
+	}
+}
+
+// skips a value without parsing it, only tracking string literals and
+// nesting of arrays and objects
+func (p *scanner) skipValue() error {
+	_, err := p.rawValue(false)
+	return err
+}
+
+// skips a value like skipValue. If keep is set, returns the input of an
+// array or object value as is.
+func (p *scanner) rawValue(keep bool) ([]byte, error) {
+	switch t := p.peek(); t {
+	case tokLBrack, tokLBrace:
+	case tokEOF:
+		return nil, p.errorf("unexpected EOF")
+	default:
+		if p.next() == tokErr {
+			return nil, p.err
+		}
+		return nil, nil
+	}
+	p.str = -1
+	var (
+		raw        []byte
+		depth      int
+		instr, esc bool
+		start      = p.pos
+	)
+	for {
+		if p.pos == len(p.buf) {
+			if keep {
+				raw = append(raw, p.buf[start:]...)
+			}
+			if !p.ensure(1) {
+				break
+			}
+			start = p.pos
+		}
+		c := p.buf[p.pos]
+		p.pos++
+		switch {
+		case esc:
+			esc = false
+		case instr:
+			if c == '\\' {
+				esc = true
+			} else if c == '"' {
+				instr = false
+			}
+		case c == '"':
+			instr = true
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			if depth--; depth == 0 {
+				if keep {
+					raw = append(raw, p.buf[start:p.pos]...)
+				}
+				return raw, nil
+			}
+		}
+	}
+	return nil, p.errorf("unexpected EOF")
+}
+
 func (p *scanner) current() int {
 	return p.off + p.pos
 }
@@ -161,18 +350,25 @@ func (p *scanner) ensure(size int) bool {
 	var bs = len(p.buf)
 	if bs-p.pos >= size {
 		return true
+	} else if p.r == nil {
+		// the whole input is in the buffer
+		p.err = io.EOF
+		return false
 	} else if p.str > 0 {
+		p.discard(p.str)
 		copy(p.buf, p.buf[p.str:])
 		bs -= p.str
 		p.pos -= p.str
 		p.off += p.str
 		p.str = 0
 	} else if p.str == 0 {
+		p.discard(p.pos)
 		p.sb.Write(p.buf[:p.pos])
 		p.off += p.pos
 		bs -= p.pos
 		p.pos = 0
 	} else if p.str < 0 {
+		p.discard(p.pos)
 		p.off += p.pos
 		bs -= p.pos
 		p.pos = 0
@@ -195,7 +391,29 @@ func (p *scanner) init(r io.Reader) {
 	} else {
 		buf = p.buf[:0]
 	}
-	*p = scanner{r: r, buf: buf}
+	*p = scanner{r: r, buf: buf, opts: p.opts}
+}
+
+// initializes the scanner to scan b in place
+func (p *scanner) initBytes(b []byte) {
+	*p = scanner{buf: b[:len(b):len(b)], opts: p.opts, borrow: p.opts.Borrow}
+}
+
+// in the lenient mode, reports err and tries to skip the rest of the
+// malformed value at nesting depth; returns false if the value can't be
+// skipped and the error must be returned
+func (p *scanner) recover(depth int, err error) bool {
+	if !p.opts.Lenient {
+		return false
+	}
+	var se *SyntaxError
+	if !errors.As(err, &se) || !p.skip(depth) {
+		return false
+	}
+	if p.opts.Warn != nil {
+		p.opts.Warn(err)
+	}
+	return true
 }
 
 func (p *scanner) skipws() {
@@ -214,7 +432,26 @@ func (p *scanner) peek() token {
 	if !p.ensure(1) {
 		return tokEOF
 	}
-	switch p.buf[p.pos] {
+	return tokenOf(p.buf[p.pos])
+}
+
+// returns the token after the next one, if the next one is a single
+// character token
+func (p *scanner) peek2() token {
+	p.skipws()
+	for i := 1; p.ensure(i + 1); i++ {
+		switch c := p.buf[p.pos+i]; c {
+		case ' ', '\t', '\n', '\r':
+		default:
+			return tokenOf(c)
+		}
+	}
+	return tokEOF
+}
+
+// returns the token starting with character c
+func tokenOf(c byte) token {
+	switch c {
 	case ',':
 		return tokComma
 	case ':':
@@ -250,15 +487,19 @@ scan:
 	switch c := p.buf[p.pos]; c {
 	case '[':
 		p.pos++
+		p.depth++
 		return tokLBrack
 	case ']':
 		p.pos++
+		p.depth--
 		return tokRBrack
 	case '{':
 		p.pos++
+		p.depth++
 		return tokLBrace
 	case '}':
 		p.pos++
+		p.depth--
 		return tokRBrace
 	case ',':
 		p.pos++
@@ -272,7 +513,7 @@ scan:
 			p.pos += 4
 			return tokNull
 		} else {
-			p.err = fmt.Errorf("unexpected character %c at %d", c, p.off+p.pos)
+			p.err = p.errorf("unexpected character %q", c)
 			return tokErr
 		}
 	case 't':
@@ -281,7 +522,7 @@ scan:
 			p.pos += 4
 			return tokTrue
 		} else {
-			p.err = fmt.Errorf("unexpected character %c at %d", c, p.off+p.pos)
+			p.err = p.errorf("unexpected character %q", c)
 			return tokErr
 		}
 	case 'f':
@@ -290,7 +531,7 @@ scan:
 			p.pos += 5
 			return tokFalse
 		} else {
-			p.err = fmt.Errorf("unexpected character %c at %d", c, p.off+p.pos)
+			p.err = p.errorf("unexpected character %q", c)
 			return tokErr
 		}
 	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
@@ -302,7 +543,7 @@ scan:
 		p.pos++
 		goto scan
 	default:
-		p.err = fmt.Errorf("unexpected character %c at %d", c, p.off+p.pos)
+		p.err = p.errorf("unexpected character %q", c)
 		return tokErr
 	}
 }
@@ -312,18 +553,18 @@ func (p *scanner) parseNum(c byte) token {
 		sign int
 		num  uint64
 		flt  bool
-		off  = p.off
+		off  = p.current()
 	)
 	p.sb.Reset()
 	p.str = p.pos
 	if c == '-' {
 		p.pos++
 		if !p.ensure(1) {
-			p.err = fmt.Errorf("unexpected EOF at %d", off)
+			p.err = p.errorAt(off, "unexpected EOF")
 			return tokErr
 		}
 		if c = p.buf[p.pos]; c < '0' || c > '9' {
-			p.err = fmt.Errorf("invalid number literal at %d", off)
+			p.err = p.errorAt(off, "invalid number literal")
 			return tokErr
 		}
 		sign = -1
@@ -337,7 +578,7 @@ func (p *scanner) parseNum(c byte) token {
 		}
 		switch {
 		case c >= '0' && c <= '9':
-			p.err = fmt.Errorf("invalid number literal at %d", off)
+			p.err = p.errorAt(off, "invalid number literal")
 			return tokErr
 		case c == '.':
 			flt = true
@@ -392,7 +633,7 @@ func (p *scanner) parseNum(c byte) token {
 	}
 frac:
 	if !p.ensure(1) || p.buf[p.pos] < '0' || p.buf[p.pos] > '9' {
-		p.err = fmt.Errorf("invalid number literal at %d", off)
+		p.err = p.errorAt(off, "invalid number literal")
 		return tokErr
 	}
 	c = p.buf[p.pos]
@@ -412,20 +653,20 @@ frac:
 	}
 exp:
 	if !p.ensure(1) {
-		p.err = fmt.Errorf("invalid number literal at %d", off)
+		p.err = p.errorAt(off, "invalid number literal")
 		return tokErr
 	}
 	c = p.buf[p.pos]
 	if c == '+' || c == '-' {
 		p.pos++
 		if !p.ensure(1) {
-			p.err = fmt.Errorf("invalid number literal at %d", off)
+			p.err = p.errorAt(off, "invalid number literal")
 			return tokErr
 		}
 		c = p.buf[p.pos]
 	}
 	if c < '0' || c > '9' {
-		p.err = fmt.Errorf("invalid number literal at %d", off)
+		p.err = p.errorAt(off, "invalid number literal")
 		return tokErr
 	}
 	for {
@@ -474,7 +715,7 @@ done:
 			float, err = strconv.ParseFloat(string(p.buf[p.str:p.pos]), 64)
 		}
 		if err != nil {
-			p.err = fmt.Errorf("invalid number literal at %d (%w)", off, err)
+			p.err = p.errorAt(off, "invalid number literal (%s)", err)
 			return tokErr
 		} else {
 			p.num = int64(math.Float64bits(float))
@@ -502,7 +743,7 @@ scan:
 		} else if c >= utf8.RuneSelf {
 			b := bits.LeadingZeros8(^c)
 			if b > 4 {
-				p.err = fmt.Errorf("invalid UTF-8 encoding at %d", p.off+p.pos)
+				p.err = p.errorf("invalid UTF-8 encoding")
 				return tokErr
 			} else {
 
@@ -511,12 +752,12 @@ scan:
 					p.str = p.pos
 				}
 				if !p.ensure(b) {
-					p.err = fmt.Errorf("unexpected EOF at %d", p.off+p.pos)
+					p.err = p.errorf("unexpected EOF")
 					return tokErr
 				}
 			}
 			if _, n := utf8.DecodeRune(p.buf[p.pos:]); n != b {
-				p.err = fmt.Errorf("invalid UTF-8 encoding at %d", p.off+p.pos)
+				p.err = p.errorf("invalid UTF-8 encoding")
 				return tokErr
 			} else {
 				p.pos += n
@@ -525,11 +766,11 @@ scan:
 			p.pos++
 		}
 	}
-	p.err = fmt.Errorf("unexpected EOF at %d", p.off+p.pos)
+	p.err = p.errorf("unexpected EOF")
 	return tokErr
 escape:
 	if !p.ensure(1) {
-		p.err = fmt.Errorf("unexpected EOF at %d", p.off+p.pos)
+		p.err = p.errorf("unexpected EOF")
 		return tokErr
 	}
 	switch p.buf[p.pos] {
@@ -553,7 +794,7 @@ escape:
 		// pandoc never produces unicode escapes
 		fallthrough
 	default:
-		p.err = fmt.Errorf("invalid escape sequence at %d", p.off+p.pos)
+		p.err = p.errorf("invalid escape sequence")
 		return tokErr
 	}
 	p.pos++
@@ -0,0 +1,56 @@
+// Command pandoc-script runs a Starlark filter script as a pandoc filter,
+// see package script.
+//
+// Usage:
+//
+//	pandoc-script script.star [format] < in.json > out.json
+//
+// A script starting with
+//
+//	#!/usr/bin/env pandoc-script
+//
+// is a filter of its own:
+//
+//	pandoc --filter ./todo.star -o doc.html doc.md
+//
+// The exit status is the one of filtercmd.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/growler/go-pandoc"
+	"github.com/growler/go-pandoc/filtercmd"
+	"github.com/growler/go-pandoc/script"
+	"go.starlark.net/starlark"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s script [format]\n", filepath.Base(os.Args[0]))
+		os.Exit(filtercmd.ExitUsage)
+	}
+	file := os.Args[1]
+	s, err := script.Load(file, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(filtercmd.ExitUsage)
+	}
+	name := filepath.Base(file)
+	os.Exit(filtercmd.Run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, filtercmd.Filter{
+		Name: strings.TrimSuffix(name, filepath.Ext(name)),
+		Run: func(doc *pandoc.Pandoc, format string) (*pandoc.Pandoc, error) {
+			doc, err := s.Run(context.Background(), doc, format)
+			var eval *starlark.EvalError
+			if errors.As(err, &eval) {
+				return nil, errors.New(eval.Backtrace())
+			}
+			return doc, err
+		},
+	}))
+}
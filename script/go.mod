@@ -0,0 +1,12 @@
+module github.com/growler/go-pandoc/script
+
+go 1.25.0
+
+require github.com/growler/go-pandoc v0.0.0
+
+require (
+	go.starlark.net v0.0.0-20260908191801-89a6a09411d5
+	golang.org/x/sys v0.42.0 // indirect
+)
+
+replace github.com/growler/go-pandoc => ../
@@ -0,0 +1,123 @@
+package script
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// decodes JSON into Starlark values, keeping the order of object keys,
+// since pandoc.ReadBytes needs "t" before "c"
+func toValue(s string) (starlark.Value, error) {
+	d := json.NewDecoder(strings.NewReader(s))
+	d.UseNumber()
+	return decode(d)
+}
+
+func decode(d *json.Decoder) (starlark.Value, error) {
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			var items []starlark.Value
+			for d.More() {
+				v, err := decode(d)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, v)
+			}
+			_, err := d.Token()
+			return starlark.NewList(items), err
+		}
+		dict := starlark.NewDict(2)
+		for d.More() {
+			key, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decode(d)
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(key.(string)), v); err != nil {
+				return nil, err
+			}
+		}
+		_, err := d.Token()
+		return dict, err
+	case string:
+		return starlark.String(tok), nil
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		f, err := tok.Float64()
+		return starlark.Float(f), err
+	case bool:
+		return starlark.Bool(tok), nil
+	default:
+		return starlark.None, nil
+	}
+}
+
+// encodes a Starlark value as JSON, keeping the order of dict keys
+func encode(b *bytes.Buffer, v starlark.Value) error {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		b.WriteString("null")
+	case starlark.Bool:
+		b.WriteString(strconv.FormatBool(bool(v)))
+	case starlark.Int:
+		b.WriteString(v.String())
+	case starlark.Float:
+		if math.IsInf(float64(v), 0) || math.IsNaN(float64(v)) {
+			return fmt.Errorf("can't encode %s", v)
+		}
+		b.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 64))
+	case starlark.String:
+		s, _ := json.Marshal(string(v))
+		b.Write(s)
+	case starlark.Indexable: // lists and tuples
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := encode(b, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case *starlark.Dict:
+		b.WriteByte('{')
+		for i, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return fmt.Errorf("can't encode dict key %s", item[0])
+			}
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := encode(b, key); err != nil {
+				return err
+			}
+			b.WriteByte(':')
+			if err := encode(b, item[1]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	default:
+		return fmt.Errorf("can't encode %s", v.Type())
+	}
+	return nil
+}
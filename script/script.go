@@ -0,0 +1,296 @@
+// Package script runs filters written in Starlark, a dialect of Python,
+// so that filters can be written without recompiling. It is a module of
+// its own, so that only programs running scripts depend on Starlark.
+//
+// As pandoc Lua filters, a script defines functions named by the tags of
+// elements, called with each element of the tag, children before their
+// parents. Elements are dicts of their pandoc JSON encoding, such as
+// {"t": "Str", "c": "hello"}. A function returns None to keep the
+// element, an element to replace it with, or a list of elements to
+// replace it with in a list of elements, [] to delete it. A function
+// Pandoc, if any, is called last with the document, a dict with "meta"
+// and "blocks". The target format, if any, is the global FORMAT.
+//
+// The predeclared module pandoc has constructors of elements named by
+// tags, which take the content of the element, a list if several, and
+// stringify, returning the text of an element or a list of them:
+//
+//	def Str(e):
+//	    if e["c"] == "TODO":
+//	        return pandoc.Strong([pandoc.Str("TODO")])
+//
+//	def Header(e):
+//	    if FORMAT == "html" and pandoc.stringify(e) == "Internal":
+//	        return []
+//
+// Example:
+//
+//	s, err := script.Load("todo.star", nil)
+//	...
+//	doc, err = doc.Apply(s.Apply)
+package script
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/growler/go-pandoc"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// A compiled script, safe for concurrent use.
+type Script struct {
+	name string
+	prog *starlark.Program
+}
+
+// Compiles a script. The source is read from the file if src is nil,
+// otherwise it is a string, []byte or io.Reader, and filename is used in
+// messages.
+func Load(filename string, src any) (*Script, error) {
+	_, prog, err := starlark.SourceProgramOptions(&syntax.FileOptions{}, filename, src, func(name string) bool {
+		return name == "pandoc" || name == "FORMAT"
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Script{name: filename, prog: prog}, nil
+}
+
+// Applies the script to the document without a target format. It is a
+// transformer for pandoc.Pandoc.Apply.
+func (s *Script) Apply(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+	return s.Run(context.Background(), doc, "")
+}
+
+// Applies the script to the document for the target format, stopping
+// once ctx is done. Errors of the script are *starlark.EvalError, with
+// the Starlark call stack.
+func (s *Script) Run(ctx context.Context, doc *pandoc.Pandoc, format string) (*pandoc.Pandoc, error) {
+	thread := &starlark.Thread{Name: s.name}
+	stop := context.AfterFunc(ctx, func() { thread.Cancel(ctx.Err().Error()) })
+	defer stop()
+	globals, err := s.prog.Init(thread, starlark.StringDict{
+		"pandoc": module{},
+		"FORMAT": starlark.String(format),
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := &run{thread: thread, funcs: make(map[string]starlark.Callable)}
+	for name, v := range globals {
+		if fn, ok := v.(starlark.Callable); ok && name != "" && name[0] >= 'A' && name[0] <= 'Z' {
+			r.funcs[name] = fn
+		}
+	}
+	v, err := toValue(pandoc.Sprint(doc))
+	if err != nil {
+		return nil, err
+	}
+	if v, err = r.document(v.(*starlark.Dict)); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := encode(&b, v); err != nil {
+		return nil, err
+	}
+	res, err := pandoc.ReadBytes(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid document: %w", s.name, err)
+	}
+	return res, nil
+}
+
+// a run of a script
+type run struct {
+	thread *starlark.Thread
+	funcs  map[string]starlark.Callable // functions by tags
+}
+
+// walks the document and calls Pandoc with it
+func (r *run) document(doc *starlark.Dict) (starlark.Value, error) {
+	if _, _, err := r.walk(doc); err != nil {
+		return nil, err
+	}
+	fn, ok := r.funcs["Pandoc"]
+	if !ok {
+		return doc, nil
+	}
+	res, err := starlark.Call(r.thread, fn, starlark.Tuple{doc}, nil)
+	switch res.(type) {
+	case nil:
+		return nil, err
+	case starlark.NoneType:
+		return doc, nil
+	case *starlark.Dict:
+		return res, nil
+	default:
+		return nil, fmt.Errorf("Pandoc returned %s, want a document or None", res.Type())
+	}
+}
+
+// walks the value, children first, returning its replacement and whether
+// it is a list of elements to splice into the list containing the value
+func (r *run) walk(v starlark.Value) (starlark.Value, bool, error) {
+	switch v := v.(type) {
+	case *starlark.List:
+		l, err := r.list(v)
+		return l, false, err
+	case *starlark.Dict:
+		for _, item := range v.Items() {
+			c, splice, err := r.walk(item[1])
+			if err != nil {
+				return nil, false, err
+			} else if splice {
+				return nil, false, fmt.Errorf("%s: a list replaces an element out of a list", tag(item[1]))
+			} else if c != item[1] {
+				if err := v.SetKey(item[0], c); err != nil {
+					return nil, false, err
+				}
+			}
+		}
+		return r.call(v)
+	default:
+		return v, false, nil
+	}
+}
+
+// walks the items of the list, splicing lists replacing them
+func (r *run) list(l *starlark.List) (*starlark.List, error) {
+	items := make([]starlark.Value, 0, l.Len())
+	for i := 0; i < l.Len(); i++ {
+		v, splice, err := r.walk(l.Index(i))
+		if err != nil {
+			return nil, err
+		} else if !splice {
+			items = append(items, v)
+			continue
+		}
+		l := v.(*starlark.List)
+		for j := 0; j < l.Len(); j++ {
+			items = append(items, l.Index(j))
+		}
+	}
+	return starlark.NewList(items), nil
+}
+
+// calls the function of the element tag, if any
+func (r *run) call(e *starlark.Dict) (starlark.Value, bool, error) {
+	fn, ok := r.funcs[tag(e)]
+	if !ok {
+		return e, false, nil
+	}
+	res, err := starlark.Call(r.thread, fn, starlark.Tuple{e}, nil)
+	switch res.(type) {
+	case nil:
+		return nil, false, err
+	case starlark.NoneType:
+		return e, false, nil
+	case *starlark.Dict:
+		return res, false, nil
+	case *starlark.List:
+		return res, true, nil
+	default:
+		return nil, false, fmt.Errorf("%s returned %s, want an element, a list or None", fn.Name(), res.Type())
+	}
+}
+
+// returns the tag of an element, empty if v is not one
+func tag(v starlark.Value) string {
+	d, ok := v.(*starlark.Dict)
+	if !ok {
+		return ""
+	}
+	t, _, _ := d.Get(starlark.String("t"))
+	s, _ := t.(starlark.String)
+	return string(s)
+}
+
+// the predeclared module pandoc
+type module struct{}
+
+func (module) String() string        { return "<module pandoc>" }
+func (module) Type() string          { return "module" }
+func (module) Freeze()               {}
+func (module) Truth() starlark.Bool  { return starlark.True }
+func (module) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable: module") }
+func (module) AttrNames() []string   { return []string{"stringify"} }
+
+func (module) Attr(name string) (starlark.Value, error) {
+	switch {
+	case name == "stringify":
+		return starlark.NewBuiltin("stringify", stringify), nil
+	case name != "" && name[0] >= 'A' && name[0] <= 'Z':
+		return starlark.NewBuiltin(name, construct), nil
+	default:
+		return nil, nil
+	}
+}
+
+// makes an element of the tag of the builtin name
+func construct(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(kwargs) > 0 {
+		return nil, fmt.Errorf("%s: unexpected keyword arguments", fn.Name())
+	}
+	e := starlark.NewDict(2)
+	_ = e.SetKey(starlark.String("t"), starlark.String(fn.Name()))
+	switch len(args) {
+	case 0:
+	case 1:
+		_ = e.SetKey(starlark.String("c"), args[0])
+	default:
+		_ = e.SetKey(starlark.String("c"), starlark.NewList(append([]starlark.Value(nil), args...)))
+	}
+	return e, nil
+}
+
+func stringify(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var v starlark.Value
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &v); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	text(&sb, v)
+	return starlark.String(sb.String()), nil
+}
+
+// writes the text of a value, as pandoc.Stringify does
+func text(sb *strings.Builder, v starlark.Value) {
+	switch v := v.(type) {
+	case *starlark.List:
+		for i := 0; i < v.Len(); i++ {
+			text(sb, v.Index(i))
+		}
+	case *starlark.Dict:
+		c, _, _ := v.Get(starlark.String("c"))
+		switch tag(v) {
+		case "Str", "MetaString":
+			if s, ok := c.(starlark.String); ok {
+				sb.WriteString(string(s))
+			}
+		case "Space", "SoftBreak":
+			sb.WriteByte(' ')
+		case "LineBreak":
+			sb.WriteByte('\n')
+		case "Code", "Math":
+			// the text follows the attributes or the math type
+			if l, ok := c.(*starlark.List); ok && l.Len() == 2 {
+				if s, ok := l.Index(1).(starlark.String); ok {
+					sb.WriteString(string(s))
+				}
+			}
+		case "Note", "RawInline", "RawBlock":
+		case "":
+			for _, item := range v.Items() {
+				text(sb, item[1])
+			}
+		default:
+			if c != nil {
+				text(sb, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/growler/go-pandoc"
+	"go.starlark.net/starlark"
+)
+
+const (
+	intro    = `{"t":"Header","c":[1,["intro",[],[]],[{"t":"Str","c":"Intro"}]]}`
+	para     = `,{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Space"},{"t":"Str","c":"TODO"},{"t":"Space"},{"t":"Code","c":[["",[],[]],"x"]}]}`
+	internal = `,{"t":"Header","c":[1,["internal",[],[]],[{"t":"Str","c":"Internal"}]]}`
+	input    = `{"pandoc-api-version":[1,23,1],"meta":{"title":{"t":"MetaInlines","c":[{"t":"Str","c":"Notes"}]}},"blocks":[` +
+		intro + para + internal + `]}`
+)
+
+// reads a document of blocks
+func blocks(t *testing.T, s string) *pandoc.Pandoc {
+	t.Helper()
+	doc, err := pandoc.ReadBytes([]byte(`{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[` + s + `]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestScript(t *testing.T) {
+	doc, err := pandoc.ReadBytes([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name   string
+		src    string
+		format string
+		want   string
+	}{
+		{"none", ``, "", intro + para + internal},
+		{"replace", `
+def Str(e):
+    if e["c"] == "TODO":
+        return pandoc.Strong([pandoc.Str("TODO")])
+`, "", intro + `,{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Space"},{"t":"Strong","c":[{"t":"Str","c":"TODO"}]},{"t":"Space"},{"t":"Code","c":[["",[],[]],"x"]}]}` + internal},
+		{"splice", `
+def Space(e):
+    return [pandoc.Str("-"), pandoc.Space()]
+
+def Header(e):
+    if FORMAT == "html" and pandoc.stringify(e) == "Internal":
+        return []
+`, "html", intro + `,{"t":"Para","c":[{"t":"Str","c":"a"},{"t":"Str","c":"-"},{"t":"Space"},{"t":"Str","c":"TODO"},{"t":"Str","c":"-"},{"t":"Space"},{"t":"Code","c":[["",[],[]],"x"]}]}`},
+		{"children first", `
+def Para(e):
+    return pandoc.Plain([pandoc.Str(pandoc.stringify(e))])
+
+def Str(e):
+    return pandoc.Str(e["c"].lower())
+`, "", `{"t":"Header","c":[1,["intro",[],[]],[{"t":"Str","c":"intro"}]]},{"t":"Plain","c":[{"t":"Str","c":"a todo x"}]},{"t":"Header","c":[1,["internal",[],[]],[{"t":"Str","c":"internal"}]]}`},
+		{"document", `
+def Pandoc(doc):
+    doc["blocks"] = doc["blocks"][:1] + [pandoc.Para([pandoc.Str(pandoc.stringify(doc["meta"]["title"]))])]
+    return doc
+`, "", intro + `,{"t":"Para","c":[{"t":"Str","c":"Notes"}]}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Load(tt.name+".star", tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := s.Run(context.Background(), doc, tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := blocks(t, tt.want)
+			if res.Meta = nil; !pandoc.Equal(res, want) {
+				t.Errorf("got %s\nwant %s", pandoc.Sprint(res), pandoc.Sprint(want))
+			}
+		})
+	}
+	if s := pandoc.Stringify(doc); !strings.Contains(s, "TODO") {
+		t.Errorf("document changed: %q", s)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	doc, err := pandoc.ReadBytes([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load("syntax.star", "def Str(e)\n"); err == nil {
+		t.Error("no error for a syntax error")
+	}
+	for _, tt := range []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"fail", "def Str(e):\n    fail(\"boom\")\n", "boom"},
+		{"result", "def Str(e):\n    return 1\n", "Str returned int"},
+		{"out of list", "def MetaInlines(e):\n    return []\n", "a list replaces an element"},
+		{"invalid", "def Space(e):\n    return {\"t\": \"Space\", \"c\": 1}\n", "invalid document"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Load(tt.name+".star", tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Apply(doc); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want %q", err, tt.want)
+			}
+		})
+	}
+	s, err := Load("loop.star", "def Str(e):\n    for i in range(1000000000):\n        pass\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var eval *starlark.EvalError
+	if _, err := s.Run(ctx, doc, ""); !errors.As(err, &eval) || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("error %v, want canceled", err)
+	}
+}
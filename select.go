@@ -0,0 +1,278 @@
+package pandoc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A parsed CSS-like selector of elements.
+//
+// A selector is a comma-separated list of alternatives, each a sequence
+// of compound selectors separated by combinators: whitespace for a
+// descendant, ">" for a child. A compound selector is an optional tag
+// name, matched case-insensitively, or "*", followed by any of
+//
+//   - #id: the element identifier
+//   - .class: a class of the element
+//   - [key]: an attribute present
+//   - [key=value]: an attribute value, which may be quoted
+//
+// For example, "div.warning > para str" selects strings of paragraphs
+// that are children of divs with the class "warning".
+type Selector struct {
+	alts [][]compound
+}
+
+type compound struct {
+	child   bool // the previous compound must match the parent
+	tag     string
+	id      string
+	classes []string
+	attrs   []selectorAttr
+}
+
+type selectorAttr struct {
+	key, value string
+	any        bool // matches any value
+}
+
+// Parses a selector.
+func ParseSelector(s string) (*Selector, error) {
+	p := selectorParser{src: s}
+	sel, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", s, err)
+	}
+	return sel, nil
+}
+
+// Returns the descendants of root matching the selector, in the order
+// of Query.
+//
+// Example:
+//
+//	strs, err := pandoc.Select(doc, "div.warning > para str")
+func Select(root Element, selector string) ([]Element, error) {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return sel.Select(root), nil
+}
+
+// Returns the descendants of root matching the selector, in the order
+// of Query.
+func (s *Selector) Select(root Element) []Element {
+	var res []Element
+	QueryCursor(root, func(e Element, c *Cursor) {
+		if s.Match(c) {
+			res = append(res, e)
+		}
+	})
+	return res
+}
+
+// Reports whether the element under the cursor matches the selector.
+// The queried element is matched as an ancestor only.
+func (s *Selector) Match(c *Cursor) bool {
+	for _, alt := range s.alts {
+		if matchCompounds(alt, c.frames, len(c.frames)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// reports whether compounds match the frame at i and its ancestors
+func matchCompounds(compounds []compound, frames []cursorFrame, i int) bool {
+	last := compounds[len(compounds)-1]
+	if !last.match(frames[i].elt) {
+		return false
+	} else if len(compounds) == 1 {
+		return true
+	}
+	rest := compounds[:len(compounds)-1]
+	if last.child {
+		return i > 0 && matchCompounds(rest, frames, i-1)
+	}
+	for j := i - 1; j >= 0; j-- {
+		if matchCompounds(rest, frames, j) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *compound) match(e Element) bool {
+	if c.tag != "" && c.tag != "*" && !strings.EqualFold(c.tag, string(tagOf(e))) {
+		return false
+	}
+	if c.id == "" && len(c.classes) == 0 && len(c.attrs) == 0 {
+		return true
+	}
+	attr := attrOf(e)
+	if attr == nil {
+		return false
+	} else if c.id != "" && attr.Id != c.id {
+		return false
+	}
+	for _, class := range c.classes {
+		if !attr.HasClass(class) {
+			return false
+		}
+	}
+	for _, a := range c.attrs {
+		if v, ok := attr.Get(a.key); !ok || !a.any && v != a.value {
+			return false
+		}
+	}
+	return true
+}
+
+type selectorParser struct {
+	src string
+	pos int
+}
+
+func (p *selectorParser) parse() (*Selector, error) {
+	sel := &Selector{}
+	for {
+		alt, err := p.alternative()
+		if err != nil {
+			return nil, err
+		}
+		sel.alts = append(sel.alts, alt)
+		if p.pos == len(p.src) {
+			return sel, nil
+		}
+		p.pos++ // comma
+	}
+}
+
+func (p *selectorParser) alternative() ([]compound, error) {
+	var (
+		alt   []compound
+		child bool
+	)
+	for {
+		p.space()
+		if p.pos < len(p.src) && p.src[p.pos] == '>' {
+			if len(alt) == 0 || child {
+				return nil, p.errorf("unexpected '>'")
+			}
+			child = true
+			p.pos++
+			continue
+		}
+		if p.pos == len(p.src) || p.src[p.pos] == ',' {
+			if len(alt) == 0 || child {
+				return nil, p.errorf("missing selector")
+			}
+			return alt, nil
+		}
+		c, err := p.compound()
+		if err != nil {
+			return nil, err
+		}
+		c.child = child
+		alt = append(alt, c)
+		child = false
+	}
+}
+
+func (p *selectorParser) compound() (compound, error) {
+	var c compound
+	if p.pos < len(p.src) && p.src[p.pos] == '*' {
+		c.tag = "*"
+		p.pos++
+	} else {
+		c.tag = p.name()
+	}
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '#':
+			p.pos++
+			if c.id = p.name(); c.id == "" {
+				return c, p.errorf("missing identifier")
+			}
+		case '.':
+			p.pos++
+			class := p.name()
+			if class == "" {
+				return c, p.errorf("missing class")
+			}
+			c.classes = append(c.classes, class)
+		case '[':
+			p.pos++
+			a, err := p.attr()
+			if err != nil {
+				return c, err
+			}
+			c.attrs = append(c.attrs, a)
+		case ' ', '\t', '\n', '>', ',':
+			return c, nil
+		default:
+			return c, p.errorf("unexpected %q", p.src[p.pos])
+		}
+	}
+	return c, nil
+}
+
+func (p *selectorParser) attr() (selectorAttr, error) {
+	var a selectorAttr
+	p.space()
+	if a.key = p.name(); a.key == "" {
+		return a, p.errorf("missing attribute name")
+	}
+	p.space()
+	if p.pos < len(p.src) && p.src[p.pos] == ']' {
+		p.pos++
+		a.any = true
+		return a, nil
+	} else if p.pos == len(p.src) || p.src[p.pos] != '=' {
+		return a, p.errorf("expected '=' or ']'")
+	}
+	p.pos++
+	p.space()
+	if p.pos < len(p.src) && (p.src[p.pos] == '"' || p.src[p.pos] == '\'') {
+		q := p.src[p.pos]
+		end := strings.IndexByte(p.src[p.pos+1:], q)
+		if end < 0 {
+			return a, p.errorf("unterminated string")
+		}
+		a.value = p.src[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+	} else {
+		a.value = p.name()
+	}
+	p.space()
+	if p.pos == len(p.src) || p.src[p.pos] != ']' {
+		return a, p.errorf("expected ']'")
+	}
+	p.pos++
+	return a, nil
+}
+
+// reads a name: letters, digits and "-_:"
+func (p *selectorParser) name() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		if c := p.src[p.pos]; c == '-' || c == '_' || c == ':' || c >= '0' && c <= '9' ||
+			c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 {
+			p.pos++
+		} else {
+			break
+		}
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *selectorParser) space() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *selectorParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,57 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSelect(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Div{Attr: Attr{Classes: []string{"warning"}}, Blocks: []Block{
+			&Para{Inlines: []Inline{&Str{"a"}, &Emph{[]Inline{&Str{"b"}}}}},
+			&BlockQuote{Blocks: []Block{&Para{Inlines: []Inline{&Str{"c"}}}}},
+		}},
+		&Para{Inlines: []Inline{
+			&Span{Attr: Attr{Id: "s1", KVs: []KV{{"lang", "en"}}}, Inlines: []Inline{&Str{"d"}}},
+			&Span{Attr: Attr{Classes: []string{"x", "y"}}, Inlines: []Inline{&Str{"e"}}},
+		}},
+	}}
+	for _, test := range []struct {
+		selector string
+		want     string
+	}{
+		{"str", "abcde"},
+		{"div.warning > para str", "ab"},
+		{"div.warning para str", "abc"},
+		{"div > para > str", "a"},
+		{"para > * > str", "bde"},
+		{"#s1 str", "d"},
+		{"span.x.y str, emph str", "be"},
+		{"span.x.z str", ""},
+		{"[lang] str", "d"},
+		{`span[lang="en"] str`, "d"},
+		{"span[lang=fr] str", ""},
+		{"Pandoc > Para Str", "de"},
+	} {
+		sel, err := ParseSelector(test.selector)
+		if err != nil {
+			t.Errorf("%s: %v", test.selector, err)
+			continue
+		}
+		var sb strings.Builder
+		for _, e := range sel.Select(doc) {
+			sb.WriteString(e.(*Str).Text)
+		}
+		if got := sb.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.selector, got, test.want)
+		}
+	}
+	if divs, err := Select(doc, "div"); err != nil || len(divs) != 1 || divs[0] != doc.Blocks[0] {
+		t.Errorf("select div: %v %v", divs, err)
+	}
+	for _, s := range []string{"", "> str", "div >", "div,", "span[lang", `[a="b]`, "span!", "#"} {
+		if _, err := ParseSelector(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
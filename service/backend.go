@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/growler/go-pandoc"
+)
+
+// A Backend loads and stores documents on behalf of the service.
+type Backend interface {
+	// Loads a document in the format described by conf from r.
+	Load(ctx context.Context, r io.Reader, conf pandoc.Conf) (*pandoc.Pandoc, error)
+	// Stores a document to w in the format described by conf.
+	Store(ctx context.Context, doc *pandoc.Pandoc, w io.Writer, conf pandoc.Conf) error
+}
+
+// A backend that can report its health.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// A backend that can store documents in formats pandoc only writes to
+// files (such as PDF). The file extension ext selects the output kind.
+type FileStorer interface {
+	StoreFile(ctx context.Context, doc *pandoc.Pandoc, w io.Writer, conf pandoc.Conf, ext string) error
+}
+
+// Exec is the default Backend that runs the pandoc executable for each
+// conversion. The "json" format is handled without pandoc. A
+// pandoc.Pool as the Runner keeps pandoc processes warm between
+// conversions:
+//
+//	pool := pandoc.NewPool(pandoc.ExecRunner{}, 4)
+//	srv := &service.Service{Backend: service.Exec{Runner: pool}}
+type Exec struct {
+	Pandoc string        // Path to pandoc executable, see pandoc.Conf
+	Runner pandoc.Runner // Runs pandoc, see pandoc.Conf
+	TmpDir string        // Directory for temporary files, defaults to os.TempDir()
+}
+
+func (e Exec) conf(conf pandoc.Conf) pandoc.Conf {
+	if conf.Pandoc == "" {
+		conf.Pandoc = e.Pandoc
+	}
+	if conf.Runner == nil {
+		conf.Runner = e.Runner
+	}
+	return conf
+}
+
+func (e Exec) Load(ctx context.Context, r io.Reader, conf pandoc.Conf) (*pandoc.Pandoc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if conf.Format == "json" {
+		return pandoc.ReadFrom(r)
+	}
+	return pandoc.LoadFromContext(ctx, r, e.conf(conf))
+}
+
+func (e Exec) Store(ctx context.Context, doc *pandoc.Pandoc, w io.Writer, conf pandoc.Conf) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if conf.Format == "json" {
+		return doc.WriteTo(w)
+	}
+	return doc.StoreToContext(ctx, w, e.conf(conf))
+}
+
+// StoreFile stores a document to a temporary file with extension ext and
+// copies the file to w.
+func (e Exec) StoreFile(ctx context.Context, doc *pandoc.Pandoc, w io.Writer, conf pandoc.Conf, ext string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(e.TmpDir, "pandoc-service-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "output"+ext)
+	if err := doc.StoreFileContext(ctx, out, e.conf(conf)); err != nil {
+		return err
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Check reports an error if pandoc can't be run.
+func (e Exec) Check(ctx context.Context) error {
+	_, err := pandoc.Conf{Pandoc: e.Pandoc, Runner: e.Runner}.CapabilitiesContext(ctx)
+	return err
+}
@@ -0,0 +1,412 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/growler/go-pandoc"
+)
+
+// State of an asynchronous conversion job.
+type JobState string
+
+const (
+	JobQueued   JobState = "queued"
+	JobRunning  JobState = "running"
+	JobDone     JobState = "done"
+	JobFailed   JobState = "failed"
+	JobCanceled JobState = "canceled"
+)
+
+// Job describes an asynchronous conversion.
+type Job struct {
+	ID        string    `json:"id"`
+	State     JobState  `json:"state"`
+	MediaType string    `json:"mediaType"`       // Media type of the result
+	Error     string    `json:"error,omitempty"` // Conversion error, if failed
+	Created   time.Time `json:"created"`
+	Started   time.Time `json:"started"`
+	Finished  time.Time `json:"finished"`
+}
+
+// Returns true if the job will not change its state anymore.
+func (j Job) Done() bool {
+	return j.State == JobDone || j.State == JobFailed || j.State == JobCanceled
+}
+
+var (
+	ErrNoJob     = errors.New("no such job")
+	ErrNotDone   = errors.New("job is not done")
+	ErrQueueFull = errors.New("job queue is full")
+	ErrClosed    = errors.New("job queue is closed")
+)
+
+// JobStore keeps jobs states and results. Implementations must be safe
+// for concurrent use.
+type JobStore interface {
+	// Saves the job state.
+	Save(ctx context.Context, job Job) error
+	// Returns the job state, or ErrNoJob.
+	Load(ctx context.Context, id string) (Job, error)
+	// Saves the job result.
+	SaveResult(ctx context.Context, id string, data []byte) error
+	// Returns the job result, or ErrNoJob.
+	LoadResult(ctx context.Context, id string) ([]byte, error)
+	// Deletes the job and its result.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemoryStore is a JobStore keeping everything in memory. Finished jobs
+// are removed with their results TTL after they finish. The zero value
+// is ready to use.
+type MemoryStore struct {
+	TTL time.Duration // Time finished jobs are kept, defaults to DefaultJobTTL
+
+	mu      sync.Mutex
+	jobs    map[string]Job
+	results map[string][]byte
+	swept   time.Time
+	now     func() time.Time // time.Now if nil
+}
+
+func (m *MemoryStore) Save(_ context.Context, job Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobs == nil {
+		m.jobs = make(map[string]Job)
+	}
+	m.jobs[job.ID] = job
+	m.sweep()
+	return nil
+}
+
+func (m *MemoryStore) Load(_ context.Context, id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, ok := m.jobs[id]; ok && !m.expired(job) {
+		return job, nil
+	}
+	return Job{}, ErrNoJob
+}
+
+func (m *MemoryStore) SaveResult(_ context.Context, id string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.results == nil {
+		m.results = make(map[string][]byte)
+	}
+	m.results[id] = data
+	return nil
+}
+
+func (m *MemoryStore) LoadResult(_ context.Context, id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if data, ok := m.results[id]; ok && !m.expired(m.jobs[id]) {
+		return data, nil
+	}
+	return nil, ErrNoJob
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.jobs, id)
+	delete(m.results, id)
+	return nil
+}
+
+func (m *MemoryStore) clock() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+// reports whether the job is finished for longer than TTL
+func (m *MemoryStore) expired(job Job) bool {
+	return job.Done() && m.clock().Sub(job.Finished) > limit(m.TTL, DefaultJobTTL)
+}
+
+// removes expired jobs, at most once in TTL
+func (m *MemoryStore) sweep() {
+	now := m.clock()
+	if now.Sub(m.swept) < limit(m.TTL, DefaultJobTTL) {
+		return
+	}
+	m.swept = now
+	for id, job := range m.jobs {
+		if m.expired(job) {
+			delete(m.jobs, id)
+			delete(m.results, id)
+		}
+	}
+}
+
+const (
+	DefaultQueueSize  = 100
+	DefaultJobTimeout = 10 * time.Minute
+	DefaultJobTTL     = time.Hour
+)
+
+// Jobs runs conversions asynchronously with a fixed number of workers.
+// The zero value is ready to use; Close stops the workers.
+//
+// Example:
+//
+//	jobs := &service.Jobs{Workers: 2}
+//	defer jobs.Close()
+//	job, err := jobs.Submit(ctx, input, from, to)
+//	...
+//	for job, err = jobs.Status(ctx, job.ID); err == nil && !job.Done(); job, err = jobs.Status(ctx, job.ID) {
+//	    time.Sleep(time.Second)
+//	}
+//	data, job, err := jobs.Result(ctx, job.ID)
+type Jobs struct {
+	Backend   Backend       // Conversion backend, defaults to Exec{}
+	Store     JobStore      // Job store, defaults to MemoryStore
+	Workers   int           // Number of workers, defaults to runtime.NumCPU()
+	QueueSize int           // Maximum number of queued jobs, defaults to DefaultQueueSize
+	Timeout   time.Duration // Maximum job run time, defaults to DefaultJobTimeout
+
+	// Transform, if set, is applied to every document between loading
+	// and storing.
+	Transform func(*pandoc.Pandoc) (*pandoc.Pandoc, error)
+
+	once    sync.Once
+	mu      sync.Mutex
+	closed  bool
+	queue   chan *task
+	running map[string]*run
+	ctx     context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type task struct {
+	id       string
+	input    []byte
+	from, to Format
+}
+
+type run struct {
+	cancel   context.CancelFunc
+	canceled bool
+	deleted  bool
+}
+
+func (j *Jobs) init() {
+	j.once.Do(func() {
+		if j.Backend == nil {
+			j.Backend = Exec{}
+		}
+		if j.Store == nil {
+			j.Store = &MemoryStore{}
+		}
+		workers := j.Workers
+		if workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		j.queue = make(chan *task, int(limit(int64(j.QueueSize), DefaultQueueSize)))
+		j.running = make(map[string]*run)
+		j.ctx, j.stop = context.WithCancel(context.Background())
+		for i := 0; i < workers; i++ {
+			j.wg.Add(1)
+			go j.worker()
+		}
+	})
+}
+
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Submit queues a conversion of the input document and returns the
+// queued job.
+func (j *Jobs) Submit(ctx context.Context, input []byte, from, to Format) (Job, error) {
+	j.init()
+	job := Job{
+		ID:        newID(),
+		State:     JobQueued,
+		MediaType: to.MediaType,
+		Created:   time.Now(),
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.closed {
+		return Job{}, ErrClosed
+	}
+	if err := j.Store.Save(ctx, job); err != nil {
+		return Job{}, err
+	}
+	select {
+	case j.queue <- &task{job.ID, input, from, to}:
+		return job, nil
+	default:
+		_ = j.Store.Delete(ctx, job.ID)
+		return Job{}, ErrQueueFull
+	}
+}
+
+// Status returns the current state of the job.
+func (j *Jobs) Status(ctx context.Context, id string) (Job, error) {
+	j.init()
+	return j.Store.Load(ctx, id)
+}
+
+// Result returns the result of a finished job. It returns ErrNotDone if
+// the job is still queued or running, and the job error if the job has
+// failed or was canceled.
+func (j *Jobs) Result(ctx context.Context, id string) ([]byte, Job, error) {
+	j.init()
+	job, err := j.Store.Load(ctx, id)
+	if err != nil {
+		return nil, job, err
+	}
+	switch job.State {
+	case JobDone:
+		data, err := j.Store.LoadResult(ctx, id)
+		return data, job, err
+	case JobFailed:
+		return nil, job, errors.New(job.Error)
+	case JobCanceled:
+		return nil, job, context.Canceled
+	default:
+		return nil, job, ErrNotDone
+	}
+}
+
+// Cancel cancels a queued or running job. Canceling a finished job
+// is a no-op.
+func (j *Jobs) Cancel(ctx context.Context, id string) error {
+	j.init()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if r, ok := j.running[id]; ok {
+		r.canceled = true
+		r.cancel()
+		return nil
+	}
+	job, err := j.Store.Load(ctx, id)
+	if err != nil || job.Done() {
+		return err
+	}
+	job.State = JobCanceled
+	job.Finished = time.Now()
+	return j.Store.Save(ctx, job)
+}
+
+// Delete cancels the job if it's not finished and removes it with its
+// result from the store.
+func (j *Jobs) Delete(ctx context.Context, id string) error {
+	j.init()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if r, ok := j.running[id]; ok {
+		// the worker drops the job instead of saving it
+		r.canceled, r.deleted = true, true
+		r.cancel()
+	} else if _, err := j.Store.Load(ctx, id); err != nil {
+		return err
+	}
+	return j.Store.Delete(ctx, id)
+}
+
+// Close stops accepting new jobs, cancels running and queued jobs and
+// waits for workers to exit.
+func (j *Jobs) Close() error {
+	j.init()
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return nil
+	}
+	j.closed = true
+	j.stop()
+	close(j.queue)
+	j.mu.Unlock()
+	j.wg.Wait()
+	return nil
+}
+
+func (j *Jobs) worker() {
+	defer j.wg.Done()
+	for t := range j.queue {
+		j.process(t)
+	}
+}
+
+// marks the job as running, returns false if it was canceled while queued
+func (j *Jobs) start(t *task) (Job, context.Context, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, err := j.Store.Load(j.ctx, t.id)
+	if err != nil || job.State != JobQueued {
+		return job, nil, false
+	}
+	ctx, cancel := context.WithTimeout(j.ctx, limit(j.Timeout, DefaultJobTimeout))
+	if err := j.ctx.Err(); err != nil {
+		cancel()
+		job.State, job.Error, job.Finished = JobCanceled, err.Error(), time.Now()
+		_ = j.Store.Save(context.Background(), job)
+		return job, nil, false
+	}
+	job.State, job.Started = JobRunning, time.Now()
+	if err := j.Store.Save(ctx, job); err != nil {
+		cancel()
+		return job, nil, false
+	}
+	j.running[t.id] = &run{cancel: cancel}
+	return job, ctx, true
+}
+
+func (j *Jobs) process(t *task) {
+	job, ctx, ok := j.start(t)
+	if !ok {
+		return
+	}
+	var out bytes.Buffer
+	err := Convert(ctx, j.Backend, bytes.NewReader(t.input), t.from, t.to, &out, j.Transform)
+	if err == nil {
+		err = ctx.Err()
+	}
+	// the job is saved locked, so that Delete does not interleave
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r := j.running[t.id]
+	delete(j.running, t.id)
+	r.cancel()
+	if r.deleted {
+		return
+	}
+	// the job context may be done, so the result is saved regardless
+	sctx := context.Background()
+	switch {
+	case r.canceled || (err != nil && j.ctx.Err() != nil):
+		job.State = JobCanceled
+		job.Error = "canceled"
+	case err != nil:
+		job.State = JobFailed
+		job.Error = err.Error()
+	default:
+		if err = j.Store.SaveResult(sctx, t.id, out.Bytes()); err != nil {
+			job.State = JobFailed
+			job.Error = fmt.Sprintf("saving result: %s", err)
+		} else {
+			job.State = JobDone
+		}
+	}
+	job.Finished = time.Now()
+	_ = j.Store.Save(sctx, job)
+}
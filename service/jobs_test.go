@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/growler/go-pandoc"
+)
+
+func waitJob(t *testing.T, jobs *Jobs, id string) Job {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		job, err := jobs.Status(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Done() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s is not done", id)
+	return Job{}
+}
+
+func TestJobs(t *testing.T) {
+	const doc = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[]}`
+	var (
+		ctx     = context.Background()
+		jsonFmt = Format{MediaType: "application/json", Conf: pandoc.Format("json")}
+		slow    = Format{MediaType: "text/slow", Conf: pandoc.Format("slow")}
+		jobs    = &Jobs{Backend: fakeBackend{true}, Workers: 1, Timeout: time.Minute}
+	)
+	defer jobs.Close()
+	job, err := jobs.Submit(ctx, []byte(doc), jsonFmt, jsonFmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job = waitJob(t, jobs, job.ID); job.State != JobDone {
+		t.Fatalf("unexpected job state %s: %s", job.State, job.Error)
+	}
+	if data, _, err := jobs.Result(ctx, job.ID); err != nil || string(data) != doc {
+		t.Errorf("unexpected result %q, %v", data, err)
+	}
+	if job, _ = jobs.Submit(ctx, []byte("{"), jsonFmt, jsonFmt); waitJob(t, jobs, job.ID).State != JobFailed {
+		t.Errorf("malformed document did not fail")
+	}
+	// slow job occupies the only worker, so the next one stays queued
+	running, _ := jobs.Submit(ctx, []byte(doc), jsonFmt, slow)
+	queued, _ := jobs.Submit(ctx, []byte(doc), jsonFmt, jsonFmt)
+	if _, _, err := jobs.Result(ctx, queued.ID); err != ErrNotDone {
+		t.Errorf("unexpected result error %v", err)
+	}
+	if err := jobs.Cancel(ctx, queued.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := jobs.Cancel(ctx, running.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{running.ID, queued.ID} {
+		if job := waitJob(t, jobs, id); job.State != JobCanceled {
+			t.Errorf("job state %s, want canceled", job.State)
+		}
+	}
+	if err := jobs.Delete(ctx, job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jobs.Status(ctx, job.ID); err != ErrNoJob {
+		t.Errorf("deleted job status error %v", err)
+	}
+	// a running job deleted is not saved when it finishes
+	running, _ = jobs.Submit(ctx, []byte(doc), jsonFmt, slow)
+	for job, err := jobs.Status(ctx, running.ID); err == nil && job.State != JobRunning; job, err = jobs.Status(ctx, running.ID) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := jobs.Delete(ctx, running.ID); err != nil {
+		t.Fatal(err)
+	}
+	// the only worker is done with the deleted job once the next one is
+	next, _ := jobs.Submit(ctx, []byte(doc), jsonFmt, jsonFmt)
+	waitJob(t, jobs, next.ID)
+	if _, err := jobs.Status(ctx, running.ID); err != ErrNoJob {
+		t.Errorf("deleted running job status error %v", err)
+	}
+	if err := jobs.Delete(ctx, running.ID); err != ErrNoJob {
+		t.Errorf("deleting a deleted job error %v", err)
+	}
+	jobs.Close()
+	if _, err := jobs.Submit(ctx, []byte(doc), jsonFmt, jsonFmt); err != ErrClosed {
+		t.Errorf("submit to closed queue error %v", err)
+	}
+}
+
+func TestMemoryStoreTTL(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		now   = time.Now()
+		store = &MemoryStore{TTL: time.Minute, now: func() time.Time { return now }}
+	)
+	_ = store.Save(ctx, Job{ID: "done", State: JobDone, Finished: now})
+	_ = store.SaveResult(ctx, "done", []byte("result"))
+	_ = store.Save(ctx, Job{ID: "running", State: JobRunning})
+	now = now.Add(30 * time.Second)
+	if _, err := store.LoadResult(ctx, "done"); err != nil {
+		t.Errorf("job expired early: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := store.Load(ctx, "done"); err != ErrNoJob {
+		t.Errorf("expired job status error %v", err)
+	}
+	if _, err := store.LoadResult(ctx, "done"); err != ErrNoJob {
+		t.Errorf("expired job result error %v", err)
+	}
+	_ = store.Save(ctx, Job{ID: "queued", State: JobQueued})
+	if len(store.jobs) != 2 || len(store.results) != 0 {
+		t.Errorf("expired job is kept: %v", store.jobs)
+	}
+	if _, err := store.Load(ctx, "running"); err != nil {
+		t.Errorf("unfinished job expired: %v", err)
+	}
+}
+
+func TestServiceJobs(t *testing.T) {
+	const doc = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[]}`
+	jobs := &Jobs{Backend: fakeBackend{true}}
+	defer jobs.Close()
+	srv := &Service{Backend: fakeBackend{true}, Jobs: jobs}
+	do := func(method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(doc))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		return w
+	}
+	w := do("POST", "/jobs")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("submit status %d: %s", w.Code, w.Body.String())
+	}
+	var job Job
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	if loc := w.Header().Get("Location"); loc != "/jobs/"+job.ID {
+		t.Errorf("unexpected location %q", loc)
+	}
+	waitJob(t, jobs, job.ID)
+	if w := do("GET", "/jobs/"+job.ID); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"state":"done"`) {
+		t.Errorf("status %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("GET", "/jobs/"+job.ID+"/result"); w.Code != http.StatusOK || w.Body.String() != doc {
+		t.Errorf("result %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("DELETE", "/jobs/"+job.ID); w.Code != http.StatusNoContent {
+		t.Errorf("delete status %d", w.Code)
+	}
+	if w := do("GET", "/jobs/"+job.ID); w.Code != http.StatusNotFound {
+		t.Errorf("deleted job status %d", w.Code)
+	}
+}
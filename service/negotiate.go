@@ -0,0 +1,138 @@
+package service
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/growler/go-pandoc"
+)
+
+// A document format served by the service.
+type Format struct {
+	MediaType string      // Media type, e.g. "text/html"
+	Conf      pandoc.Conf // Pandoc configuration to read or write the format
+	File      string      // If not empty, the format is only written to files with this extension
+}
+
+// Input formats used by the service if none are configured.
+var DefaultInputs = []Format{
+	{MediaType: "text/markdown", Conf: pandoc.Format("markdown")},
+	{MediaType: "text/html", Conf: pandoc.Format("html")},
+	{MediaType: "text/plain", Conf: pandoc.Format("markdown")},
+	{MediaType: "text/x-rst", Conf: pandoc.Format("rst")},
+	{MediaType: "application/json", Conf: pandoc.Format("json")},
+	{MediaType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Conf: pandoc.Format("docx")},
+	{MediaType: "application/epub+zip", Conf: pandoc.Format("epub")},
+}
+
+// Output formats used by the service if none are configured, in order
+// of preference for requests accepting any format.
+var DefaultOutputs = []Format{
+	{MediaType: "text/html", Conf: pandoc.Format("html").WithOpt("standalone")},
+	{MediaType: "text/markdown", Conf: pandoc.Format("markdown")},
+	{MediaType: "text/plain", Conf: pandoc.Format("plain")},
+	{MediaType: "text/x-rst", Conf: pandoc.Format("rst")},
+	{MediaType: "application/json", Conf: pandoc.Format("json")},
+	{MediaType: "application/pdf", Conf: pandoc.Format("latex"), File: ".pdf"},
+	{MediaType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document", Conf: pandoc.Format("docx"), File: ".docx"},
+	{MediaType: "application/epub+zip", Conf: pandoc.Format("epub"), File: ".epub"},
+}
+
+// an Accept header entry
+type acceptRange struct {
+	typ, sub string
+	q        float64
+}
+
+// parses an Accept header, returns media ranges sorted by preference
+func parseAccept(h string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(h, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		r := acceptRange{q: 1}
+		r.typ, r.sub, _ = strings.Cut(mt, "/")
+		if r.sub == "" {
+			r.sub = "*"
+		}
+		if q, ok := params["q"]; ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v >= 0 && v <= 1 {
+				r.q = v
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	// more specific ranges win over wildcards with the same q
+	specificity := func(r acceptRange) int {
+		switch {
+		case r.typ == "*":
+			return 0
+		case r.sub == "*":
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i]) > specificity(ranges[j])
+	})
+	return ranges
+}
+
+func (r acceptRange) match(mediaType string) bool {
+	typ, sub, _ := strings.Cut(mediaType, "/")
+	return (r.typ == "*" || r.typ == typ) && (r.sub == "*" || r.sub == sub)
+}
+
+// negotiate returns the most preferred of formats acceptable according to
+// the Accept header. An empty header accepts the first format.
+func negotiate(accept string, formats []Format) (Format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		if len(formats) == 0 {
+			return Format{}, false
+		}
+		return formats[0], true
+	}
+	for _, r := range parseAccept(accept) {
+		if r.q == 0 {
+			continue
+		}
+		for _, f := range formats {
+			if r.match(f.MediaType) && !rejected(accept, f.MediaType) {
+				return f, true
+			}
+		}
+	}
+	return Format{}, false
+}
+
+// reports whether the media type is explicitly excluded with q=0
+func rejected(accept string, mediaType string) bool {
+	for _, r := range parseAccept(accept) {
+		if r.q == 0 && r.typ+"/"+r.sub == mediaType {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the format for the request content type
+func lookup(contentType string, formats []Format) (Format, bool) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Format{}, false
+	}
+	for _, f := range formats {
+		if f.MediaType == mt {
+			return f, true
+		}
+	}
+	return Format{}, false
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/growler/go-pandoc"
+)
+
+// Server is a Backend posting conversions to a pandoc-server instance,
+// instead of running pandoc for each of them. The "json" format is
+// handled without the server. Conf options other than standalone are
+// not passed to the server.
+//
+// Example:
+//
+//	srv := &service.Service{Backend: service.Server{URL: "http://pandoc:3030"}}
+type Server struct {
+	URL    string       // URL of the server
+	Client *http.Client // Defaults to http.DefaultClient
+}
+
+// the request of pandoc-server
+type serverRequest struct {
+	Text       string `json:"text"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Standalone bool   `json:"standalone,omitempty"`
+}
+
+// the JSON response of pandoc-server
+type serverResponse struct {
+	Output string `json:"output"`
+	Base64 bool   `json:"base64"`
+}
+
+// binary input formats, passed to the server base64-encoded
+var binaryInputs = map[string]bool{"docx": true, "odt": true, "epub": true, "pptx": true, "xlsx": true}
+
+func serverFormat(conf pandoc.Conf) string {
+	return conf.Format + strings.Join(conf.Ext, "")
+}
+
+func standalone(conf pandoc.Conf) bool {
+	for _, opt := range conf.Opts {
+		if opt == "-s" || opt == "--standalone" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Server) Load(ctx context.Context, r io.Reader, conf pandoc.Conf) (*pandoc.Pandoc, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if conf.Format == "json" {
+		return pandoc.ReadFrom(r)
+	}
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	req := serverRequest{Text: string(input), From: serverFormat(conf), To: "json"}
+	if binaryInputs[conf.Format] {
+		req.Text = base64.StdEncoding.EncodeToString(input)
+	}
+	var out bytes.Buffer
+	if err := s.post(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return pandoc.ReadFrom(&out)
+}
+
+func (s Server) Store(ctx context.Context, doc *pandoc.Pandoc, w io.Writer, conf pandoc.Conf) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if conf.Format == "json" {
+		return doc.WriteTo(w)
+	}
+	var sb strings.Builder
+	if err := doc.WriteTo(&sb); err != nil {
+		return err
+	}
+	return s.post(ctx, serverRequest{Text: sb.String(), From: "json", To: serverFormat(conf), Standalone: standalone(conf)}, w)
+}
+
+// Check reports an error if the server is not available.
+func (s Server) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.URL, "/")+"/version", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pandoc-server: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s Server) client() *http.Client {
+	if s.Client == nil {
+		return http.DefaultClient
+	}
+	return s.Client
+}
+
+// posts a conversion request and writes the output to w
+func (s Server) post(ctx context.Context, conv serverRequest, w io.Writer) error {
+	body, err := json.Marshal(conv)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return errorf(http.StatusUnprocessableEntity, "pandoc-server: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var res serverResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("pandoc-server: %w", err)
+	}
+	if res.Base64 {
+		_, err = io.Copy(w, base64.NewDecoder(base64.StdEncoding, strings.NewReader(res.Output)))
+	} else {
+		_, err = io.WriteString(w, res.Output)
+	}
+	return err
+}
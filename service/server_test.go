@@ -0,0 +1,85 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/growler/go-pandoc"
+)
+
+// a pandoc-server converting "markdown" to a fixed document and
+// documents to "html" and "docx" by their text
+func fakeServer(t *testing.T) *httptest.Server {
+	const doc = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Str","c":"Hello"}]}]}`
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/version" {
+			w.Write([]byte("3.1"))
+			return
+		}
+		var req serverRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Header.Get("Accept") != "application/json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var res serverResponse
+		switch {
+		case req.From == "markdown+smart" && req.To == "json":
+			res.Output = doc
+		case req.From == "json" && req.To == "html":
+			d, err := pandoc.ReadFrom(strings.NewReader(req.Text))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Output = "<p>" + pandoc.Stringify(d) + "</p>"
+			if req.Standalone {
+				res.Output = "<html>" + res.Output + "</html>"
+			}
+		case req.From == "json" && req.To == "docx":
+			res.Output, res.Base64 = base64.StdEncoding.EncodeToString([]byte("PK")), true
+		default:
+			http.Error(w, "Unknown format "+req.From, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(res)
+	}))
+}
+
+func TestServer(t *testing.T) {
+	fake := fakeServer(t)
+	defer fake.Close()
+	s := Server{URL: fake.URL}
+	ctx := context.Background()
+	if err := s.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := s.Load(ctx, strings.NewReader("Hello"), pandoc.Format("markdown").WithExt("smart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		conf pandoc.Conf
+		want string
+	}{
+		{pandoc.Format("html"), "<p>Hello</p>"},
+		{pandoc.Format("html").WithOpt("standalone"), "<html><p>Hello</p></html>"},
+		{pandoc.Format("docx"), "PK"},
+	} {
+		var out bytes.Buffer
+		if err := s.Store(ctx, doc, &out, test.conf); err != nil {
+			t.Errorf("%s: %v", test.conf.Format, err)
+		} else if out.String() != test.want {
+			t.Errorf("%s: got %q, want %q", test.conf.Format, out.String(), test.want)
+		}
+	}
+	_, err = s.Load(ctx, strings.NewReader("x"), pandoc.Format("nope"))
+	if err == nil || httpStatus(err) != http.StatusUnprocessableEntity || !strings.Contains(err.Error(), "Unknown format nope") {
+		t.Errorf("error: %v", err)
+	}
+}
@@ -0,0 +1,521 @@
+// Package service implements an HTTP document conversion service on top
+// of pandoc.
+//
+// The service converts documents posted to it, negotiating the output
+// format from the request's Accept header:
+//
+//	curl -H 'Content-Type: text/markdown' -H 'Accept: application/pdf' \
+//	    --data-binary @doc.md http://localhost:8080/ > doc.pdf
+//
+// The input format is taken from the Content-Type header, or from the
+// "from" query parameter naming one of Service.Inputs, optionally with
+// extensions (e.g. ?from=markdown+smart), and the output
+// format may be forced with the "to" query parameter. Besides conversion,
+// the service exposes /healthz and /metrics (in Prometheus text format).
+//
+// If Jobs is set, long conversions may be run asynchronously: POST /jobs
+// accepts the same request as a conversion and responds with the job
+// status, GET /jobs/{id} returns the status, GET /jobs/{id}/result the
+// converted document, and DELETE /jobs/{id} cancels and removes the job.
+//
+// Example:
+//
+//	srv := &service.Service{
+//	    MaxBodySize: 1 << 20,
+//	    Timeout:     30 * time.Second,
+//	}
+//	log.Fatal(http.ListenAndServe(":8080", srv))
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/growler/go-pandoc"
+)
+
+const (
+	DefaultMaxBodySize   = 10 << 20
+	DefaultMaxOutputSize = 100 << 20
+	DefaultTimeout       = time.Minute
+)
+
+// Service is an http.Handler converting documents. The zero value is
+// a usable service running pandoc for every conversion.
+type Service struct {
+	Backend       Backend       // Conversion backend, defaults to Exec{}
+	Inputs        []Format      // Accepted input formats, defaults to DefaultInputs
+	Outputs       []Format      // Produced output formats, defaults to DefaultOutputs
+	MaxBodySize   int64         // Maximum request body size, defaults to DefaultMaxBodySize
+	MaxOutputSize int64         // Maximum response size, defaults to DefaultMaxOutputSize
+	Timeout       time.Duration // Maximum conversion time, defaults to DefaultTimeout
+	MaxConcurrent int           // Maximum number of concurrent conversions, unlimited if 0
+
+	// Stream, if set, sends the output of conversions as it is produced
+	// instead of buffering it. A conversion failing after the output has
+	// started can't report an error status, the response is aborted.
+	Stream bool
+
+	// Transform, if set, is applied to every document between loading
+	// and storing.
+	Transform func(*pandoc.Pandoc) (*pandoc.Pandoc, error)
+
+	// Jobs, if set, serves asynchronous conversions under /jobs/.
+	Jobs *Jobs
+
+	once    sync.Once
+	slots   chan struct{}
+	metrics metrics
+}
+
+func (s *Service) init() {
+	s.once.Do(func() {
+		if s.MaxConcurrent > 0 {
+			s.slots = make(chan struct{}, s.MaxConcurrent)
+		}
+	})
+}
+
+func (s *Service) backend() Backend {
+	if s.Backend == nil {
+		return Exec{}
+	}
+	return s.Backend
+}
+
+func (s *Service) inputs() []Format {
+	if s.Inputs == nil {
+		return DefaultInputs
+	}
+	return s.Inputs
+}
+
+func (s *Service) outputs() []Format {
+	if s.Outputs == nil {
+		return DefaultOutputs
+	}
+	return s.Outputs
+}
+
+func limit[T int64 | time.Duration](v, def T) T {
+	if v <= 0 {
+		return def
+	}
+	return v
+}
+
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.init()
+	switch r.URL.Path {
+	case "/healthz":
+		s.serveHealth(w, r)
+	case "/metrics":
+		s.metrics.serve(w)
+	case "/jobs", "/jobs/":
+		if s.Jobs == nil {
+			http.NotFound(w, r)
+		} else if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		} else {
+			s.serveSubmit(w, r)
+		}
+	case "/":
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.serveConvert(w, r)
+	default:
+		if s.Jobs != nil && strings.HasPrefix(r.URL.Path, "/jobs/") {
+			s.serveJob(w, r)
+		} else {
+			http.NotFound(w, r)
+		}
+	}
+}
+
+func (s *Service) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if c, ok := s.backend().(Checker); ok {
+		if err := c.Check(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// an error with an HTTP status
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string { return e.err.Error() }
+func (e *httpError) Unwrap() error { return e.err }
+
+func errorf(status int, f string, a ...any) error {
+	return &httpError{status, fmt.Errorf(f, a...)}
+}
+
+// returns the HTTP status for a conversion error
+func httpStatus(err error) int {
+	var he *httpError
+	var mbe *http.MaxBytesError
+	switch {
+	case errors.As(err, &he):
+		return he.status
+	case errors.As(err, &mbe):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+func (s *Service) serveConvert(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	s.metrics.started()
+	var (
+		buf bytes.Buffer
+		out = &limitedWriter{w: &buf, limit: limit(s.MaxOutputSize, DefaultMaxOutputSize)}
+		sw  *streamWriter
+	)
+	if s.Stream {
+		sw = &streamWriter{w: w}
+		out.w = sw
+	}
+	format, err := s.convert(r, out, sw)
+	s.metrics.finished(err, r.ContentLength, int(out.n), time.Since(start))
+	switch {
+	case err != nil && sw != nil && sw.started:
+		// the status is sent, abort the response
+		panic(http.ErrAbortHandler)
+	case err != nil:
+		http.Error(w, err.Error(), httpStatus(err))
+	case sw != nil:
+		if !sw.started {
+			w.Header().Set("Content-Type", format.MediaType)
+			w.Header().Set("Content-Length", "0")
+		}
+	default:
+		w.Header().Set("Content-Type", format.MediaType)
+		w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+		_, _ = buf.WriteTo(w)
+	}
+}
+
+// a response writer setting the content type on the first write
+type streamWriter struct {
+	w         http.ResponseWriter
+	mediaType string
+	started   bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", s.mediaType)
+	}
+	return s.w.Write(p)
+}
+
+func (s *Service) body(r *http.Request) *bodyReader {
+	return &bodyReader{r: http.MaxBytesReader(nil, r.Body, limit(s.MaxBodySize, DefaultMaxBodySize))}
+}
+
+// a reader remembering the read error
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// a writer refusing to write beyond a limit
+type limitedWriter struct {
+	w     io.Writer
+	n     int64
+	limit int64
+}
+
+var errOutputTooLarge = errorf(http.StatusInsufficientStorage, "output is too large")
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n+int64(len(p)) > l.limit {
+		return 0, errOutputTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// returns the request input and output formats
+func (s *Service) formats(r *http.Request) (from, to Format, err error) {
+	var (
+		ok bool
+		q  = r.URL.Query()
+	)
+	if f := q.Get("from"); f != "" {
+		if from, ok = LookupInput(f, s.inputs()); !ok {
+			return from, to, errorf(http.StatusUnsupportedMediaType, "unsupported input format %q", f)
+		}
+	} else if from, ok = lookup(r.Header.Get("Content-Type"), s.inputs()); !ok {
+		return from, to, errorf(http.StatusUnsupportedMediaType, "unsupported content type %q", r.Header.Get("Content-Type"))
+	}
+	if t := q.Get("to"); t != "" {
+		for _, f := range s.outputs() {
+			if f.Conf.Format == t || f.MediaType == t {
+				return from, f, nil
+			}
+		}
+		return from, to, errorf(http.StatusNotAcceptable, "unsupported output format %q", t)
+	} else if to, ok = negotiate(r.Header.Get("Accept"), s.outputs()); !ok {
+		return from, to, errorf(http.StatusNotAcceptable, "none of accepted formats %q is supported", r.Header.Get("Accept"))
+	}
+	return from, to, nil
+}
+
+// Returns the input format of inputs named name, its pandoc format
+// optionally followed by extensions, e.g. "markdown+smart-raw_html", or
+// its media type. Other readers, such as Lua files, are not accepted.
+func LookupInput(name string, inputs []Format) (Format, bool) {
+	base, ext := name, ""
+	if i := strings.IndexAny(name, "+-"); i > 0 {
+		base, ext = name[:i], name[i:]
+	}
+	for _, f := range inputs {
+		if f.Conf.Format != base && f.MediaType != name {
+			continue
+		} else if f.MediaType == name {
+			return f, true
+		}
+		exts, ok := extensions(ext)
+		if !ok {
+			return Format{}, false
+		}
+		for _, e := range exts {
+			if e[0] == '+' {
+				f.Conf = f.Conf.WithExt(e[1:])
+			} else {
+				f.Conf = f.Conf.WithoutExt(e[1:])
+			}
+		}
+		return f, true
+	}
+	return Format{}, false
+}
+
+// splits extensions, e.g. "+smart-raw_html", reporting whether they are
+// well-formed
+func extensions(s string) ([]string, bool) {
+	var exts []string
+	for len(s) > 0 {
+		j := strings.IndexAny(s[1:], "+-") + 1
+		if j == 0 {
+			j = len(s)
+		}
+		name := s[1:j]
+		if name == "" || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+			return nil, false
+		}
+		exts = append(exts, s[:j])
+		s = s[j:]
+	}
+	return exts, true
+}
+
+// converts the request body to out; the media type of sw, if not nil,
+// is set once the output format is known
+func (s *Service) convert(r *http.Request, out io.Writer, sw *streamWriter) (Format, error) {
+	from, to, err := s.formats(r)
+	if err != nil {
+		return to, err
+	}
+	if sw != nil {
+		sw.mediaType = to.MediaType
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), limit(s.Timeout, DefaultTimeout))
+	defer cancel()
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-ctx.Done():
+			return to, ctx.Err()
+		}
+	}
+	body := s.body(r)
+	err = Convert(ctx, s.backend(), body, from, to, out, s.Transform)
+	if body.err != nil && body.err != io.EOF {
+		// the backend may not report why the input ended prematurely
+		return to, body.err
+	} else if err == nil {
+		err = ctx.Err()
+	}
+	return to, err
+}
+
+// Converts a document read from r in the format from and writes it to w
+// in the format to with the backend, applying transform, if not nil.
+func Convert(ctx context.Context, b Backend, r io.Reader, from, to Format, w io.Writer, transform func(*pandoc.Pandoc) (*pandoc.Pandoc, error)) error {
+	doc, err := b.Load(ctx, r, from.Conf)
+	if err != nil {
+		return err
+	}
+	if transform != nil {
+		if doc, err = transform(doc); err != nil {
+			return err
+		}
+	}
+	if to.File == "" {
+		return b.Store(ctx, doc, w, to.Conf)
+	} else if fs, ok := b.(FileStorer); ok {
+		return fs.StoreFile(ctx, doc, w, to.Conf, to.File)
+	} else {
+		return errorf(http.StatusNotAcceptable, "backend does not support %s", to.MediaType)
+	}
+}
+
+// ----------- metrics -------------
+
+type metrics struct {
+	mu        sync.Mutex
+	requests  int64
+	failures  int64
+	inflight  int64
+	bytesIn   int64
+	bytesOut  int64
+	totalTime time.Duration
+}
+
+func (m *metrics) started() {
+	m.mu.Lock()
+	m.requests++
+	m.inflight++
+	m.mu.Unlock()
+}
+
+func (m *metrics) finished(err error, in int64, out int, d time.Duration) {
+	m.mu.Lock()
+	m.inflight--
+	if err != nil {
+		m.failures++
+	}
+	if in > 0 {
+		m.bytesIn += in
+	}
+	m.bytesOut += int64(out)
+	m.totalTime += d
+	m.mu.Unlock()
+}
+
+func (m *metrics) serve(w http.ResponseWriter) {
+	m.mu.Lock()
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "pandoc_conversions_total %d\n", m.requests)
+	fmt.Fprintf(&sb, "pandoc_conversions_failed_total %d\n", m.failures)
+	fmt.Fprintf(&sb, "pandoc_conversions_in_flight %d\n", m.inflight)
+	fmt.Fprintf(&sb, "pandoc_input_bytes_total %d\n", m.bytesIn)
+	fmt.Fprintf(&sb, "pandoc_output_bytes_total %d\n", m.bytesOut)
+	fmt.Fprintf(&sb, "pandoc_conversion_seconds_total %g\n", m.totalTime.Seconds())
+	m.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = w.Write([]byte(sb.String()))
+}
+
+// ----------- jobs -------------
+
+func writeJob(w http.ResponseWriter, status int, job Job) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// POST /jobs: submits a job, the request is the same as for conversion
+func (s *Service) serveSubmit(w http.ResponseWriter, r *http.Request) {
+	from, to, err := s.formats(r)
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+	input, err := io.ReadAll(s.body(r))
+	if err != nil {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+	job, err := s.Jobs.Submit(r.Context(), input, from, to)
+	switch {
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrClosed):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		w.Header().Set("Location", "/jobs/"+job.ID)
+		writeJob(w, http.StatusAccepted, job)
+	}
+}
+
+// GET /jobs/{id}: returns the job status
+// GET /jobs/{id}/result: returns the job result
+// DELETE /jobs/{id}: cancels and deletes the job
+func (s *Service) serveJob(w http.ResponseWriter, r *http.Request) {
+	id, sub, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	var err error
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		var job Job
+		if job, err = s.Jobs.Status(r.Context(), id); err == nil {
+			writeJob(w, http.StatusOK, job)
+			return
+		}
+	case sub == "" && r.Method == http.MethodDelete:
+		if err = s.Jobs.Delete(r.Context(), id); err == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	case sub == "result" && r.Method == http.MethodGet:
+		var (
+			data []byte
+			job  Job
+		)
+		if data, job, err = s.Jobs.Result(r.Context(), id); err == nil {
+			w.Header().Set("Content-Type", job.MediaType)
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			_, _ = w.Write(data)
+			return
+		} else if job.State == JobFailed {
+			err = errorf(http.StatusUnprocessableEntity, "%s", job.Error)
+		}
+	case sub == "" || sub == "result":
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case errors.Is(err, ErrNoJob):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrNotDone), errors.Is(err, context.Canceled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), httpStatus(err))
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/growler/go-pandoc"
+)
+
+func TestNegotiate(t *testing.T) {
+	var tests = []struct {
+		accept string
+		want   string
+	}{
+		{"", "text/html"},
+		{"*/*", "text/html"},
+		{"application/pdf", "application/pdf"},
+		{"text/*", "text/html"},
+		{"text/*;q=0.5, text/markdown", "text/markdown"},
+		{"text/html;q=0, text/*", "text/markdown"},
+		{"text/html;q=0.1, application/json;q=0.9", "application/json"},
+		{"image/png", ""},
+	}
+	for _, tt := range tests {
+		f, ok := negotiate(tt.accept, DefaultOutputs)
+		if got := f.MediaType; got != tt.want || ok != (tt.want != "") {
+			t.Errorf("negotiate(%q) = %q, %v, want %q", tt.accept, got, ok, tt.want)
+		}
+	}
+}
+
+// a backend handling json only, waiting for timeout on "slow" format
+type fakeBackend struct{ healthy bool }
+
+func (b fakeBackend) Load(ctx context.Context, r io.Reader, conf pandoc.Conf) (*pandoc.Pandoc, error) {
+	return pandoc.ReadFrom(r)
+}
+
+func (b fakeBackend) Store(ctx context.Context, doc *pandoc.Pandoc, w io.Writer, conf pandoc.Conf) error {
+	if conf.Format == "slow" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return doc.WriteTo(w)
+}
+
+func (b fakeBackend) Check(ctx context.Context) error {
+	if !b.healthy {
+		return errors.New("unhealthy")
+	}
+	return nil
+}
+
+func TestService(t *testing.T) {
+	const doc = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Str","c":"Hello"}]}]}`
+	srv := &Service{
+		Backend:     fakeBackend{true},
+		MaxBodySize: 1024,
+		Timeout:     50 * time.Millisecond,
+		Outputs: []Format{
+			{MediaType: "application/json", Conf: pandoc.Format("json")},
+			{MediaType: "text/slow", Conf: pandoc.Format("slow")},
+		},
+	}
+	do := func(method, path, ctype, accept, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if ctype != "" {
+			r.Header.Set("Content-Type", ctype)
+		}
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, r)
+		return w
+	}
+	var tests = []struct {
+		method, path, ctype, accept, body string
+		status                            int
+	}{
+		{"POST", "/", "application/json", "application/json", doc, http.StatusOK},
+		{"POST", "/?from=json&to=json", "", "", doc, http.StatusOK},
+		{"POST", "/?from=application/json&to=json", "", "", doc, http.StatusOK},
+		{"POST", "/?from=reader.lua&to=json", "", "", doc, http.StatusUnsupportedMediaType},
+		{"POST", "/?from=/tmp/reader.lua", "application/json", "", doc, http.StatusUnsupportedMediaType},
+		{"POST", "/?from=org&to=json", "", "", doc, http.StatusUnsupportedMediaType},
+		{"POST", "/?from=json+x.lua&to=json", "", "", doc, http.StatusUnsupportedMediaType},
+		{"POST", "/", "image/png", "application/json", doc, http.StatusUnsupportedMediaType},
+		{"POST", "/", "application/json", "text/html", doc, http.StatusNotAcceptable},
+		{"POST", "/", "application/json", "", strings.Repeat(" ", 2048) + doc, http.StatusRequestEntityTooLarge},
+		{"POST", "/", "application/json", "", "{", http.StatusUnprocessableEntity},
+		{"POST", "/", "application/json", "text/slow", doc, http.StatusGatewayTimeout},
+		{"GET", "/", "", "", "", http.StatusMethodNotAllowed},
+		{"GET", "/healthz", "", "", "", http.StatusOK},
+		{"GET", "/nothing", "", "", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := do(tt.method, tt.path, tt.ctype, tt.accept, tt.body)
+		if w.Code != tt.status {
+			t.Errorf("%s %s (%s -> %s): status %d, want %d: %s", tt.method, tt.path, tt.ctype, tt.accept, w.Code, tt.status, w.Body.String())
+		}
+	}
+	if w := do("POST", "/", "application/json", "", doc); w.Body.String() != doc ||
+		w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %q (%s)", w.Body.String(), w.Header().Get("Content-Type"))
+	}
+	m := do("GET", "/metrics", "", "", "").Body.String()
+	if !strings.Contains(m, "pandoc_conversions_total 13\n") || !strings.Contains(m, "pandoc_conversions_failed_total 9\n") {
+		t.Errorf("unexpected metrics:\n%s", m)
+	}
+	srv.Backend = fakeBackend{false}
+	if w := do("GET", "/healthz", "", "", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unhealthy backend status %d", w.Code)
+	}
+}
+
+// a backend writing part of the output before failing on "broken" format
+type brokenBackend struct{ fakeBackend }
+
+func (b brokenBackend) Store(ctx context.Context, doc *pandoc.Pandoc, w io.Writer, conf pandoc.Conf) error {
+	if conf.Format == "broken" {
+		w.Write([]byte("{"))
+		return errors.New("broken")
+	}
+	return b.fakeBackend.Store(ctx, doc, w, conf)
+}
+
+func TestServiceStream(t *testing.T) {
+	const doc = `{"pandoc-api-version":[1,23,1],"meta":{},"blocks":[{"t":"Para","c":[{"t":"Str","c":"Hello"}]}]}`
+	srv := httptest.NewServer(&Service{
+		Backend: brokenBackend{fakeBackend{true}},
+		Timeout: 50 * time.Millisecond,
+		Stream:  true,
+		Outputs: []Format{
+			{MediaType: "application/json", Conf: pandoc.Format("json")},
+			{MediaType: "text/slow", Conf: pandoc.Format("slow")},
+			{MediaType: "text/broken", Conf: pandoc.Format("broken")},
+		},
+	})
+	defer srv.Close()
+	post := func(accept string) (*http.Response, string, error) {
+		req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(doc))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp, string(body), err
+	}
+	if resp, body, err := post("application/json"); err != nil || resp.StatusCode != http.StatusOK ||
+		body != doc || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("streamed conversion: %v, %v, %q", err, resp, body)
+	}
+	// failures before the output starts are reported
+	if resp, _, err := post("text/slow"); err != nil || resp.StatusCode != http.StatusGatewayTimeout {
+		t.Errorf("timeout: %v, %v", err, resp)
+	}
+	// failures after that abort the response
+	if _, _, err := post("text/broken"); err == nil {
+		t.Error("broken response not aborted")
+	}
+}
+
+func TestInputFormat(t *testing.T) {
+	srv := &Service{Inputs: []Format{
+		{MediaType: "text/markdown", Conf: pandoc.Format("markdown").WithoutExt("smart")},
+		{MediaType: "application/json", Conf: pandoc.Format("json")},
+	}}
+	for _, tt := range []struct {
+		from string
+		ok   bool
+		ext  string
+	}{
+		{"markdown", true, "-smart"},
+		{"markdown+smart-raw_html", true, "+smart-raw_html"},
+		{"text/markdown", true, "-smart"},
+		{"markdown+", false, ""},
+		{"markdown+a b", false, ""},
+		{"rst", false, ""},
+		{"custom.lua", false, ""},
+		{"./markdown", false, ""},
+	} {
+		f, ok := LookupInput(tt.from, srv.Inputs)
+		if ok != tt.ok {
+			t.Errorf("%s: accepted %v, want %v", tt.from, ok, tt.ok)
+		} else if ok && strings.Join(f.Conf.Ext, "") != tt.ext {
+			t.Errorf("%s: extensions %q, want %s", tt.from, f.Conf.Ext, tt.ext)
+		}
+	}
+	if exts := srv.Inputs[0].Conf.Ext; len(exts) != 1 {
+		t.Errorf("input format is changed: %q", exts)
+	}
+}
@@ -0,0 +1,165 @@
+package pandoc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A Session is an editing session of a document, keeping the history of
+// applied changes for undo and redo. Each change is recorded as the
+// edit script of the top-level blocks (see DiffList), so unchanged
+// blocks are shared between revisions.
+//
+// Since revisions share elements, transformers applied to a session must
+// not modify elements in place, but return modified copies, as filter
+// functions do (see Filter and Clone).
+//
+// Example:
+//
+//	s := pandoc.NewSession(doc)
+//	s.Checkpoint("loaded")
+//	if err := s.Apply("strip notes", stripNotes); err != nil {
+//	    ...
+//	}
+//	s.Undo()
+//	s.Redo()
+//	s.Restore("loaded")
+type Session struct {
+	doc         *Pandoc
+	undo, redo  []revision
+	seq         int
+	checkpoints map[string]int
+}
+
+// a recorded change
+type revision struct {
+	id               int
+	name             string
+	edits            []Edit[Block]
+	oldMeta, newMeta Meta
+}
+
+var ErrNoCheckpoint = errors.New("no such checkpoint")
+
+// Makes a new session editing document doc.
+func NewSession(doc *Pandoc) *Session {
+	return &Session{
+		doc:         &Pandoc{Meta: doc.Meta, Blocks: doc.Blocks, Version: doc.Version},
+		checkpoints: make(map[string]int),
+	}
+}
+
+// Returns the current revision of the document. The returned document
+// is not changed by the subsequent session operations.
+func (s *Session) Document() *Pandoc {
+	return s.doc
+}
+
+// Applies transformers to the document and records the change under
+// name. If a transformer fails, the document is left unchanged. Applying
+// a change discards the redo history.
+func (s *Session) Apply(name string, transformer ...func(*Pandoc) (*Pandoc, error)) error {
+	doc := &Pandoc{Meta: append(Meta(nil), s.doc.Meta...), Blocks: s.doc.Blocks, Version: s.doc.Version}
+	doc, err := apply(doc, transformer...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	s.seq++
+	s.undo = append(s.undo, revision{
+		id:      s.seq,
+		name:    name,
+		edits:   Diff(s.doc, doc),
+		oldMeta: s.doc.Meta,
+		newMeta: append(Meta(nil), doc.Meta...),
+	})
+	s.redo = nil
+	s.doc = &Pandoc{Meta: s.undo[len(s.undo)-1].newMeta, Blocks: doc.Blocks, Version: doc.Version}
+	return nil
+}
+
+// Reverts the last change. Returns false if there is nothing to undo.
+func (s *Session) Undo() bool {
+	if len(s.undo) == 0 {
+		return false
+	}
+	r := s.undo[len(s.undo)-1]
+	blocks, err := Patch(s.doc.Blocks, Invert(r.edits))
+	if err != nil {
+		panic("pandoc: session history is corrupted: " + err.Error())
+	}
+	s.undo = s.undo[:len(s.undo)-1]
+	s.redo = append(s.redo, r)
+	s.doc = &Pandoc{Meta: r.oldMeta, Blocks: blocks, Version: s.doc.Version}
+	return true
+}
+
+// Reapplies the last undone change. Returns false if there is nothing
+// to redo.
+func (s *Session) Redo() bool {
+	if len(s.redo) == 0 {
+		return false
+	}
+	r := s.redo[len(s.redo)-1]
+	blocks, err := Patch(s.doc.Blocks, r.edits)
+	if err != nil {
+		panic("pandoc: session history is corrupted: " + err.Error())
+	}
+	s.redo = s.redo[:len(s.redo)-1]
+	s.undo = append(s.undo, r)
+	s.doc = &Pandoc{Meta: r.newMeta, Blocks: blocks, Version: s.doc.Version}
+	return true
+}
+
+// Returns names of changes that can be undone, oldest first.
+func (s *Session) History() []string {
+	names := make([]string, len(s.undo))
+	for i, r := range s.undo {
+		names[i] = r.name
+	}
+	return names
+}
+
+// Returns the id of the current revision.
+func (s *Session) current() int {
+	if len(s.undo) == 0 {
+		return 0
+	}
+	return s.undo[len(s.undo)-1].id
+}
+
+// Marks the current revision with name, replacing a previous checkpoint
+// with the same name.
+func (s *Session) Checkpoint(name string) {
+	s.checkpoints[name] = s.current()
+}
+
+// Restores the revision marked with the named checkpoint by undoing
+// or redoing changes. Returns ErrNoCheckpoint if the checkpoint does
+// not exist or is not reachable anymore, since the changes after it
+// were undone and replaced by new ones.
+func (s *Session) Restore(name string) error {
+	id, ok := s.checkpoints[name]
+	if !ok {
+		return ErrNoCheckpoint
+	}
+	if id != 0 && !hasRevision(s.undo, id) && !hasRevision(s.redo, id) {
+		delete(s.checkpoints, name)
+		return ErrNoCheckpoint
+	}
+	for s.current() != id && (id == 0 || hasRevision(s.undo, id)) {
+		s.Undo()
+	}
+	for s.current() != id {
+		s.Redo()
+	}
+	return nil
+}
+
+func hasRevision(revs []revision, id int) bool {
+	for _, r := range revs {
+		if r.id == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSession(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Para{words("one")},
+		&Para{words("two")},
+		&Para{words("three")},
+	}}
+	text := func(s *Session) string {
+		return strings.ReplaceAll(Stringify(s.Document()), "\n", ",")
+	}
+	drop := func(w string) func(*Pandoc) (*Pandoc, error) {
+		return Transformer[*Pandoc](func(p *Para) ([]Block, error) {
+			if Stringify(p) == w {
+				return nil, ReplaceSkip
+			}
+			return nil, Skip
+		})
+	}
+	upper := Transformer[*Pandoc](func(s *Str) ([]Inline, error) {
+		return []Inline{&Str{strings.ToUpper(s.Text)}}, ReplaceSkip
+	})
+	s := NewSession(doc)
+	s.Checkpoint("start")
+	if err := s.Apply("drop two", drop("two")); err != nil {
+		t.Fatal(err)
+	}
+	s.Checkpoint("dropped")
+	if err := s.Apply("upper", upper); err != nil {
+		t.Fatal(err)
+	}
+	if got := text(s); got != "ONE,THREE" {
+		t.Fatalf("unexpected %q", got)
+	}
+	if got := strings.Join(s.History(), ","); got != "drop two,upper" {
+		t.Errorf("unexpected history %q", got)
+	}
+	if !s.Undo() || text(s) != "one,three" {
+		t.Errorf("undo: unexpected %q", text(s))
+	}
+	if !s.Undo() || text(s) != "one,two,three" {
+		t.Errorf("undo: unexpected %q", text(s))
+	}
+	if s.Undo() {
+		t.Errorf("undo beyond the start")
+	}
+	if !s.Redo() || text(s) != "one,three" {
+		t.Errorf("redo: unexpected %q", text(s))
+	}
+	if err := s.Restore("start"); err != nil || text(s) != "one,two,three" {
+		t.Errorf("restore: unexpected %q (%v)", text(s), err)
+	}
+	if err := s.Restore("dropped"); err != nil || text(s) != "one,three" {
+		t.Errorf("restore: unexpected %q (%v)", text(s), err)
+	}
+	if got := Stringify(doc); got != "one\ntwo\nthree" {
+		t.Errorf("original document is modified: %q", got)
+	}
+	s.Restore("start")
+	if err := s.Apply("drop one", drop("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Restore("dropped"); err != ErrNoCheckpoint {
+		t.Errorf("unreachable checkpoint is restored")
+	}
+	if s.Redo() {
+		t.Errorf("redo after apply")
+	}
+}
+
+func TestPatch(t *testing.T) {
+	a, b := words("a b c d"), words("b x d e")
+	edits := DiffList(a, b)
+	if got, err := Patch(a, edits); err != nil || Sprint(&Para{got}) != Sprint(&Para{b}) {
+		t.Errorf("Patch: unexpected %v (%v)", Stringify(&Para{got}), err)
+	}
+	if got, err := Patch(b, Invert(edits)); err != nil || Sprint(&Para{got}) != Sprint(&Para{a}) {
+		t.Errorf("Patch: unexpected %v (%v)", Stringify(&Para{got}), err)
+	}
+	if _, err := Patch(a[1:], edits); err != ErrPatchMismatch {
+		t.Errorf("Patch: mismatch is not detected")
+	}
+}
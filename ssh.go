@@ -0,0 +1,72 @@
+package pandoc
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// SSHRunner is a Runner running pandoc on a remote host with the ssh
+// client. The document is piped through the connection, the exit status
+// and standard error of the remote pandoc are reported as if pandoc were
+// run locally; the exit status 255 is an ssh failure. Conf.Dir and
+// Conf.Pandoc, as well as files given to LoadFiles or StoreFile, are
+// paths on the remote host.
+//
+// ssh is run with BatchMode, so the host must be reachable without
+// prompts, e.g. with an ssh agent or a key in ssh config.
+//
+// Example:
+//
+//	conf := pandoc.Format("pdf").WithRunner(pandoc.SSHRunner{Host: "render@render-host"})
+type SSHRunner struct {
+	Host string   // Destination, [user@]host
+	SSH  string   // Path to ssh executable, defaults to "ssh" in PATH
+	Opts []string // Additional ssh options, e.g. "-p", "2222"
+}
+
+func (r SSHRunner) Start(ctx context.Context, c *Command) (Process, error) {
+	ssh := r.SSH
+	if ssh == "" {
+		ssh = "ssh"
+	}
+	ssh, err := exec.LookPath(ssh)
+	if err != nil {
+		return nil, err
+	}
+	args := append(append([]string{"-T", "-o", "BatchMode=yes"}, r.Opts...), "--", r.Host, remoteCommand(c))
+	return startExec(exec.CommandContext(ctx, ssh, args...), c)
+}
+
+// returns the shell command running c on the remote host
+func remoteCommand(c *Command) string {
+	var sb strings.Builder
+	sb.WriteString(c.Limits.ulimit())
+	if c.Dir != "" {
+		sb.WriteString("cd " + shellQuote(c.Dir) + " && ")
+	}
+	sb.WriteString("exec ")
+	if c.ClearEnv || len(c.Env) > 0 {
+		sb.WriteString("env")
+		if c.ClearEnv {
+			sb.WriteString(" -i")
+		}
+		for _, env := range c.Env {
+			sb.WriteString(" " + shellQuote(env))
+		}
+		sb.WriteString(" ")
+	}
+	if c.Pandoc != "" {
+		sb.WriteString(shellQuote(c.Pandoc))
+	} else {
+		sb.WriteString("pandoc")
+	}
+	for _, arg := range c.Args {
+		sb.WriteString(" " + shellQuote(arg))
+	}
+	return sb.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
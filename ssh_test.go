@@ -0,0 +1,64 @@
+package pandoc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSSHRunner(t *testing.T) {
+	var (
+		dir = t.TempDir()
+		ssh = filepath.Join(dir, "ssh")
+	)
+	// a fake ssh running the remote command locally
+	script := `#!/bin/sh
+while [ "$1" != -- ]; do shift; done
+[ "$2" = render-host ] || exit 255
+exec sh -c "$3"
+`
+	if err := os.WriteFile(ssh, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	conf := Format("json").WithPandoc(fakePandoc(t)).WithDir(dir).WithRunner(SSHRunner{Host: "render-host", SSH: ssh})
+	doc := &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"it's"}}}}}
+	var out bytes.Buffer
+	if err := doc.StoreTo(&out, conf); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := LoadFrom(&out, conf); err != nil {
+		t.Fatal(err)
+	} else if s := Stringify(res); s != "it's" {
+		t.Errorf("unexpected document %q", s)
+	}
+
+	doc = &Pandoc{Blocks: []Block{&Para{[]Inline{&Str{"FAIL"}}}}}
+	var (
+		perr *PandocError
+		eerr *exec.ExitError
+	)
+	err := doc.StoreTo(io.Discard, conf)
+	if !errors.As(err, &perr) || !strings.Contains(perr.Stderr, "fake pandoc failed") || !errors.As(err, &eerr) || eerr.ExitCode() != 1 {
+		t.Errorf("unexpected error %v", err)
+	}
+	conf.Runner = SSHRunner{Host: "unknown", SSH: ssh}
+	if err := doc.StoreTo(io.Discard, conf); !errors.As(err, &eerr) || eerr.ExitCode() != 255 {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRemoteCommand(t *testing.T) {
+	c := &Command{Dir: "/srv/my docs", Env: []string{"A=1"}, Args: []string{"-fjson", "it's"}}
+	if s := remoteCommand(c); s != `cd '/srv/my docs' && exec env 'A=1' pandoc '-fjson' 'it'\''s'` {
+		t.Errorf("unexpected command %s", s)
+	}
+	c = &Command{Env: []string{"LANG=C"}, ClearEnv: true, Limits: Limits{Files: 64}}
+	if s := remoteCommand(c); s != `ulimit -n 64 && exec env -i 'LANG=C' pandoc` {
+		t.Errorf("unexpected command %s", s)
+	}
+}
@@ -0,0 +1,234 @@
+package pandoc
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Options of TOC.
+type TOCOptions struct {
+	// The deepest level of headers listed, 3 if zero, as pandoc
+	// --toc-depth.
+	Depth int
+
+	// Prefix entries with section numbers, e.g. "1.2", in a Span with the
+	// class "toc-section-number", as pandoc --number-sections does. The
+	// number of a header is the "number" attribute set by NumberHeadings,
+	// if any, and its number in the header text is dropped.
+	Number bool
+
+	// Class of the Div the table of contents is put in, replacing the
+	// content of the Div, "toc" if empty. Without such Div the table of
+	// contents is inserted at the top of the document.
+	Marker string
+}
+
+// Returns a transformer adding to the document a table of contents, a
+// nested BulletList of links to headers, which do not have to be
+// top-level blocks. As with pandoc, headers of the class "unlisted" are
+// not listed, with their subsections, and headers of the class
+// "unnumbered" are not numbered. Entries of headers without identifiers
+// are not links. Footnotes and links of headers are dropped from the
+// entries.
+//
+// Example:
+//
+//	doc, err = doc.Apply(pandoc.TOC(pandoc.TOCOptions{Depth: 2}))
+func TOC(opts TOCOptions) func(*Pandoc) (*Pandoc, error) {
+	if opts.Depth == 0 {
+		opts.Depth = 3
+	}
+	if opts.Marker == "" {
+		opts.Marker = "toc"
+	}
+	return func(doc *Pandoc) (*Pandoc, error) {
+		list, err := tocList(doc, opts)
+		if err != nil || list == nil {
+			return doc, err
+		}
+		var marked bool
+		doc, err = Filter(doc, func(d *Div) ([]Block, error) {
+			if !d.HasClass(opts.Marker) {
+				return nil, Continue
+			}
+			marked = true
+			d = Clone(d)
+			d.Blocks = []Block{list}
+			return []Block{d}, ReplaceSkip
+		})
+		if err != nil || marked {
+			return doc, err
+		}
+		doc = Clone(doc)
+		doc.Blocks = append([]Block{list}, doc.Blocks...)
+		return doc, nil
+	}
+}
+
+// an entry of a table of contents
+type tocEntry struct {
+	level    int
+	item     Block
+	children []*tocEntry
+}
+
+// returns the table of contents of the document, nil if it is empty
+func tocList(doc *Pandoc, opts TOCOptions) (Block, error) {
+	var (
+		root    = &tocEntry{}
+		stack   = []*tocEntry{root}
+		numbers headerNumbers
+		skip    int // level of the unlisted section, 0 if none
+	)
+	err := QueryE(&Pandoc{Blocks: doc.Blocks}, func(b Block) error {
+		if d, ok := b.(*Div); ok && d.HasClass(opts.Marker) {
+			return Skip
+		}
+		h, ok := b.(*Header)
+		if !ok {
+			return nil
+		}
+		num := numbers.next(h)
+		if skip > 0 && h.Level > skip {
+			return nil
+		}
+		skip = 0
+		if h.HasClass("unlisted") {
+			skip = h.Level
+			return nil
+		} else if h.Level > opts.Depth {
+			return nil
+		}
+		entry, err := tocItem(h, num, opts)
+		if err != nil {
+			return err
+		}
+		for len(stack) > 1 && stack[len(stack)-1].level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		e := &tocEntry{level: h.Level, item: entry}
+		parent.children = append(parent.children, e)
+		stack = append(stack, e)
+		return nil
+	})
+	if err != nil || len(root.children) == 0 {
+		return nil, err
+	}
+	return root.list(), nil
+}
+
+// returns the entry of the header h with the number num
+func tocItem(h *Header, num string, opts TOCOptions) (Block, error) {
+	numbered := opts.Number && num != ""
+	inlines := h.Inlines
+	if numbered && len(inlines) > 0 {
+		// the number put by NumberHeadings is replaced by the one of the
+		// entry
+		if s, ok := inlines[0].(*Span); ok && s.HasClass("header-section-number") {
+			inlines = inlines[1:]
+			if len(inlines) > 0 && inlines[0].Tag() == SpaceTag {
+				inlines = inlines[1:]
+			}
+		}
+	}
+	text, err := Filter(&Span{Inlines: inlines}, func(i Inline) ([]Inline, error) {
+		switch i := i.(type) {
+		case *Note:
+			return nil, Delete
+		case *Link:
+			return i.Inlines, ReplaceContinue
+		}
+		return nil, Continue
+	})
+	if err != nil {
+		return nil, err
+	}
+	inlines = text.Inlines
+	if numbered {
+		inlines = append([]Inline{
+			&Span{Attr: Attr{Classes: []string{"toc-section-number"}}, Inlines: []Inline{&Str{Text: num}}},
+			&Space{},
+		}, inlines...)
+	}
+	if h.Ident() != "" {
+		inlines = []Inline{&Link{Inlines: inlines, Target: Target{Url: "#" + h.Ident()}}}
+	}
+	return &Plain{Inlines: inlines}, nil
+}
+
+func (e *tocEntry) list() Block {
+	items := make([][]Block, len(e.children))
+	for i, c := range e.children {
+		items[i] = []Block{c.item}
+		if len(c.children) > 0 {
+			items[i] = append(items[i], c.list())
+		}
+	}
+	return &BulletList{Items: items}
+}
+
+// Options of NumberHeadings.
+type NumberOptions struct {
+	// The deepest level of headers numbered, all if zero.
+	Depth int
+}
+
+// Numbers headers of the document hierarchically, e.g. "1.2.3" for the
+// third header of level 3 in the second section of level 2 of the first
+// one, for writers not numbering sections themselves. The number is put
+// before the header text in a Span with the class
+// "header-section-number" and in the attribute "number", as pandoc
+// --number-sections does, for cross-references, see also TOC. Headers of
+// the class "unnumbered" are not numbered, nor are headers numbered
+// already, which keep their numbers. Headers nested in other blocks,
+// such as Divs, are numbered in document order.
+//
+// Example:
+//
+//	doc, err = pandoc.NumberHeadings(doc, pandoc.NumberOptions{Depth: 3})
+func NumberHeadings(doc *Pandoc, opts NumberOptions) (*Pandoc, error) {
+	var numbers headerNumbers
+	body, err := Filter(&Pandoc{Blocks: doc.Blocks}, func(h *Header) ([]Block, error) {
+		num := numbers.next(h)
+		if _, ok := h.Get("number"); ok || num == "" || (opts.Depth > 0 && h.Level > opts.Depth) {
+			return nil, Skip
+		}
+		h = Clone(h)
+		h.Attr = h.WithKV("number", num)
+		h.Inlines = append([]Inline{
+			&Span{Attr: Attr{Classes: []string{"header-section-number"}}, Inlines: []Inline{&Str{Text: num}}},
+			&Space{},
+		}, h.Inlines...)
+		return []Block{h}, ReplaceSkip
+	})
+	if err != nil {
+		return nil, err
+	}
+	doc = Clone(doc)
+	doc.Blocks = body.Blocks
+	return doc, nil
+}
+
+// hierarchical numbers of headers in document order
+type headerNumbers struct {
+	counts [6]int
+}
+
+// returns the number of the next header, e.g. "1.2", empty if it is
+// not numbered; the "number" attribute of the header takes precedence
+func (n *headerNumbers) next(h *Header) string {
+	if h.HasClass("unnumbered") || h.Level < 1 || h.Level > len(n.counts) {
+		return ""
+	}
+	n.counts[h.Level-1]++
+	clear(n.counts[h.Level:])
+	if num, ok := h.Get("number"); ok {
+		return num
+	}
+	parts := make([]string, h.Level)
+	for i := range parts {
+		parts[i] = strconv.Itoa(n.counts[i])
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,124 @@
+package pandoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTOC(t *testing.T) {
+	header := func(level int, id string, classes ...string) Block {
+		return &Header{Attr: Attr{Id: id, Classes: classes}, Level: level, Inlines: words(strings.ToUpper(id))}
+	}
+	// renders the table of contents as indented lines
+	var render func(b Block, indent string) string
+	render = func(b Block, indent string) string {
+		var s string
+		for _, item := range b.(*BulletList).Items {
+			var url string
+			Query(item[0], func(l *Link) { url = l.Target.Url })
+			s += indent + strings.TrimSpace(Stringify(item[0])+" "+url) + "\n"
+			if len(item) > 1 {
+				s += render(item[1], indent+"  ")
+			}
+		}
+		return s
+	}
+	doc := &Pandoc{Blocks: []Block{
+		header(1, "one"),
+		&Para{Inlines: words("text")},
+		header(2, "sub"),
+		header(3, "deep"),
+		header(2, "hidden", "unlisted"),
+		header(3, "under-hidden"),
+		&Div{Blocks: []Block{header(2, "nested", "unnumbered")}},
+		header(1, "two"),
+		&Header{Level: 3, Inlines: words("NO ID")},
+	}}
+	before := Sprint(doc)
+	got, err := doc.Apply(TOC(TOCOptions{Depth: 2, Number: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(doc) != before {
+		t.Errorf("document is changed")
+	}
+	want := `1 ONE #one
+  1.1 SUB #sub
+  NESTED #nested
+2 TWO #two
+`
+	if len(got.Blocks) != len(doc.Blocks)+1 {
+		t.Fatalf("got %d blocks, want the table of contents at the top", len(got.Blocks))
+	} else if s := render(got.Blocks[0], ""); s != want {
+		t.Errorf("got\n%s\nwant\n%s", s, want)
+	}
+
+	doc.Blocks = append([]Block{&Div{Attr: Attr{Classes: []string{"toc"}}, Blocks: []Block{&Para{Inlines: words("placeholder")}}}}, doc.Blocks...)
+	got, err = doc.Apply(TOC(TOCOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `ONE #one
+  SUB #sub
+    DEEP #deep
+  NESTED #nested
+TWO #two
+  NO ID
+`
+	if len(got.Blocks) != len(doc.Blocks) {
+		t.Fatalf("got %d blocks, want the table of contents in the marker", len(got.Blocks))
+	} else if s := render(got.Blocks[0].(*Div).Blocks[0], ""); s != want {
+		t.Errorf("got\n%s\nwant\n%s", s, want)
+	}
+}
+
+func TestNumberHeadings(t *testing.T) {
+	header := func(level int, id string, classes ...string) Block {
+		return &Header{Attr: Attr{Id: id, Classes: classes}, Level: level, Inlines: words(id)}
+	}
+	doc := &Pandoc{Blocks: []Block{
+		header(1, "a"),
+		header(2, "b"),
+		header(2, "c", "unnumbered"),
+		header(3, "d"),
+		header(4, "deep"),
+		&Div{Blocks: []Block{header(1, "e")}},
+		header(2, "f"),
+	}}
+	before := Sprint(doc)
+	got, err := NumberHeadings(doc, NumberOptions{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(doc) != before {
+		t.Errorf("document is changed")
+	}
+	var texts, numbers []string
+	Query(got, func(h *Header) {
+		num, _ := h.Get("number")
+		texts, numbers = append(texts, Stringify(h)), append(numbers, num)
+	})
+	wantTexts := []string{"1 a", "1.1 b", "c", "1.1.1 d", "deep", "2 e", "2.1 f"}
+	wantNumbers := []string{"1", "1.1", "", "1.1.1", "", "2", "2.1"}
+	if strings.Join(texts, ",") != strings.Join(wantTexts, ",") || strings.Join(numbers, ",") != strings.Join(wantNumbers, ",") {
+		t.Errorf("got headers %q numbered %q, want %q numbered %q", texts, numbers, wantTexts, wantNumbers)
+	}
+	again, err := NumberHeadings(got, NumberOptions{Depth: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(again) != Sprint(got) {
+		t.Errorf("numbered headers are numbered again: %s", Sprint(again))
+	}
+	toc, err := got.Apply(TOC(TOCOptions{Depth: 1, Number: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []string
+	for _, item := range toc.Blocks[0].(*BulletList).Items {
+		entries = append(entries, Stringify(item[0]))
+	}
+	if want := []string{"1 a", "2 e"}; strings.Join(entries, ",") != strings.Join(want, ",") {
+		t.Errorf("got entries %q of numbered headers, want %q", entries, want)
+	}
+}
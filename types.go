@@ -12,15 +12,26 @@ import (
 // Implemented Pandoc protocol version.
 const Version = "1.23.1"
 
-var _Version = func() []int {
-	c := strings.Split(Version, ".")
+// The oldest Pandoc protocol version accepted by ReadFrom. Versions
+// between MinVersion and Version differ only by constructs added later,
+// such as Figure, and the simple table representation of versions
+// before 1.21, which is read as Table.
+const MinVersion = "1.17"
+
+var (
+	_Version    = parseVersionConst(Version)
+	_MinVersion = parseVersionConst(MinVersion)
+)
+
+func parseVersionConst(s string) []int {
+	c := strings.Split(s, ".")
 	v := make([]int, len(c))
 	for i, s := range c {
 		n, _ := strconv.ParseInt(s, 10, 64)
 		v[i] = int(n)
 	}
 	return v
-}()
+}
 
 // A convenience function to check if an element is of a particular type.
 //
@@ -46,6 +57,30 @@ type Element interface {
 	writable
 	element()
 	clone() Element
+
+	// Returns a pointer to the i-th field of the element content, in the
+	// order of its JSON encoding, nil if there are no more. Fields
+	// holding children, such as *[]Inline, are walked in order, other
+	// ones, such as *Attr or *string, are skipped. The document and
+	// citations return their fields in the order of the JSON object.
+	Field(i int) any
+}
+
+// returns the i-th of the fields, nil if there are no more
+func nth(i int, fields ...any) any {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return nil
+}
+
+// reports whether a field of element content holds no children
+func leaf(field any) bool {
+	switch field.(type) {
+	case *Attr, *ListAttrs, *Target, *ColWidth, *QuoteType, *MathType, *CitationMode, *Alignment, *string, *int:
+		return true
+	}
+	return false
 }
 
 type inlinesContainer interface {
@@ -105,9 +140,15 @@ type MetaValue interface {
 type Pandoc struct {
 	Meta   Meta
 	Blocks []Block
+
+	// Pandoc-types API version the document was read with, written back
+	// as is, converting constructs the version does not support (see
+	// WriteOptions). If nil, Version is written.
+	Version []int
 }
 
-func (p *Pandoc) element() {}
+func (p *Pandoc) element()        {}
+func (p *Pandoc) Field(i int) any { return nth(i, &p.Meta, &p.Blocks) }
 func (p *Pandoc) clone() Element {
 	c := *p
 	return &c
@@ -124,6 +165,7 @@ type MetaMapEntry struct {
 }
 
 func (m MetaMapEntry) element()       {}
+func (MetaMapEntry) Field(int) any    { return nil } // the value is walked by walkChildren
 func (m MetaMapEntry) clone() Element { return m }
 
 // Pandoc's Meta
@@ -188,8 +230,9 @@ func (m *MetaMap) clone() Element {
 	c := *m
 	return &c
 }
-func (m *MetaMap) element() {}
-func (m *MetaMap) meta()    {}
+func (m *MetaMap) element()        {}
+func (m *MetaMap) Field(i int) any { return nth(i, &m.Entries) }
+func (m *MetaMap) meta()           {}
 
 // Returns a value of the given key or nil if the key is not present.
 func (m *MetaMap) Get(key string) MetaValue {
@@ -213,8 +256,9 @@ func (m *MetaList) clone() Element {
 	c := *m
 	return &c
 }
-func (m *MetaList) element() {}
-func (m *MetaList) meta()    {}
+func (m *MetaList) element()        {}
+func (m *MetaList) Field(i int) any { return nth(i, &m.Entries) }
+func (m *MetaList) meta()           {}
 
 // Pandoc document metadata inlines block
 type MetaInlines struct {
@@ -229,8 +273,9 @@ func (m *MetaInlines) clone() Element {
 	c := *m
 	return &c
 }
-func (m *MetaInlines) element() {}
-func (m *MetaInlines) meta()    {}
+func (m *MetaInlines) element()        {}
+func (m *MetaInlines) Field(i int) any { return nth(i, &m.Inlines) }
+func (m *MetaInlines) meta()           {}
 func (m *MetaInlines) Text() string {
 	var sb strings.Builder
 	walkList(m.Inlines, func(i Inline) ([]Inline, error) {
@@ -247,7 +292,7 @@ func (m *MetaInlines) Text() string {
 			return nil, Skip
 		}
 		return nil, Continue
-	})
+	}, walker{})
 	return sb.String()
 }
 
@@ -264,8 +309,9 @@ func (m *MetaBlocks) clone() Element {
 	c := *m
 	return &c
 }
-func (m *MetaBlocks) element() {}
-func (m *MetaBlocks) meta()    {}
+func (m *MetaBlocks) element()        {}
+func (m *MetaBlocks) Field(i int) any { return nth(i, &m.Blocks) }
+func (m *MetaBlocks) meta()           {}
 
 // Pandoc document metadata boolean
 type MetaBool bool
@@ -275,6 +321,7 @@ const MetaBoolTag = Tag("MetaBool")
 func (b MetaBool) Tag() Tag       { return MetaBoolTag }
 func (b MetaBool) clone() Element { return b }
 func (b MetaBool) element()       {}
+func (MetaBool) Field(int) any    { return nil }
 func (b MetaBool) meta()          {}
 
 // Pandoc document metadata string
@@ -286,6 +333,7 @@ func (MetaString) Tag() Tag         { return MetaStringTag }
 func (s MetaString) clone() Element { return s }
 func (s MetaString) String() string { return string(s) }
 func (MetaString) element()         {}
+func (MetaString) Field(int) any    { return nil }
 func (MetaString) meta()            {}
 
 // Pandoc elements attribute' key-value pair.
@@ -437,8 +485,9 @@ func (s *Str) clone() Element {
 	c := *s
 	return &c
 }
-func (s *Str) inline()  {}
-func (s *Str) element() {}
+func (s *Str) inline()         {}
+func (s *Str) element()        {}
+func (s *Str) Field(i int) any { return nth(i, &s.Text) }
 
 // Emphasized text (list of inlines)
 type Emph struct {
@@ -453,8 +502,9 @@ func (e *Emph) clone() Element {
 	c := *e
 	return &c
 }
-func (e *Emph) inline()  {}
-func (e *Emph) element() {}
+func (e *Emph) inline()         {}
+func (e *Emph) element()        {}
+func (e *Emph) Field(i int) any { return nth(i, &e.Inlines) }
 func (e *Emph) Apply(transformers ...func(*Emph) (*Emph, error)) (*Emph, error) {
 	return apply(e, transformers...)
 }
@@ -473,7 +523,8 @@ func (u *Underline) clone() Element {
 	c := *u
 	return &c
 }
-func (u *Underline) element() {}
+func (u *Underline) element()        {}
+func (u *Underline) Field(i int) any { return nth(i, &u.Inlines) }
 func (u *Underline) Apply(transformers ...func(*Underline) (*Underline, error)) (*Underline, error) {
 	return apply(u, transformers...)
 }
@@ -492,7 +543,8 @@ func (s *Strong) clone() Element {
 	c := *s
 	return &c
 }
-func (s *Strong) element() {}
+func (s *Strong) element()        {}
+func (s *Strong) Field(i int) any { return nth(i, &s.Inlines) }
 func (s *Strong) Apply(transformers ...func(*Strong) (*Strong, error)) (*Strong, error) {
 	return apply(s, transformers...)
 }
@@ -511,7 +563,8 @@ func (s *Strikeout) clone() Element {
 	c := *s
 	return &c
 }
-func (s *Strikeout) element() {}
+func (s *Strikeout) element()        {}
+func (s *Strikeout) Field(i int) any { return nth(i, &s.Inlines) }
 func (s *Strikeout) Apply(transformers ...func(*Strikeout) (*Strikeout, error)) (*Strikeout, error) {
 	return apply(s, transformers...)
 }
@@ -529,8 +582,9 @@ func (s *Superscript) clone() Element {
 	c := *s
 	return &c
 }
-func (s *Superscript) inline()  {}
-func (s *Superscript) element() {}
+func (s *Superscript) inline()         {}
+func (s *Superscript) element()        {}
+func (s *Superscript) Field(i int) any { return nth(i, &s.Inlines) }
 func (s *Superscript) Apply(transformers ...func(*Superscript) (*Superscript, error)) (*Superscript, error) {
 	return apply(s, transformers...)
 }
@@ -549,7 +603,8 @@ func (s *Subscript) clone() Element {
 	c := *s
 	return &c
 }
-func (s *Subscript) element() {}
+func (s *Subscript) element()        {}
+func (s *Subscript) Field(i int) any { return nth(i, &s.Inlines) }
 func (s *Subscript) Apply(transformers ...func(*Subscript) (*Subscript, error)) (*Subscript, error) {
 	return apply(s, transformers...)
 }
@@ -568,7 +623,8 @@ func (s *SmallCaps) clone() Element {
 	c := *s
 	return &c
 }
-func (s *SmallCaps) element() {}
+func (s *SmallCaps) element()        {}
+func (s *SmallCaps) Field(i int) any { return nth(i, &s.Inlines) }
 func (s *SmallCaps) Apply(transformers ...func(*SmallCaps) (*SmallCaps, error)) (*SmallCaps, error) {
 	return apply(s, transformers...)
 }
@@ -595,7 +651,8 @@ func (q *Quoted) clone() Element {
 	c := *q
 	return &c
 }
-func (q *Quoted) element() {}
+func (q *Quoted) element()        {}
+func (q *Quoted) Field(i int) any { return nth(i, &q.QuoteType, &q.Inlines) }
 func (q *Quoted) Apply(transformers ...func(*Quoted) (*Quoted, error)) (*Quoted, error) {
 	return apply(q, transformers...)
 }
@@ -618,6 +675,9 @@ type Citation struct {
 }
 
 func (c *Citation) element() {}
+func (c *Citation) Field(i int) any {
+	return nth(i, &c.Id, &c.Prefix, &c.Suffix, &c.Mode, &c.NoteNum, &c.Hash)
+}
 func (c *Citation) clone() Element {
 	c1 := *c
 	return &c1
@@ -641,7 +701,8 @@ func (c *Cite) clone() Element {
 	c2 := *c
 	return &c2
 }
-func (c *Cite) element() {}
+func (c *Cite) element()        {}
+func (c *Cite) Field(i int) any { return nth(i, &c.Citations, &c.Inlines) }
 func (c *Cite) Apply(transformers ...func(*Cite) (*Cite, error)) (*Cite, error) {
 	return apply(c, transformers...)
 }
@@ -659,8 +720,9 @@ func (c *Code) clone() Element {
 	c1 := *c
 	return &c1
 }
-func (c *Code) inline()  {}
-func (c *Code) element() {}
+func (c *Code) inline()         {}
+func (c *Code) element()        {}
+func (c *Code) Field(i int) any { return nth(i, &c.Attr, &c.Text) }
 
 var SP = &Space{}
 
@@ -674,6 +736,7 @@ func (*Space) space()         {}
 func (*Space) clone() Element { return SP }
 func (*Space) inline()        {}
 func (*Space) element()       {}
+func (*Space) Field(int) any  { return nil }
 
 var SB = &SoftBreak{}
 
@@ -687,6 +750,7 @@ func (*SoftBreak) space()         {}
 func (*SoftBreak) clone() Element { return SB }
 func (*SoftBreak) inline()        {}
 func (*SoftBreak) element()       {}
+func (*SoftBreak) Field(int) any  { return nil }
 
 var LB = &LineBreak{}
 
@@ -700,6 +764,7 @@ func (*LineBreak) space()         {}
 func (*LineBreak) clone() Element { return LB }
 func (*LineBreak) inline()        {}
 func (*LineBreak) element()       {}
+func (*LineBreak) Field(int) any  { return nil }
 
 type MathType Tag
 
@@ -721,8 +786,9 @@ func (m *Math) clone() Element {
 	c := *m
 	return &c
 }
-func (m *Math) inline()  {}
-func (m *Math) element() {}
+func (m *Math) inline()         {}
+func (m *Math) element()        {}
+func (m *Math) Field(i int) any { return nth(i, &m.MathType, &m.Text) }
 
 // Raw inline
 type RawInline struct {
@@ -737,8 +803,9 @@ func (r *RawInline) clone() Element {
 	c := *r
 	return &c
 }
-func (r *RawInline) element() {}
-func (r *RawInline) inline()  {}
+func (r *RawInline) element()        {}
+func (r *RawInline) Field(i int) any { return nth(i, &r.Format, &r.Text) }
+func (r *RawInline) inline()         {}
 
 type Target struct {
 	Url   string
@@ -760,8 +827,9 @@ func (l *Link) clone() Element {
 	c := *l
 	return &c
 }
-func (l *Link) inline()  {}
-func (l *Link) element() {}
+func (l *Link) inline()         {}
+func (l *Link) element()        {}
+func (l *Link) Field(i int) any { return nth(i, &l.Attr, &l.Inlines, &l.Target) }
 func (l *Link) Apply(transformers ...func(*Link) (*Link, error)) (*Link, error) {
 	return apply(l, transformers...)
 }
@@ -781,8 +849,9 @@ func (i *Image) clone() Element {
 	c := *i
 	return &c
 }
-func (i *Image) element() {}
-func (i *Image) inline()  {}
+func (i *Image) element()        {}
+func (i *Image) Field(n int) any { return nth(n, &i.Attr, &i.Inlines, &i.Target) }
+func (i *Image) inline()         {}
 func (i *Image) Apply(transformers ...func(*Image) (*Image, error)) (*Image, error) {
 	return apply(i, transformers...)
 }
@@ -800,8 +869,9 @@ func (n *Note) clone() Element {
 	c := *n
 	return &c
 }
-func (n *Note) element() {}
-func (n *Note) inline()  {}
+func (n *Note) element()        {}
+func (n *Note) Field(i int) any { return nth(i, &n.Blocks) }
+func (n *Note) inline()         {}
 func (n *Note) Apply(transformers ...func(*Note) (*Note, error)) (*Note, error) {
 	return apply(n, transformers...)
 }
@@ -820,8 +890,9 @@ func (s *Span) clone() Element {
 	c := *s
 	return &c
 }
-func (s *Span) inline()  {}
-func (s *Span) element() {}
+func (s *Span) inline()         {}
+func (s *Span) element()        {}
+func (s *Span) Field(i int) any { return nth(i, &s.Attr, &s.Inlines) }
 func (s *Span) Apply(transformers ...func(*Span) (*Span, error)) (*Span, error) {
 	return apply(s, transformers...)
 }
@@ -839,8 +910,9 @@ func (p *Plain) clone() Element {
 	c := *p
 	return &c
 }
-func (p *Plain) block()   {}
-func (p *Plain) element() {}
+func (p *Plain) block()          {}
+func (p *Plain) element()        {}
+func (p *Plain) Field(i int) any { return nth(i, &p.Inlines) }
 func (p *Plain) Apply(transformers ...func(*Plain) (*Plain, error)) (*Plain, error) {
 	return apply(p, transformers...)
 }
@@ -858,8 +930,9 @@ func (p *Para) clone() Element {
 	c := *p
 	return &c
 }
-func (p *Para) block()   {}
-func (p *Para) element() {}
+func (p *Para) block()          {}
+func (p *Para) element()        {}
+func (p *Para) Field(i int) any { return nth(i, &p.Inlines) }
 func (p *Para) Apply(transformers ...func(*Para) (*Para, error)) (*Para, error) {
 	return apply(p, transformers...)
 }
@@ -876,8 +949,9 @@ func (b *LineBlock) clone() Element {
 	c := *b
 	return &c
 }
-func (b *LineBlock) block()   {}
-func (b *LineBlock) element() {}
+func (b *LineBlock) block()          {}
+func (b *LineBlock) element()        {}
+func (b *LineBlock) Field(i int) any { return nth(i, &b.Inlines) }
 func (b *LineBlock) Apply(transformers ...func(*LineBlock) (*LineBlock, error)) (*LineBlock, error) {
 	return apply(b, transformers...)
 }
@@ -895,8 +969,9 @@ func (b *CodeBlock) clone() Element {
 	c := *b
 	return &c
 }
-func (b *CodeBlock) block()   {}
-func (b *CodeBlock) element() {}
+func (b *CodeBlock) block()          {}
+func (b *CodeBlock) element()        {}
+func (c *CodeBlock) Field(i int) any { return nth(i, &c.Attr, &c.Text) }
 
 // Raw block
 type RawBlock struct {
@@ -911,8 +986,9 @@ func (b *RawBlock) clone() Element {
 	c := *b
 	return &c
 }
-func (b *RawBlock) block()   {}
-func (b *RawBlock) element() {}
+func (b *RawBlock) block()          {}
+func (b *RawBlock) element()        {}
+func (r *RawBlock) Field(i int) any { return nth(i, &r.Format, &r.Text) }
 
 // Block quote (list of blocks)
 type BlockQuote struct {
@@ -927,8 +1003,9 @@ func (b *BlockQuote) clone() Element {
 	c := *b
 	return &c
 }
-func (b *BlockQuote) block()   {}
-func (b *BlockQuote) element() {}
+func (b *BlockQuote) block()          {}
+func (b *BlockQuote) element()        {}
+func (b *BlockQuote) Field(i int) any { return nth(i, &b.Blocks) }
 func (b *BlockQuote) Apply(transformers ...func(*BlockQuote) (*BlockQuote, error)) (*BlockQuote, error) {
 	return apply(b, transformers...)
 }
@@ -973,8 +1050,9 @@ func (l *OrderedList) clone() Element {
 	c := *l
 	return &c
 }
-func (l *OrderedList) block()   {}
-func (l *OrderedList) element() {}
+func (l *OrderedList) block()          {}
+func (l *OrderedList) element()        {}
+func (l *OrderedList) Field(i int) any { return nth(i, &l.Attr, &l.Items) }
 func (l *OrderedList) Apply(transformers ...func(*OrderedList) (*OrderedList, error)) (*OrderedList, error) {
 	return apply(l, transformers...)
 }
@@ -991,8 +1069,9 @@ func (l *BulletList) clone() Element {
 	c := *l
 	return &c
 }
-func (l *BulletList) block()   {}
-func (l *BulletList) element() {}
+func (l *BulletList) block()          {}
+func (l *BulletList) element()        {}
+func (l *BulletList) Field(i int) any { return nth(i, &l.Items) }
 func (l *BulletList) Apply(transformers ...func(*BulletList) (*BulletList, error)) (*BulletList, error) {
 	return apply(l, transformers...)
 }
@@ -1002,6 +1081,13 @@ type Definition struct {
 	Definition [][]Block
 }
 
+func (d *Definition) element()        {}
+func (d *Definition) Field(i int) any { return nth(i, &d.Term, &d.Definition) }
+func (d *Definition) clone() Element {
+	n := *d
+	return &n
+}
+
 // Definition list (list of items, each a pair of inlines and a list of blocks)
 type DefinitionList struct {
 	Items []Definition
@@ -1014,8 +1100,9 @@ func (d *DefinitionList) clone() Element {
 	c := *d
 	return &c
 }
-func (d *DefinitionList) block()   {}
-func (d *DefinitionList) element() {}
+func (d *DefinitionList) block()          {}
+func (d *DefinitionList) element()        {}
+func (d *DefinitionList) Field(i int) any { return nth(i, &d.Items) }
 func (d *DefinitionList) Apply(transformers ...func(*DefinitionList) (*DefinitionList, error)) (*DefinitionList, error) {
 	return apply(d, transformers...)
 }
@@ -1031,6 +1118,7 @@ func (*HorizontalRule) Tag() Tag       { return HorizontalRuleTag }
 func (*HorizontalRule) clone() Element { return HR }
 func (*HorizontalRule) block()         {}
 func (*HorizontalRule) element()       {}
+func (*HorizontalRule) Field(int) any  { return nil }
 
 // Header - level (integer) and text (inlines)
 type Header struct {
@@ -1047,8 +1135,9 @@ func (h *Header) clone() Element {
 	c := *h
 	return &c
 }
-func (h *Header) block()   {}
-func (h *Header) element() {}
+func (h *Header) block()          {}
+func (h *Header) element()        {}
+func (h *Header) Field(i int) any { return nth(i, &h.Level, &h.Attr, &h.Inlines) }
 func (h *Header) Apply(transformers ...func(*Header) (*Header, error)) (*Header, error) {
 	return apply(h, transformers...)
 }
@@ -1069,7 +1158,7 @@ func (h *Header) Title() string {
 			return nil, Skip
 		}
 		return nil, Continue
-	})
+	}, walker{})
 	return sb.String()
 }
 
@@ -1078,6 +1167,13 @@ type Caption struct {
 	Long  []Block
 }
 
+func (c *Caption) element()        {}
+func (c *Caption) Field(i int) any { return nth(i, &c.Short, &c.Long) }
+func (c *Caption) clone() Element {
+	n := *c
+	return &n
+}
+
 type Alignment Tag
 
 const (
@@ -1111,12 +1207,20 @@ type ColSpec struct {
 	Width ColWidth
 }
 
+func (c *ColSpec) element()        {}
+func (c *ColSpec) Field(i int) any { return nth(i, &c.Align, &c.Width) }
+func (c *ColSpec) clone() Element {
+	n := *c
+	return &n
+}
+
 type TableHeadFoot struct {
 	Attr
 	Rows []*TableRow
 }
 
-func (t *TableHeadFoot) element() {}
+func (t *TableHeadFoot) element()        {}
+func (t *TableHeadFoot) Field(i int) any { return nth(i, &t.Attr, &t.Rows) }
 func (t *TableHeadFoot) clone() Element {
 	c := *t
 	return &c
@@ -1130,7 +1234,8 @@ type TableRow struct {
 	Cells []*TableCell
 }
 
-func (t *TableRow) element() {}
+func (t *TableRow) element()        {}
+func (t *TableRow) Field(i int) any { return nth(i, &t.Attr, &t.Cells) }
 func (t *TableRow) clone() Element {
 	c := *t
 	return &c
@@ -1148,6 +1253,9 @@ type TableCell struct {
 }
 
 func (t *TableCell) element() {}
+func (t *TableCell) Field(i int) any {
+	return nth(i, &t.Attr, &t.Align, &t.RowSpan, &t.ColSpan, &t.Blocks)
+}
 func (t *TableCell) clone() Element {
 	c := *t
 	return &c
@@ -1164,7 +1272,8 @@ type TableBody struct {
 	Body           []*TableRow
 }
 
-func (t *TableBody) element() {}
+func (t *TableBody) element()        {}
+func (t *TableBody) Field(i int) any { return nth(i, &t.Attr, &t.RowHeadColumns, &t.Head, &t.Body) }
 func (t *TableBody) clone() Element {
 	c := *t
 	return &c
@@ -1193,6 +1302,9 @@ func (t *Table) clone() Element {
 }
 func (t *Table) block()   {}
 func (t *Table) element() {}
+func (t *Table) Field(i int) any {
+	return nth(i, &t.Attr, &t.Caption, &t.Aligns, &t.Head, &t.Bodies, &t.Foot)
+}
 func (t *Table) Apply(transformers ...func(*Table) (*Table, error)) (*Table, error) {
 	return apply(t, transformers...)
 }
@@ -1211,8 +1323,9 @@ func (f *Figure) clone() Element {
 	c := *f
 	return &c
 }
-func (f *Figure) block()   {}
-func (f *Figure) element() {}
+func (f *Figure) block()          {}
+func (f *Figure) element()        {}
+func (f *Figure) Field(i int) any { return nth(i, &f.Attr, &f.Caption, &f.Blocks) }
 func (f *Figure) Apply(transformers ...func(*Figure) (*Figure, error)) (*Figure, error) {
 	return apply(f, transformers...)
 }
@@ -1231,8 +1344,28 @@ func (d *Div) clone() Element {
 	c := *d
 	return &c
 }
-func (d *Div) block()   {}
-func (d *Div) element() {}
+func (d *Div) block()          {}
+func (d *Div) element()        {}
+func (d *Div) Field(i int) any { return nth(i, &d.Attr, &d.Blocks) }
 func (d *Div) Apply(transformers ...func(*Div) (*Div, error)) (*Div, error) {
 	return apply(d, transformers...)
 }
+
+// An element of a type unknown to the package, such as a constructor
+// added by a newer pandoc-types version. Only read if
+// ReadOptions.KeepUnknown is set; written back as read.
+type UnknownElement struct {
+	Type    Tag    // The element tag
+	Content []byte // Compact JSON of the element contents, nil if there are none
+}
+
+func (u *UnknownElement) Tag() Tag { return u.Type }
+func (u *UnknownElement) clone() Element {
+	c := *u
+	return &c
+}
+func (u *UnknownElement) inline()     {}
+func (u *UnknownElement) block()      {}
+func (u *UnknownElement) meta()       {}
+func (u *UnknownElement) element()    {}
+func (*UnknownElement) Field(int) any { return nil }
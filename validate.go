@@ -0,0 +1,198 @@
+package pandoc
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// versions of pandoc introducing and removing options; options not
+// listed are known to all supported versions
+var optVersions = map[string]struct{ since, removed string }{
+	"metadata-file":          {since: "2.3"},
+	"ipynb-output":           {since: "2.6"},
+	"shift-heading-level-by": {since: "2.8"},
+	"citeproc":               {since: "2.11"},
+	"markdown-headings":      {since: "2.11.2"},
+	"sandbox":                {since: "2.15"},
+	"embed-resources":        {since: "2.19"},
+	"split-level":            {since: "3.0"},
+	"chunk-template":         {since: "3.0"},
+	"variable-json":          {since: "3.7"},
+	"base-header-level":      {removed: "3.0"},
+}
+
+// Works as ValidateFor for both directions and any pandoc version.
+func (c Conf) Validate() error {
+	return c.ValidateFor(Reading|Writing, "")
+}
+
+// Reports unknown options of c, with suggestions for typos, options
+// without required arguments and options a Conf used for dir has no use
+// for, such as writer options of a Conf loading documents. Options not
+// supported by the pandoc version are reported unless version is empty.
+// Directories of --data-dir and --resource-path must exist and the one
+// of --extract-media must be a directory if it exists, relative to Dir;
+// they are not checked if pandoc is run by a Runner other than
+// ExecRunner. The errors are joined.
+//
+// Only conversion options are known, see ParseCommandLine.
+func (c Conf) ValidateFor(dir Direction, version string) error {
+	var errs []error
+	for i := 0; i < len(c.Opts); i++ {
+		arg := c.Opts[i]
+		var (
+			name, val string
+			hasVal    bool
+		)
+		if strings.HasPrefix(arg, "--") {
+			name, val, hasVal = strings.Cut(arg[2:], "=")
+		} else if len(arg) > 1 && arg[0] == '-' && shortOpts[arg[1]] != "" {
+			name, val, hasVal = shortOpts[arg[1]], arg[2:], len(arg) > 2
+		} else {
+			errs = append(errs, fmt.Errorf("unknown pandoc option %q", arg))
+			continue
+		}
+		opt, ok := lookupOpt(name)
+		if !ok {
+			if s := suggestOpt(name); s != "" {
+				errs = append(errs, fmt.Errorf("unknown pandoc option %s, did you mean --%s?", arg, s))
+			} else {
+				errs = append(errs, fmt.Errorf("unknown pandoc option %s", arg))
+			}
+			continue
+		}
+		if opt.arg == requiredArg && !hasVal {
+			if i++; i == len(c.Opts) {
+				errs = append(errs, fmt.Errorf("pandoc option %s requires an argument", arg))
+				continue
+			}
+			val = c.Opts[i]
+		} else if opt.arg == noArg && hasVal {
+			errs = append(errs, fmt.Errorf("pandoc option %s has no argument", arg))
+		}
+		switch name {
+		case "from", "read", "to", "write":
+			errs = append(errs, fmt.Errorf("pandoc option %s conflicts with the format", arg))
+		case "output":
+			if dir&Writing == 0 {
+				errs = append(errs, fmt.Errorf("pandoc option %s is for storing documents", arg))
+			}
+		default:
+			if opt.side&dir == 0 {
+				if opt.side == Reading {
+					errs = append(errs, fmt.Errorf("pandoc option %s is a reader option", arg))
+				} else {
+					errs = append(errs, fmt.Errorf("pandoc option %s is a writer option", arg))
+				}
+			}
+		}
+		if err := c.checkDirs(arg, name, val); err != nil {
+			errs = append(errs, err)
+		}
+		if v, ok := optVersions[name]; ok && version != "" {
+			if v.since != "" && compareVersions(version, v.since) < 0 {
+				errs = append(errs, fmt.Errorf("pandoc option %s requires pandoc %s", arg, v.since))
+			} else if v.removed != "" && compareVersions(version, v.removed) >= 0 {
+				errs = append(errs, fmt.Errorf("pandoc option %s is removed in pandoc %s", arg, v.removed))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checks directories of option name with value val
+func (c *Conf) checkDirs(arg, name, val string) error {
+	if _, ok := c.runner().(ExecRunner); !ok {
+		return nil
+	}
+	var dirs []string
+	switch name {
+	case "data-dir", "extract-media":
+		dirs = []string{val}
+	case "resource-path":
+		dirs = filepath.SplitList(val)
+	default:
+		return nil
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		path := dir
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.Dir, path)
+		}
+		if fi, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) && name == "extract-media" {
+			// made by pandoc
+		} else if err != nil {
+			return fmt.Errorf("pandoc option %s: %w", arg, err)
+		} else if !fi.IsDir() {
+			return fmt.Errorf("pandoc option %s: %s is not a directory", arg, dir)
+		}
+	}
+	return nil
+}
+
+// returns the known option closest to name, if close enough
+func suggestOpt(name string) string {
+	names := make([]string, 0, len(cmdOpts))
+	for n := range cmdOpts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	best, dist := "", 3
+	for _, n := range names {
+		if d := editDistance(name, n); d < dist {
+			best, dist = n, d
+		}
+	}
+	return best
+}
+
+// returns the Levenshtein distance
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// compares dotted version numbers
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,57 @@
+package pandoc
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConfValidate(t *testing.T) {
+	for _, tt := range []struct {
+		conf    Conf
+		dir     Direction
+		version string
+		errs    []string
+	}{
+		{Format("html").WithStandalone().WithTOC(2).WithOpt("o", "out.html").WithOpt("V", "a=b"), Writing, "3.1", nil},
+		{Format("markdown").WithMetadata("title", "x").WithFilter("f").WithDataDir("testdata"), Reading, "3.1", nil},
+		{Format("docx").WithResourcePath("testdata", ".").WithExtractMedia("media").WithDataDir("testdata"), Reading, "", nil},
+		{Format("docx").WithResourcePath("testdata", "missing").WithExtractMedia("go.mod").WithDataDir("d"), Reading, "", []string{
+			"--resource-path=testdata" + string(os.PathListSeparator) + "missing: stat missing: no such file",
+			"--extract-media=go.mod: go.mod is not a directory",
+			"--data-dir=d: stat d: no such file",
+		}},
+		{Format("docx").WithDataDir("d").WithRunner(SSHRunner{Host: "h"}), Reading, "", nil},
+		{Format("docx").WithDir("testdata").WithResourcePath("../testdata"), Reading, "", nil},
+		{Format("html").WithOpt("number-scetions"), Writing, "", []string{"unknown pandoc option --number-scetions, did you mean --number-sections?"}},
+		{Conf{Format: "html", Opts: []string{"--xyzzy", "-q"}}, Writing, "", []string{"unknown pandoc option --xyzzy", `unknown pandoc option "-q"`}},
+		{Format("markdown").WithStandalone(), Reading, "", []string{"--standalone is a writer option"}},
+		{Format("html").WithOpt("metadata-file", "m.yaml"), Writing, "", []string{"--metadata-file=m.yaml is a reader option"}},
+		{Format("html").WithOpt("toc", "2").WithOpt("toc-depth"), Writing, "", []string{"--toc=2 has no argument", "--toc-depth requires an argument"}},
+		{Format("html").WithEmbedResources().WithOpt("split-level", "2"), Writing, "2.19", []string{"--split-level=2 requires pandoc 3.0"}},
+		{Format("html").WithOpt("base-header-level", "2"), Reading, "3.1.1", []string{"--base-header-level=2 is removed in pandoc 3.0"}},
+		{Format("html").WithOpt("t", "latex"), Writing, "", []string{"-t conflicts with the format"}},
+	} {
+		err := tt.conf.ValidateFor(tt.dir, tt.version)
+		if tt.errs == nil {
+			if err != nil {
+				t.Errorf("%q: %v", tt.conf.Opts, err)
+			}
+			continue
+		} else if err == nil {
+			t.Errorf("%q: no error", tt.conf.Opts)
+			continue
+		}
+		if lines := strings.Split(err.Error(), "\n"); len(lines) != len(tt.errs) {
+			t.Errorf("%q: unexpected errors %q", tt.conf.Opts, lines)
+		}
+		for _, e := range tt.errs {
+			if !strings.Contains(err.Error(), e) {
+				t.Errorf("%q: %q does not contain %q", tt.conf.Opts, err, e)
+			}
+		}
+	}
+	if err := Format("html").WithStandalone().WithMetadata("a", "b").Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,249 @@
+package pandoc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Returned by Load and Store functions if Conf.CheckVersion is set and
+// pandoc is incompatible.
+var ErrIncompatibleVersion = errors.New("incompatible pandoc version")
+
+// Capabilities of a pandoc executable.
+type Capabilities struct {
+	Version    string          // Pandoc version, e.g. "3.1.2"
+	APIVersion []int           // Pandoc-types API version of the produced AST JSON
+	Features   map[string]bool // Compile-time features, e.g. "server", "lua"
+	Lua        string          // Lua scripting engine version, empty if not available
+	Citeproc   bool            // True if the built-in citeproc is available (pandoc 2.11+)
+	DataDir    string          // User data directory, empty if not reported
+}
+
+// Returns true if the AST JSON of the pandoc executable is compatible
+// with the package's Version, i.e. the first two components of the
+// API versions are equal.
+func (c *Capabilities) Compatible() bool {
+	if len(c.APIVersion) < 2 || len(_Version) < 2 {
+		return false
+	}
+	return c.APIVersion[0] == _Version[0] && c.APIVersion[1] == _Version[1]
+}
+
+// returns ErrIncompatibleVersion, wrapped, unless c is Compatible
+func (c *Capabilities) compatible() error {
+	if c.Compatible() {
+		return nil
+	}
+	return fmt.Errorf("%w: pandoc %s uses API %s, %s is required",
+		ErrIncompatibleVersion, c.Version, c.API(), Version)
+}
+
+// Returns the pandoc-types API version as a string, e.g. "1.23.1".
+func (c *Capabilities) API() string {
+	s := make([]string, len(c.APIVersion))
+	for i, n := range c.APIVersion {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ".")
+}
+
+// results of pandoc queries by cacheKey
+var cache sync.Map
+
+// the runner, the pandoc executable path, resolved for ExecRunner, and
+// the query, e.g. "--version"
+type cacheKey struct {
+	runner Runner
+	pandoc string
+	query  string
+}
+
+type cacheEntry struct {
+	once sync.Once
+	val  any
+	err  error
+}
+
+// returns the result of query run by fun with the runner and pandoc of
+// c, cached for each pandoc executable and comparable Runner. A failure
+// is not cached.
+func (c *Conf) cached(ctx context.Context, query string, fun func(context.Context, Runner, string) (any, error)) (any, error) {
+	key := cacheKey{runner: c.runner(), pandoc: c.Pandoc, query: query}
+	if _, ok := key.runner.(ExecRunner); ok {
+		exe, err := c.pandocExecutable()
+		if err != nil {
+			return nil, err
+		}
+		key.pandoc = exe
+	} else if !reflect.TypeOf(key.runner).Comparable() {
+		return fun(ctx, key.runner, key.pandoc)
+	}
+	v, _ := cache.LoadOrStore(key, &cacheEntry{})
+	e := v.(*cacheEntry)
+	e.once.Do(func() {
+		e.val, e.err = fun(ctx, key.runner, key.pandoc)
+		if e.err != nil {
+			cache.Delete(key)
+		}
+	})
+	return e.val, e.err
+}
+
+// Returns the version of the pandoc executable. See Capabilities.
+func (c Conf) Version() (string, error) {
+	caps, err := c.Capabilities()
+	if err != nil {
+		return "", err
+	}
+	return caps.Version, nil
+}
+
+// Runs pandoc to detect its version and capabilities. The result is
+// cached for each pandoc executable and comparable Runner.
+func (c Conf) Capabilities() (*Capabilities, error) {
+	return c.CapabilitiesContext(context.Background())
+}
+
+// CapabilitiesContext is Capabilities that kills pandoc if ctx is done
+// before pandoc responds. A failed detection is not cached.
+func (c Conf) CapabilitiesContext(ctx context.Context) (*Capabilities, error) {
+	v, err := c.cached(ctx, "--version", func(ctx context.Context, r Runner, pandoc string) (any, error) {
+		return detect(ctx, r, pandoc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Capabilities), nil
+}
+
+// Returns the input and output formats of the pandoc executable, sorted.
+// The result is cached as the one of Capabilities.
+func (c Conf) Formats() (in, out []string, err error) {
+	return c.FormatsContext(context.Background())
+}
+
+// FormatsContext is Formats that kills pandoc if ctx is done before
+// pandoc responds.
+func (c Conf) FormatsContext(ctx context.Context) (in, out []string, err error) {
+	if in, err = c.list(ctx, "--list-input-formats"); err != nil {
+		return nil, nil, err
+	}
+	if out, err = c.list(ctx, "--list-output-formats"); err != nil {
+		return nil, nil, err
+	}
+	return in, out, nil
+}
+
+// returns the cached output of pandoc run with query, one item a line
+func (c *Conf) list(ctx context.Context, query string) ([]string, error) {
+	v, err := c.cached(ctx, query, func(ctx context.Context, r Runner, pandoc string) (any, error) {
+		out, err := output(ctx, r, pandoc, query)
+		if err != nil {
+			return nil, fmt.Errorf("running pandoc %s: %w", query, err)
+		}
+		l := strings.Fields(string(out))
+		sort.Strings(l)
+		return l, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// a copy, so that callers cannot modify the cached list
+	return append([]string(nil), v.([]string)...), nil
+}
+
+// returns the output of pandoc run with args and no input
+func output(ctx context.Context, r Runner, pandoc string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	err := run(ctx, r, &Command{Pandoc: pandoc, Args: args}, nil,
+		func(r io.Reader) error {
+			_, err := out.ReadFrom(r)
+			return err
+		})
+	return out.Bytes(), err
+}
+
+func detect(ctx context.Context, r Runner, pandoc string) (*Capabilities, error) {
+	out, err := output(ctx, r, pandoc, "--version")
+	if err != nil {
+		return nil, fmt.Errorf("running pandoc --version: %w", err)
+	}
+	caps := parseVersion(string(out))
+	if caps.Version == "" {
+		return nil, fmt.Errorf("unexpected pandoc --version output: %q", firstLine(string(out)))
+	}
+	// the API version is only known from the AST JSON itself
+	if out, err = output(ctx, r, pandoc, "-f", "markdown", "-t", "json"); err != nil {
+		return nil, fmt.Errorf("running pandoc to detect API version: %w", err)
+	}
+	var doc struct {
+		API []int `json:"pandoc-api-version"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(out), &doc); err != nil {
+		return nil, fmt.Errorf("detecting API version: %w", err)
+	}
+	caps.APIVersion = doc.API
+	return caps, nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// parses output of pandoc --version
+func parseVersion(out string) *Capabilities {
+	caps := &Capabilities{Features: make(map[string]bool)}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "pandoc ") && caps.Version == "":
+			if f := strings.Fields(line); len(f) > 1 {
+				caps.Version = f[1]
+			}
+		case strings.HasPrefix(line, "Features:"):
+			for _, f := range strings.Fields(strings.TrimPrefix(line, "Features:")) {
+				if strings.HasPrefix(f, "+") {
+					caps.Features[f[1:]] = true
+				} else if strings.HasPrefix(f, "-") {
+					caps.Features[f[1:]] = false
+				}
+			}
+		case strings.HasPrefix(line, "User data directory:"):
+			caps.DataDir = strings.TrimSpace(strings.TrimPrefix(line, "User data directory:"))
+		case strings.HasPrefix(line, "Scripting engine:"):
+			caps.Lua = strings.TrimSpace(strings.TrimPrefix(line, "Scripting engine:"))
+			caps.Features["lua"] = true
+		}
+	}
+	if v := semver(caps.Version); len(v) > 0 {
+		caps.Citeproc = cmpSemver(v, []int{2, 11}) >= 0
+		if _, ok := caps.Features["lua"]; !ok {
+			// Lua filters are supported since 2.0, the engine is not
+			// reported by older versions
+			caps.Features["lua"] = cmpSemver(v, []int{2}) >= 0
+		}
+	}
+	return caps
+}
+
+func semver(s string) []int {
+	var v []int
+	for _, c := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			break
+		}
+		v = append(v, n)
+	}
+	return v
+}
@@ -0,0 +1,95 @@
+package pandoc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	caps := parseVersion(`pandoc 3.1.2
+Features: +server +lua
+Scripting engine: Lua 5.4
+User data directory: /home/user/.local/share/pandoc
+Copyright (C) 2006-2023 John MacFarlane. Web:  https://pandoc.org
+`)
+	if caps.Version != "3.1.2" || !caps.Features["server"] || !caps.Features["lua"] ||
+		caps.Lua != "Lua 5.4" || !caps.Citeproc || caps.DataDir != "/home/user/.local/share/pandoc" {
+		t.Errorf("unexpected %+v", caps)
+	}
+	caps = parseVersion("pandoc 2.9.2.1\nCompiled with pandoc-types 1.20\n")
+	if caps.Version != "2.9.2.1" || caps.Citeproc || !caps.Features["lua"] {
+		t.Errorf("unexpected %+v", caps)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	fake := func(api string) Conf {
+		exe := filepath.Join(t.TempDir(), "pandoc")
+		script := `#!/bin/sh
+case "$1" in
+--version) echo "pandoc 3.1.2"; echo "Features: +lua" ;;
+*) echo '{"pandoc-api-version":[` + api + `],"meta":{},"blocks":[]}' ;;
+esac
+`
+		if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return Format("markdown").WithPandoc(exe)
+	}
+	conf := fake(strings.ReplaceAll(Version, ".", ","))
+	caps, err := conf.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caps.Version != "3.1.2" || caps.API() != Version || !caps.Compatible() {
+		t.Errorf("unexpected %+v", caps)
+	}
+	if again, _ := conf.Capabilities(); again != caps {
+		t.Errorf("capabilities are not cached")
+	}
+	if _, err := LoadFrom(strings.NewReader(""), conf.WithVersionCheck()); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	old := fake("1,20")
+	if _, err := LoadFrom(strings.NewReader(""), old.WithVersionCheck()); !errors.Is(err, ErrIncompatibleVersion) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	exe := filepath.Join(t.TempDir(), "pandoc")
+	script := `#!/bin/sh
+case "$1" in
+--list-input-formats) printf 'markdown\ndocx\ncommonmark\n' ;;
+--list-output-formats) printf 'html\npdf\n' ;;
+*) exit 1 ;;
+esac
+`
+	if err := os.WriteFile(exe, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	conf := Conf{}.WithPandoc(exe)
+	in, out, err := conf.Formats()
+	if err != nil {
+		t.Fatal(err)
+	} else if strings.Join(in, " ") != "commonmark docx markdown" || strings.Join(out, " ") != "html pdf" {
+		t.Errorf("got %q, %q", in, out)
+	}
+	in[0] = "x"
+	if again, _, _ := conf.Formats(); again[0] != "commonmark" {
+		t.Errorf("cached formats are modified: %q", again)
+	}
+	if _, _, err := conf.WithPandoc(filepath.Join(t.TempDir(), "missing")).Formats(); err == nil {
+		t.Error("no error")
+	}
+}
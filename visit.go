@@ -0,0 +1,105 @@
+package pandoc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// An alternative to generic filters dispatching elements to methods of
+// a single value. Walk calls the method VisitT(e T) error, if the visitor
+// has one, for an element e of type T (such as VisitStr(*Str) or
+// VisitMetaString(MetaString)), and Visit otherwise. The returned error
+// controls the traversal as in QueryE: Continue, Skip, Halt or an error
+// to stop with. Other methods whose names start with Visit, such as a
+// misspelled VisitHeading, or VisitStr(Str) of a wrong signature, are an
+// error of Walk.
+//
+// Example:
+//
+//	type counter struct{ words, links int }
+//
+//	func (c *counter) Visit(pandoc.Element) error    { return pandoc.Continue }
+//	func (c *counter) VisitStr(*pandoc.Str) error    { c.words++; return pandoc.Continue }
+//	func (c *counter) VisitLink(*pandoc.Link) error  { c.links++; return pandoc.Skip }
+type Visitor interface {
+	Visit(e Element) error
+}
+
+// Walks elt and its descendants in the order of Query, dispatching them
+// to the methods of the visitor. Returns an error returned by the
+// visitor, if any, or an error if the visitor has a Visit method of no
+// element type.
+func Walk[E Element](elt E, v Visitor) error {
+	methods, err := visitMethods(reflect.TypeOf(v))
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v)
+	visit := func(e Element) error {
+		m, ok := methods[reflect.TypeOf(e)]
+		if !ok {
+			return v.Visit(e)
+		}
+		err, _ := rv.Method(m).Call([]reflect.Value{reflect.ValueOf(e)})[0].Interface().(error)
+		return err
+	}
+	if err := visit(elt); err == Skip || err == Halt {
+		return nil
+	} else if err != nil && err != Continue {
+		return err
+	}
+	return QueryE(elt, visit)
+}
+
+// the dispatch of visitor types, by the types
+var visitors sync.Map
+
+type visitDispatch struct {
+	methods map[reflect.Type]int // indices of VisitT methods by T
+	err     error                // a method named Visit of no element type
+}
+
+// returns the indices of the VisitT methods of the visitor type by T
+func visitMethods(t reflect.Type) (map[reflect.Type]int, error) {
+	if d, ok := visitors.Load(t); ok {
+		return d.(*visitDispatch).methods, d.(*visitDispatch).err
+	}
+	d := &visitDispatch{methods: make(map[reflect.Type]int)}
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		if m.Name == "Visit" || !strings.HasPrefix(m.Name, "Visit") {
+			continue
+		} else if e, ok := visitParam(m); ok && m.Name == "Visit"+typeName(e) {
+			d.methods[e] = i
+		} else if d.err == nil {
+			d.err = fmt.Errorf("pandoc: visitor method %s.%s is not VisitT(T) error of an element type T", t, m.Name)
+		}
+	}
+	v, _ := visitors.LoadOrStore(t, d)
+	return v.(*visitDispatch).methods, v.(*visitDispatch).err
+}
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	elementType = reflect.TypeOf((*Element)(nil)).Elem()
+)
+
+// returns the element type T of the method m(T) error
+func visitParam(m reflect.Method) (reflect.Type, bool) {
+	// the receiver is the first parameter
+	if m.Type.NumIn() != 2 || m.Type.NumOut() != 1 || m.Type.Out(0) != errorType {
+		return nil, false
+	}
+	e := m.Type.In(1)
+	return e, e.Kind() != reflect.Interface && e.Implements(elementType)
+}
+
+// returns the name of the type, or of the type pointed to
+func typeName(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
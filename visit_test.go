@@ -0,0 +1,66 @@
+package pandoc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testVisitor struct {
+	docs, strs, links, meta, other int
+	err                            error
+}
+
+func (v *testVisitor) Visit(Element) error       { v.other++; return Continue }
+func (v *testVisitor) VisitPandoc(*Pandoc) error { v.docs++; return Continue }
+func (v *testVisitor) VisitStr(*Str) error       { v.strs++; return v.err }
+func (v *testVisitor) VisitLink(*Link) error {
+	v.links++
+	return Skip
+}
+func (v *testVisitor) VisitMetaString(MetaString) error { v.meta++; return Continue }
+
+func TestWalk(t *testing.T) {
+	doc := &Pandoc{
+		Meta: Meta{{Key: "tags", Value: &MetaList{Entries: []MetaValue{MetaString("t")}}}},
+		Blocks: []Block{&Para{Inlines: []Inline{
+			&Str{"a"}, &Space{}, &Link{Inlines: []Inline{&Str{"b"}}}, &Str{"c"},
+		}}},
+	}
+	var v testVisitor
+	if err := Walk(doc, &v); err != nil {
+		t.Fatal(err)
+	}
+	// the other elements are the meta entry, the paragraph and the space
+	if v.docs != 1 || v.strs != 2 || v.links != 1 || v.meta != 1 || v.other != 3 {
+		t.Errorf("got %+v", v)
+	}
+
+	v = testVisitor{err: Halt}
+	if err := Walk(doc, &v); err != nil || v.strs != 1 {
+		t.Errorf("halt: %v, %+v", err, v)
+	}
+	stop := errors.New("stop")
+	v = testVisitor{err: stop}
+	if err := Walk(doc, &v); !errors.Is(err, stop) {
+		t.Errorf("error: %v", err)
+	}
+	v = testVisitor{}
+	if err := Walk(&Str{"a"}, &v); err != nil || v.strs != 1 || v.other != 0 {
+		t.Errorf("root: %v, %+v", err, v)
+	}
+	if err := Walk(doc, &misspelledVisitor{}); err == nil || !strings.Contains(err.Error(), "VisitHeading") {
+		t.Errorf("misspelled method: %v", err)
+	}
+	if err := Walk(doc, &wrongVisitor{}); err == nil || !strings.Contains(err.Error(), "VisitStr") {
+		t.Errorf("method of a wrong signature: %v", err)
+	}
+}
+
+type misspelledVisitor struct{ testVisitor }
+
+func (*misspelledVisitor) VisitHeading(*Header) error { return Continue }
+
+type wrongVisitor struct{ testVisitor }
+
+func (*wrongVisitor) VisitStr(Str) error { return Continue }
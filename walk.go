@@ -1,9 +1,16 @@
 package pandoc
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
+	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"unicode"
 )
 
@@ -14,6 +21,7 @@ const (
 	skipChildren = 1 << iota
 	replaceElement
 	haltTraversal
+	deleteElement
 )
 
 func (e traversalResult) replace() bool {
@@ -47,6 +55,8 @@ func (e traversalResult) Error() string {
 		return "replace element and halt traversal"
 	case haltTraversal | replaceElement | skipChildren:
 		return "replace element and halt traversal"
+	case deleteElement | replaceElement | skipChildren:
+		return "delete element"
 	default:
 		return "unknown error"
 	}
@@ -57,7 +67,7 @@ func isResult(err error) (traversalResult, bool) {
 		return 0, true
 	}
 	r, ok := err.(traversalResult)
-	if ok && r <= (skipChildren|replaceElement|haltTraversal) {
+	if ok && r <= (skipChildren|replaceElement|haltTraversal|deleteElement) {
 		return r, true
 	} else {
 		return 0, false
@@ -93,6 +103,14 @@ var (
 	// Might be useful to change or remove the only specific element in the AST.
 	ReplaceHalt = func() error { return traversalResult(replaceElement | haltTraversal) }()
 
+	// Delete indicates that the current element should be removed,
+	// whatever elements the function returns.
+	Delete = func() error { return traversalResult(deleteElement | replaceElement | skipChildren) }()
+
+	// Keep indicates that the current element should be kept as is. It
+	// is the same as Continue.
+	Keep = Continue
+
 	// Returned by Filter if the function returns the wrong type.
 	ErrUnexpectedType = errors.New("unexpected type")
 )
@@ -119,11 +137,12 @@ var (
 //   - StopTraversal: Skips processing children of the current element
 //     and terminates the traversal process immediately.
 //   - Replace: Replaces the current element with the elements returned by 'fun'.
+//   - Delete: Removes the current element.
 //   - any other error: Terminates the traversal process immediately and
 //     returns the error.
 //
-// To remove an element, 'fun' should return an empty slice of elements along with
-// Replace.
+// To remove an element, 'fun' should return Delete, or an empty slice of
+// elements along with Replace.
 //
 // The function returns an updated version of the 'elt' after applying the
 // specified function 'fun' (it might be the same 'elt' if no changes were made).
@@ -136,15 +155,180 @@ var (
 //		    return Inlines(Quoted(SingleQuote, Str("foo"))), Replace
 //		})
 func Filter[P any, E Element, R Element](elt E, fun func(P) ([]R, error)) (E, error) {
-	elt, err := walkChildren(elt, fun)
+	return FilterWith(elt, WalkOptions{}, fun)
+}
+
+// Options of a traversal.
+type WalkOptions struct {
+	// Walk lists of siblings from the last element to the first. Children
+	// are still visited after the element, and the parts of an element
+	// (e.g. a table head and bodies) are visited in their usual order.
+	Reverse bool
+
+	// The maximum depth of visited elements, the children of the
+	// element being walked have depth 1. Zero means no limit.
+	MaxDepth int
+
+	// If set, called for every element walked, before it is passed to
+	// the function, and after it and its children are walked. Elements
+	// returned by the function are not passed to the hooks, but their
+	// children are. Useful to keep the context of the element, such as
+	// the current section.
+	Enter, Exit func(Element)
+
+	skip []func(Element) bool
+}
+
+// Returns the options extended to not descend into elements of type T:
+// they are visited, but their children are not.
+//
+// Example:
+//
+//	opts := pandoc.SkipInside[*pandoc.Note](pandoc.WalkOptions{MaxDepth: 4})
+//	opts = pandoc.SkipInside[*pandoc.Table](opts)
+//	pandoc.QueryWith(doc, opts, func(s *pandoc.Str) error { ... })
+func SkipInside[T Element](opts WalkOptions) WalkOptions {
+	opts.skip = append(opts.skip[:len(opts.skip):len(opts.skip)], func(e Element) bool {
+		_, ok := e.(T)
+		return ok
+	})
+	return opts
+}
+
+// Works as Filter, traversing elt according to opts.
+//
+// Example:
+//
+//	// removes headers of empty sections
+//	level := 1 // level of the following header, 0 if followed by content
+//	doc, err = pandoc.FilterWith(doc, pandoc.WalkOptions{Reverse: true}, func(b pandoc.Block) ([]pandoc.Block, error) {
+//	    if h, ok := b.(*pandoc.Header); !ok {
+//	        level = 0
+//	    } else if level > 0 && level <= h.Level {
+//	        return nil, pandoc.ReplaceSkip
+//	    } else {
+//	        level = h.Level
+//	    }
+//	    return nil, pandoc.Skip
+//	})
+func FilterWith[P any, E Element, R Element](elt E, opts WalkOptions, fun func(P) ([]R, error)) (E, error) {
+	w := opts.walker()
+	elt, err := walkChildren(elt, fun, w)
+	w.done()
 	_, ok := isResult(err)
 	if !ok {
+		if ee, ok := err.(*elementError); ok {
+			return elt, locate(elt, ee)
+		}
 		return elt, err
 	} else {
 		return elt, nil
 	}
 }
 
+// An error returned by a filter function, with the location of the
+// element the function failed on. Filter returns errors of functions
+// as *FilterError.
+//
+// Example:
+//
+//	var ferr *pandoc.FilterError
+//	if errors.As(err, &ferr) {
+//	    log.Printf("failed at %s", ferr.Path)
+//	}
+type FilterError struct {
+	Path   ElementPath // Empty if the element is not in the filtered tree
+	Tags   []Tag       // Tags of the ancestors of the element and its own, if any
+	Header string      // Title of the nearest header preceding the element
+	Err    error
+}
+
+func (e *FilterError) Error() string {
+	var sb strings.Builder
+	for i, tag := range e.Tags {
+		if i > 0 {
+			sb.WriteString(" > ")
+		}
+		sb.WriteString(string(tag))
+	}
+	if e.Path != "" {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString("at " + string(e.Path))
+	}
+	if e.Header != "" {
+		sb.WriteString(" under " + strconv.Quote(e.Header))
+	}
+	if sb.Len() == 0 {
+		return e.Err.Error()
+	}
+	return sb.String() + ": " + e.Err.Error()
+}
+
+func (e *FilterError) Unwrap() error { return e.Err }
+
+// an error of a filter function and the element it failed on
+type elementError struct {
+	elt any
+	err error
+}
+
+func (e *elementError) Error() string { return e.err.Error() }
+
+// returns the filter error of ee located in root
+func locate[E Element](root E, ee *elementError) *FilterError {
+	fe := &FilterError{Err: ee.err}
+	list := reflect.ValueOf(ee.elt)
+	if list.Kind() != reflect.Slice {
+		list = reflect.Value{}
+	}
+	var (
+		header string
+		found  bool
+	)
+	QueryCursor(root, func(e Element, c *Cursor) {
+		if found {
+			return
+		}
+		f := c.top()
+		inList := list.IsValid() && f.list.IsValid() && f.list.Len() == list.Len() &&
+			f.list.Pointer() == list.Pointer() && f.list.Type() == list.Type()
+		if !inList && !sameElement(e, ee.elt) {
+			if h, ok := e.(*Header); ok {
+				header = h.Title()
+			}
+			return
+		}
+		found = true
+		fe.Path, fe.Header = f.path, header
+		frames := c.frames
+		if inList {
+			fe.Path = fe.Path[:strings.LastIndexByte(string(fe.Path), '/')]
+			frames = frames[:len(frames)-1]
+		}
+		for _, f := range frames {
+			if t, ok := f.elt.(Tagged); ok {
+				fe.Tags = append(fe.Tags, t.Tag())
+			}
+		}
+	})
+	if !found {
+		if t, ok := ee.elt.(Tagged); ok {
+			fe.Tags = []Tag{t.Tag()}
+		}
+	}
+	return fe
+}
+
+// reports whether a visited element is the element x
+func sameElement(e Element, x any) bool {
+	if v := reflect.ValueOf(e); v.Kind() == reflect.Pointer {
+		return any(e) == x
+	}
+	return false
+}
+
 // Takes a filter function and returns a transformer function that can be used
 // with element.Apply.
 //
@@ -164,6 +348,169 @@ func Transformer[E Element, P any, R Element](fun func(P) ([]R, error)) func(E)
 	}
 }
 
+// Returns elt with all the descendants of type T removed. T must be a
+// type of list items, such as *Note or Block: elements in single slots,
+// such as table heads or captions, can't be removed.
+//
+// Example:
+//
+//	doc, err = pandoc.RemoveAll[*pandoc.Note](doc)
+func RemoveAll[T Element, E Element](elt E) (E, error) {
+	return Filter(elt, func(T) ([]T, error) {
+		return nil, Delete
+	})
+}
+
+// A set of filter functions of different element types, applied in a
+// single traversal. Each element is passed to the first function of the
+// set accepting its type.
+//
+// Example:
+//
+//	var fs pandoc.FilterSet
+//	fs = pandoc.AddFilter(fs, func(s *pandoc.Str) ([]pandoc.Inline, error) { ... })
+//	fs = pandoc.AddFilter(fs, func(c *pandoc.CodeBlock) ([]pandoc.Block, error) { ... })
+//	doc, err = pandoc.Filter(doc, fs.Func())
+type FilterSet struct {
+	funs []func(Element) ([]Element, bool, error)
+}
+
+// Returns the set with filter function fun added.
+func AddFilter[P Element, R Element](fs FilterSet, fun func(P) ([]R, error)) FilterSet {
+	fs.funs = append(fs.funs[:len(fs.funs):len(fs.funs)], func(e Element) ([]Element, bool, error) {
+		p, ok := e.(P)
+		if !ok {
+			return nil, false, nil
+		}
+		r, err := call(fun, p)
+		if len(r) == 0 {
+			return nil, true, err
+		}
+		lst := make([]Element, len(r))
+		for i := range r {
+			lst[i] = r[i]
+		}
+		return lst, true, err
+	})
+	return fs
+}
+
+// Returns the filter function of the set for Filter or Transformer.
+func (fs FilterSet) Func() func(Element) ([]Element, error) {
+	return func(e Element) ([]Element, error) {
+		for _, fun := range fs.funs {
+			if r, ok, err := fun(e); ok {
+				return r, err
+			}
+		}
+		return nil, Continue
+	}
+}
+
+// Returns the filter function applying all functions of the set to each
+// element in order, each one to the result of the previous ones. It fuses
+// separate Filter passes of the functions into one traversal, which is
+// equivalent for the functions that don't depend on changes made by the
+// previous ones to the children of the element: children are visited
+// after all the functions are applied to the element.
+//
+// Example:
+//
+//	doc.Apply(pandoc.Transformer[*pandoc.Pandoc](fs.Fused()))
+func (fs FilterSet) Fused() func(Element) ([]Element, error) {
+	return func(e Element) ([]Element, error) {
+		var (
+			lst      []Element // replacements of e, if replaced
+			replaced bool
+			result   traversalResult
+		)
+		for _, fun := range fs.funs {
+			if !replaced {
+				r, ok, err := fun(e)
+				if !ok {
+					continue
+				}
+				rslt, ok := isResult(err)
+				if !ok {
+					return nil, err
+				}
+				if rslt.replace() {
+					lst, replaced = r, true
+				}
+				if result |= rslt & (skipChildren | haltTraversal); result.halt() {
+					break
+				}
+				continue
+			}
+			var (
+				next    []Element
+				changed bool
+			)
+			for i, e := range lst {
+				r, ok, err := fun(e)
+				if !ok {
+					if changed {
+						next = append(next, e)
+					}
+					continue
+				}
+				rslt, ok := isResult(err)
+				if !ok {
+					return nil, err
+				}
+				if rslt.replace() && !changed {
+					next = append(make([]Element, 0, len(lst)+len(r)), lst[:i]...)
+					changed = true
+				}
+				if !changed {
+				} else if rslt.replace() {
+					next = append(next, r...)
+				} else {
+					next = append(next, e)
+				}
+				if result |= rslt & (skipChildren | haltTraversal); result.halt() {
+					if changed {
+						next = append(next, lst[i+1:]...)
+					}
+					break
+				}
+			}
+			if changed {
+				lst = next
+			}
+			if result.halt() {
+				break
+			}
+		}
+		if replaced {
+			result |= replaceElement
+		}
+		return lst, result
+	}
+}
+
+// calls fun, dropping the elements it returns with Delete and attaching
+// p to errors
+func call[P any, R Element](fun func(P) ([]R, error), p P) ([]R, error) {
+	r, err := fun(p)
+	if err == Delete {
+		return nil, ReplaceSkip
+	} else if _, ok := isResult(err); ok {
+		return r, err
+	} else if _, ok := err.(*elementError); ok {
+		return r, err
+	}
+	return r, &elementError{elt: p, err: err}
+}
+
+// returns the error of a filter function
+func unwrap(err error) error {
+	if ee, ok := err.(*elementError); ok {
+		return ee.err
+	}
+	return err
+}
+
 func apply[E Element](elt E, transformer ...func(E) (E, error)) (E, error) {
 	var err error
 	for _, t := range transformer {
@@ -177,6 +524,7 @@ func apply[E Element](elt E, transformer ...func(E) (E, error)) (E, error) {
 type queryResult struct{}
 
 func (queryResult) element()              {}
+func (queryResult) Field(int) any         { return nil }
 func (queryResult) clone() Element        { return nil }
 func (queryResult) write(io.Writer) error { return nil }
 
@@ -186,7 +534,58 @@ func Query[P any, E Element](elt E, fun func(P)) {
 	walkChildren(elt, func(e P) ([]queryResult, error) {
 		fun(e)
 		return nil, nil
-	})
+	}, walker{})
+}
+
+// Works as Query, but walks the top-level blocks of the document in up
+// to workers goroutines, runtime.GOMAXPROCS(0) if workers is not
+// positive. Metadata is walked first in the calling goroutine.
+//
+// fun is called concurrently and must be safe for concurrent use. The
+// order of calls is only defined for elements of the same top-level
+// block.
+//
+// Example:
+//
+//	var words atomic.Int64
+//	pandoc.QueryParallel(doc, 0, func(*pandoc.Str) { words.Add(1) })
+func QueryParallel[P any](doc *Pandoc, workers int, fun func(P)) {
+	query := func(e P) ([]queryResult, error) {
+		fun(e)
+		return nil, nil
+	}
+	walkList(doc.Meta, query, walker{})
+	if p, ok := any(doc.Blocks).(P); ok {
+		fun(p)
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var (
+		wg   sync.WaitGroup
+		next atomic.Int64
+	)
+	for n := min(workers, len(doc.Blocks)); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < len(doc.Blocks); i = int(next.Add(1) - 1) {
+				blk := doc.Blocks[i]
+				if lb, ok := blk.(*LazyBlock); ok && lazyMatch[P](lb) {
+					decoded, err := lb.Decode()
+					if err != nil {
+						continue
+					}
+					blk = decoded
+				}
+				if p, ok := any(blk).(P); ok {
+					fun(p)
+				}
+				walkChildren(blk, query, walker{})
+			}
+		}()
+	}
+	wg.Wait()
 }
 
 // QueryE applies the specified function 'fun' to each child element of the provided
@@ -226,15 +625,113 @@ func Query[P any, E Element](elt E, fun func(P)) {
 func QueryE[P any, E Element](elt E, fun func(P) error) error {
 	_, err := walkChildren(elt, func(e P) ([]queryResult, error) {
 		return nil, fun(e)
-	})
+	}, walker{})
 	_, ok := isResult(err)
 	if !ok {
-		return err
+		return unwrap(err)
 	} else {
 		return nil
 	}
 }
 
+// Works as QueryE, traversing elt according to opts.
+func QueryWith[P any, E Element](elt E, opts WalkOptions, fun func(P) error) error {
+	w := opts.walker()
+	_, err := walkChildren(elt, func(e P) ([]queryResult, error) {
+		return nil, fun(e)
+	}, w)
+	w.done()
+	if _, ok := isResult(err); !ok {
+		return unwrap(err)
+	}
+	return nil
+}
+
+// Works as Filter, passing fun the state along with the element. The
+// state is usually a pointer, shared by the calls of a single traversal
+// only.
+//
+// Example:
+//
+//	type counter struct{ n int }
+//
+//	func number(c *counter, n *pandoc.Note) ([]pandoc.Inline, error) {
+//	    c.n++
+//	    ...
+//	}
+//
+//	doc, err = pandoc.FilterState(doc, &counter{}, number)
+func FilterState[S any, P any, E Element, R Element](elt E, state S, fun func(S, P) ([]R, error)) (E, error) {
+	return Filter(elt, func(p P) ([]R, error) {
+		return fun(state, p)
+	})
+}
+
+// Works as QueryE, passing fun the state along with the element.
+func QueryState[S any, P any, E Element](elt E, state S, fun func(S, P) error) error {
+	return QueryE(elt, func(p P) error {
+		return fun(state, p)
+	})
+}
+
+// Works as FilterState with the context as the state, stopping with the
+// error of the context once it is done.
+func FilterContext[P any, E Element, R Element](ctx context.Context, elt E, fun func(context.Context, P) ([]R, error)) (E, error) {
+	return Filter(elt, func(p P) ([]R, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return fun(ctx, p)
+	})
+}
+
+// Works as QueryState with the context as the state, stopping with the
+// error of the context once it is done.
+func QueryContext[P any, E Element](ctx context.Context, elt E, fun func(context.Context, P) error) error {
+	return QueryE(elt, func(p P) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fun(ctx, p)
+	})
+}
+
+// Returns all descendants of elt of type T, in the order of Query.
+//
+// Example:
+//
+//	links := pandoc.Collect[*pandoc.Link](doc)
+func Collect[T any, E Element](elt E) []T {
+	var lst []T
+	Query(elt, func(t T) {
+		lst = append(lst, t)
+	})
+	return lst
+}
+
+// Returns the first descendant of elt of type T, in the order of Query,
+// and true, or false if there is none.
+func First[T any, E Element](elt E) (T, bool) {
+	var (
+		first T
+		found bool
+	)
+	_ = QueryE(elt, func(t T) error {
+		first, found = t, true
+		return Halt
+	})
+	return first, found
+}
+
+// Returns the number of descendants of elt of type T.
+func Count[T any, E Element](elt E) int {
+	var n int
+	Query(elt, func(T) {
+		n++
+	})
+	return n
+}
+
 // Index returns index of the first element of type E in the list of elements
 // implementing interface L (either Block or Inline), and the element itself.
 // Returns -1, nil if []L does not contain any element of type E
@@ -307,6 +804,104 @@ func Index3[E1 Element, E2 Element, E3 Element, L Element](lst []L) (int, E1, E2
 	return -1, cero1, cero2, cero3
 }
 
+// IndexFunc returns index of the first element of type E satisfying pred
+// in the list of elements implementing interface L (either Block or
+// Inline), and the element itself. Returns -1, nil if there is none.
+//
+// Example:
+//
+//	if idx, str := IndexFunc(lst, func(s *Str) bool { return s.Text == "TODO" }); idx >= 0 {
+func IndexFunc[E Element, L Element](lst []L, pred func(E) bool) (int, E) {
+	for i := range lst {
+		if e, ok := any(lst[i]).(E); ok && pred(e) {
+			return i, e
+		}
+	}
+	var cero E
+	return -1, cero
+}
+
+// IndexSeq returns index of the first sequence of elements satisfying
+// predicates in order in the list of elements implementing interface L
+// (either Block or Inline). Returns -1 if there is none. See Where for
+// making predicates of element types.
+//
+// Example:
+//
+//	idx := IndexSeq(lst,
+//	    Where[*Str, Inline](func(s *Str) bool { return strings.HasPrefix(s.Text, "TODO") }),
+//	    Where[*Space, Inline](nil))
+func IndexSeq[L Element](lst []L, predicates ...func(L) bool) int {
+next:
+	for i := 0; i+len(predicates) <= len(lst); i++ {
+		for j, pred := range predicates {
+			if !pred(lst[i+j]) {
+				continue next
+			}
+		}
+		return i
+	}
+	return -1
+}
+
+// Where returns a predicate of element of interface L that holds for
+// elements of type E satisfying pred, or for all of them if pred is nil.
+func Where[E Element, L Element](pred func(E) bool) func(L) bool {
+	return func(l L) bool {
+		e, ok := any(l).(E)
+		return ok && (pred == nil || pred(e))
+	}
+}
+
+// SplitWhen splits the list at elements satisfying sep, removing them.
+// A list with n separators is split into n+1 lists, some of them
+// possibly empty. The lists don't share memory with lst.
+//
+// Example:
+//
+//	lines := SplitWhen(para.Inlines, Where[*LineBreak, Inline](nil))
+func SplitWhen[L Element](lst []L, sep func(L) bool) [][]L {
+	return split(lst, sep, false)
+}
+
+// SplitAfter splits the list after elements satisfying sep, which end
+// the lists but the last one. The lists don't share memory with lst.
+func SplitAfter[L Element](lst []L, sep func(L) bool) [][]L {
+	return split(lst, sep, true)
+}
+
+func split[L Element](lst []L, sep func(L) bool, keep bool) [][]L {
+	var (
+		parts [][]L
+		start int
+	)
+	for i, e := range lst {
+		if sep(e) {
+			end := i
+			if keep {
+				end++
+			}
+			parts = append(parts, append([]L{}, lst[start:end]...))
+			start = i + 1
+		}
+	}
+	return append(parts, append([]L{}, lst[start:]...))
+}
+
+// Partition returns the elements of the list satisfying pred and the
+// rest of them, keeping the order. The lists don't share memory with
+// lst.
+func Partition[L Element](lst []L, pred func(L) bool) (match, rest []L) {
+	for _, e := range lst {
+		if pred(e) {
+			match = append(match, e)
+		} else {
+			rest = append(rest, e)
+		}
+	}
+	return match, rest
+}
+
 // Converts string to identifier.
 func StringToIdent(s string) string {
 	var sb strings.Builder
@@ -354,86 +949,208 @@ func InlinesToIdent(inlines []Inline) string {
 			return nil, Skip
 		}
 		return nil, nil
-	})
+	}, walker{})
 	return sb.String()
 }
 
-func matchList[T Element](t []T, l []T) bool {
-	if len(t) != len(l) {
-		return false
+// Returns the plain text content of an element: text of strings, code
+// and math, with whitespace elements converted to spaces or newlines,
+// and blocks separated by newlines. Notes are omitted.
+func Stringify(e Element) string {
+	var sb strings.Builder
+	newline := func() {
+		if sb.Len() > 0 && sb.String()[sb.Len()-1] != '\n' {
+			sb.WriteByte('\n')
+		}
 	}
-	for i := range t {
-		if _, ok := Match(t[i], l[i]); !ok {
-			return false
+	visit := func(elt Element) error {
+		switch e := elt.(type) {
+		case *Str:
+			sb.WriteString(e.Text)
+		case *Code:
+			sb.WriteString(e.Text)
+		case *Math:
+			sb.WriteString(e.Text)
+		case *Space, *SoftBreak:
+			sb.WriteByte(' ')
+		case *LineBreak:
+			sb.WriteByte('\n')
+		case *CodeBlock:
+			newline()
+			sb.WriteString(e.Text)
+		case *Note:
+			return Skip
+		case Block:
+			newline()
 		}
+		return Continue
 	}
-	return true
+	if visit(e) == Continue {
+		_ = QueryE(e, visit)
+	}
+	return sb.String()
 }
 
-// Matches element E of type E agains template m of type T.
-// Returns T and true if e matches. Does not modify m.
-//
-// Example:
-//
-//	  var tmpl = &Para{[]Inline{&Code{}, &Link{}}}
-//	  Query(doc, func(e Block) {
-//		     if para, ok := Match(tmpl, e); ok {
-//		         ... // para is Para that consists of a single Code followed by Link
-func Match[T Element, E Element](m T, e E) (T, bool) {
-	var zero T
-	if r, ok := any(e).(T); !ok {
-		return zero, false
-	} else {
-		switch e := any(e).(type) {
-		case inlinesContainer:
-			if !matchList(any(m).(inlinesContainer).inlines(), e.inlines()) {
-				return zero, false
-			}
-		case blocksContainer:
-			if matchList(any(m).(blocksContainer).blocks(), e.blocks()) {
-				return zero, false
-			}
-		}
-		return r, true
-	}
+// state of a traversal
+type walker struct {
+	reverse bool
+	opts    *walkOpts // nil unless the descent is limited or hooked
 }
 
-// Walk support following filter input/output combinations (input columns, output rows):
-//
-//  |     R     | Inline | Block | []Inline | []Block | *E (E <: R) |
-//  |-----------|--------|-------|----------|---------|-------------|
-//  | []Inline  |   X    |       |    X     |         |      X      |
-//  | []Block   |        |   X   |          |    X    |      X      |
-//  | []*E      |        |       |          |         |      X      |
-//
-//
-// So from the walk's point of view, following functions are possible
-//
-//    func (elt Inline) ([]Inline, WalkResult)
-//    func (elt Block) ([]Block, WalkResult)
-//
-//    func (elt []Inline) ([]Inline, WalkResult)
-//    func (elt []Block) ([]Block, WalkResult)
-//
-//    func (elt *E) ([]R, WalkResult) // *E <: R, R \in {Inline, Block}
+// options of a traversal checked on every element walked, kept out of
+// walker so that traversals without them stay cheap, with the depth of
+// the element walked
+type walkOpts struct {
+	depth    int
+	maxDepth int
+	skip     []func(Element) bool
+	hooks    *walkHooks
+}
 
-func walkLists[P any, E1 Element, E2 Element, R Element](l1 []E1, l2 []E2, fun func(P) ([]R, error)) ([]E1, []E2, error) {
-	nl1, err := walkList(l1, fun)
-	rl1, ok := isResult(err)
-	if !ok {
-		return l1, l2, err
+func (o WalkOptions) walker() walker {
+	w := walker{reverse: o.Reverse}
+	if o.MaxDepth != 0 || len(o.skip) != 0 || o.Enter != nil || o.Exit != nil {
+		w.opts = &walkOpts{maxDepth: o.MaxDepth, skip: o.skip}
 	}
-	if rl1.halt() {
-		if rl1.replace() {
-			return nl1, l2, ReplaceHalt
-		} else {
-			return l1, l2, Halt
-		}
+	if o.Enter != nil || o.Exit != nil {
+		w.opts.hooks = &walkHooks{onEnter: o.Enter, onExit: o.Exit}
 	}
-	nl2, err := walkList(l2, fun)
-	rl2, ok := isResult(err)
-	if !ok {
-		return l1, l2, err
+	return w
+}
+
+// enter and exit hooks of a traversal, with the elements entered and
+// not exited yet. An element is exited when an element of the same or
+// lesser depth is entered, or the traversal is over.
+type walkHooks struct {
+	onEnter, onExit func(Element)
+	stack           []hookFrame
+}
+
+type hookFrame struct {
+	elt   Element
+	depth int
+}
+
+// returns the hooks of the traversal, nil if there are none; walks of
+// lists check it once, not for every item
+func (w walker) hooks() *walkHooks {
+	if w.opts == nil {
+		return nil
+	}
+	return w.opts.hooks
+}
+
+// calls the enter hook for e, the item of a list being walked at depth
+func (h *walkHooks) enter(e Element, depth int) {
+	h.exitTo(depth)
+	h.stack = append(h.stack, hookFrame{e, depth})
+	if h.onEnter != nil {
+		h.onEnter(e)
+	}
+}
+
+// calls the exit hook for the entered elements of the depth or deeper
+func (h *walkHooks) exitTo(depth int) {
+	for n := len(h.stack); n > 0 && h.stack[n-1].depth >= depth; n-- {
+		e := h.stack[n-1].elt
+		h.stack = h.stack[:n-1]
+		if h.onExit != nil {
+			h.onExit(e)
+		}
+	}
+}
+
+// calls the exit hook for all the entered elements
+func (w walker) done() {
+	if hooks := w.hooks(); hooks != nil {
+		hooks.exitTo(0)
+	}
+}
+
+// enters the children of e, reporting whether they should be walked;
+// only called if w.opts is set, and followed by ascend if entered
+func (w walker) descend(e Element) bool {
+	o := w.opts
+	if o.depth > 0 {
+		for _, skip := range o.skip {
+			if skip(e) {
+				return false
+			}
+		}
+	}
+	if o.maxDepth != 0 && o.depth >= o.maxDepth {
+		return false
+	}
+	o.depth++
+	return true
+}
+
+// leaves the children entered by descend
+func (w walker) ascend() {
+	w.opts.depth--
+}
+
+// returns the index of the first element of a list of n siblings
+func (w walker) first(n int) int {
+	if w.reverse {
+		return n - 1
+	}
+	return 0
+}
+
+// returns the index of the k-th element visited in a list of n siblings
+func (w walker) index(k, n int) int {
+	if w.reverse {
+		return n - 1 - k
+	}
+	return k
+}
+
+// returns the index of the sibling to visit after the element at i,
+// replaced with n elements
+func (w walker) next(i, n int) int {
+	if w.reverse {
+		return i - 1
+	}
+	return i + n
+}
+
+// Walk support following filter input/output combinations (input columns, output rows):
+//
+//  |     R     | Inline | Block | []Inline | []Block | *E (E <: R) |
+//  |-----------|--------|-------|----------|---------|-------------|
+//  | []Inline  |   X    |       |    X     |         |      X      |
+//  | []Block   |        |   X   |          |    X    |      X      |
+//  | []*E      |        |       |          |         |      X      |
+//
+//
+// So from the walk's point of view, following functions are possible
+//
+//    func (elt Inline) ([]Inline, WalkResult)
+//    func (elt Block) ([]Block, WalkResult)
+//
+//    func (elt []Inline) ([]Inline, WalkResult)
+//    func (elt []Block) ([]Block, WalkResult)
+//
+//    func (elt *E) ([]R, WalkResult) // *E <: R, R \in {Inline, Block}
+
+func walkLists[P any, E1 Element, E2 Element, R Element](l1 []E1, l2 []E2, fun func(P) ([]R, error), w walker) ([]E1, []E2, error) {
+	nl1, err := walkList(l1, fun, w)
+	rl1, ok := isResult(err)
+	if !ok {
+		return l1, l2, err
+	}
+	if rl1.halt() {
+		if rl1.replace() {
+			return nl1, l2, ReplaceHalt
+		} else {
+			return l1, l2, Halt
+		}
+	}
+	nl2, err := walkList(l2, fun, w)
+	rl2, ok := isResult(err)
+	if !ok {
+		return l1, l2, err
 	}
 	if rl2.halt() {
 		if rl1.replace() && !rl2.replace() {
@@ -465,455 +1182,186 @@ func walkLists[P any, E1 Element, E2 Element, R Element](l1 []E1, l2 []E2, fun f
 // - ReplaceAndStop
 // - StopTraversal
 // - TraverseChildren
-func walkChildren[P any, E Element, R Element](e E, fun func(P) ([]R, error)) (E, error) {
+func walkChildren[P any, E Element, R Element](e E, fun func(P) ([]R, error), w walker) (E, error) {
+	if w.opts != nil {
+		if !w.descend(Element(e)) {
+			return e, Continue
+		}
+		defer w.ascend()
+	}
 	switch e := any(e).(type) {
 	case *Pandoc:
-		meta, blocks, err := walkLists(e.Meta, e.Blocks, fun)
-		rslt, ok := isResult(err)
-		if !ok {
+		blocks, lazy, err := resolveLazy(e.Blocks, fun)
+		if err != nil {
 			return any(e).(E), err
 		}
-		if rslt.replace() {
-			e = &Pandoc{Meta: meta, Blocks: blocks}
-		}
-		return any(e).(E), err
-	// Inlines
-	case *Emph:
-		lst, err := walkList(e.Inlines, fun)
+		meta, blocks, err := walkLists(e.Meta, blocks, fun, w)
 		rslt, ok := isResult(err)
 		if !ok {
 			return any(e).(E), err
 		}
 		if rslt.replace() {
-			e = &Emph{Inlines: lst}
+			e = &Pandoc{Meta: meta, Blocks: restoreLazy(blocks, lazy), Version: e.Version}
 		}
 		return any(e).(E), err
-	case *Strong:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Strong{Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Strikeout:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Strikeout{Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Superscript:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Superscript{Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Subscript:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Subscript{Inlines: lst}
-		}
-		return any(e).(E), err
-	case *SmallCaps:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &SmallCaps{Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Quoted:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Quoted{QuoteType: e.QuoteType, Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Citation:
-		pref, suff, err := walkLists(e.Prefix, e.Suffix, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			newElt := *e
-			newElt.Prefix = pref
-			newElt.Suffix = suff
-			e = &newElt
-		}
-		return any(e).(E), err
-	case *Cite:
-		cts, lst, err := walkLists(e.Citations, e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Cite{Citations: cts, Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Link:
-		lst, err := walkList(e.Inlines, fun)
+	case MetaMapEntry:
+		val, err := walkChildren(e.Value, fun, w)
 		rslt, ok := isResult(err)
 		if !ok {
 			return any(e).(E), err
 		}
 		if rslt.replace() {
-			e = &Link{Attr: e.Attr, Target: e.Target, Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Image:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
+			return any(MetaMapEntry{Key: e.Key, Value: val}).(E), err
+		} else {
 			return any(e).(E), err
 		}
-		if rslt.replace() {
-			e = &Image{Attr: e.Attr, Target: e.Target, Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Note:
-		lst, err := walkList(e.Blocks, fun)
+	}
+	elt := Element(e)
+	u := update{src: elt}
+	var halted bool
+	for i := 0; ; i++ {
+		part := elt.Field(i)
+		if part == nil {
+			break
+		} else if leaf(part) {
+			continue
+		}
+		err := walkPart(part, &u, i, fun, w)
 		rslt, ok := isResult(err)
 		if !ok {
-			return any(e).(E), err
+			return e, err
 		}
-		if rslt.replace() {
-			e = &Note{Blocks: lst}
-		}
-		return any(e).(E), err
-	case *Span:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
+		if halted = rslt.halt(); halted {
+			break
 		}
-		if rslt.replace() {
-			e = &Span{Attr: e.Attr, Inlines: lst}
-		}
-		return any(e).(E), err
+	}
+	switch {
+	case u.elt != nil && halted:
+		return u.elt.(E), ReplaceHalt
+	case u.elt != nil:
+		return u.elt.(E), ReplaceContinue
+	case halted:
+		return e, Halt
+	default:
+		return e, Continue
+	}
+}
 
-	// following inlines have no children
-	//
-	// case *Str:
-	// case *Code:
-	// case *Space:
-	// case *SoftBreak:
-	// case *LineBreak:
-	// case *Math:
-	// case *RawInline:
-
-	// Blocks
-	case *Plain:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Plain{Inlines: lst}
-		}
-		return any(e).(E), err
-	case *Para:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Para{Inlines: lst}
-		}
-		return any(e).(E), err
-	case *LineBlock:
-		lst, err := walkListOfLists(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &LineBlock{Inlines: lst}
-		}
-		return any(e).(E), err
-	// case *CodeBlock: // no children
-	// case *RawBlock: // no children
-	case *BlockQuote:
-		lst, err := walkList(e.Blocks, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
+// a copy of an element made on the first change of its children
+type update struct {
+	src, elt Element
+}
+
+// returns a pointer to the i-th field of the copy holding children
+func (u *update) field(i int) any {
+	if u.elt == nil {
+		u.elt = u.src.clone()
+	}
+	return u.elt.Field(i)
+}
+
+// walks the i-th field of an element holding its children, setting the
+// new value of the field in u if replaced
+func walkPart[P any, R Element](part any, u *update, i int, fun func(P) ([]R, error), w walker) error {
+	if leaf(part) {
+		return Continue
+	}
+	switch p := part.(type) {
+	case *[]Inline:
+		lst, err := walkList(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]Inline) = lst
 		}
-		if rslt.replace() {
-			e = &BlockQuote{Blocks: lst}
+		return err
+	case *[]Block:
+		lst, err := walkList(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]Block) = lst
 		}
-		return any(e).(E), err
-	case *OrderedList:
-		lst, err := walkListOfLists(e.Items, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
+		return err
+	case *[][]Inline:
+		lst, err := walkListOfLists(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[][]Inline) = lst
 		}
-		if rslt.replace() {
-			e = &OrderedList{Attr: e.Attr, Items: lst}
+		return err
+	case *[][]Block:
+		lst, err := walkListOfLists(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[][]Block) = lst
 		}
-		return any(e).(E), err
-	case *BulletList:
-		lst, err := walkListOfLists(e.Items, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
+		return err
+	case *[]MetaValue:
+		lst, err := walkList(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]MetaValue) = lst
 		}
-		if rslt.replace() {
-			e = &BulletList{Items: lst}
+		return err
+	case *Meta:
+		lst, err := walkList(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*Meta) = lst
 		}
-		return any(e).(E), err
-	case *DefinitionList:
-		var (
-			updated bool
-			inlines []Inline
-			blocks  [][]Block
-			err     error
-			items   = e.Items
-			orig    = e
-		)
-		for i := range items {
-			inlines, err = walkList(items[i].Term, fun)
-			rslt, ok := isResult(err)
-			if !ok {
-				return any(orig).(E), err
-			}
-			if rslt.replace() {
-				if !updated {
-					updated = true
-					items = append([]Definition(nil), items...)
-				}
-				items[i].Term = inlines
-			}
-			if rslt.halt() {
-				if updated {
-					e = &DefinitionList{Items: items}
-					return any(e).(E), ReplaceHalt
-				} else {
-					return any(orig).(E), Halt
-				}
-			}
-			blocks, err = walkListOfLists(items[i].Definition, fun)
-			rslt, ok = isResult(err)
-			if !ok {
-				return any(orig).(E), err
-			}
-			if rslt.replace() {
-				if !updated {
-					updated = true
-					items = append([]Definition(nil), items...)
-				}
-				items[i].Definition = blocks
-			}
-			if rslt.halt() {
-				if updated {
-					e = &DefinitionList{Items: items}
-					return any(e).(E), ReplaceHalt
-				} else {
-					return any(orig).(E), Halt
-				}
-			}
+		return err
+	case *[]*Citation:
+		lst, err := walkList(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]*Citation) = lst
 		}
-		if updated {
-			e = &DefinitionList{Items: items}
-			return any(e).(E), ReplaceContinue
-		} else {
-			return any(orig).(E), Continue
+		return err
+	case *[]Definition:
+		lst, err := walkValues[P, Definition, *Definition](*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]Definition) = lst
 		}
-	case *Header:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
+		return err
+	case *Caption:
+		caption, err := walkCaption(p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*Caption) = *caption
 		}
-		if rslt.replace() {
-			e = &Header{
-				Level:   e.Level,
-				Attr:    e.Attr,
-				Inlines: lst,
-			}
+		return err
+	case *[]ColSpec:
+		lst, err := walkValues[P, ColSpec, *ColSpec](*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]ColSpec) = lst
 		}
-		return any(e).(E), err
-	// case *HorizontalRule: // no children
-	case *Table:
-		table, err := walkTable(e, fun)
-		return any(table).(E), err
+		return err
 	case *TableHeadFoot:
-		lst, err := walkList(e.Rows, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &TableHeadFoot{Attr: e.Attr, Rows: lst}
-		}
-		return any(e).(E), err
-	case *TableBody:
-		hdr, body, err := walkLists(e.Head, e.Body, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &TableBody{Attr: e.Attr, RowHeadColumns: e.RowHeadColumns, Head: hdr, Body: body}
-		}
-		return any(e).(E), err
-	case *TableRow:
-		lst, err := walkList(e.Cells, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &TableRow{Attr: e.Attr, Cells: lst}
-		}
-		return any(e).(E), err
-	case *TableCell:
-		lst, err := walkList(e.Blocks, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &TableCell{
-				Attr:    e.Attr,
-				Align:   e.Align,
-				RowSpan: e.RowSpan,
-				ColSpan: e.ColSpan,
-				Blocks:  lst,
-			}
+		hf, err := walkTableHeadFoot(p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*TableHeadFoot) = *hf
 		}
-		return any(e).(E), err
-	case *Figure:
-		caption, err := walkCaption(e.Caption, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		newF := e
-		if rslt.replace() {
-			newF = &Figure{
-				Attr:    e.Attr,
-				Caption: caption,
-				Blocks:  e.Blocks,
-			}
-		}
-		if rslt.halt() {
-			return any(newF).(E), err
-		}
-		lst, err := walkList(e.Blocks, fun)
-		rslt, ok = isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			newF = &Figure{
-				Attr:    e.Attr,
-				Caption: caption,
-				Blocks:  lst,
-			}
-		}
-		if newF != e {
-			if rslt.halt() {
-				return any(newF).(E), ReplaceHalt
-			} else {
-				return any(newF).(E), ReplaceContinue
-			}
-		}
-		return any(e).(E), err
-	case *Div:
-		lst, err := walkList(e.Blocks, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &Div{Attr: e.Attr, Blocks: lst}
-		}
-		return any(e).(E), err
-
-	// Meta
-	case *MetaMap:
-		lst, err := walkList(e.Entries, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &MetaMap{lst}
-		}
-		return any(e).(E), err
-	case MetaMapEntry:
-		val, err := walkChildren(e.Value, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			return any(MetaMapEntry{Key: e.Key, Value: val}).(E), err
-		} else {
-			return any(e).(E), err
-		}
-	case *MetaList:
-		lst, err := walkList(e.Entries, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &MetaList{lst}
-		}
-		return any(e).(E), err
-	case *MetaBlocks:
-		lst, err := walkList(e.Blocks, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
-		}
-		if rslt.replace() {
-			e = &MetaBlocks{lst}
+		return err
+	case *[]*TableBody:
+		lst, err := walkList(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]*TableBody) = lst
 		}
-		return any(e).(E), err
-	case *MetaInlines:
-		lst, err := walkList(e.Inlines, fun)
-		rslt, ok := isResult(err)
-		if !ok {
-			return any(e).(E), err
+		return err
+	case *[]*TableRow:
+		lst, err := walkList(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]*TableRow) = lst
 		}
-		if rslt.replace() {
-			e = &MetaInlines{lst}
+		return err
+	case *[]*TableCell:
+		lst, err := walkList(*p, fun, w)
+		if rslt, _ := isResult(err); rslt.replace() {
+			*u.field(i).(*[]*TableCell) = lst
 		}
-		return any(e).(E), err
+		return err
 	default:
-		return any(e).(E), nil
+		return fmt.Errorf("pandoc: can't walk children of type %T", part)
 	}
 }
 
-func walkTableHeadFoot[P any, R Element](hf *TableHeadFoot, fun func(P) ([]R, error)) (*TableHeadFoot, error) {
+func walkTableHeadFoot[P any, R Element](hf *TableHeadFoot, fun func(P) ([]R, error), w walker) (*TableHeadFoot, error) {
+	if hooks := w.hooks(); hooks != nil {
+		hooks.enter(hf, w.opts.depth)
+	}
 	if param, ok := any(hf).(P); ok {
-		replace, err := fun(param)
+		replace, err := call(fun, param)
 		rslt, ok := isResult(err)
 		if !ok {
 			return hf, err
@@ -933,7 +1381,7 @@ func walkTableHeadFoot[P any, R Element](hf *TableHeadFoot, fun func(P) ([]R, er
 		if rslt.skipChildren() {
 			return hf, err
 		}
-		hf, err := walkChildren(hf, fun)
+		hf, err := walkChildren(hf, fun, w)
 		rslt, ok = isResult(err)
 		if !ok {
 			return src, err
@@ -955,95 +1403,137 @@ func walkTableHeadFoot[P any, R Element](hf *TableHeadFoot, fun func(P) ([]R, er
 			return hf, err
 		}
 	} else {
-		return walkChildren(hf, fun)
+		return walkChildren(hf, fun, w)
 	}
 }
 
-func walkTable[P any, R Element](table *Table, fun func(P) ([]R, error)) (*Table, error) {
-	var (
-		updated bool
-		err     error
-		caption = table.Caption
-		head    = &table.Head
-		foot    = &table.Foot
-		bodies  = table.Bodies
-	)
-	caption, err = walkCaption(table.Caption, fun)
-	rslt, ok := isResult(err)
-	if !ok {
-		return table, err
-	}
-	updated = updated || rslt.replace()
-	if rslt.halt() {
-		goto fin
-	}
-	head, err = walkTableHeadFoot(&table.Head, fun)
-	rslt, ok = isResult(err)
-	if !ok {
-		return table, err
-	}
-	updated = updated || rslt.replace()
-	if rslt.halt() {
-		goto fin
-	}
-	bodies, err = walkList(table.Bodies, fun)
-	rslt, ok = isResult(err)
-	if !ok {
-		return table, err
-	}
-	updated = updated || rslt.replace()
-	if rslt.halt() {
-		goto fin
+func walkCaption[P any, R Element](caption *Caption, fun func(P) ([]R, error), w walker) (*Caption, error) {
+	if hooks := w.hooks(); hooks != nil {
+		hooks.enter(caption, w.opts.depth)
 	}
-	foot, err = walkTableHeadFoot(&table.Foot, fun)
-	rslt, ok = isResult(err)
-	if !ok {
-		return table, err
-	}
-	updated = updated || rslt.replace()
-fin:
-	if updated {
-		table = &Table{
-			Attr:    table.Attr,
-			Caption: caption,
-			Aligns:  table.Aligns,
-			Head:    *head,
-			Bodies:  bodies,
-			Foot:    *foot,
+	if param, ok := any(caption).(P); ok {
+		replace, err := call(fun, param)
+		rslt, ok := isResult(err)
+		if !ok {
+			return caption, err
 		}
-		if rslt.halt() {
-			return table, ReplaceHalt
+		src := caption
+		var updated bool
+		if rslt.replace() {
+			if len(replace) != 1 {
+				return caption, ErrUnexpectedType
+			} else if newc, ok := any(replace[0]).(*Caption); !ok {
+				return caption, ErrUnexpectedType
+			} else {
+				caption = newc
+				updated = true
+			}
+		}
+		if rslt.skipChildren() {
+			return caption, err
+		}
+		caption, err := walkChildren(caption, fun, w)
+		rslt, ok = isResult(err)
+		if !ok {
+			return src, err
+		}
+		if !rslt.replace() && updated {
+			if rslt.halt() {
+				return caption, ReplaceHalt
+			} else {
+				return caption, ReplaceContinue
+			}
 		} else {
-			return table, ReplaceContinue
+			return caption, err
 		}
 	} else {
-		return table, err
+		return walkChildren(caption, fun, w)
 	}
 }
 
-func walkCaption[P any, R Element](caption Caption, fun func(P) ([]R, error)) (Caption, error) {
-	var cap = caption
-	short, long, err := walkLists(caption.Short, caption.Long, fun)
-	rslt, ok := isResult(err)
-	if !ok {
-		return cap, err
+// walkValues walks a list of structs, such as definitions of a list,
+// passing fun pointers to the items
+func walkValues[P any, T any, PT interface {
+	*T
+	Element
+}, R Element](source []T, fun func(P) ([]R, error), w walker) ([]T, error) {
+	var (
+		updated bool
+		halted  bool
+		src     = source
+	)
+	hooks := w.hooks()
+	for i := w.first(len(source)); i >= 0 && i < len(source); {
+		if hooks != nil {
+			hooks.enter(PT(&source[i]), w.opts.depth)
+		}
+		var rslt traversalResult
+		n := 1
+		if param, ok := any(PT(&source[i])).(P); ok {
+			replace, err := call(fun, param)
+			if rslt, ok = isResult(err); !ok {
+				return src, err
+			}
+			if rslt.replace() {
+				items := make([]T, len(replace))
+				for j := range replace {
+					if item, ok := any(replace[j]).(PT); !ok {
+						return src, ErrUnexpectedType
+					} else {
+						items[j] = *item
+					}
+				}
+				source = append(append(append(make([]T, 0, len(source)-1+len(items)), source[:i]...), items...), source[i+1:]...)
+				updated = true
+				n = len(items)
+			}
+		}
+		halted = rslt.halt()
+		if !rslt.skipChildren() {
+			for j := i; j < i+n; j++ {
+				item, err := walkChildren(PT(&source[j]), fun, w)
+				rslt, ok := isResult(err)
+				if !ok {
+					return src, err
+				}
+				if rslt.replace() {
+					if !updated {
+						updated = true
+						source = append([]T(nil), source...)
+					}
+					source[j] = *item
+				}
+				if halted = rslt.halt(); halted {
+					break
+				}
+			}
+		}
+		if halted {
+			break
+		}
+		i = w.next(i, n)
 	}
-	if rslt.replace() {
-		cap.Short = short
-		cap.Long = long
+	switch {
+	case updated && halted:
+		return source, ReplaceHalt
+	case updated:
+		return source, ReplaceContinue
+	case halted:
+		return source, Halt
+	default:
+		return source, Continue
 	}
-	return cap, err
 }
 
-func walkListOfLists[P any, S Element, R Element](source [][]S, fun func(P) ([]R, error)) ([][]S, error) {
+func walkListOfLists[P any, S Element, R Element](source [][]S, fun func(P) ([]R, error), w walker) ([][]S, error) {
 	var (
 		newList []S
 		err     error
 		updated bool
 		src     = source
 	)
-	for i := 0; i < len(source); {
-		newList, err = walkList(source[i], fun)
+	for i := w.first(len(source)); i >= 0 && i < len(source); {
+		newList, err = walkList(source[i], fun, w)
 		rslt, ok := isResult(err)
 		if !ok {
 			return src, err
@@ -1055,12 +1545,13 @@ func walkListOfLists[P any, S Element, R Element](source [][]S, fun func(P) ([]R
 			}
 			if len(newList) == 0 {
 				source = append(source[:i], source[i+1:]...)
+				i = w.next(i, 0)
 			} else {
 				source[i] = newList
-				i++
+				i = w.next(i, 1)
 			}
 		} else {
-			i++
+			i = w.next(i, 1)
 		}
 		if rslt.halt() {
 			if updated {
@@ -1078,7 +1569,7 @@ func walkListOfLists[P any, S Element, R Element](source [][]S, fun func(P) ([]R
 }
 
 // walkList
-func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error)) ([]S, error) {
+func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error), w walker) ([]S, error) {
 	var (
 		replace   []R
 		err       error
@@ -1089,7 +1580,7 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 	var src = source
 	if _, ok := any(source).(P); ok {
 		list := any(source).(P)
-		replace, err = fun(list)
+		replace, err = call(fun, list)
 		rslt, ok := isResult(err)
 		if !ok {
 			return src, err
@@ -1111,9 +1602,14 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 		if rslt.skipChildren() {
 			return source, err
 		}
-		for i := range source {
+		hooks := w.hooks()
+		for k := range source {
+			i := w.index(k, len(source))
+			if hooks != nil {
+				hooks.enter(source[i], w.opts.depth)
+			}
 			var item S
-			item, err = walkChildren(source[i], fun)
+			item, err = walkChildren(source[i], fun, w)
 			rslt, ok := isResult(err)
 			if !ok {
 				return src, err
@@ -1139,9 +1635,13 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 			return source, Continue
 		}
 	}
-	for i := 0; i < len(source); {
+	hooks := w.hooks()
+	for i := w.first(len(source)); i >= 0 && i < len(source); {
+		if hooks != nil {
+			hooks.enter(source[i], w.opts.depth)
+		}
 		if val, ok := any(source[i]).(P); !ok {
-			item, err := walkChildren(source[i], fun)
+			item, err := walkChildren(source[i], fun, w)
 			rslt, ok := isResult(err)
 			if !ok {
 				return src, err
@@ -1160,16 +1660,16 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 					return source, Halt
 				}
 			}
-			i++
+			i = w.next(i, 1)
 		} else {
-			replace, err = fun(val)
+			replace, err = call(fun, val)
 			rslt, ok := isResult(err)
 			if !ok {
 				return src, err
 			}
 			if !rslt.replace() {
 				if !rslt.skipChildren() {
-					item, err := walkChildren(source[i], fun)
+					item, err := walkChildren(source[i], fun, w)
 					rslt, ok := isResult(err)
 					if !ok {
 						return src, err
@@ -1189,7 +1689,7 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 						}
 					}
 				}
-				i++
+				i = w.next(i, 1)
 			} else {
 				if !updated {
 					updated = true
@@ -1197,6 +1697,7 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 				}
 				if len(replace) == 0 {
 					source = append(source[:i], source[i+1:]...)
+					i = w.next(i, 0)
 				} else {
 					if len(replace) == 1 {
 						if s, ok := any(replace[0]).(S); !ok {
@@ -1205,7 +1706,7 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 							if rslt.skipChildren() {
 								source[i] = s
 							} else {
-								item, err := walkChildren(s, fun)
+								item, err := walkChildren(s, fun, w)
 								rslt, ok := isResult(err)
 								if !ok {
 									return src, err
@@ -1224,7 +1725,7 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 						source = append(source[:i], append(any(replace).([]S), source[i+1:]...)...)
 						if !rslt.skipChildren() {
 							for j := range replace {
-								item, err := walkChildren(source[i+j], fun)
+								item, err := walkChildren(source[i+j], fun, w)
 								rslt, ok := isResult(err)
 								if !ok {
 									return src, err
@@ -1243,7 +1744,7 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 							if s, ok := any(replace[j]).(S); !ok {
 								return src, ErrUnexpectedType
 							} else {
-								item, err := walkChildren(s, fun)
+								item, err := walkChildren(s, fun, w)
 								rslt, ok := isResult(err)
 								if !ok {
 									return src, err
@@ -1259,7 +1760,7 @@ func walkList[P any, S Element, R Element](source []S, fun func(P) ([]R, error))
 							}
 						}
 					}
-					i += len(replace)
+					i = w.next(i, len(replace))
 				}
 			}
 			if rslt.halt() {
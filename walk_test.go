@@ -1,8 +1,14 @@
 package pandoc
 
 import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"unicode"
 )
 
 func testTable() *Table {
@@ -48,3 +54,664 @@ func TestWalkTable(t *testing.T) {
 		t.Errorf("Expected %q, got %q", expected, result)
 	}
 }
+
+func TestCollect(t *testing.T) {
+	table := testTable()
+	var items []string
+	for _, s := range Collect[*Str](table) {
+		items = append(items, s.Text)
+	}
+	if result := strings.Join(items, ","); result != "TableHead,BodyHead,BodyBody,TableFoot" {
+		t.Errorf("collected %q", result)
+	}
+	if s, ok := First[*Str](table); !ok || s.Text != "TableHead" {
+		t.Errorf("first is %v, %v", s, ok)
+	}
+	if _, ok := First[*Link](table); ok {
+		t.Error("found a link")
+	}
+	if n := Count[*TableRow](table); n != 4 {
+		t.Errorf("counted %d rows, want 4", n)
+	}
+}
+
+func TestIndexFunc(t *testing.T) {
+	lst := words("a TODO: b TODO c")
+	if idx, s := IndexFunc(lst, func(s *Str) bool { return strings.HasPrefix(s.Text, "TODO") }); idx != 2 || s.Text != "TODO:" {
+		t.Errorf("found %d %v", idx, s)
+	}
+	if idx, _ := IndexFunc(lst, func(s *Str) bool { return s.Text == "x" }); idx != -1 {
+		t.Errorf("found %d", idx)
+	}
+	todo := Where[*Str, Inline](func(s *Str) bool { return s.Text == "TODO" })
+	if idx := IndexSeq(lst, todo, Where[*Space, Inline](nil)); idx != 6 {
+		t.Errorf("found sequence at %d, want 6", idx)
+	}
+	if idx := IndexSeq(lst, Where[*Space, Inline](nil), todo, Where[*Space, Inline](nil)); idx != 5 {
+		t.Errorf("found sequence at %d, want 5", idx)
+	}
+	if idx := IndexSeq(lst, todo, Where[*Space, Inline](nil), todo); idx != -1 {
+		t.Errorf("found sequence at %d", idx)
+	}
+	if idx := IndexSeq(lst); idx != 0 {
+		t.Errorf("found empty sequence at %d", idx)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	lst := []Inline{&Str{"a"}, LB, &Str{"b"}, SP, &Str{"c"}, LB}
+	str := func(parts [][]Inline) string {
+		var s []string
+		for _, p := range parts {
+			s = append(s, Stringify(&Para{p}))
+		}
+		return strings.Join(s, "|")
+	}
+	lines := SplitWhen(lst, Where[*LineBreak, Inline](nil))
+	if got := str(lines); got != "a|b c|" {
+		t.Errorf("split into %q", got)
+	}
+	lines[0] = append(lines[0], &Str{"x"})
+	if lst[1] != LB {
+		t.Error("the list is modified")
+	}
+	if got := SplitAfter(lst, Where[*LineBreak, Inline](nil)); len(got) != 3 || len(got[0]) != 2 || len(got[2]) != 0 {
+		t.Errorf("split after into %q", str(got))
+	}
+	if got := SplitWhen([]Inline{}, Where[*LineBreak, Inline](nil)); len(got) != 1 || len(got[0]) != 0 {
+		t.Errorf("split empty list into %d parts", len(got))
+	}
+	strs, rest := Partition(lst, Where[*Str, Inline](nil))
+	if len(strs) != 3 || len(rest) != 3 || rest[1] != SP {
+		t.Errorf("partitioned into %d and %d", len(strs), len(rest))
+	}
+}
+
+func TestFilterSet(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Para{Inlines: []Inline{&Str{"a"}, SP, &Link{Inlines: words("b c"), Target: Target{Url: "x.md"}}}},
+		&CodeBlock{Text: "code"},
+		testTable(),
+	}}
+	var (
+		fs     FilterSet
+		spaces int
+	)
+	fs = AddFilter(fs, func(s *Str) ([]Inline, error) {
+		return []Inline{&Str{strings.ToUpper(s.Text)}}, ReplaceContinue
+	})
+	fs = AddFilter(fs, func(l *Link) ([]Inline, error) {
+		c := Clone(l)
+		c.Target.Url = strings.TrimSuffix(l.Target.Url, ".md") + ".html"
+		return []Inline{c}, ReplaceContinue
+	})
+	fs = AddFilter(fs, func(*CodeBlock) ([]Block, error) {
+		return nil, ReplaceSkip
+	})
+	more := AddFilter(fs, func(Inline) ([]Inline, error) {
+		spaces++
+		return nil, Continue
+	})
+	if len(fs.funs) != 3 || len(more.funs) != 4 {
+		t.Error("the set is modified by AddFilter")
+	}
+	res, err := Filter(doc, more.Func())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Stringify(res); got != "A B C\nTABLEHEAD\nBODYHEAD\nBODYBODY\nTABLEFOOT" {
+		t.Errorf("got %q", got)
+	}
+	if len(res.Blocks) != 2 || res.Blocks[0].(*Para).Inlines[2].(*Link).Target.Url != "x.html" {
+		t.Errorf("unexpected result %s", Sprint(res))
+	}
+	if spaces != 2 {
+		t.Errorf("the last function is called %d times, want 2", spaces)
+	}
+}
+
+func fusionTest() FilterSet {
+	var fs FilterSet
+	fs = AddFilter(fs, func(s *Str) ([]Inline, error) {
+		return []Inline{&Str{strings.ToUpper(s.Text)}}, ReplaceContinue
+	})
+	fs = AddFilter(fs, func(s *Str) ([]Inline, error) {
+		if s.Text == "A" {
+			return []Inline{&Emph{[]Inline{s}}}, ReplaceSkip
+		}
+		return nil, Continue
+	})
+	fs = AddFilter(fs, func(e *Emph) ([]Inline, error) {
+		return []Inline{&Strong{e.Inlines}}, ReplaceContinue
+	})
+	fs = AddFilter(fs, func(*Space) ([]Inline, error) {
+		return nil, ReplaceContinue
+	})
+	return fs
+}
+
+func TestFused(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Para{Inlines: []Inline{&Str{"a"}, SP, &Emph{words("b c")}}},
+	}}
+	fs := fusionTest()
+	passes := doc
+	for _, fun := range fs.funs {
+		var err error
+		fs := FilterSet{funs: []func(Element) ([]Element, bool, error){fun}}
+		if passes, err = Filter(passes, fs.Func()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fused, err := Filter(doc, fs.Fused())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Sprint(fused) != Sprint(passes) {
+		t.Errorf("fused %s\nseparate %s", Sprint(fused), Sprint(passes))
+	}
+	if Stringify(doc) != "a b c" {
+		t.Error("the document is modified")
+	}
+}
+
+func BenchmarkFused(b *testing.B) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	doc, err := ReadBytes(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	fs := fusionTest()
+	b.Run("separate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			d := doc
+			for _, fun := range fs.funs {
+				fs := FilterSet{funs: []func(Element) ([]Element, bool, error){fun}}
+				if d, err = Filter(d, fs.Func()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("fused", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := Filter(doc, fs.Fused()); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestFilterReverse(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Inlines: words("a")},
+		&Header{Level: 2, Inlines: words("b")},
+		&Para{Inlines: []Inline{&Str{"a"}, &Emph{words("b c")}, &Str{"d"}}},
+		&Header{Level: 2, Inlines: words("c")},
+		&Header{Level: 1, Inlines: words("d")},
+		&Header{Level: 2, Inlines: words("e")},
+	}}
+	var texts []string
+	if err := QueryWith(doc, WalkOptions{Reverse: true}, func(s *Str) error {
+		texts = append(texts, s.Text)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(texts, ""); got != "edcdcbaba" {
+		t.Errorf("reverse query order: %s", got)
+	}
+
+	level := 1
+	res, err := FilterWith(doc, WalkOptions{Reverse: true}, func(b Block) ([]Block, error) {
+		if h, ok := b.(*Header); !ok {
+			level = 0
+		} else if level > 0 && level <= h.Level {
+			return nil, ReplaceSkip
+		} else {
+			level = h.Level
+		}
+		return nil, Skip
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, b := range res.Blocks {
+		if h, ok := b.(*Header); ok {
+			titles = append(titles, h.Title())
+		}
+	}
+	if got := strings.Join(titles, ","); got != "a,b" || len(res.Blocks) != 3 {
+		t.Errorf("remaining headers: %s", got)
+	}
+
+	res, err = FilterWith(doc, WalkOptions{Reverse: true}, func(s *Str) ([]Inline, error) {
+		switch s.Text {
+		case "b":
+			return nil, ReplaceContinue
+		case "c":
+			return []Inline{&Str{"c1"}, &Str{"c2"}}, ReplaceContinue
+		}
+		return nil, Continue
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Stringify(res.Blocks[2]); got != "a c1c2d" {
+		t.Errorf("reverse replacement: %s", got)
+	}
+}
+
+func TestWalkOptions(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Para{Inlines: []Inline{&Str{"a"}, &Note{[]Block{&Para{words("b")}}}}},
+		&Div{Blocks: []Block{&Para{Inlines: []Inline{&Emph{words("c")}}}}},
+	}}
+	texts := func(opts WalkOptions) string {
+		var sb strings.Builder
+		if err := QueryWith(doc, opts, func(e Element) error {
+			switch e := e.(type) {
+			case *Str:
+				sb.WriteString(e.Text)
+			case Tagged:
+				sb.WriteString("<" + string(e.Tag()) + ">")
+			}
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return sb.String()
+	}
+	for _, test := range []struct {
+		opts WalkOptions
+		want string
+	}{
+		{WalkOptions{}, "<Para>a<Note><Para>b<Div><Para><Emph>c"},
+		{WalkOptions{MaxDepth: 1}, "<Para><Div>"},
+		{WalkOptions{MaxDepth: 2}, "<Para>a<Note><Div><Para>"},
+		{SkipInside[*Note](WalkOptions{}), "<Para>a<Note><Div><Para><Emph>c"},
+		{SkipInside[*Emph](SkipInside[*Note](WalkOptions{MaxDepth: 3})), "<Para>a<Note><Div><Para><Emph>"},
+	} {
+		if got := texts(test.opts); got != test.want {
+			t.Errorf("%+v: got %s, want %s", test.opts, got, test.want)
+		}
+	}
+	// the element being walked is not pruned
+	var n int
+	QueryWith(doc.Blocks[0].(*Para).Inlines[1].(*Note), SkipInside[*Note](WalkOptions{}), func(*Str) error { n++; return nil })
+	if n != 1 {
+		t.Errorf("root pruned: %d", n)
+	}
+}
+
+func TestWalkHooks(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Inlines: []Inline{&Str{"A"}}},
+		&Para{Inlines: []Inline{&Str{"a"}, &Emph{[]Inline{&Str{"b"}}}}},
+		&Div{Blocks: []Block{&Para{Inlines: []Inline{&Str{"c"}}}}},
+	}}
+	tag := func(e Element) string {
+		if s, ok := e.(*Str); ok {
+			return s.Text
+		}
+		return string(e.(Tagged).Tag())
+	}
+	var sb strings.Builder
+	opts := WalkOptions{
+		Enter: func(e Element) { sb.WriteString("<" + tag(e) + ">") },
+		Exit:  func(e Element) { sb.WriteString("</" + tag(e) + ">") },
+	}
+	// the function replaces the emphasis and skips the div
+	res, err := FilterWith(doc, opts, func(b Element) ([]Element, error) {
+		switch b := b.(type) {
+		case *Emph:
+			return []Element{&Strong{b.Inlines}}, ReplaceContinue
+		case *Div:
+			return nil, Skip
+		}
+		return nil, Continue
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<Header><A></A></Header><Para><a></a><Emph><b></b></Emph></Para><Div></Div>"
+	if got := sb.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, ok := res.Blocks[1].(*Para).Inlines[1].(*Strong); !ok {
+		t.Errorf("not replaced: %#v", res.Blocks[1])
+	}
+
+	// tracking the current section
+	var (
+		section string
+		strs    []string
+	)
+	QueryWith(doc, WalkOptions{Enter: func(e Element) {
+		if h, ok := e.(*Header); ok {
+			section = Stringify(h)
+		}
+	}}, func(s *Str) error {
+		strs = append(strs, section+":"+s.Text)
+		return nil
+	})
+	if got := strings.Join(strs, " "); got != "A:A A:a A:b A:c" {
+		t.Errorf("sections: %s", got)
+	}
+}
+
+func TestWalkAuxiliary(t *testing.T) {
+	table := testTable()
+	table.Caption = Caption{Long: []Block{&Plain{words("table")}}}
+	table.Aligns = []ColSpec{{Align: AlignLeft, Width: DefaultColWidth()}}
+	doc := &Pandoc{Blocks: []Block{
+		table,
+		&Figure{Caption: Caption{Short: words("figure")}},
+		&DefinitionList{Items: []Definition{{Term: words("term"), Definition: [][]Block{{&Para{words("def")}}}}}},
+		&Para{Inlines: []Inline{
+			&Cite{Citations: []*Citation{{Id: "doe", Prefix: words("see")}}, Inlines: words("[@doe]")},
+			&Underline{words("underlined")},
+		}},
+	}}
+	for _, test := range []struct {
+		name  string
+		count int
+		want  int
+	}{
+		{"Citation", Count[*Citation](doc), 1},
+		{"Caption", Count[*Caption](doc), 2},
+		{"ColSpec", Count[*ColSpec](doc), 1},
+		{"Definition", Count[*Definition](doc), 1},
+		{"TableHeadFoot", Count[*TableHeadFoot](doc), 2},
+		{"TableBody", Count[*TableBody](doc), 1},
+		{"TableRow", Count[*TableRow](doc), 4},
+		{"TableCell", Count[*TableCell](doc), 4},
+		{"Underline", Count[*Underline](doc), 1},
+		{"Str", Count[*Str](doc), 11},
+	} {
+		if test.count != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, test.count, test.want)
+		}
+	}
+
+	res, err := Filter(doc, func(c *Caption) ([]*Caption, error) {
+		return []*Caption{{Short: words("new")}}, ReplaceSkip
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Stringify(&res.Blocks[0].(*Table).Caption); got != "new" {
+		t.Errorf("table caption: %q", got)
+	}
+	if got := Stringify(&res.Blocks[1].(*Figure).Caption); got != "new" {
+		t.Errorf("figure caption: %q", got)
+	}
+	if Stringify(&table.Caption) != "table" {
+		t.Errorf("source modified")
+	}
+
+	res, err = Filter(doc, func(c *ColSpec) ([]*ColSpec, error) {
+		return []*ColSpec{{Align: AlignRight, Width: c.Width}}, ReplaceContinue
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Blocks[0].(*Table).Aligns[0].Align; got != AlignRight || table.Aligns[0].Align != AlignLeft {
+		t.Errorf("colspec: %s", got)
+	}
+
+	res, err = Filter(doc, func(d *Definition) ([]*Definition, error) {
+		return []*Definition{d, {Term: words("more")}}, ReplaceContinue
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if items := res.Blocks[2].(*DefinitionList).Items; len(items) != 2 || Stringify(&items[1]) != "more" {
+		t.Errorf("definitions: %v", items)
+	}
+
+	res, err = Filter(doc, func(c *Citation) ([]*Citation, error) {
+		return []*Citation{{Id: "roe"}}, ReplaceContinue
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cite := res.Blocks[3].(*Para).Inlines[0].(*Cite); cite.Citations[0].Id != "roe" {
+		t.Errorf("citation: %s", cite.Citations[0].Id)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{
+		&Para{Inlines: []Inline{&Str{"a"}, &Note{[]Block{&Para{words("b")}}}, &Str{"c"}}},
+		&Para{Inlines: words("d")},
+	}}
+	res, err := Filter(doc, func(s *Str) ([]Inline, error) {
+		if s.Text == "c" {
+			// returned elements are ignored
+			return []Inline{s}, Delete
+		}
+		return nil, Keep
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Stringify(res); got != "a\nd" {
+		t.Errorf("delete: %q", got)
+	}
+
+	if res, err = RemoveAll[*Note](doc); err != nil {
+		t.Fatal(err)
+	} else if got := Stringify(res); got != "ac\nd" || Count[*Note](doc) != 1 {
+		t.Errorf("remove all: %q", got)
+	}
+	if _, err = RemoveAll[*TableHeadFoot](&Pandoc{Blocks: []Block{testTable()}}); err != ErrUnexpectedType {
+		t.Errorf("table head removed: %v", err)
+	}
+
+	var fs FilterSet
+	fs = AddFilter(fs, func(p *Para) ([]Block, error) {
+		if Stringify(p) == "d" {
+			return nil, Delete
+		}
+		return nil, Keep
+	})
+	if res, err = Filter(doc, fs.Fused()); err != nil {
+		t.Fatal(err)
+	} else if len(res.Blocks) != 1 {
+		t.Errorf("filter set: %d blocks", len(res.Blocks))
+	}
+}
+
+func TestFilterError(t *testing.T) {
+	errBad := errors.New("bad")
+	doc := &Pandoc{Blocks: []Block{
+		&Header{Level: 1, Inlines: words("Intro")},
+		&Div{Blocks: []Block{&Para{Inlines: []Inline{&Str{"a"}, &Str{"bad"}}}}},
+	}}
+	_, err := Filter(doc, func(s *Str) ([]Inline, error) {
+		if s.Text == "bad" {
+			return nil, errBad
+		}
+		return nil, Continue
+	})
+	var ferr *FilterError
+	if !errors.As(err, &ferr) || !errors.Is(err, errBad) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if ferr.Path != "/blocks/1/c/1/0/c/1" || ferr.Header != "Intro" || len(ferr.Tags) != 3 || ferr.Tags[2] != StrTag {
+		t.Errorf("unexpected location %#v", ferr)
+	}
+	if want := `Div > Para > Str at /blocks/1/c/1/0/c/1 under "Intro": bad`; err.Error() != want {
+		t.Errorf("got %q, want %q", err, want)
+	}
+
+	var fs FilterSet
+	fs = AddFilter(fs, func(p *Para) ([]Block, error) { return nil, errBad })
+	if _, err = Filter(doc, fs.Fused()); !errors.As(err, &ferr) || ferr.Path != "/blocks/1/c/1/0" {
+		t.Errorf("filter set: %v", err)
+	}
+
+	_, err = Filter(doc, func(l []Inline) ([]Inline, error) {
+		if len(l) == 2 {
+			return nil, errBad
+		}
+		return nil, Continue
+	})
+	if !errors.As(err, &ferr) || ferr.Path != "/blocks/1/c/1/0/c" || len(ferr.Tags) != 2 {
+		t.Errorf("list: %#v", ferr)
+	}
+
+	if err := QueryE(doc, func(*Str) error { return errBad }); err != errBad {
+		t.Errorf("query: %v", err)
+	}
+}
+
+func TestChildren(t *testing.T) {
+	kinds := map[reflect.Type]bool{}
+	for _, v := range []any{
+		[]Inline{}, []Block{}, [][]Inline{}, [][]Block{}, []MetaValue{}, Meta{}, []*Citation{},
+		[]Definition{}, Caption{}, []ColSpec{}, TableHeadFoot{}, []*TableBody{}, []*TableRow{}, []*TableCell{},
+	} {
+		kinds[reflect.TypeOf(v)] = true
+	}
+	for _, e := range []Element{
+		&Pandoc{}, &MetaMap{}, &MetaList{}, &MetaInlines{}, &MetaBlocks{},
+		&Str{}, &Emph{}, &Underline{}, &Strong{}, &Strikeout{}, &Superscript{}, &Subscript{}, &SmallCaps{},
+		&Quoted{}, &Citation{}, &Cite{}, &Code{}, &Space{}, &SoftBreak{}, &LineBreak{}, &Math{}, &RawInline{},
+		&Link{}, &Image{}, &Note{}, &Span{},
+		&Plain{}, &Para{}, &LineBlock{}, &CodeBlock{}, &RawBlock{}, &BlockQuote{}, &OrderedList{}, &BulletList{},
+		&Definition{}, &DefinitionList{}, &HorizontalRule{}, &Header{}, &Caption{}, &ColSpec{},
+		&TableHeadFoot{}, &TableRow{}, &TableCell{}, &TableBody{}, &Table{}, &Figure{}, &Div{}, &UnknownElement{},
+	} {
+		var fields []any
+		v := reflect.ValueOf(e).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if kinds[v.Field(i).Type()] {
+				fields = append(fields, v.Field(i).Addr().Interface())
+			}
+		}
+		var parts []any
+		for i := 0; ; i++ {
+			part := e.Field(i)
+			if part == nil {
+				break
+			} else if !leaf(part) {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) != len(fields) {
+			t.Errorf("%T: %d fields with children, got %d", e, len(fields), len(parts))
+			continue
+		}
+		for i := range parts {
+			if parts[i] != fields[i] {
+				t.Errorf("%T: unexpected field %d", e, i)
+			}
+		}
+	}
+	var unknown float64
+	if err := walkPart(&unknown, &update{}, 0, func(Element) ([]Element, error) { return nil, Continue }, walker{}); err == nil {
+		t.Error("no error walking unknown field")
+	}
+}
+
+func TestQueryParallel(t *testing.T) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []ReadOptions{{}, {Lazy: true}} {
+		doc, err := opts.ReadBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var strs, elts, lists atomic.Int64
+		QueryParallel(doc, 4, func(*Str) { strs.Add(1) })
+		QueryParallel(doc, 0, func(Element) { elts.Add(1) })
+		QueryParallel(doc, 4, func([]Block) { lists.Add(1) })
+		var want, wantLists int
+		Query(doc, func(Element) { want++ })
+		Query(doc, func([]Block) { wantLists++ })
+		if got := strs.Load(); got != int64(Count[*Str](doc)) || got == 0 {
+			t.Errorf("lazy %v: %d strs", opts.Lazy, got)
+		}
+		if got := elts.Load(); got != int64(want) {
+			t.Errorf("lazy %v: %d elements, want %d", opts.Lazy, got, want)
+		}
+		if got := lists.Load(); got != int64(wantLists) {
+			t.Errorf("lazy %v: %d lists, want %d", opts.Lazy, got, wantLists)
+		}
+	}
+}
+
+func BenchmarkQueryParallel(b *testing.B) {
+	data, err := os.ReadFile("testdata/test.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	doc, err := ReadBytes(data)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		doc.Blocks = append(doc.Blocks, doc.Blocks...)
+	}
+	words := func(s *Str) int { return len(strings.FieldsFunc(s.Text, unicode.IsPunct)) }
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var n int
+			Query(doc, func(s *Str) { n += words(s) })
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var n atomic.Int64
+			QueryParallel(doc, 0, func(s *Str) { n.Add(int64(words(s))) })
+		}
+	})
+}
+
+func TestFilterState(t *testing.T) {
+	doc := &Pandoc{Blocks: []Block{&Para{Inlines: []Inline{&Str{"a"}, &Space{}, &Str{"b"}}}}}
+	type counter struct{ n int }
+	res, err := FilterState(doc, &counter{}, func(c *counter, s *Str) ([]Inline, error) {
+		c.n++
+		return []Inline{&Str{strings.Repeat(s.Text, c.n)}}, ReplaceSkip
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if got := Stringify(res); got != "a bb" {
+		t.Errorf("got %q", got)
+	}
+	var c counter
+	QueryState(doc, &c, func(c *counter, _ *Str) error {
+		c.n++
+		return nil
+	})
+	if c.n != 2 {
+		t.Errorf("query state: %d", c.n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var n int
+	err = QueryContext(ctx, doc, func(_ context.Context, _ *Str) error {
+		n++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || n != 1 {
+		t.Errorf("query context: %v, %d", err, n)
+	}
+	if _, err := FilterContext(ctx, doc, func(context.Context, *Str) ([]Inline, error) {
+		return nil, Continue
+	}); !errors.Is(err, context.Canceled) {
+		t.Errorf("filter context: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+// A fake pandoc for tests, built for wasip1. It writes its input, the
+// files given or the standard input, to the output, the -o file or the
+// standard output. It fails if the input has FAIL, sleeps if SLEEP and
+// prints the environment variable of the name following ENV.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+func main() {
+	var files []string
+	out := os.Stdout
+	for i := 1; i < len(os.Args); i++ {
+		switch a := os.Args[i]; {
+		case a == "-o" && i+1 < len(os.Args):
+			i++
+			f, err := os.Create(os.Args[i])
+			if err != nil {
+				fail(err)
+			}
+			defer f.Close()
+			out = f
+		case len(a) > 0 && a[0] == '-':
+		default:
+			files = append(files, a)
+		}
+	}
+	var input []byte
+	if len(files) == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fail(err)
+		}
+		input = data
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			fail(err)
+		}
+		input = append(input, data...)
+	}
+	switch {
+	case bytes.Contains(input, []byte("FAIL")):
+		fail(fmt.Errorf("failed"))
+	case bytes.Contains(input, []byte("SLEEP")):
+		time.Sleep(10 * time.Second)
+	case bytes.HasPrefix(input, []byte("ENV ")):
+		input = []byte(os.Getenv(string(bytes.TrimSpace(input[4:]))))
+	}
+	out.Write(input)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "fake pandoc", err)
+	os.Exit(1)
+}
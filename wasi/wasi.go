@@ -0,0 +1,183 @@
+// Package wasi runs a WebAssembly build of pandoc in process with the
+// wazero runtime, for hosts without pandoc, such as fully static
+// programs. It is a module of its own, so that only programs running
+// pandoc.wasm depend on wazero.
+//
+// Example:
+//
+//	r := &wasi.Runner{Module: "pandoc.wasm"}
+//	defer r.Close(ctx)
+//	conf := pandoc.Format("docx").WithRunner(r)
+package wasi
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/growler/go-pandoc"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Runner is a pandoc.Runner running a WebAssembly build of pandoc with
+// the WASI preview 1 interface. The module is compiled once, on the
+// first run. The working directory of the command is mounted as the
+// root of the module file system, so files given to LoadFiles or
+// StoreFile must be relative to it. The module sees only the environment
+// variables of the command, not the ones of the process. Command.Pandoc,
+// if set, replaces Module. Runners must not be copied after the first
+// use, and are safe for concurrent use.
+type Runner struct {
+	Module string               // Path to pandoc.wasm
+	Config wazero.RuntimeConfig // Runtime configuration, wazero.NewRuntimeConfig() if nil
+
+	mu       sync.Mutex
+	runtime  wazero.Runtime
+	compiled map[string]wazero.CompiledModule
+}
+
+func (r *Runner) Start(ctx context.Context, c *pandoc.Command) (pandoc.Process, error) {
+	if c.Limits != (pandoc.Limits{}) {
+		return nil, errors.New("pandoc resource limits are not supported by wasi.Runner")
+	}
+	module := r.Module
+	if c.Pandoc != "" {
+		module = c.Pandoc
+	}
+	rt, compiled, err := r.compile(ctx, module)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	stdout, w := io.Pipe()
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append([]string{"pandoc"}, c.Args...)...).
+		WithFSConfig(wazero.NewFSConfig().WithDirMount(dir, "/")).
+		WithStdout(w).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep().
+		WithRandSource(rand.Reader)
+	if c.Stderr != nil {
+		cfg = cfg.WithStderr(c.Stderr)
+	}
+	for _, env := range c.Env {
+		k, v, _ := strings.Cut(env, "=")
+		cfg = cfg.WithEnv(k, v)
+	}
+	p := &process{stdout: stdout, done: make(chan struct{})}
+	var in *io.PipeReader
+	if c.Input {
+		in, p.stdin = io.Pipe()
+		cfg = cfg.WithStdin(in)
+	}
+	go func() {
+		defer close(p.done)
+		mod, err := rt.InstantiateModule(ctx, compiled, cfg)
+		if mod != nil {
+			mod.Close(context.Background())
+		}
+		p.err = exitError(ctx, err)
+		w.Close()
+		if in != nil {
+			in.Close()
+		}
+	}()
+	return p, nil
+}
+
+// Closes the runtime of the runner, stopping the modules running.
+func (r *Runner) Close(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.runtime == nil {
+		return nil
+	}
+	err := r.runtime.Close(ctx)
+	r.runtime, r.compiled = nil, nil
+	return err
+}
+
+// returns the runtime and the module compiled in it, compiling it on
+// the first use
+func (r *Runner) compile(ctx context.Context, module string) (wazero.Runtime, wazero.CompiledModule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.runtime == nil {
+		cfg := r.Config
+		if cfg == nil {
+			cfg = wazero.NewRuntimeConfig()
+		}
+		rt := wazero.NewRuntimeWithConfig(ctx, cfg.WithCloseOnContextDone(true))
+		if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
+			rt.Close(ctx)
+			return nil, nil, err
+		}
+		r.runtime, r.compiled = rt, make(map[string]wazero.CompiledModule)
+	}
+	if compiled, ok := r.compiled[module]; ok {
+		return r.runtime, compiled, nil
+	}
+	code, err := os.ReadFile(module)
+	if err != nil {
+		return nil, nil, err
+	}
+	compiled, err := r.runtime.CompileModule(ctx, code)
+	if err != nil {
+		return nil, nil, fmt.Errorf("compiling %s: %w", module, err)
+	}
+	r.compiled[module] = compiled
+	return r.runtime, compiled, nil
+}
+
+type process struct {
+	stdin  io.WriteCloser
+	stdout io.Reader
+	done   chan struct{}
+	err    error
+}
+
+func (p *process) Stdin() io.WriteCloser { return p.stdin }
+func (p *process) Stdout() io.Reader     { return p.stdout }
+
+func (p *process) Wait() error {
+	<-p.done
+	return p.err
+}
+
+// ExitError is returned by Process.Wait if pandoc exits with a non-zero
+// code, see pandoc.PandocError.ExitCode.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
+func (e *ExitError) ExitCode() int { return e.Code }
+
+// returns the error of the module exit
+func exitError(ctx context.Context, err error) error {
+	var exit *sys.ExitError
+	switch {
+	case !errors.As(err, &exit):
+		return err
+	case exit.ExitCode() == sys.ExitCodeContextCanceled || exit.ExitCode() == sys.ExitCodeDeadlineExceeded:
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	default:
+		return &ExitError{Code: int(exit.ExitCode())}
+	}
+}
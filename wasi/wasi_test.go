@@ -0,0 +1,101 @@
+package wasi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/growler/go-pandoc"
+)
+
+// builds the fake pandoc of testdata for wasip1
+func fakePandoc(t *testing.T) string {
+	t.Helper()
+	goExe, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("building the fake pandoc requires the go command")
+	}
+	module := filepath.Join(t.TempDir(), "pandoc.wasm")
+	cmd := exec.Command(goExe, "build", "-o", module, "./testdata/fakepandoc")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("building fake pandoc: %v\n%s", err, out)
+	}
+	return module
+}
+
+func TestRunner(t *testing.T) {
+	var (
+		ctx = context.Background()
+		dir = t.TempDir()
+		r   = &Runner{Module: fakePandoc(t)}
+	)
+	defer r.Close(ctx)
+	conf := pandoc.Format("json").WithDir(dir).WithRunner(r)
+	doc := &pandoc.Pandoc{Blocks: []pandoc.Block{&pandoc.Para{Inlines: []pandoc.Inline{&pandoc.Str{Text: "hello"}}}}}
+	var out bytes.Buffer
+	if err := doc.StoreTo(&out, conf); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := pandoc.LoadFrom(&out, conf); err != nil {
+		t.Fatal(err)
+	} else if s := pandoc.Stringify(res); s != "hello" {
+		t.Errorf("unexpected document %q", s)
+	}
+
+	var perr *pandoc.PandocError
+	failing := &pandoc.Pandoc{Blocks: []pandoc.Block{&pandoc.Para{Inlines: []pandoc.Inline{&pandoc.Str{Text: "FAIL"}}}}}
+	if err := failing.StoreTo(&out, conf); !errors.As(err, &perr) || perr.ExitCode() != 1 {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// files are relative to the directory mounted
+	if err := doc.StoreFile("out.json", conf); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := pandoc.LoadFiles([]string{"out.json"}, conf); err != nil {
+		t.Fatal(err)
+	} else if s := pandoc.Stringify(res); s != "hello" {
+		t.Errorf("unexpected document of file %q", s)
+	}
+
+	run := func(ctx context.Context, input string, env ...string) (string, error) {
+		var stderr bytes.Buffer
+		p, err := r.Start(ctx, &pandoc.Command{Dir: dir, Env: env, Input: true, Stderr: &stderr})
+		if err != nil {
+			return "", err
+		}
+		go func() {
+			p.Stdin().Write([]byte(input))
+			p.Stdin().Close()
+		}()
+		var out bytes.Buffer
+		if _, err := out.ReadFrom(p.Stdout()); err != nil {
+			return "", err
+		}
+		if err := p.Wait(); err != nil {
+			return stderr.String(), err
+		}
+		return out.String(), nil
+	}
+	if out, err := run(ctx, "ENV GREETING", "GREETING=hi"); err != nil || out != "hi" {
+		t.Errorf("environment: %q, %v", out, err)
+	}
+	var exit *ExitError
+	if out, err := run(ctx, "FAIL"); !errors.As(err, &exit) || exit.ExitCode() != 1 || out != "fake pandoc failed\n" {
+		t.Errorf("failure: %q, %v", out, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	if _, err := run(ctx, "SLEEP"); err != context.DeadlineExceeded {
+		t.Errorf("timeout: %v", err)
+	}
+	if _, err := r.Start(ctx, &pandoc.Command{Limits: pandoc.Limits{Files: 10}}); err == nil {
+		t.Error("no error with resource limits")
+	}
+}
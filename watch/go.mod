@@ -0,0 +1,12 @@
+module github.com/growler/go-pandoc/watch
+
+go 1.23
+
+require (
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/growler/go-pandoc v0.0.0
+)
+
+require golang.org/x/sys v0.13.0 // indirect
+
+replace github.com/growler/go-pandoc => ../
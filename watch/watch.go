@@ -0,0 +1,95 @@
+// Package watch rebuilds documents when their source files change, the
+// core loop of a documentation preview server. It is a module of its own,
+// so that only programs watching files depend on fsnotify.
+//
+// Example:
+//
+//	err := watch.Watch(ctx, []string{"index.md"}, pandoc.Format("markdown"), pipeline,
+//		func(doc *pandoc.Pandoc, err error) {
+//			if err == nil {
+//				err = doc.StoreFile("index.html", pandoc.Format("html"))
+//			}
+//			if err != nil {
+//				log.Print(err)
+//			}
+//		})
+package watch
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/growler/go-pandoc"
+)
+
+// A Watcher loads files and transforms the document each time the files
+// change.
+type Watcher struct {
+	Conf     pandoc.Conf     // Loads the files
+	Pipeline pandoc.Pipeline // Transforms the document
+	Debounce time.Duration   // Time to wait for more changes, defaults to 100ms
+}
+
+// Works as Watcher.Run.
+func Watch(ctx context.Context, paths []string, conf pandoc.Conf, pipeline pandoc.Pipeline, onResult func(*pandoc.Pandoc, error)) error {
+	return Watcher{Conf: conf, Pipeline: pipeline}.Run(ctx, paths, onResult)
+}
+
+// Builds the document concatenated from files in paths, then rebuilds it
+// each time the files change, until ctx is done. Each result is passed
+// to onResult, which usually stores the document. Changes made during a
+// build start another one once it finishes. Returns ctx.Err() or an
+// error of watching the files.
+//
+// Directories of the files are watched rather than files themselves, so
+// files replaced by editors on save are followed.
+func (w Watcher) Run(ctx context.Context, paths []string, onResult func(*pandoc.Pandoc, error)) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+	watched := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		p, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		if dir := filepath.Dir(p); !watched[dir] {
+			if err := fsw.Add(dir); err != nil {
+				return err
+			}
+			watched[dir] = true
+		}
+		watched[p] = true
+	}
+	debounce := w.Debounce
+	if debounce <= 0 {
+		debounce = 100 * time.Millisecond
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-fsw.Errors:
+			return err
+		case ev := <-fsw.Events:
+			if ev.Op != fsnotify.Chmod && watched[filepath.Clean(ev.Name)] {
+				timer.Reset(debounce)
+			}
+		case <-timer.C:
+			doc, err := pandoc.LoadFilesContext(ctx, paths, w.Conf)
+			if err == nil {
+				doc, err = w.Pipeline.Run(doc)
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			onResult(doc, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/growler/go-pandoc"
+)
+
+func TestWatch(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake pandoc requires a POSIX shell")
+	}
+	dir := t.TempDir()
+	// a fake pandoc concatenating JSON input files
+	exe := filepath.Join(dir, "pandoc")
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\nshift 2\ncat \"$@\"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(dir, "doc.json")
+	write := func(s string) {
+		t.Helper()
+		doc := &pandoc.Pandoc{Blocks: []pandoc.Block{&pandoc.Para{Inlines: []pandoc.Inline{&pandoc.Str{Text: s}}}}}
+		// replaced as editors do
+		if err := os.WriteFile(src+".tmp", []byte(pandoc.Sprint(doc)), 0o644); err != nil {
+			t.Fatal(err)
+		} else if err := os.Rename(src+".tmp", src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("first")
+
+	var (
+		results     = make(chan string, 10)
+		ctx, cancel = context.WithCancel(context.Background())
+		done        = make(chan error)
+	)
+	defer cancel()
+	pipeline := pandoc.Pipeline{}.Then("upper", func(doc *pandoc.Pandoc) (*pandoc.Pandoc, error) {
+		if s := pandoc.Stringify(doc); s == "fail" {
+			return nil, errors.New("failed")
+		} else {
+			return &pandoc.Pandoc{Blocks: []pandoc.Block{&pandoc.Plain{Inlines: []pandoc.Inline{&pandoc.Str{Text: strings.ToUpper(s)}}}}}, nil
+		}
+	})
+	go func() {
+		done <- Watcher{Conf: pandoc.Format("json").WithPandoc(exe), Pipeline: pipeline, Debounce: 10 * time.Millisecond}.
+			Run(ctx, []string{src}, func(doc *pandoc.Pandoc, err error) {
+				if err != nil {
+					results <- err.Error()
+				} else {
+					results <- pandoc.Stringify(doc)
+				}
+			})
+	}()
+	next := func(want string) {
+		t.Helper()
+		select {
+		case got := <-results:
+			if got != want {
+				t.Fatalf("got %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no result %q", want)
+		}
+	}
+	next("FIRST")
+	// changes of other files are ignored
+	if err := os.WriteFile(filepath.Join(dir, "other"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	write("fail")
+	next("upper: failed")
+	write("second")
+	next("SECOND")
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("unexpected error %v", err)
+	}
+	select {
+	case got := <-results:
+		t.Errorf("unexpected result %q", got)
+	default:
+	}
+}
@@ -1,11 +1,16 @@
 package pandoc
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
 	"io"
 	"math"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type writable interface {
@@ -15,6 +20,8 @@ type writable interface {
 // interface check
 
 var _ []writable = []writable{
+	&UnknownElement{},
+	&LazyBlock{},
 	&MetaMap{},
 	&MetaList{},
 
@@ -228,6 +235,26 @@ func (p *DefinitionList) write(w io.Writer) error {
 	return withTag(p, list(p.Items)).write(w)
 }
 
+func (u *UnknownElement) write(w io.Writer) error {
+	if u.Content == nil {
+		return taggedStr(u.Type).write(w)
+	}
+	return withTag(u, rawJSON(u.Content)).write(w)
+}
+
+type writerFunc func(io.Writer) error
+
+func (f writerFunc) write(w io.Writer) error {
+	return f(w)
+}
+
+type rawJSON []byte
+
+func (r rawJSON) write(w io.Writer) error {
+	_, err := w.Write(r)
+	return err
+}
+
 func (l *HorizontalRule) write(w io.Writer) error {
 	return taggedStr(l.Tag()).write(w)
 }
@@ -381,6 +408,9 @@ type metaValue struct {
 }
 
 func (m metaValue) write(w io.Writer) error {
+	if u, ok := m.v.(*UnknownElement); ok {
+		return u.write(w)
+	}
 	if _, err := w.Write([]byte("{\"t\":\"")); err != nil {
 		return err
 	}
@@ -767,14 +797,17 @@ func appendQuote(b []byte, s string) []byte {
 	return b
 }
 
-func writeVersion(w io.Writer) error {
+func writeVersion(w io.Writer, version []int) error {
 	if err := writeKey(w, "pandoc-api-version"); err != nil {
 		return err
 	}
 	if err := writeDelim(w, '['); err != nil {
 		return err
 	}
-	for i, n := range _Version {
+	if version == nil {
+		version = _Version
+	}
+	for i, n := range version {
 		if i > 0 {
 			if _, err := w.Write([]byte{','}); err != nil {
 				return err
@@ -790,11 +823,12 @@ func writeVersion(w io.Writer) error {
 	return nil
 }
 
-func writeMany(w io.Writer, meta Meta, p ...*Pandoc) error {
+// writes the document start up to the blocks list opening bracket
+func writeHead(w io.Writer, meta Meta, version []int) error {
 	if err := writeDelim(w, '{'); err != nil {
 		return err
 	}
-	if err := writeVersion(w); err != nil {
+	if err := writeVersion(w, version); err != nil {
 		return err
 	}
 	if err := writeDelim(w, ','); err != nil {
@@ -812,35 +846,150 @@ func writeMany(w io.Writer, meta Meta, p ...*Pandoc) error {
 	if err := writeKey(w, "blocks"); err != nil {
 		return err
 	}
-	if err := writeDelim(w, '['); err != nil {
+	return writeDelim(w, '[')
+}
+
+// Writes the JSON encoding of one document combined from documents p:
+// its blocks are the blocks of p in order, with nothing between them,
+// and its metadata is meta alone, the metadata of p is not merged, so
+// pass e.g. p[0].Meta to keep the metadata of the first document. The
+// document has the current API version. Nil documents are skipped.
+//
+// Example:
+//
+//	// a book of chapters, with the title of the book
+//	meta := pandoc.Meta{{Key: "title", Value: pandoc.MetaString(title)}}
+//	err := pandoc.WriteMany(w, meta, chapters...)
+func WriteMany(w io.Writer, meta Meta, p ...*Pandoc) error {
+	bw := NewBlockWriter(w, meta)
+	for _, doc := range p {
+		if doc == nil {
+			continue
+		}
+		if err := bw.WriteBlocks(doc.Blocks...); err != nil {
+			return err
+		}
+	}
+	return bw.Close()
+}
+
+// BlockWriter writes the JSON encoding of a document incrementally, one
+// top-level block at a time, so the document does not have to be held
+// in memory entirely.
+//
+// Example:
+//
+//	bw := pandoc.NewBlockWriter(os.Stdout, meta)
+//	for rows.Next() {
+//	    if err := bw.WriteBlocks(row(rows)); err != nil {
+//	        return err
+//	    }
+//	}
+//	return bw.Close()
+type BlockWriter struct {
+	w       io.Writer
+	buf     *bufio.Writer // buffer of w, if not buffered already
+	meta    Meta
+	version []int
+	n       int
+	err     error
+}
+
+// Returns a BlockWriter writing a document with metadata meta to w.
+func NewBlockWriter(w io.Writer, meta Meta) *BlockWriter {
+	b := &BlockWriter{w: w, meta: meta, n: -1}
+	if !buffered(w) {
+		b.buf = bufio.NewWriterSize(w, writeBufferSize)
+		b.w = b.buf
+	}
+	return b
+}
+
+func (b *BlockWriter) flush() error {
+	if b.buf != nil {
+		b.err = b.buf.Flush()
+	}
+	return b.err
+}
+
+func (b *BlockWriter) head() error {
+	if b.err == nil && b.n < 0 {
+		b.err = writeHead(b.w, b.meta, b.version)
+		b.n = 0
+	}
+	return b.err
+}
+
+// Writes top-level blocks.
+func (b *BlockWriter) WriteBlocks(blocks ...Block) error {
+	if err := b.head(); err != nil {
 		return err
 	}
-	for i := range p {
-		for j := range p[i].Blocks {
-			if i > 0 || j > 0 {
-				if err := writeDelim(w, ','); err != nil {
-					return err
-				}
-			}
-			if err := p[i].Blocks[j].write(w); err != nil {
-				return err
+	if b.version != nil && cmpSemver(b.version, _Version[:2]) < 0 {
+		doc, err := downgrade(&Pandoc{Blocks: blocks}, b.version)
+		if err != nil {
+			return err
+		}
+		blocks = doc.Blocks
+	}
+	for _, blk := range blocks {
+		if b.n > 0 {
+			if b.err = writeDelim(b.w, ','); b.err != nil {
+				return b.err
 			}
 		}
+		if b.err = blk.write(b.w); b.err != nil {
+			return b.err
+		}
+		b.n++
 	}
-	if err := writeDelim(w, ']'); err != nil {
+	return b.flush()
+}
+
+// Returns the number of blocks written.
+func (b *BlockWriter) Count() int {
+	if b.n < 0 {
+		return 0
+	}
+	return b.n
+}
+
+var errBlockWriterClosed = errors.New("block writer is closed")
+
+// Close finishes the document. It does not close the underlying writer.
+func (b *BlockWriter) Close() error {
+	if err := b.head(); err != nil {
 		return err
 	}
-	if err := writeDelim(w, '}'); err != nil {
+	if b.err = writeDelim(b.w, ']'); b.err != nil {
+		return b.err
+	}
+	if b.err = writeDelim(b.w, '}'); b.err != nil {
+		return b.err
+	}
+	if err := b.flush(); err != nil {
 		return err
 	}
+	b.err = errBlockWriterClosed
 	return nil
 }
 
 func (p *Pandoc) write(w io.Writer) error {
+	if p.Version != nil && cmpSemver(p.Version, _Version[:2]) < 0 {
+		doc, err := downgrade(p, p.Version)
+		if err != nil {
+			return err
+		}
+		return doc.writeDoc(w)
+	}
+	return p.writeDoc(w)
+}
+
+func (p *Pandoc) writeDoc(w io.Writer) error {
 	if err := writeDelim(w, '{'); err != nil {
 		return err
 	}
-	if err := writeVersion(w); err != nil {
+	if err := writeVersion(w, p.Version); err != nil {
 		return err
 	}
 	if err := writeDelim(w, ','); err != nil {
@@ -867,6 +1016,18 @@ func (p *Pandoc) write(w io.Writer) error {
 	return nil
 }
 
+// counts bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
 // Write writes the JSON encoding of pandoc AST to w.
 //
 // Example:
@@ -877,19 +1038,56 @@ func (p *Pandoc) write(w io.Writer) error {
 //		log.Fatal(err)
 //	}
 func (p *Pandoc) WriteTo(w io.Writer) error {
-	return p.write(w)
+	if buffered(w) {
+		return p.write(w)
+	}
+	return writeBuffered(w, p)
 }
 
 // Prints the JSON encoding of element e to w.
 // Usefull for debugging.
 func Fprint(w io.Writer, e Element) error {
-	return e.write(w)
+	return writeBuffered(w, e)
 }
 
 // Prints the JSON encoding of element e to stdout.
 // Usefull for debugging.
 func Print(e Element) error {
-	return e.write(os.Stdout)
+	return writeBuffered(os.Stdout, e)
+}
+
+const writeBufferSize = 32 << 10
+
+var writeBuffers = sync.Pool{
+	New: func() any { return bufio.NewWriterSize(nil, writeBufferSize) },
+}
+
+// reports whether w is an in-memory or buffered writer, that does not
+// need buffering of the many small writes of an element
+func buffered(w io.Writer) bool {
+	switch w.(type) {
+	case *bufio.Writer, *bytes.Buffer, *strings.Builder, *appendWriter:
+		return true
+	default:
+		return false
+	}
+}
+
+// writes e to w through a pooled buffer unless w is buffered
+func writeBuffered(w io.Writer, e writable) error {
+	if buffered(w) {
+		return e.write(w)
+	}
+	bw := writeBuffers.Get().(*bufio.Writer)
+	bw.Reset(w)
+	defer func() {
+		bw.Reset(nil)
+		writeBuffers.Put(bw)
+	}()
+	if err := e.write(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
 }
 
 // Returns the JSON encoding of element e as a string.
@@ -899,3 +1097,58 @@ func Sprint(e Element) string {
 	_ = e.write(&b)
 	return b.String()
 }
+
+type appendWriter struct {
+	b []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+// buffers of Marshal larger than this are not reused
+const maxPooledBuffer = 1 << 20
+
+var marshalBuffers = sync.Pool{
+	New: func() any { return &appendWriter{b: make([]byte, 0, 4096)} },
+}
+
+// Returns the JSON encoding of element e.
+func Marshal(e Element) ([]byte, error) {
+	w := marshalBuffers.Get().(*appendWriter)
+	defer func() {
+		if cap(w.b) <= maxPooledBuffer {
+			w.b = w.b[:0]
+			marshalBuffers.Put(w)
+		}
+	}()
+	if err := e.write(w); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), w.b...), nil
+}
+
+// Appends the JSON encoding of element e to dst and returns the extended
+// buffer.
+func AppendJSON(dst []byte, e Element) ([]byte, error) {
+	w := appendWriter{b: dst}
+	err := e.write(&w)
+	return w.b, err
+}
+
+// Writes indented JSON encoding of the document to w, for reading by
+// humans. Each line starts with prefix followed by copies of indent
+// for the nesting level, as with json.Indent.
+func WriteIndent(w io.Writer, doc *Pandoc, prefix, indent string) error {
+	compact, err := AppendJSON(nil, doc)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := json.Indent(&b, compact, prefix, indent); err != nil {
+		return err
+	}
+	_, err = b.WriteTo(w)
+	return err
+}